@@ -20,13 +20,21 @@ import (
 )
 
 type Cloud struct {
-	Region  *string
-	Profile *string
+	Region *string
+	// Profile is the named profile of the shared config and credentials files, AWS_PROFILE or default profile is used if it's empty
+	Profile      *string
+	AccessKey    *string
+	SecretKey    *string
+	SessionToken *string
+	AssumeRole   *AssumeRole
+	WebIdentity  *WebIdentity
 
 	Meta cloud.Metadata
 
 	client  *ec2.EC2
 	session *session.Session
+	// endpoint overrides the endpoints of all AWS services
+	endpoint *string
 
 	configs   map[string]*resourceConfig
 	configsMu sync.Mutex
@@ -162,19 +170,33 @@ func (c *Cloud) CreateInstances(ctx context.Context, resourceID string, size int
 				instanceIds, err)
 		}
 	}
-	instances, err := c.ListInstances(ctx, resourceID, labels)
+	ec2Instances, err := c.describeInstances(ctx, &ec2.DescribeInstancesInput{
+		InstanceIds: instanceIds,
+	})
 	if err != nil {
 		return nil, errors.Wrap(err, "list instances")
 	}
-	return instances, nil
+	return toCloudInstances(ec2Instances), nil
 }
 
 func (c *Cloud) ListInstances(ctx context.Context, resourceID string, labels map[string]string) ([]cloud.Instance, error) {
+	ec2Instances, err := c.listInstances(ctx, resourceID, labels)
+	if err != nil {
+		return nil, err
+	}
+	return toCloudInstances(ec2Instances), nil
+}
+
+func (c *Cloud) listInstances(ctx context.Context, resourceID string, labels map[string]string) ([]*ec2.Instance, error) {
 	labels = utils.MapMerge(labels, map[string]string{
 		resource.LabelKeyResourceID: resourceID,
 	})
 
-	filters := make([]*ec2.Filter, 0, len(labels))
+	filters := make([]*ec2.Filter, 0, len(labels)+1)
+	filters = append(filters, &ec2.Filter{
+		Name:   aws.String("instance-state-name"),
+		Values: aws.StringSlice(activeInstanceStates),
+	})
 	for k, v := range labels {
 		filters = append(filters, &ec2.Filter{
 			Name:   aws.String(fmt.Sprintf("tag:%s", k)),
@@ -182,10 +204,13 @@ func (c *Cloud) ListInstances(ctx context.Context, resourceID string, labels map
 		})
 	}
 
-	var instances []cloud.Instance
-	in := &ec2.DescribeInstancesInput{
+	return c.describeInstances(ctx, &ec2.DescribeInstancesInput{
 		Filters: filters,
-	}
+	})
+}
+
+func (c *Cloud) describeInstances(ctx context.Context, in *ec2.DescribeInstancesInput) ([]*ec2.Instance, error) {
+	var instances []*ec2.Instance
 	for i := 0; in.NextToken != nil || i == 0; i++ {
 		describeInstances, err := c.client.DescribeInstancesWithContext(ctx, in)
 		if err != nil {
@@ -195,18 +220,58 @@ func (c *Cloud) ListInstances(ctx context.Context, resourceID string, labels map
 			return nil, errors.Wrap(err, "describe instances failed")
 		}
 		for _, reservation := range describeInstances.Reservations {
-			for _, instance := range reservation.Instances {
-				instances = append(instances, cloud.Instance{
-					PublicIpAddress:  aws.StringValue(instance.PublicIpAddress),
-					PrivateIpAddress: aws.StringValue(instance.PrivateIpAddress),
-				})
-			}
+			instances = append(instances, reservation.Instances...)
 		}
 		in.NextToken = describeInstances.NextToken
 	}
 	return instances, nil
 }
 
+func toCloudInstances(ec2Instances []*ec2.Instance) []cloud.Instance {
+	instances := make([]cloud.Instance, 0, len(ec2Instances))
+	for _, instance := range ec2Instances {
+		instances = append(instances, cloud.Instance{
+			PublicIpAddress:  aws.StringValue(instance.PublicIpAddress),
+			PrivateIpAddress: aws.StringValue(instance.PrivateIpAddress),
+		})
+	}
+	return instances
+}
+
+func (c *Cloud) DeleteInstances(ctx context.Context, resourceID string, instances []cloud.Instance) error {
+	if len(instances) == 0 {
+		return nil
+	}
+	ec2Instances, err := c.listInstances(ctx, resourceID, nil)
+	if err != nil {
+		return errors.Wrap(err, "list instances")
+	}
+	privateIPs := make(map[string]struct{}, len(instances))
+	for _, instance := range instances {
+		privateIPs[instance.PrivateIpAddress] = struct{}{}
+	}
+	var instanceIDs []*string
+	for _, instance := range ec2Instances {
+		if _, ok := privateIPs[aws.StringValue(instance.PrivateIpAddress)]; ok {
+			instanceIDs = append(instanceIDs, instance.InstanceId)
+		}
+	}
+	if len(instanceIDs) != len(instances) {
+		return errors.Errorf("found %d of %d instances to delete", len(instanceIDs), len(instances))
+	}
+	if _, err = c.client.TerminateInstancesWithContext(ctx, &ec2.TerminateInstancesInput{
+		InstanceIds: instanceIDs,
+	}); err != nil {
+		return errors.Wrap(err, "failed to terminate instances")
+	}
+	if err = c.client.WaitUntilInstanceTerminatedWithContext(ctx, &ec2.DescribeInstancesInput{
+		InstanceIds: instanceIDs,
+	}); err != nil {
+		return errors.Wrap(err, "failed to wait for instance termination")
+	}
+	return nil
+}
+
 func (c *Cloud) keyPairPath(resourceID string) (string, error) {
 	cfg := c.config(resourceID)
 	filePath, err := filepath.Abs(path.Join(aws.StringValue(cfg.pathToKeyPair), aws.StringValue(cfg.keyPair)+".pem"))
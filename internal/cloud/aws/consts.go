@@ -1,6 +1,7 @@
 package aws
 
 import (
+	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 )
 
@@ -10,6 +11,14 @@ const (
 	defaultSecurityGroupDescription = "Percona Terraform plugin security group"
 )
 
+// Terminated instances are still returned by DescribeInstances for some time after termination.
+var activeInstanceStates = []string{
+	ec2.InstanceStateNamePending,
+	ec2.InstanceStateNameRunning,
+	ec2.InstanceStateNameStopping,
+	ec2.InstanceStateNameStopped,
+}
+
 const (
 	volumeThroughput = "volume_throughput"
 	vpcID            = "vpc_id"
@@ -7,7 +7,6 @@ import (
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/hashicorp/terraform-plugin-log/tflog"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
@@ -75,11 +74,9 @@ func (c *Cloud) Configure(ctx context.Context, resourceID string, data *schema.R
 		cfg.vpcId = aws.String(data.Get(vpcID).(string))
 	}
 	var err error
-	c.session, err = session.NewSession(&aws.Config{
-		Region: c.Region,
-	})
+	c.session, err = c.newSession()
 	if err != nil {
-		return errors.Wrap(err, "failed create aws session")
+		return err
 	}
 	c.client = ec2.New(c.session)
 	cfg.ami, err = c.sourceImage(ctx)
@@ -95,13 +92,16 @@ func (c *Cloud) Credentials() (cloud.Credentials, error) {
 		return cloud.Credentials{}, errors.Wrap(err, "failed to get credentials")
 	}
 	return cloud.Credentials{
-		AccessKey: creds.AccessKeyID,
-		SecretKey: creds.SecretAccessKey,
+		AccessKey:    creds.AccessKeyID,
+		SecretKey:    creds.SecretAccessKey,
+		SessionToken: creds.SessionToken,
+		Region:       aws.StringValue(c.Region),
 	}, nil
 }
 
 func (c *Cloud) CreateInfrastructure(ctx context.Context, resourceID string) error {
 	c.infraMu.Lock()
+	defer c.infraMu.Unlock()
 
 	if err := c.createKeyPair(ctx, resourceID); err != nil {
 		return err
@@ -134,8 +134,6 @@ func (c *Cloud) CreateInfrastructure(ctx context.Context, resourceID string) err
 
 	c.config(resourceID).securityGroupID = securityGroupId
 	c.config(resourceID).subnetID = subnet.SubnetId
-
-	c.infraMu.Unlock()
 	return nil
 }
 
@@ -241,6 +239,19 @@ func (c *Cloud) createOrGetVPC(ctx context.Context, resourceID string) (*ec2.Vpc
 			return out.Vpcs[0], nil
 		}
 	}
+	// VPC created by the previous CreateInfrastructure call for the same resource
+	out, err := c.client.DescribeVpcsWithContext(ctx, &ec2.DescribeVpcsInput{
+		Filters: []*ec2.Filter{{
+			Name:   aws.String("tag:" + resource.LabelKeyResourceID),
+			Values: []*string{aws.String(resourceID)},
+		}},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "describe vpc")
+	}
+	if len(out.Vpcs) > 0 {
+		return out.Vpcs[0], nil
+	}
 	in := &ec2.CreateVpcInput{
 		CidrBlock: aws.String(cloud.DefaultVpcCidrBlock),
 		TagSpecifications: []*ec2.TagSpecification{
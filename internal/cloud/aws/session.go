@@ -0,0 +1,76 @@
+package aws
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/pkg/errors"
+)
+
+// AssumeRole is the role assumed with the credentials of the session.
+type AssumeRole struct {
+	RoleARN     string
+	ExternalID  string
+	SessionName string
+	Duration    time.Duration
+}
+
+// WebIdentity is the role assumed with the OIDC token, e.g. issued by the CI system.
+type WebIdentity struct {
+	RoleARN     string
+	SessionName string
+	TokenFile   string
+}
+
+const defaultSessionName = "percona-terraform"
+
+// newSession creates the session used by all AWS clients of the cloud and by the secret stores and RDS discovery.
+// Static keys take precedence over the profile, the default credential chain is used if neither is set.
+// The web identity role is assumed first, then the role is assumed with the resulting credentials.
+func (c *Cloud) newSession() (*session.Session, error) {
+	config := aws.Config{
+		Region:   c.Region,
+		Endpoint: c.endpoint,
+	}
+	if aws.StringValue(c.AccessKey) != "" || aws.StringValue(c.SecretKey) != "" {
+		config.Credentials = credentials.NewStaticCredentials(aws.StringValue(c.AccessKey), aws.StringValue(c.SecretKey), aws.StringValue(c.SessionToken))
+	}
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            config,
+		Profile:           aws.StringValue(c.Profile),
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed create aws session")
+	}
+
+	if c.WebIdentity != nil {
+		sessionName := c.WebIdentity.SessionName
+		if sessionName == "" {
+			sessionName = defaultSessionName
+		}
+		provider := stscreds.NewWebIdentityRoleProviderWithOptions(sts.New(sess), c.WebIdentity.RoleARN, sessionName, stscreds.FetchTokenPath(c.WebIdentity.TokenFile))
+		sess = sess.Copy(&aws.Config{Credentials: credentials.NewCredentials(provider)})
+	}
+
+	if c.AssumeRole != nil {
+		creds := stscreds.NewCredentials(sess, c.AssumeRole.RoleARN, func(p *stscreds.AssumeRoleProvider) {
+			if c.AssumeRole.ExternalID != "" {
+				p.ExternalID = aws.String(c.AssumeRole.ExternalID)
+			}
+			p.RoleSessionName = c.AssumeRole.SessionName
+			if p.RoleSessionName == "" {
+				p.RoleSessionName = defaultSessionName
+			}
+			if c.AssumeRole.Duration != 0 {
+				p.Duration = c.AssumeRole.Duration
+			}
+		})
+		sess = sess.Copy(&aws.Config{Credentials: creds})
+	}
+	return sess, nil
+}
@@ -0,0 +1,210 @@
+package aws
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+
+	"terraform-percona/internal/cloud"
+)
+
+// stsServer issues temporary credentials for AssumeRole and AssumeRoleWithWebIdentity requests and records them.
+type stsServer struct {
+	mu       sync.Mutex
+	requests []url.Values
+	// authorization headers of the requests, AssumeRoleWithWebIdentity requests are not signed
+	authorization []string
+}
+
+func (s *stsServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	s.requests = append(s.requests, r.PostForm)
+	s.authorization = append(s.authorization, r.Header.Get("Authorization"))
+	n := len(s.requests)
+	s.mu.Unlock()
+
+	action := r.PostForm.Get("Action")
+	w.Header().Set("Content-Type", "text/xml")
+	fmt.Fprintf(w, `<%[1]sResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <%[1]sResult>
+    <Credentials>
+      <AccessKeyId>ASSUMED%[2]d</AccessKeyId>
+      <SecretAccessKey>secret%[2]d</SecretAccessKey>
+      <SessionToken>token%[2]d</SessionToken>
+      <Expiration>2100-01-01T00:00:00Z</Expiration>
+    </Credentials>
+  </%[1]sResult>
+</%[1]sResponse>`, action, n)
+}
+
+// setupEnv isolates the test from the AWS configuration of the environment.
+func setupEnv(t *testing.T) string {
+	dir := t.TempDir()
+	for _, env := range []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN", "AWS_PROFILE", "AWS_ROLE_ARN", "AWS_WEB_IDENTITY_TOKEN_FILE"} {
+		t.Setenv(env, "")
+	}
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+	return dir
+}
+
+func sessionCredentials(t *testing.T, c *Cloud) cloud.Credentials {
+	t.Helper()
+	var err error
+	c.session, err = c.newSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	creds, err := c.Credentials()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return creds
+}
+
+func TestSessionCredentials(t *testing.T) {
+	dir := setupEnv(t)
+	if err := os.WriteFile(filepath.Join(dir, "credentials"), []byte(`[default]
+aws_access_key_id = DEFAULT
+aws_secret_access_key = default
+
+[ci]
+aws_access_key_id = PROFILE
+aws_secret_access_key = profile
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		cloud *Cloud
+		want  cloud.Credentials
+	}{
+		"default profile": {
+			cloud: &Cloud{Profile: aws.String("")},
+			want:  cloud.Credentials{AccessKey: "DEFAULT", SecretKey: "default"},
+		},
+		"named profile": {
+			cloud: &Cloud{Profile: aws.String("ci")},
+			want:  cloud.Credentials{AccessKey: "PROFILE", SecretKey: "profile"},
+		},
+		"static keys override profile": {
+			cloud: &Cloud{
+				Profile:      aws.String("ci"),
+				AccessKey:    aws.String("STATIC"),
+				SecretKey:    aws.String("static"),
+				SessionToken: aws.String("token"),
+			},
+			want: cloud.Credentials{AccessKey: "STATIC", SecretKey: "static", SessionToken: "token"},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt.cloud.Region = aws.String("eu-north-1")
+			tt.want.Region = "eu-north-1"
+			if creds := sessionCredentials(t, tt.cloud); creds != tt.want {
+				t.Errorf("Credentials() = %+v, want %+v", creds, tt.want)
+			}
+		})
+	}
+}
+
+func TestSessionAssumeRole(t *testing.T) {
+	setupEnv(t)
+	sts := new(stsServer)
+	srv := httptest.NewServer(sts)
+	defer srv.Close()
+
+	c := &Cloud{
+		Region:    aws.String("eu-north-1"),
+		AccessKey: aws.String("SOURCE"),
+		SecretKey: aws.String("source"),
+		AssumeRole: &AssumeRole{
+			RoleARN:    "arn:aws:iam::123456789012:role/percona",
+			ExternalID: "external",
+		},
+		endpoint: aws.String(srv.URL),
+	}
+	want := cloud.Credentials{AccessKey: "ASSUMED1", SecretKey: "secret1", SessionToken: "token1", Region: "eu-north-1"}
+	if creds := sessionCredentials(t, c); creds != want {
+		t.Errorf("Credentials() = %+v, want %+v", creds, want)
+	}
+	if len(sts.requests) != 1 {
+		t.Fatalf("%d sts requests, want 1", len(sts.requests))
+	}
+	req := sts.requests[0]
+	for k, v := range map[string]string{
+		"Action":          "AssumeRole",
+		"RoleArn":         "arn:aws:iam::123456789012:role/percona",
+		"ExternalId":      "external",
+		"RoleSessionName": defaultSessionName,
+	} {
+		if req.Get(k) != v {
+			t.Errorf("%s = %q, want %q", k, req.Get(k), v)
+		}
+	}
+	if !strings.Contains(sts.authorization[0], "Credential=SOURCE/") {
+		t.Errorf("AssumeRole is signed with %q, want static keys", sts.authorization[0])
+	}
+}
+
+func TestSessionWebIdentity(t *testing.T) {
+	dir := setupEnv(t)
+	sts := new(stsServer)
+	srv := httptest.NewServer(sts)
+	defer srv.Close()
+	tokenFile := filepath.Join(dir, "token")
+	if err := os.WriteFile(tokenFile, []byte("oidc-token"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Cloud{
+		Region: aws.String("eu-north-1"),
+		WebIdentity: &WebIdentity{
+			RoleARN:     "arn:aws:iam::123456789012:role/ci",
+			SessionName: "pipeline",
+			TokenFile:   tokenFile,
+		},
+		AssumeRole: &AssumeRole{
+			RoleARN:     "arn:aws:iam::210987654321:role/percona",
+			SessionName: "percona",
+		},
+		endpoint: aws.String(srv.URL),
+	}
+	want := cloud.Credentials{AccessKey: "ASSUMED2", SecretKey: "secret2", SessionToken: "token2", Region: "eu-north-1"}
+	if creds := sessionCredentials(t, c); creds != want {
+		t.Errorf("Credentials() = %+v, want %+v", creds, want)
+	}
+	if len(sts.requests) != 2 {
+		t.Fatalf("%d sts requests, want 2", len(sts.requests))
+	}
+	webIdentity, assumeRole := sts.requests[0], sts.requests[1]
+	for k, v := range map[string]string{
+		"Action":           "AssumeRoleWithWebIdentity",
+		"RoleArn":          "arn:aws:iam::123456789012:role/ci",
+		"RoleSessionName":  "pipeline",
+		"WebIdentityToken": "oidc-token",
+	} {
+		if webIdentity.Get(k) != v {
+			t.Errorf("web identity %s = %q, want %q", k, webIdentity.Get(k), v)
+		}
+	}
+	if assumeRole.Get("RoleArn") != "arn:aws:iam::210987654321:role/percona" || assumeRole.Get("RoleSessionName") != "percona" {
+		t.Errorf("assume role request %v", assumeRole)
+	}
+	if !strings.Contains(sts.authorization[1], "Credential=ASSUMED1/") {
+		t.Errorf("AssumeRole is signed with %q, want web identity credentials", sts.authorization[1])
+	}
+}
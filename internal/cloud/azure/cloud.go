@@ -0,0 +1,246 @@
+package azure
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"path"
+	"path/filepath"
+	"sync"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v4"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+
+	"terraform-percona/internal/cloud"
+	"terraform-percona/internal/resource"
+	"terraform-percona/internal/utils"
+)
+
+const (
+	user = "ubuntu"
+
+	defaultVolumeType = "StandardSSD_LRS"
+	// minVolumeSize is the size of the disk of the Ubuntu image, disks can't be smaller than the image
+	minVolumeSize = 30
+)
+
+type Cloud struct {
+	SubscriptionID string
+	Location       string
+	// ResourceGroup is shared by all resources of the provider if set, otherwise each resource gets its own resource group.
+	// Shared resource group is created if it doesn't exist and is never deleted.
+	ResourceGroup string
+
+	Meta cloud.Metadata
+
+	client struct {
+		ResourceGroups    *armresources.ResourceGroupsClient
+		Resources         *armresources.Client
+		VirtualNetworks   *armnetwork.VirtualNetworksClient
+		Subnets           *armnetwork.SubnetsClient
+		SecurityGroups    *armnetwork.SecurityGroupsClient
+		PublicIPAddresses *armnetwork.PublicIPAddressesClient
+		Interfaces        *armnetwork.InterfacesClient
+		VirtualMachines   *armcompute.VirtualMachinesClient
+	}
+	// credential and clientOptions are used by the ARM clients, DefaultAzureCredential and the public cloud are used if they are nil
+	credential    azcore.TokenCredential
+	clientOptions *arm.ClientOptions
+	// sshPing checks that the instance accepts ssh connections
+	sshPing func(ctx context.Context, host string, config *ssh.ClientConfig) error
+
+	configs   map[string]*resourceConfig
+	configsMu sync.Mutex
+	infraMu   sync.Mutex
+	// reserved are the names of the instances being created, so that concurrent calls don't pick the same names
+	reserved   map[string]struct{}
+	reservedMu sync.Mutex
+}
+
+type resourceConfig struct {
+	keyPair       string
+	pathToKeyPair string
+	instanceType  string
+	publicKey     string
+	volumeType    string
+	volumeSize    int32
+	vnetName      string
+	subnetID      string
+}
+
+func (c *Cloud) config(resourceID string) *resourceConfig {
+	c.configsMu.Lock()
+	if c.configs == nil {
+		c.configs = make(map[string]*resourceConfig)
+	}
+	res, ok := c.configs[resourceID]
+	if !ok {
+		res = new(resourceConfig)
+		c.configs[resourceID] = res
+	}
+	c.configsMu.Unlock()
+	return res
+}
+
+func (c *Cloud) Metadata() cloud.Metadata {
+	return c.Meta
+}
+
+// Configure reads the resource attributes, volume_iops is ignored, as IOPS of OS disks are defined by their type and size.
+func (c *Cloud) Configure(_ context.Context, resourceID string, data *schema.ResourceData) error {
+	if c.SubscriptionID == "" {
+		return errors.New("subscription id is required for azure cloud")
+	}
+	cfg := c.config(resourceID)
+	if data != nil {
+		cfg.keyPair = data.Get(resource.SchemaKeyKeyPairName).(string)
+		cfg.pathToKeyPair = data.Get(resource.SchemaKeyPathToKeyPairStorage).(string)
+		cfg.instanceType = data.Get(resource.SchemaKeyInstanceType).(string)
+		cfg.volumeType = data.Get(resource.SchemaKeyVolumeType).(string)
+		if cfg.volumeType == "" {
+			cfg.volumeType = defaultVolumeType
+		}
+		cfg.volumeSize = int32(data.Get(resource.SchemaKeyVolumeSize).(int))
+		if cfg.volumeSize < minVolumeSize {
+			cfg.volumeSize = minVolumeSize
+		}
+		cfg.vnetName = data.Get(resource.SchemaKeyVPCName).(string)
+	}
+	if cfg.vnetName == "" {
+		cfg.vnetName = "percona-" + resourceID
+	}
+	if c.sshPing == nil {
+		c.sshPing = utils.SSHPing
+	}
+
+	credential := c.credential
+	var err error
+	if credential == nil {
+		credential, err = azidentity.NewDefaultAzureCredential(nil)
+		if err != nil {
+			return errors.Wrap(err, "failed to get azure credentials")
+		}
+	}
+	c.client.ResourceGroups, err = armresources.NewResourceGroupsClient(c.SubscriptionID, credential, c.clientOptions)
+	if err != nil {
+		return errors.Wrap(err, "failed to create resource groups client")
+	}
+	c.client.Resources, err = armresources.NewClient(c.SubscriptionID, credential, c.clientOptions)
+	if err != nil {
+		return errors.Wrap(err, "failed to create resources client")
+	}
+	c.client.VirtualNetworks, err = armnetwork.NewVirtualNetworksClient(c.SubscriptionID, credential, c.clientOptions)
+	if err != nil {
+		return errors.Wrap(err, "failed to create virtual networks client")
+	}
+	c.client.Subnets, err = armnetwork.NewSubnetsClient(c.SubscriptionID, credential, c.clientOptions)
+	if err != nil {
+		return errors.Wrap(err, "failed to create subnets client")
+	}
+	c.client.SecurityGroups, err = armnetwork.NewSecurityGroupsClient(c.SubscriptionID, credential, c.clientOptions)
+	if err != nil {
+		return errors.Wrap(err, "failed to create security groups client")
+	}
+	c.client.PublicIPAddresses, err = armnetwork.NewPublicIPAddressesClient(c.SubscriptionID, credential, c.clientOptions)
+	if err != nil {
+		return errors.Wrap(err, "failed to create public ip addresses client")
+	}
+	c.client.Interfaces, err = armnetwork.NewInterfacesClient(c.SubscriptionID, credential, c.clientOptions)
+	if err != nil {
+		return errors.Wrap(err, "failed to create network interfaces client")
+	}
+	c.client.VirtualMachines, err = armcompute.NewVirtualMachinesClient(c.SubscriptionID, credential, c.clientOptions)
+	if err != nil {
+		return errors.Wrap(err, "failed to create virtual machines client")
+	}
+	return nil
+}
+
+// Credentials returns the location of the cloud services only, there are no Azure secret store and RDS-like discovery.
+func (c *Cloud) Credentials() (cloud.Credentials, error) {
+	return cloud.Credentials{
+		Region: c.Location,
+	}, nil
+}
+
+func (c *Cloud) resourceGroup(resourceID string) string {
+	if c.ResourceGroup != "" {
+		return c.ResourceGroup
+	}
+	return "percona-" + resourceID
+}
+
+func (c *Cloud) RunCommand(ctx context.Context, resourceID string, instance cloud.Instance, cmd string) (string, error) {
+	sshConfig, err := c.sshConfig(resourceID)
+	if err != nil {
+		return "", errors.Wrap(err, "ssh config")
+	}
+	return utils.RunCommand(ctx, cmd, instance.PublicIpAddress, sshConfig)
+}
+
+func (c *Cloud) SendFile(ctx context.Context, resourceID string, instance cloud.Instance, file io.Reader, remotePath string) error {
+	sshConfig, err := c.sshConfig(resourceID)
+	if err != nil {
+		return errors.Wrap(err, "ssh config")
+	}
+	return utils.SendFile(ctx, file, remotePath, instance.PublicIpAddress, sshConfig)
+}
+
+func (c *Cloud) EditFile(ctx context.Context, resourceID string, instance cloud.Instance, path string, editFunc func(io.ReadWriteSeeker) error) error {
+	sshConfig, err := c.sshConfig(resourceID)
+	if err != nil {
+		return errors.Wrap(err, "ssh config")
+	}
+	return utils.EditFile(ctx, instance.PublicIpAddress, path, sshConfig, editFunc)
+}
+
+func (c *Cloud) keyPairPath(resourceID string) (string, error) {
+	cfg := c.config(resourceID)
+	filePath, err := filepath.Abs(path.Join(cfg.pathToKeyPair, cfg.keyPair+".pem"))
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get absolute key pair path")
+	}
+	return filePath, nil
+}
+
+func (c *Cloud) sshConfig(resourceID string) (*ssh.ClientConfig, error) {
+	sshKeyPath, err := c.keyPairPath(resourceID)
+	if err != nil {
+		return nil, errors.Wrap(err, "get key pair path")
+	}
+	sshConfig, err := utils.SSHConfig(user, sshKeyPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "get ssh config")
+	}
+	return sshConfig, nil
+}
+
+func isNotFound(err error) bool {
+	var rerr *azcore.ResponseError
+	return errors.As(err, &rerr) && rerr.StatusCode == http.StatusNotFound
+}
+
+func labelsToTags(labels map[string]string) map[string]*string {
+	tags := make(map[string]*string, len(labels))
+	for k, v := range labels {
+		tags[k] = utils.Ref(v)
+	}
+	return tags
+}
+
+// matchTags reports whether tags contain all of the labels.
+func matchTags(tags map[string]*string, labels map[string]string) bool {
+	for k, v := range labels {
+		if tag, ok := tags[k]; !ok || tag == nil || *tag != v {
+			return false
+		}
+	}
+	return true
+}
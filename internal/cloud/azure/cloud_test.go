@@ -0,0 +1,398 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	azcloud "github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/sync/errgroup"
+
+	"terraform-percona/internal/resource"
+)
+
+const subscriptionID = "00000000-0000-0000-0000-000000000000"
+
+// armServer is an in-memory Azure Resource Manager, which keeps the resources by their lowercase ids.
+// Long-running operations complete immediately, the addresses are assigned and virtual machines are attached to
+// their network interfaces like Azure does.
+type armServer struct {
+	mu        sync.Mutex
+	resources map[string]map[string]interface{}
+	nextIP    int
+}
+
+var tagFilter = regexp.MustCompile(`tagName eq '(.*)' and tagValue eq '(.*)'`)
+
+func (s *armServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := strings.ToLower(r.URL.Path)
+	switch r.Method {
+	case http.MethodGet:
+		if res, ok := s.resources[key]; ok {
+			writeJSON(w, http.StatusOK, res)
+			return
+		}
+		if strings.HasSuffix(key, "/resources") {
+			writeJSON(w, http.StatusOK, map[string]interface{}{"value": s.tagged(strings.TrimSuffix(key, "/resources"), r.URL.Query().Get("$filter"))})
+			return
+		}
+		if isCollection(key) {
+			if _, ok := s.resources[key[:strings.Index(key, "/providers/")]]; !ok {
+				writeJSON(w, http.StatusNotFound, notFound)
+				return
+			}
+			writeJSON(w, http.StatusOK, map[string]interface{}{"value": s.list(key)})
+			return
+		}
+		writeJSON(w, http.StatusNotFound, notFound)
+	case http.MethodPut:
+		res := make(map[string]interface{})
+		if err := json.NewDecoder(r.Body).Decode(&res); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.put(r.URL.Path, res)
+		writeJSON(w, http.StatusOK, res)
+	case http.MethodDelete:
+		s.delete(key)
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+var notFound = map[string]interface{}{
+	"error": map[string]interface{}{"code": "ResourceNotFound", "message": "resource not found"},
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// isCollection reports whether the path lists the resources of a type, e.g. .../providers/Microsoft.Compute/virtualMachines.
+func isCollection(key string) bool {
+	i := strings.Index(key, "/providers/")
+	return i >= 0 && len(strings.Split(key[i+len("/providers/"):], "/"))%2 == 0
+}
+
+func resourceType(key string) string {
+	i := strings.Index(key, "/providers/")
+	if i < 0 {
+		return "microsoft.resources/resourcegroups"
+	}
+	// Nested resources have the types of their parents, e.g. microsoft.network/virtualnetworks/subnets
+	parts := strings.Split(key[i+len("/providers/"):], "/")
+	t := parts[0]
+	for j := 1; j < len(parts); j += 2 {
+		t += "/" + parts[j]
+	}
+	return t
+}
+
+func (s *armServer) put(path string, res map[string]interface{}) {
+	key := strings.ToLower(path)
+	res["id"] = path
+	res["name"] = path[strings.LastIndex(path, "/")+1:]
+	res["type"] = resourceType(key)
+	props, _ := res["properties"].(map[string]interface{})
+	if props == nil {
+		props = make(map[string]interface{})
+		res["properties"] = props
+	}
+	props["provisioningState"] = "Succeeded"
+	switch resourceType(key) {
+	case resourceTypePublicIPAddress:
+		s.nextIP++
+		props["ipAddress"] = fmt.Sprintf("127.0.0.%d", s.nextIP)
+	case resourceTypeNetworkInterface:
+		s.nextIP++
+		ipConfig := props["ipConfigurations"].([]interface{})[0].(map[string]interface{})
+		ipConfig["properties"].(map[string]interface{})["privateIPAddress"] = fmt.Sprintf("10.0.0.%d", s.nextIP)
+	case resourceTypeVirtualMachine:
+		for _, nic := range props["networkProfile"].(map[string]interface{})["networkInterfaces"].([]interface{}) {
+			nicID := strings.ToLower(nic.(map[string]interface{})["id"].(string))
+			s.resources[nicID]["properties"].(map[string]interface{})["virtualMachine"] = map[string]interface{}{"id": path}
+		}
+	}
+	s.resources[key] = res
+}
+
+// delete deletes the resource, virtual machines are deleted with their network interfaces and public addresses,
+// resource groups are deleted with everything in them.
+func (s *armServer) delete(key string) {
+	res, ok := s.resources[key]
+	if !ok {
+		return
+	}
+	delete(s.resources, key)
+	switch res["type"] {
+	case "microsoft.resources/resourcegroups":
+		for k := range s.resources {
+			if strings.HasPrefix(k, key+"/") {
+				delete(s.resources, k)
+			}
+		}
+	case resourceTypeVirtualMachine:
+		for _, nic := range res["properties"].(map[string]interface{})["networkProfile"].(map[string]interface{})["networkInterfaces"].([]interface{}) {
+			nicID := strings.ToLower(nic.(map[string]interface{})["id"].(string))
+			for _, ipConfig := range s.resources[nicID]["properties"].(map[string]interface{})["ipConfigurations"].([]interface{}) {
+				ipID := ipConfig.(map[string]interface{})["properties"].(map[string]interface{})["publicIPAddress"].(map[string]interface{})["id"].(string)
+				delete(s.resources, strings.ToLower(ipID))
+			}
+			delete(s.resources, nicID)
+		}
+	}
+}
+
+func (s *armServer) list(collection string) []map[string]interface{} {
+	var list []map[string]interface{}
+	for k, res := range s.resources {
+		if strings.HasPrefix(k, collection+"/") && !strings.Contains(k[len(collection)+1:], "/") {
+			list = append(list, res)
+		}
+	}
+	return list
+}
+
+func (s *armServer) tagged(group, filter string) []map[string]interface{} {
+	m := tagFilter.FindStringSubmatch(filter)
+	var list []map[string]interface{}
+	for k, res := range s.resources {
+		if !strings.HasPrefix(k, group+"/providers/") {
+			continue
+		}
+		if tags, ok := res["tags"].(map[string]interface{}); ok && m != nil && tags[m[1]] == m[2] {
+			list = append(list, res)
+		}
+	}
+	return list
+}
+
+func (s *armServer) names(resourceType string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var names []string
+	for _, res := range s.resources {
+		if res["type"] == resourceType {
+			names = append(names, res["name"].(string))
+		}
+	}
+	return names
+}
+
+type fakeCredential struct{}
+
+func (fakeCredential) GetToken(context.Context, policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	return azcore.AccessToken{Token: "token", ExpiresOn: time.Now().Add(time.Hour)}, nil
+}
+
+func newTestCloud(t *testing.T, resourceGroup string) (*Cloud, *armServer) {
+	t.Helper()
+	arm := &armServer{resources: make(map[string]map[string]interface{})}
+	srv := httptest.NewTLSServer(arm)
+	t.Cleanup(srv.Close)
+	c := &Cloud{
+		SubscriptionID: subscriptionID,
+		Location:       "westeurope",
+		ResourceGroup:  resourceGroup,
+		credential:     fakeCredential{},
+		clientOptions:  clientOptions(srv),
+		sshPing: func(context.Context, string, *ssh.ClientConfig) error {
+			return nil
+		},
+	}
+	return c, arm
+}
+
+func clientOptions(srv *httptest.Server) *arm.ClientOptions {
+	return &arm.ClientOptions{
+		ClientOptions: policy.ClientOptions{
+			Cloud: azcloud.Configuration{
+				ActiveDirectoryAuthorityHost: srv.URL,
+				Services: map[azcloud.ServiceName]azcloud.ServiceConfiguration{
+					azcloud.ResourceManager: {
+						Audience: "https://management.core.windows.net/",
+						Endpoint: srv.URL,
+					},
+				},
+			},
+			Transport: srv.Client(),
+		},
+		DisableRPRegistration: true,
+	}
+}
+
+func configure(t *testing.T, c *Cloud, resourceID string) {
+	t.Helper()
+	data := schema.TestResourceDataRaw(t, resource.DefaultSchema(), map[string]interface{}{
+		resource.SchemaKeyKeyPairName:          "key",
+		resource.SchemaKeyPathToKeyPairStorage: t.TempDir(),
+		resource.SchemaKeyInstanceType:         "Standard_B2s",
+	})
+	if err := c.Configure(context.Background(), resourceID, data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCloud(t *testing.T) {
+	ctx := context.Background()
+	c, arm := newTestCloud(t, "")
+	configure(t, c, "resource")
+
+	if err := c.CreateInfrastructure(ctx, "resource"); err != nil {
+		t.Fatal(err)
+	}
+	for resourceType, want := range map[string]string{
+		"microsoft.resources/resourcegroups": "percona-resource",
+		resourceTypeVirtualNetwork:           "percona-resource",
+		resourceTypeSecurityGroup:            "percona-resource-nsg",
+	} {
+		if names := arm.names(resourceType); len(names) != 1 || names[0] != want {
+			t.Errorf("%s = %v, want %s", resourceType, names, want)
+		}
+	}
+
+	mysql := map[string]string{resource.LabelKeyInstanceType: resource.LabelValueInstanceTypeMySQL}
+	instances, err := c.CreateInstances(ctx, "resource", 2, mysql)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(instances) != 2 {
+		t.Fatalf("CreateInstances() returned %d instances, want 2", len(instances))
+	}
+	for _, instance := range instances {
+		if instance.PublicIpAddress == "" || instance.PrivateIpAddress == "" {
+			t.Errorf("instance %+v has no addresses", instance)
+		}
+	}
+	if _, err := c.CreateInstances(ctx, "resource", 1, map[string]string{resource.LabelKeyInstanceType: "orchestrator"}); err != nil {
+		t.Fatal(err)
+	}
+	if names := strings.Join(arm.names(resourceTypeVirtualMachine), ","); !strings.Contains(names, "instance-resource-2") {
+		t.Errorf("virtual machines %s, want numbering to continue after the existing instances", names)
+	}
+
+	listed, err := c.ListInstances(ctx, "resource", mysql)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(listed) != fmt.Sprint(instances) {
+		t.Errorf("ListInstances() = %v, want %v", listed, instances)
+	}
+
+	if err := c.DeleteInstances(ctx, "resource", instances[1:]); err != nil {
+		t.Fatal(err)
+	}
+	listed, err = c.ListInstances(ctx, "resource", mysql)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(listed) != fmt.Sprint(instances[:1]) {
+		t.Errorf("ListInstances() after deletion = %v, want %v", listed, instances[:1])
+	}
+	if nics := arm.names(resourceTypeNetworkInterface); len(nics) != 2 {
+		t.Errorf("network interfaces %v are left after deletion of the instance", nics)
+	}
+
+	if err := c.DeleteInfrastructure(ctx, "resource"); err != nil {
+		t.Fatal(err)
+	}
+	if len(arm.resources) != 0 {
+		t.Errorf("resources are left after DeleteInfrastructure: %v", arm.resources)
+	}
+	if listed, err := c.ListInstances(ctx, "resource", nil); err != nil || len(listed) != 0 {
+		t.Errorf("ListInstances() after DeleteInfrastructure = %v, %v, want no instances", listed, err)
+	}
+}
+
+func TestSharedResourceGroup(t *testing.T) {
+	ctx := context.Background()
+	c, arm := newTestCloud(t, "shared")
+	configure(t, c, "first")
+	configure(t, c, "second")
+
+	for _, resourceID := range []string{"first", "second"} {
+		if err := c.CreateInfrastructure(ctx, resourceID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.CreateInstances(ctx, resourceID, 1, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Network interface left by the failed creation of an instance
+	arm.mu.Lock()
+	arm.put(fmt.Sprintf("/subscriptions/%s/resourceGroups/shared/providers/Microsoft.Network/networkInterfaces/orphan", subscriptionID), map[string]interface{}{
+		"tags": map[string]interface{}{resource.LabelKeyResourceID: "first"},
+		"properties": map[string]interface{}{
+			"ipConfigurations": []interface{}{map[string]interface{}{"properties": map[string]interface{}{}}},
+		},
+	})
+	arm.mu.Unlock()
+
+	if err := c.DeleteInfrastructure(ctx, "first"); err != nil {
+		t.Fatal(err)
+	}
+	if groups := arm.names("microsoft.resources/resourcegroups"); len(groups) != 1 {
+		t.Errorf("shared resource group is deleted")
+	}
+	for _, resourceType := range []string{resourceTypeVirtualMachine, resourceTypeNetworkInterface, resourceTypePublicIPAddress, resourceTypeVirtualNetwork, resourceTypeSecurityGroup} {
+		for _, name := range arm.names(resourceType) {
+			if !strings.Contains(name, "second") {
+				t.Errorf("%s %s of the deleted resource is left", resourceType, name)
+			}
+		}
+	}
+	instances, err := c.ListInstances(ctx, "second", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(instances) != 1 {
+		t.Errorf("ListInstances() of the remaining resource returned %d instances, want 1", len(instances))
+	}
+}
+
+// TestConcurrentCreateInstances creates instances of different types at the same time, as percona_ps creates mysql and orchestrator instances.
+func TestConcurrentCreateInstances(t *testing.T) {
+	ctx := context.Background()
+	c, arm := newTestCloud(t, "")
+	configure(t, c, "resource")
+	if err := c.CreateInfrastructure(ctx, "resource"); err != nil {
+		t.Fatal(err)
+	}
+
+	g := new(errgroup.Group)
+	for _, instanceType := range []string{resource.LabelValueInstanceTypeMySQL, resource.LabelValueInstanceTypeOrchestrator} {
+		labels := map[string]string{resource.LabelKeyInstanceType: instanceType}
+		g.Go(func() error {
+			_, err := c.CreateInstances(ctx, "resource", 3, labels)
+			return err
+		})
+	}
+	if err := g.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if names := arm.names(resourceTypeVirtualMachine); len(names) != 6 {
+		t.Errorf("virtual machines %v, want 6", names)
+	}
+
+	// Another process may create an instance with the same name, the existing virtual machine shouldn't be overwritten
+	if err := c.createInstance(ctx, "resource", "instance-resource-0", nil); err == nil {
+		t.Error("existing instance is overwritten")
+	}
+}
@@ -0,0 +1,502 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v4"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+
+	"terraform-percona/internal/cloud"
+	"terraform-percona/internal/resource"
+	"terraform-percona/internal/utils"
+)
+
+func (c *Cloud) CreateInfrastructure(ctx context.Context, resourceID string) error {
+	c.infraMu.Lock()
+	defer c.infraMu.Unlock()
+
+	sshKeyPath, err := c.keyPairPath(resourceID)
+	if err != nil {
+		return errors.Wrap(err, "key pair path")
+	}
+	cfg := c.config(resourceID)
+	cfg.publicKey, err = utils.GetSSHPublicKey(sshKeyPath)
+	if err != nil {
+		return errors.Wrap(err, "failed to create SSH key")
+	}
+
+	if err := c.createResourceGroupIfNotExists(ctx, resourceID); err != nil {
+		return errors.Wrap(err, "failed to create resource group")
+	}
+	securityGroupID, err := c.createSecurityGroupIfNotExists(ctx, resourceID, cfg.vnetName+"-nsg")
+	if err != nil {
+		return errors.Wrap(err, "failed to create network security group")
+	}
+	if err := c.createVirtualNetworkIfNotExists(ctx, resourceID, cfg.vnetName); err != nil {
+		return errors.Wrap(err, "failed to create virtual network")
+	}
+	cfg.subnetID, err = c.createSubnetIfNotExists(ctx, resourceID, cfg.vnetName, cfg.vnetName+"-subnet", securityGroupID)
+	if err != nil {
+		return errors.Wrap(err, "failed to create subnet")
+	}
+	return nil
+}
+
+// createResourceGroupIfNotExists creates the resource group, the group of the resource has the resource id tag,
+// so that it's deleted with the resource.
+func (c *Cloud) createResourceGroupIfNotExists(ctx context.Context, resourceID string) error {
+	name := c.resourceGroup(resourceID)
+	_, err := c.client.ResourceGroups.Get(ctx, name, nil)
+	if err == nil {
+		return nil
+	}
+	if !isNotFound(err) {
+		return errors.Wrap(err, "failed to get resource group")
+	}
+	group := armresources.ResourceGroup{
+		Location: utils.Ref(c.Location),
+	}
+	if c.ResourceGroup == "" {
+		group.Tags = labelsToTags(map[string]string{
+			resource.LabelKeyResourceID: resourceID,
+		})
+	}
+	if _, err := c.client.ResourceGroups.CreateOrUpdate(ctx, name, group, nil); err != nil {
+		return errors.Wrapf(err, "failed to create resource group %s", name)
+	}
+	return nil
+}
+
+func (c *Cloud) createSecurityGroupIfNotExists(ctx context.Context, resourceID, name string) (string, error) {
+	group := c.resourceGroup(resourceID)
+	existing, err := c.client.SecurityGroups.Get(ctx, group, name, nil)
+	if err == nil {
+		return *existing.ID, nil
+	}
+	if !isNotFound(err) {
+		return "", errors.Wrap(err, "failed to get network security group")
+	}
+	poller, err := c.client.SecurityGroups.BeginCreateOrUpdate(ctx, group, name, armnetwork.SecurityGroup{
+		Location: utils.Ref(c.Location),
+		Tags: labelsToTags(map[string]string{
+			resource.LabelKeyResourceID: resourceID,
+		}),
+		Properties: &armnetwork.SecurityGroupPropertiesFormat{
+			SecurityRules: []*armnetwork.SecurityRule{
+				{
+					Name: utils.Ref("allow-all"),
+					Properties: &armnetwork.SecurityRulePropertiesFormat{
+						Access:                   utils.Ref(armnetwork.SecurityRuleAccessAllow),
+						Direction:                utils.Ref(armnetwork.SecurityRuleDirectionInbound),
+						Protocol:                 utils.Ref(armnetwork.SecurityRuleProtocolAsterisk),
+						Priority:                 utils.Ref(int32(4000)),
+						SourceAddressPrefix:      utils.Ref(cloud.AllAddressesCidrBlock),
+						SourcePortRange:          utils.Ref("*"),
+						DestinationAddressPrefix: utils.Ref("*"),
+						DestinationPortRange:     utils.Ref("*"),
+					},
+				},
+			},
+		},
+	}, nil)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create network security group")
+	}
+	resp, err := poller.PollUntilDone(ctx, nil)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to wait for network security group")
+	}
+	return *resp.ID, nil
+}
+
+func (c *Cloud) createVirtualNetworkIfNotExists(ctx context.Context, resourceID, name string) error {
+	group := c.resourceGroup(resourceID)
+	_, err := c.client.VirtualNetworks.Get(ctx, group, name, nil)
+	if err == nil {
+		return nil
+	}
+	if !isNotFound(err) {
+		return errors.Wrap(err, "failed to get virtual network")
+	}
+	poller, err := c.client.VirtualNetworks.BeginCreateOrUpdate(ctx, group, name, armnetwork.VirtualNetwork{
+		Location: utils.Ref(c.Location),
+		Tags: labelsToTags(map[string]string{
+			resource.LabelKeyResourceID: resourceID,
+		}),
+		Properties: &armnetwork.VirtualNetworkPropertiesFormat{
+			AddressSpace: &armnetwork.AddressSpace{
+				AddressPrefixes: []*string{utils.Ref(cloud.DefaultVpcCidrBlock)},
+			},
+		},
+	}, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to create virtual network")
+	}
+	if _, err := poller.PollUntilDone(ctx, nil); err != nil {
+		return errors.Wrap(err, "failed to wait for virtual network")
+	}
+	return nil
+}
+
+func (c *Cloud) createSubnetIfNotExists(ctx context.Context, resourceID, vnetName, name, securityGroupID string) (string, error) {
+	group := c.resourceGroup(resourceID)
+	existing, err := c.client.Subnets.Get(ctx, group, vnetName, name, nil)
+	if err == nil {
+		return *existing.ID, nil
+	}
+	if !isNotFound(err) {
+		return "", errors.Wrap(err, "failed to get subnet")
+	}
+	poller, err := c.client.Subnets.BeginCreateOrUpdate(ctx, group, vnetName, name, armnetwork.Subnet{
+		Properties: &armnetwork.SubnetPropertiesFormat{
+			AddressPrefix: utils.Ref(cloud.DefaultVpcCidrBlock),
+			NetworkSecurityGroup: &armnetwork.SecurityGroup{
+				ID: utils.Ref(securityGroupID),
+			},
+		},
+	}, nil)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create subnet")
+	}
+	resp, err := poller.PollUntilDone(ctx, nil)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to wait for subnet")
+	}
+	return *resp.ID, nil
+}
+
+// CreateInstances creates virtual machines with a public address and a managed disk,
+// which are deleted with the virtual machine.
+func (c *Cloud) CreateInstances(ctx context.Context, resourceID string, size int64, labels map[string]string) ([]cloud.Instance, error) {
+	labels = utils.MapMerge(labels, map[string]string{
+		resource.LabelKeyResourceID: resourceID,
+	})
+	names, err := c.reserveNames(ctx, resourceID, int(size))
+	if err != nil {
+		return nil, err
+	}
+	defer c.releaseNames(names)
+
+	g, gCtx := errgroup.WithContext(ctx)
+	for name := range names {
+		name := name
+		g.Go(func() error {
+			return c.createInstance(gCtx, resourceID, name, labels)
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	vms, err := c.listVirtualMachines(ctx, resourceID, labels)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list instances")
+	}
+	var created []*armcompute.VirtualMachine
+	for _, vm := range vms {
+		if _, ok := names[*vm.Name]; ok {
+			created = append(created, vm)
+		}
+	}
+	instances, err := c.toCloudInstances(ctx, resourceID, created)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.waitUntilInstancesAreReady(ctx, resourceID, instances); err != nil {
+		return nil, errors.Wrap(err, "failed to wait instances")
+	}
+	return instances, nil
+}
+
+// reserveNames picks the names of new instances, the numbering continues after the biggest number of the existing and reserved instances,
+// so that the names are unique when the cluster is scaled out or several instance types are created at the same time.
+func (c *Cloud) reserveNames(ctx context.Context, resourceID string, size int) (map[string]struct{}, error) {
+	c.reservedMu.Lock()
+	defer c.reservedMu.Unlock()
+	existing, err := c.listVirtualMachines(ctx, resourceID, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list instances")
+	}
+	taken := make([]string, 0, len(existing)+len(c.reserved))
+	for _, vm := range existing {
+		taken = append(taken, *vm.Name)
+	}
+	for name := range c.reserved {
+		taken = append(taken, name)
+	}
+	next := 0
+	for _, name := range taken {
+		if n, err := strconv.Atoi(strings.TrimPrefix(name, instanceNamePrefix(resourceID))); err == nil && n >= next {
+			next = n + 1
+		}
+	}
+	if c.reserved == nil {
+		c.reserved = make(map[string]struct{})
+	}
+	names := make(map[string]struct{}, size)
+	for i := 0; i < size; i++ {
+		name := instanceNamePrefix(resourceID) + strconv.Itoa(next+i)
+		names[name] = struct{}{}
+		c.reserved[name] = struct{}{}
+	}
+	return names, nil
+}
+
+func (c *Cloud) releaseNames(names map[string]struct{}) {
+	c.reservedMu.Lock()
+	defer c.reservedMu.Unlock()
+	for name := range names {
+		delete(c.reserved, name)
+	}
+}
+
+func instanceNamePrefix(resourceID string) string {
+	return fmt.Sprintf("instance-%s-", resourceID)
+}
+
+// createInstance fails if the virtual machine exists, as it would be overwritten by the update.
+func (c *Cloud) createInstance(ctx context.Context, resourceID, name string, labels map[string]string) error {
+	cfg := c.config(resourceID)
+	group := c.resourceGroup(resourceID)
+	tags := labelsToTags(labels)
+
+	if _, err := c.client.VirtualMachines.Get(ctx, group, name, nil); err == nil {
+		return errors.Errorf("instance %s already exists", name)
+	} else if !isNotFound(err) {
+		return errors.Wrapf(err, "failed to check %s instance", name)
+	}
+
+	ipPoller, err := c.client.PublicIPAddresses.BeginCreateOrUpdate(ctx, group, name+"-ip", armnetwork.PublicIPAddress{
+		Location: utils.Ref(c.Location),
+		Tags:     tags,
+		SKU: &armnetwork.PublicIPAddressSKU{
+			Name: utils.Ref(armnetwork.PublicIPAddressSKUNameStandard),
+		},
+		Properties: &armnetwork.PublicIPAddressPropertiesFormat{
+			PublicIPAllocationMethod: utils.Ref(armnetwork.IPAllocationMethodStatic),
+			DeleteOption:             utils.Ref(armnetwork.DeleteOptionsDelete),
+		},
+	}, nil)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create public ip address of %s instance", name)
+	}
+	ip, err := ipPoller.PollUntilDone(ctx, nil)
+	if err != nil {
+		return errors.Wrapf(err, "failed to wait for public ip address of %s instance", name)
+	}
+
+	nicPoller, err := c.client.Interfaces.BeginCreateOrUpdate(ctx, group, name+"-nic", armnetwork.Interface{
+		Location: utils.Ref(c.Location),
+		Tags:     tags,
+		Properties: &armnetwork.InterfacePropertiesFormat{
+			IPConfigurations: []*armnetwork.InterfaceIPConfiguration{
+				{
+					Name: utils.Ref("ipconfig"),
+					Properties: &armnetwork.InterfaceIPConfigurationPropertiesFormat{
+						PrivateIPAllocationMethod: utils.Ref(armnetwork.IPAllocationMethodDynamic),
+						Subnet:                    &armnetwork.Subnet{ID: utils.Ref(cfg.subnetID)},
+						PublicIPAddress:           &armnetwork.PublicIPAddress{ID: ip.ID},
+					},
+				},
+			},
+		},
+	}, nil)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create network interface of %s instance", name)
+	}
+	nic, err := nicPoller.PollUntilDone(ctx, nil)
+	if err != nil {
+		return errors.Wrapf(err, "failed to wait for network interface of %s instance", name)
+	}
+
+	vmPoller, err := c.client.VirtualMachines.BeginCreateOrUpdate(ctx, group, name, armcompute.VirtualMachine{
+		Location: utils.Ref(c.Location),
+		Tags:     tags,
+		Properties: &armcompute.VirtualMachineProperties{
+			HardwareProfile: &armcompute.HardwareProfile{
+				VMSize: utils.Ref(armcompute.VirtualMachineSizeTypes(cfg.instanceType)),
+			},
+			StorageProfile: &armcompute.StorageProfile{
+				ImageReference: &armcompute.ImageReference{
+					Publisher: utils.Ref("Canonical"),
+					Offer:     utils.Ref("0001-com-ubuntu-server-focal"),
+					SKU:       utils.Ref("20_04-lts-gen2"),
+					Version:   utils.Ref("latest"),
+				},
+				OSDisk: &armcompute.OSDisk{
+					Name:         utils.Ref(name + "-disk"),
+					CreateOption: utils.Ref(armcompute.DiskCreateOptionTypesFromImage),
+					DeleteOption: utils.Ref(armcompute.DiskDeleteOptionTypesDelete),
+					DiskSizeGB:   utils.Ref(cfg.volumeSize),
+					ManagedDisk: &armcompute.ManagedDiskParameters{
+						StorageAccountType: utils.Ref(armcompute.StorageAccountTypes(cfg.volumeType)),
+					},
+				},
+			},
+			OSProfile: &armcompute.OSProfile{
+				ComputerName:  utils.Ref(name),
+				AdminUsername: utils.Ref(user),
+				LinuxConfiguration: &armcompute.LinuxConfiguration{
+					DisablePasswordAuthentication: utils.Ref(true),
+					SSH: &armcompute.SSHConfiguration{
+						PublicKeys: []*armcompute.SSHPublicKey{
+							{
+								Path:    utils.Ref(fmt.Sprintf("/home/%s/.ssh/authorized_keys", user)),
+								KeyData: utils.Ref(cfg.publicKey),
+							},
+						},
+					},
+				},
+			},
+			NetworkProfile: &armcompute.NetworkProfile{
+				NetworkInterfaces: []*armcompute.NetworkInterfaceReference{
+					{
+						ID: nic.ID,
+						Properties: &armcompute.NetworkInterfaceReferenceProperties{
+							Primary:      utils.Ref(true),
+							DeleteOption: utils.Ref(armcompute.DeleteOptionsDelete),
+						},
+					},
+				},
+			},
+		},
+	}, nil)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create %s instance", name)
+	}
+	if _, err := vmPoller.PollUntilDone(ctx, nil); err != nil {
+		return errors.Wrapf(err, "failed to wait for %s instance", name)
+	}
+	return nil
+}
+
+func (c *Cloud) waitUntilInstancesAreReady(ctx context.Context, resourceID string, instances []cloud.Instance) error {
+	sshConfig, err := c.sshConfig(resourceID)
+	if err != nil {
+		return errors.Wrap(err, "ssh config")
+	}
+	for _, instance := range instances {
+		for {
+			err := c.sshPing(ctx, instance.PublicIpAddress, sshConfig)
+			if err == nil {
+				break
+			}
+			tflog.Debug(ctx, "instance is not ready yet", map[string]interface{}{
+				"instance": instance.PublicIpAddress, "error": err.Error(),
+			})
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Second):
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Cloud) ListInstances(ctx context.Context, resourceID string, labels map[string]string) ([]cloud.Instance, error) {
+	vms, err := c.listVirtualMachines(ctx, resourceID, labels)
+	if err != nil {
+		return nil, err
+	}
+	return c.toCloudInstances(ctx, resourceID, vms)
+}
+
+// listVirtualMachines returns the virtual machines of the resource which have the labels, ordered by name.
+// Virtual machines which are being deleted are skipped.
+func (c *Cloud) listVirtualMachines(ctx context.Context, resourceID string, labels map[string]string) ([]*armcompute.VirtualMachine, error) {
+	labels = utils.MapMerge(labels, map[string]string{
+		resource.LabelKeyResourceID: resourceID,
+	})
+	var vms []*armcompute.VirtualMachine
+	pager := c.client.VirtualMachines.NewListPager(c.resourceGroup(resourceID), nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			// Resource group of the resource is deleted along with its instances
+			if isNotFound(err) {
+				return nil, nil
+			}
+			return nil, errors.Wrap(err, "next page")
+		}
+		for _, vm := range page.Value {
+			if vm.Properties != nil && vm.Properties.ProvisioningState != nil && *vm.Properties.ProvisioningState == "Deleting" {
+				continue
+			}
+			if matchTags(vm.Tags, labels) {
+				vms = append(vms, vm)
+			}
+		}
+	}
+	sort.Slice(vms, func(i, j int) bool {
+		return instanceNumber(*vms[i].Name) < instanceNumber(*vms[j].Name)
+	})
+	return vms, nil
+}
+
+func instanceNumber(name string) int {
+	n, _ := strconv.Atoi(name[strings.LastIndex(name, "-")+1:])
+	return n
+}
+
+// toCloudInstances looks up the addresses of the virtual machines in the network interfaces and public ip addresses of the resource group.
+func (c *Cloud) toCloudInstances(ctx context.Context, resourceID string, vms []*armcompute.VirtualMachine) ([]cloud.Instance, error) {
+	if len(vms) == 0 {
+		return nil, nil
+	}
+	group := c.resourceGroup(resourceID)
+	publicIPs := make(map[string]string)
+	ipPager := c.client.PublicIPAddresses.NewListPager(group, nil)
+	for ipPager.More() {
+		page, err := ipPager.NextPage(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list public ip addresses")
+		}
+		for _, ip := range page.Value {
+			if ip.ID != nil && ip.Properties != nil && ip.Properties.IPAddress != nil {
+				publicIPs[strings.ToLower(*ip.ID)] = *ip.Properties.IPAddress
+			}
+		}
+	}
+	addresses := make(map[string]cloud.Instance)
+	nicPager := c.client.Interfaces.NewListPager(group, nil)
+	for nicPager.More() {
+		page, err := nicPager.NextPage(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list network interfaces")
+		}
+		for _, nic := range page.Value {
+			if nic.Properties == nil || nic.Properties.VirtualMachine == nil || nic.Properties.VirtualMachine.ID == nil || len(nic.Properties.IPConfigurations) == 0 {
+				continue
+			}
+			var instance cloud.Instance
+			if props := nic.Properties.IPConfigurations[0].Properties; props != nil {
+				if props.PrivateIPAddress != nil {
+					instance.PrivateIpAddress = *props.PrivateIPAddress
+				}
+				if props.PublicIPAddress != nil && props.PublicIPAddress.ID != nil {
+					instance.PublicIpAddress = publicIPs[strings.ToLower(*props.PublicIPAddress.ID)]
+				}
+			}
+			addresses[strings.ToLower(*nic.Properties.VirtualMachine.ID)] = instance
+		}
+	}
+	instances := make([]cloud.Instance, 0, len(vms))
+	for _, vm := range vms {
+		instance, ok := addresses[strings.ToLower(*vm.ID)]
+		if !ok {
+			return nil, errors.Errorf("network interface of %s instance not found", *vm.Name)
+		}
+		instances = append(instances, instance)
+	}
+	return instances, nil
+}
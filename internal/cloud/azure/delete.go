@@ -0,0 +1,163 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v4"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+
+	"terraform-percona/internal/cloud"
+	"terraform-percona/internal/resource"
+	"terraform-percona/internal/utils"
+)
+
+const (
+	resourceTypeVirtualMachine   = "microsoft.compute/virtualmachines"
+	resourceTypeNetworkInterface = "microsoft.network/networkinterfaces"
+	resourceTypePublicIPAddress  = "microsoft.network/publicipaddresses"
+	resourceTypeVirtualNetwork   = "microsoft.network/virtualnetworks"
+	resourceTypeSecurityGroup    = "microsoft.network/networksecuritygroups"
+)
+
+func (c *Cloud) DeleteInstances(ctx context.Context, resourceID string, instances []cloud.Instance) error {
+	if len(instances) == 0 {
+		return nil
+	}
+	vms, err := c.listVirtualMachines(ctx, resourceID, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to list instances")
+	}
+	cloudInstances, err := c.toCloudInstances(ctx, resourceID, vms)
+	if err != nil {
+		return errors.Wrap(err, "failed to get instance addresses")
+	}
+	privateIPs := make(map[string]struct{}, len(instances))
+	for _, instance := range instances {
+		privateIPs[instance.PrivateIpAddress] = struct{}{}
+	}
+	var toDelete []*armcompute.VirtualMachine
+	for i, vm := range vms {
+		if _, ok := privateIPs[cloudInstances[i].PrivateIpAddress]; ok {
+			toDelete = append(toDelete, vm)
+		}
+	}
+	if len(toDelete) != len(instances) {
+		return errors.Errorf("found %d of %d instances to delete", len(toDelete), len(instances))
+	}
+	g, gCtx := errgroup.WithContext(ctx)
+	for _, vm := range toDelete {
+		name := *vm.Name
+		g.Go(func() error {
+			poller, err := c.client.VirtualMachines.BeginDelete(gCtx, c.resourceGroup(resourceID), name, nil)
+			if err := wait(gCtx, poller, err); err != nil {
+				return errors.Wrapf(err, "delete %s instance", name)
+			}
+			return nil
+		})
+	}
+	return g.Wait()
+}
+
+// DeleteInfrastructure deletes the resource group of the resource with everything in it.
+// Only the resources which have the resource id tag are deleted from the shared resource group.
+func (c *Cloud) DeleteInfrastructure(ctx context.Context, resourceID string) error {
+	group := c.resourceGroup(resourceID)
+	if c.ResourceGroup == "" {
+		rg, err := c.client.ResourceGroups.Get(ctx, group, nil)
+		if err != nil {
+			return c.destroyError(ctx, err, "get resource group "+group)
+		}
+		if !matchTags(rg.Tags, map[string]string{resource.LabelKeyResourceID: resourceID}) {
+			return c.destroyError(ctx, errors.Errorf("resource group %s is not created for resource %s", group, resourceID), "delete resource group")
+		}
+		poller, err := c.client.ResourceGroups.BeginDelete(ctx, group, nil)
+		return c.destroyError(ctx, wait(ctx, poller, err), "delete resource group "+group)
+	}
+
+	resources := make(map[string][]string)
+	pager := c.client.Resources.NewListByResourceGroupPager(group, &armresources.ClientListByResourceGroupOptions{
+		Filter: utils.Ref(fmt.Sprintf("tagName eq '%s' and tagValue eq '%s'", resource.LabelKeyResourceID, resourceID)),
+	})
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return c.destroyError(ctx, err, "list resources")
+		}
+		for _, r := range page.Value {
+			resourceType := strings.ToLower(*r.Type)
+			resources[resourceType] = append(resources[resourceType], *r.Name)
+		}
+	}
+
+	// Delete Instances, their disks, network interfaces and public addresses are deleted with them
+	g, gCtx := errgroup.WithContext(ctx)
+	for _, name := range resources[resourceTypeVirtualMachine] {
+		name := name
+		g.Go(func() error {
+			poller, err := c.client.VirtualMachines.BeginDelete(gCtx, group, name, nil)
+			return c.destroyError(gCtx, wait(gCtx, poller, err), "delete "+name+" instance")
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return err
+	}
+
+	// Delete Network Interfaces and Public IP Addresses left by failed instance creation
+	for _, name := range resources[resourceTypeNetworkInterface] {
+		poller, err := c.client.Interfaces.BeginDelete(ctx, group, name, nil)
+		if err := c.destroyError(ctx, wait(ctx, poller, err), "delete network interface "+name); err != nil {
+			return err
+		}
+	}
+	for _, name := range resources[resourceTypePublicIPAddress] {
+		poller, err := c.client.PublicIPAddresses.BeginDelete(ctx, group, name, nil)
+		if err := c.destroyError(ctx, wait(ctx, poller, err), "delete public ip address "+name); err != nil {
+			return err
+		}
+	}
+
+	// Delete Virtual Networks with their subnets, security groups can be deleted only when they aren't used by the subnets
+	for _, name := range resources[resourceTypeVirtualNetwork] {
+		poller, err := c.client.VirtualNetworks.BeginDelete(ctx, group, name, nil)
+		if err := c.destroyError(ctx, wait(ctx, poller, err), "delete virtual network "+name); err != nil {
+			return err
+		}
+	}
+	for _, name := range resources[resourceTypeSecurityGroup] {
+		poller, err := c.client.SecurityGroups.BeginDelete(ctx, group, name, nil)
+		if err := c.destroyError(ctx, wait(ctx, poller, err), "delete network security group "+name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// wait waits for the long-running operation started by the call which returned the poller and err.
+func wait[T any](ctx context.Context, poller *runtime.Poller[T], err error) error {
+	if err != nil {
+		return err
+	}
+	_, err = poller.PollUntilDone(ctx, nil)
+	return err
+}
+
+// destroyError wraps err unless the errors are ignored on destroy, ignored errors are logged.
+// Resources which are not found are already deleted.
+func (c *Cloud) destroyError(ctx context.Context, err error, msg string) error {
+	if err == nil || isNotFound(err) {
+		return nil
+	}
+	if !c.Meta.IgnoreErrorsOnDestroy {
+		return errors.Wrapf(err, "failed to %s", msg)
+	}
+	tflog.Error(ctx, "failed to "+msg, map[string]interface{}{
+		"error": err.Error(),
+	})
+	return nil
+}
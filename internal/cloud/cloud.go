@@ -5,6 +5,7 @@ import (
 	"io"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"golang.org/x/oauth2"
 )
 
 const (
@@ -21,6 +22,7 @@ type Cloud interface {
 	EditFile(ctx context.Context, resourceID string, instance Instance, path string, editFunc func(io.ReadWriteSeeker) error) error
 	CreateInstances(ctx context.Context, resourceID string, size int64, labels map[string]string) ([]Instance, error)
 	ListInstances(ctx context.Context, resourceID string, labels map[string]string) ([]Instance, error)
+	DeleteInstances(ctx context.Context, resourceID string, instances []Instance) error
 	Metadata() Metadata
 	Credentials() (Credentials, error)
 }
@@ -33,9 +35,19 @@ type Instance struct {
 type Metadata struct {
 	DisableTelemetry      bool
 	IgnoreErrorsOnDestroy bool
+
+	VaultAddress string
+	VaultToken   string
 }
 
 type Credentials struct {
-	AccessKey string
-	SecretKey string
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+	// TokenSource issues OAuth2 tokens for the cloud services, it's set by GCP
+	TokenSource oauth2.TokenSource
+
+	// Region and Project locate the cloud services which are accessed with the credentials
+	Region  string
+	Project string
 }
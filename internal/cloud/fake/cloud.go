@@ -0,0 +1,386 @@
+// Package fake implements cloud.Cloud in memory, so that the managers can be tested without cloud instances.
+package fake
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/pkg/errors"
+
+	"terraform-percona/internal/cloud"
+	"terraform-percona/internal/resource"
+	"terraform-percona/internal/utils"
+)
+
+const (
+	MethodRunCommand = "RunCommand"
+	MethodSendFile   = "SendFile"
+	MethodEditFile   = "EditFile"
+)
+
+// Call is a RunCommand, SendFile or EditFile call, Arg is the command or the path of the file.
+type Call struct {
+	Method   string
+	Instance cloud.Instance
+	Arg      string
+}
+
+// Cloud records the calls against simulated instances, each instance has its own filesystem.
+// Commands are not interpreted: their output is the one set with OnCommand, files are changed only by SendFile and EditFile.
+type Cloud struct {
+	Meta cloud.Metadata
+
+	mu        sync.Mutex
+	instances []*instance
+	nextID    int
+	calls     []Call
+	outputs   []output
+	failures  []failure
+	// failAfter fails every call after the number of successful calls, it's disabled if negative
+	failAfter    int
+	failAfterErr error
+}
+
+type instance struct {
+	cloud.Instance
+	labels map[string]string
+	files  map[string][]byte
+}
+
+type output struct {
+	match  string
+	output string
+}
+
+type failure struct {
+	match string
+	err   error
+}
+
+func New() *Cloud {
+	return &Cloud{failAfter: -1}
+}
+
+// OnCommand sets the output of the commands which contain match, the output set first is used if several of them match.
+func (c *Cloud) OnCommand(match, out string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.outputs = append(c.outputs, output{match: match, output: out})
+}
+
+// FailOn makes the calls fail with err if their command or path contains match.
+func (c *Cloud) FailOn(match string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.failures = append(c.failures, failure{match: match, err: err})
+}
+
+// FailAfter makes every call fail with err after n successful calls.
+func (c *Cloud) FailAfter(n int, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.failAfter = n
+	c.failAfterErr = err
+}
+
+// Calls returns the recorded calls in the order they were made, including the failed ones.
+func (c *Cloud) Calls() []Call {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Call(nil), c.calls...)
+}
+
+// Commands returns the commands run on the instance.
+func (c *Cloud) Commands(inst cloud.Instance) []string {
+	var cmds []string
+	for _, call := range c.Calls() {
+		if call.Method == MethodRunCommand && call.Instance == inst {
+			cmds = append(cmds, call.Arg)
+		}
+	}
+	return cmds
+}
+
+// File returns the content of the file on the instance.
+func (c *Cloud) File(inst cloud.Instance, path string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	i := c.find(inst)
+	if i == nil {
+		return "", false
+	}
+	data, ok := i.files[path]
+	return string(data), ok
+}
+
+// Paths returns the paths of the files on the instance in lexical order.
+func (c *Cloud) Paths(inst cloud.Instance) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	i := c.find(inst)
+	if i == nil {
+		return nil
+	}
+	paths := make([]string, 0, len(i.files))
+	for p := range i.files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// WriteFile sets the content of the file on the instance.
+func (c *Cloud) WriteFile(inst cloud.Instance, path, content string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	i := c.find(inst)
+	if i == nil {
+		return errors.Errorf("instance %s not found", inst.PrivateIpAddress)
+	}
+	i.files[path] = []byte(content)
+	return nil
+}
+
+func (c *Cloud) Metadata() cloud.Metadata {
+	return c.Meta
+}
+
+func (c *Cloud) Configure(_ context.Context, _ string, _ *schema.ResourceData) error {
+	return nil
+}
+
+func (c *Cloud) Credentials() (cloud.Credentials, error) {
+	return cloud.Credentials{}, nil
+}
+
+func (c *Cloud) CreateInfrastructure(_ context.Context, _ string) error {
+	return nil
+}
+
+func (c *Cloud) DeleteInfrastructure(ctx context.Context, resourceID string) error {
+	instances, err := c.ListInstances(ctx, resourceID, nil)
+	if err != nil {
+		return err
+	}
+	return c.DeleteInstances(ctx, resourceID, instances)
+}
+
+// CreateInstances creates instances with unique private addresses in 10.0.0.0/16.
+// Public addresses are loopback addresses, so that the connections to the databases fail immediately.
+func (c *Cloud) CreateInstances(_ context.Context, resourceID string, size int64, labels map[string]string) ([]cloud.Instance, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	labels = utils.MapMerge(labels, map[string]string{
+		resource.LabelKeyResourceID: resourceID,
+	})
+	instances := make([]cloud.Instance, 0, size)
+	for n := int64(0); n < size; n++ {
+		c.nextID++
+		i := &instance{
+			Instance: cloud.Instance{
+				PublicIpAddress:  publicAddress(c.nextID),
+				PrivateIpAddress: fmt.Sprintf("10.0.%d.%d", c.nextID/250, c.nextID%250+1),
+			},
+			labels: labels,
+			files:  make(map[string][]byte),
+		}
+		c.instances = append(c.instances, i)
+		instances = append(instances, i.Instance)
+	}
+	return instances, nil
+}
+
+// NextAddresses returns the public addresses of the next n instances, so that servers can listen on them before the instances are created.
+func (c *Cloud) NextAddresses(n int) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	addresses := make([]string, 0, n)
+	for id := c.nextID + 1; id <= c.nextID+n; id++ {
+		addresses = append(addresses, publicAddress(id))
+	}
+	return addresses
+}
+
+func (c *Cloud) ListInstances(_ context.Context, resourceID string, labels map[string]string) ([]cloud.Instance, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	labels = utils.MapMerge(labels, map[string]string{
+		resource.LabelKeyResourceID: resourceID,
+	})
+	var instances []cloud.Instance
+	for _, i := range c.instances {
+		if matchLabels(i.labels, labels) {
+			instances = append(instances, i.Instance)
+		}
+	}
+	return instances, nil
+}
+
+func (c *Cloud) DeleteInstances(_ context.Context, resourceID string, instances []cloud.Instance) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	deleted := make(map[cloud.Instance]struct{}, len(instances))
+	for _, inst := range instances {
+		i := c.find(inst)
+		if i == nil || i.labels[resource.LabelKeyResourceID] != resourceID {
+			return errors.Errorf("instance %s of resource %s not found", inst.PrivateIpAddress, resourceID)
+		}
+		deleted[inst] = struct{}{}
+	}
+	remaining := c.instances[:0]
+	for _, i := range c.instances {
+		if _, ok := deleted[i.Instance]; !ok {
+			remaining = append(remaining, i)
+		}
+	}
+	c.instances = remaining
+	return nil
+}
+
+func (c *Cloud) RunCommand(_ context.Context, _ string, inst cloud.Instance, cmd string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := c.call(MethodRunCommand, inst, cmd); err != nil {
+		return "", err
+	}
+	for _, o := range c.outputs {
+		if strings.Contains(cmd, o.match) {
+			return o.output, nil
+		}
+	}
+	return "", nil
+}
+
+func (c *Cloud) SendFile(_ context.Context, _ string, inst cloud.Instance, file io.Reader, remotePath string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	i, err := c.call(MethodSendFile, inst, remotePath)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return errors.Wrap(err, "failed to read file")
+	}
+	i.files[remotePath] = data
+	return nil
+}
+
+// EditFile edits the file in place, missing files are edited as empty ones.
+func (c *Cloud) EditFile(_ context.Context, _ string, inst cloud.Instance, path string, editFunc func(io.ReadWriteSeeker) error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	i, err := c.call(MethodEditFile, inst, path)
+	if err != nil {
+		return err
+	}
+	f := &file{data: append([]byte(nil), i.files[path]...)}
+	if err := editFunc(f); err != nil {
+		return errors.Wrap(err, "failed to edit file")
+	}
+	i.files[path] = f.data
+	return nil
+}
+
+// call records the call and returns the instance or the injected failure, c.mu should be locked.
+// publicAddress returns the loopback address of the instance, databases of the instances can listen on it.
+func publicAddress(id int) string {
+	return fmt.Sprintf("127.0.%d.%d", id/250, id%250+1)
+}
+
+func (c *Cloud) call(method string, inst cloud.Instance, arg string) (*instance, error) {
+	c.calls = append(c.calls, Call{Method: method, Instance: inst, Arg: arg})
+	i := c.find(inst)
+	if i == nil {
+		return nil, errors.Errorf("instance %s not found", inst.PrivateIpAddress)
+	}
+	if c.failAfter >= 0 {
+		if c.failAfter == 0 {
+			return nil, c.failAfterErr
+		}
+		c.failAfter--
+	}
+	for _, f := range c.failures {
+		if strings.Contains(arg, f.match) {
+			return nil, f.err
+		}
+	}
+	return i, nil
+}
+
+func (c *Cloud) find(inst cloud.Instance) *instance {
+	for _, i := range c.instances {
+		if i.PrivateIpAddress == inst.PrivateIpAddress {
+			return i
+		}
+	}
+	return nil
+}
+
+func matchLabels(labels, required map[string]string) bool {
+	for k, v := range required {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// file is an in-memory io.ReadWriteSeeker, which can be truncated like an sftp file.
+type file struct {
+	data   []byte
+	offset int64
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	if f.offset >= int64(len(f.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, f.data[f.offset:])
+	f.offset += int64(n)
+	return n, nil
+}
+
+func (f *file) Write(p []byte) (int, error) {
+	end := f.offset + int64(len(p))
+	if end > int64(len(f.data)) {
+		f.data = append(f.data, make([]byte, end-int64(len(f.data)))...)
+	}
+	copy(f.data[f.offset:], p)
+	f.offset = end
+	return len(p), nil
+}
+
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = f.offset + offset
+	case io.SeekEnd:
+		abs = int64(len(f.data)) + offset
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if abs < 0 {
+		return 0, errors.New("negative position")
+	}
+	f.offset = abs
+	return abs, nil
+}
+
+func (f *file) Truncate(size int64) error {
+	if size < int64(len(f.data)) {
+		f.data = f.data[:size]
+		return nil
+	}
+	f.data = append(f.data, bytes.Repeat([]byte{0}, int(size)-len(f.data))...)
+	return nil
+}
@@ -19,6 +19,7 @@ import (
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/pkg/errors"
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/oauth2"
 	"golang.org/x/sync/errgroup"
 	"google.golang.org/api/googleapi"
 	"google.golang.org/api/iterator"
@@ -33,6 +34,10 @@ type Cloud struct {
 	Project string
 	Region  string
 	Zone    string
+	// CredentialsFile is the path to or the JSON content of the service account key or other credentials file
+	CredentialsFile           string
+	AccessToken               string
+	ImpersonateServiceAccount string
 
 	Meta cloud.Metadata
 
@@ -43,6 +48,9 @@ type Cloud struct {
 		Firewalls   *compute.FirewallsClient
 	}
 
+	ts     oauth2.TokenSource
+	authMu sync.Mutex
+
 	configs   map[string]*resourceConfig
 	configsMu sync.Mutex
 	infraMu   sync.Mutex
@@ -100,8 +108,11 @@ func (c *Cloud) Configure(ctx context.Context, resourceID string, data *schema.R
 		cfg.subnetwork = "default"
 	}
 
-	var err error
-	c.client.Instances, err = compute.NewInstancesRESTClient(ctx)
+	opt, err := c.clientOption(ctx)
+	if err != nil {
+		return err
+	}
+	c.client.Instances, err = compute.NewInstancesRESTClient(ctx, opt)
 	if err != nil {
 		return errors.Wrap(err, "failed to create instances client")
 	}
@@ -110,7 +121,7 @@ func (c *Cloud) Configure(ctx context.Context, resourceID string, data *schema.R
 			tflog.Error(ctx, "failed to close instances client")
 		}
 	})
-	c.client.Networks, err = compute.NewNetworksRESTClient(ctx)
+	c.client.Networks, err = compute.NewNetworksRESTClient(ctx, opt)
 	if err != nil {
 		return errors.Wrap(err, "failed to create networks client")
 	}
@@ -119,7 +130,7 @@ func (c *Cloud) Configure(ctx context.Context, resourceID string, data *schema.R
 			tflog.Error(ctx, "failed to close networks client")
 		}
 	})
-	c.client.Subnetworks, err = compute.NewSubnetworksRESTClient(ctx)
+	c.client.Subnetworks, err = compute.NewSubnetworksRESTClient(ctx, opt)
 	if err != nil {
 		return errors.Wrap(err, "failed to create subnetworks client")
 	}
@@ -128,7 +139,7 @@ func (c *Cloud) Configure(ctx context.Context, resourceID string, data *schema.R
 			tflog.Error(ctx, "failed to close subnetworks client")
 		}
 	})
-	c.client.Firewalls, err = compute.NewFirewallsRESTClient(ctx)
+	c.client.Firewalls, err = compute.NewFirewallsRESTClient(ctx, opt)
 	if err != nil {
 		return errors.Wrap(err, "failed to create firewalls client")
 	}
@@ -148,7 +159,7 @@ func (c *Cloud) createVPCIfNotExists(ctx context.Context, cfg *resourceConfig) e
 	if err != nil {
 		var gerr *googleapi.Error
 		if ok := errors.As(err, &gerr); (ok && gerr.Code != http.StatusNotFound) || !ok {
-			return errors.Wrapf(err, "failed to get vpc %b", ok)
+			return errors.Wrap(err, "failed to get vpc")
 		}
 	}
 	if vpc != nil {
@@ -177,7 +188,11 @@ func (c *Cloud) createVPCIfNotExists(ctx context.Context, cfg *resourceConfig) e
 }
 
 func (c *Cloud) sourceImageURI(ctx context.Context) (string, error) {
-	cli, err := compute.NewImagesRESTClient(ctx)
+	opt, err := c.clientOption(ctx)
+	if err != nil {
+		return "", err
+	}
+	cli, err := compute.NewImagesRESTClient(ctx, opt)
 	if err != nil {
 		return "", errors.Wrap(err, "new image rest client")
 	}
@@ -224,6 +239,15 @@ func (c *Cloud) CreateInstances(ctx context.Context, resourceID string, size int
 		resource.LabelKeyResourceID: resourceID,
 	})
 
+	existingInstances, err := c.listInstances(ctx, resourceID, labels)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list instances")
+	}
+	existingNames := make(map[string]struct{}, len(existingInstances))
+	for _, instance := range existingInstances {
+		existingNames[instance.GetName()] = struct{}{}
+	}
+
 	op, err := c.client.Instances.BulkInsert(ctx, &computepb.BulkInsertInstanceRequest{
 		BulkInsertInstanceResourceResource: &computepb.BulkInsertInstanceResource{
 			Count: utils.Ref(size),
@@ -282,15 +306,27 @@ func (c *Cloud) CreateInstances(ctx context.Context, resourceID string, size int
 	if err := c.waitUntilAllInstancesAreReady(ctx, resourceID, labels); err != nil {
 		return nil, errors.Wrap(err, "failed to wait instances")
 	}
-	instances, err := c.ListInstances(ctx, resourceID, labels)
+	pbInstances, err := c.listInstances(ctx, resourceID, labels)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to list instances")
 	}
+	var instances []cloud.Instance
+	for _, instance := range pbInstances {
+		if _, ok := existingNames[instance.GetName()]; ok {
+			continue
+		}
+		instances = append(instances, toCloudInstance(instance))
+	}
 	return instances, nil
 }
 
+// Bulk insert continues numbering after the biggest existing instance number matching the pattern,
+// so the same pattern is used when the cluster is scaled out. Two digits allow up to 99 instances in a cluster.
+// Instances of clusters created with the single-digit pattern instance-<id>-# keep their names:
+// the generated names have two digits, e.g. instance-<id>-01, so they never collide with instance-<id>-1,
+// whether or not the existing names are counted by the pattern. New instances are told apart from existing ones by name.
 func instanceNamePattern(resourceID string) string {
-	return fmt.Sprintf("instance-%s-#", resourceID)
+	return fmt.Sprintf("instance-%s-##", resourceID)
 }
 
 func (c *Cloud) waitUntilAllInstancesAreReady(ctx context.Context, resourceID string, labels map[string]string) error {
@@ -379,16 +415,68 @@ func (c *Cloud) ListInstances(ctx context.Context, resourceID string, labels map
 		return nil, err
 	}
 	for _, instance := range pbInstances {
-		instances = append(instances, cloud.Instance{
-			PrivateIpAddress: *instance.NetworkInterfaces[0].NetworkIP,
-			PublicIpAddress:  *instance.NetworkInterfaces[0].AccessConfigs[0].NatIP,
-		})
+		instances = append(instances, toCloudInstance(instance))
 	}
 	return instances, nil
 }
 
+func toCloudInstance(instance *computepb.Instance) cloud.Instance {
+	var cloudInstance cloud.Instance
+	if len(instance.NetworkInterfaces) > 0 {
+		networkInterface := instance.NetworkInterfaces[0]
+		cloudInstance.PrivateIpAddress = networkInterface.GetNetworkIP()
+		if len(networkInterface.AccessConfigs) > 0 {
+			cloudInstance.PublicIpAddress = networkInterface.AccessConfigs[0].GetNatIP()
+		}
+	}
+	return cloudInstance
+}
+
+func (c *Cloud) DeleteInstances(ctx context.Context, resourceID string, instances []cloud.Instance) error {
+	if len(instances) == 0 {
+		return nil
+	}
+	pbInstances, err := c.listInstances(ctx, resourceID, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to list instances")
+	}
+	privateIPs := make(map[string]struct{}, len(instances))
+	for _, instance := range instances {
+		privateIPs[instance.PrivateIpAddress] = struct{}{}
+	}
+	var toDelete []*computepb.Instance
+	for _, instance := range pbInstances {
+		if _, ok := privateIPs[toCloudInstance(instance).PrivateIpAddress]; ok {
+			toDelete = append(toDelete, instance)
+		}
+	}
+	if len(toDelete) != len(instances) {
+		return errors.Errorf("found %d of %d instances to delete", len(toDelete), len(instances))
+	}
+	g, gCtx := errgroup.WithContext(ctx)
+	for _, instance := range toDelete {
+		instance := instance
+		g.Go(func() error {
+			op, err := c.client.Instances.Delete(gCtx, &computepb.DeleteInstanceRequest{
+				Instance: instance.GetName(),
+				Project:  c.Project,
+				Zone:     c.Zone,
+			})
+			if err != nil {
+				return errors.Wrapf(err, "delete %s instance", instance.GetName())
+			}
+			if err = op.Wait(gCtx); err != nil {
+				return errors.Wrapf(err, "wait %s instance deletion", instance.GetName())
+			}
+			return nil
+		})
+	}
+	return g.Wait()
+}
+
 func (c *Cloud) CreateInfrastructure(ctx context.Context, resourceID string) error {
 	c.infraMu.Lock()
+	defer c.infraMu.Unlock()
 
 	sshKeyPath, err := c.keyPairPath(resourceID)
 	if err != nil {
@@ -409,13 +497,15 @@ func (c *Cloud) CreateInfrastructure(ctx context.Context, resourceID string) err
 	if err = c.createFirewallIfNotExists(ctx, cfg.vpcName+"-allow-all", cfg.vpcName); err != nil {
 		return errors.Wrap(err, "failed to create firewall")
 	}
-
-	c.infraMu.Unlock()
 	return nil
 }
 
 func (c *Cloud) createFirewallIfNotExists(ctx context.Context, firewallName, vpcName string) error {
-	client, err := compute.NewFirewallsRESTClient(ctx)
+	opt, err := c.clientOption(ctx)
+	if err != nil {
+		return err
+	}
+	client, err := compute.NewFirewallsRESTClient(ctx, opt)
 	if err != nil {
 		return errors.Wrap(err, "failed to create instances client")
 	}
@@ -461,7 +551,11 @@ func (c *Cloud) createFirewallIfNotExists(ctx context.Context, firewallName, vpc
 }
 
 func (c *Cloud) createSubnetworkIfNotExists(ctx context.Context, subnetworkName, vpcName string) error {
-	client, err := compute.NewSubnetworksRESTClient(ctx)
+	opt, err := c.clientOption(ctx)
+	if err != nil {
+		return err
+	}
+	client, err := compute.NewSubnetworksRESTClient(ctx, opt)
 	if err != nil {
 		return errors.Wrap(err, "failed to create subnetworks client")
 	}
@@ -535,9 +629,19 @@ func (c *Cloud) EditFile(ctx context.Context, resourceID string, instance cloud.
 	return utils.EditFile(ctx, instance.PublicIpAddress, path, sshConfig, editFunc)
 }
 
+// Credentials returns the token source of the provider credentials with the region and the project.
+// The access token takes precedence over the credentials file, which is either a path or the JSON content, application default credentials
+// are used if neither is set. Tokens are issued for the impersonated service account if it's set, the project of the credentials is used if the project isn't set.
 func (c *Cloud) Credentials() (cloud.Credentials, error) {
-	// TODO
-	return cloud.Credentials{}, errors.New("not implemented")
+	ts, err := c.tokenSource(context.Background())
+	if err != nil {
+		return cloud.Credentials{}, errors.Wrap(err, "failed to get credentials")
+	}
+	return cloud.Credentials{
+		TokenSource: ts,
+		Region:      c.Region,
+		Project:     c.Project,
+	}, nil
 }
 
 func (c *Cloud) DeleteInfrastructure(ctx context.Context, resourceID string) error {
@@ -0,0 +1,91 @@
+package gcp
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/impersonate"
+	"google.golang.org/api/option"
+)
+
+const cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+
+// tokenSource returns the token source shared by all clients of the cloud, it's created on the first call.
+// The access token takes precedence over the credentials file, application default credentials are used if neither is set.
+// The project of the credentials is used if the project isn't set.
+func (c *Cloud) tokenSource(ctx context.Context) (oauth2.TokenSource, error) {
+	c.authMu.Lock()
+	defer c.authMu.Unlock()
+	if c.ts != nil {
+		return c.ts, nil
+	}
+
+	var ts oauth2.TokenSource
+	switch {
+	case c.AccessToken != "":
+		ts = oauth2.StaticTokenSource(&oauth2.Token{AccessToken: c.AccessToken})
+	case c.CredentialsFile != "":
+		data, err := credentialsJSON(c.CredentialsFile)
+		if err != nil {
+			return nil, err
+		}
+		// The context is kept by the token source to refresh tokens, so it shouldn't be canceled with the request
+		creds, err := google.CredentialsFromJSON(context.Background(), data, cloudPlatformScope)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse gcp credentials")
+		}
+		ts = creds.TokenSource
+		if c.Project == "" {
+			c.Project = creds.ProjectID
+		}
+	default:
+		creds, err := google.FindDefaultCredentials(context.Background(), cloudPlatformScope)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to find default gcp credentials")
+		}
+		ts = creds.TokenSource
+		if c.Project == "" {
+			c.Project = creds.ProjectID
+		}
+	}
+
+	if c.ImpersonateServiceAccount != "" {
+		var err error
+		ts, err = impersonate.CredentialsTokenSource(context.Background(), impersonate.CredentialsConfig{
+			TargetPrincipal: c.ImpersonateServiceAccount,
+			Scopes:          []string{cloudPlatformScope},
+		}, option.WithTokenSource(ts))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to impersonate %s service account", c.ImpersonateServiceAccount)
+		}
+	}
+	if c.Project == "" {
+		return nil, errors.New("project is required for gcp cloud")
+	}
+	c.ts = ts
+	return ts, nil
+}
+
+// credentialsJSON returns the content of the credentials file, v is either the path to the file or its content.
+func credentialsJSON(v string) ([]byte, error) {
+	if json.Valid([]byte(v)) {
+		return []byte(v), nil
+	}
+	data, err := os.ReadFile(v)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read gcp credentials file")
+	}
+	return data, nil
+}
+
+func (c *Cloud) clientOption(ctx context.Context) (option.ClientOption, error) {
+	ts, err := c.tokenSource(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return option.WithTokenSource(ts), nil
+}
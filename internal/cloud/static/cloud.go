@@ -0,0 +1,351 @@
+package static
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/sync/errgroup"
+
+	"terraform-percona/internal/cloud"
+	"terraform-percona/internal/resource"
+	"terraform-percona/internal/utils"
+)
+
+// allocationPath is the file on the host which keeps the labels of the instance the host is allocated for,
+// so that the allocation is shared by all resources and survives provider restarts.
+const allocationPath = "/var/lib/percona-terraform/labels.json"
+
+const defaultUser = "ubuntu"
+
+// Host is an existing machine which is accessible over SSH on port 22 and has passwordless sudo.
+type Host struct {
+	Address        string
+	PrivateAddress string
+	User           string
+	KeyPath        string
+	// Labels restrict the instances the host can be allocated for, the host is used only for the instances which have all of the labels.
+	Labels map[string]string
+}
+
+// Cloud allocates instances from a static inventory of hosts instead of creating them.
+type Cloud struct {
+	Hosts []Host
+	// WipeOnDestroy removes the installed packages and the data from the hosts when they are released.
+	WipeOnDestroy bool
+
+	Meta cloud.Metadata
+
+	// runCommand runs the command on the host over ssh, utils.RunCommand is used if it's nil
+	runCommand func(ctx context.Context, cmd, host string, config *ssh.ClientConfig) (string, error)
+
+	allocMu sync.Mutex
+}
+
+// allocation is the labels of the instance the host is allocated for, labels of free hosts are nil.
+// err is set if the allocation of the host can't be read.
+type allocation struct {
+	labels map[string]string
+	err    error
+}
+
+func (c *Cloud) Metadata() cloud.Metadata {
+	return c.Meta
+}
+
+// Configure checks the inventory, resource attributes which describe the cloud instances are ignored.
+func (c *Cloud) Configure(_ context.Context, _ string, _ *schema.ResourceData) error {
+	if len(c.Hosts) == 0 {
+		return errors.New("static cloud requires at least one host")
+	}
+	seen := make(map[string]struct{}, len(c.Hosts))
+	for _, host := range c.Hosts {
+		if host.Address == "" {
+			return errors.New("host address is required")
+		}
+		if host.KeyPath == "" {
+			return errors.Errorf("ssh key path of host %s is required", host.Address)
+		}
+		if _, ok := seen[host.Address]; ok {
+			return errors.Errorf("host %s is specified more than once", host.Address)
+		}
+		seen[host.Address] = struct{}{}
+	}
+	return nil
+}
+
+// Credentials returns an error, as there is no cloud account behind the hosts.
+func (c *Cloud) Credentials() (cloud.Credentials, error) {
+	return cloud.Credentials{}, errors.New("static cloud has no cloud credentials")
+}
+
+func (c *Cloud) CreateInfrastructure(_ context.Context, _ string) error {
+	return nil
+}
+
+// DeleteInfrastructure releases all hosts allocated for the resource.
+// Nothing is released if some host is unreachable, as it can be allocated for the resource, unless errors on destroy are ignored.
+func (c *Cloud) DeleteInfrastructure(ctx context.Context, resourceID string) error {
+	instances, err := c.listInstances(ctx, resourceID, nil)
+	if err != nil {
+		if !c.Meta.IgnoreErrorsOnDestroy {
+			return errors.Wrap(err, "failed to list instances")
+		}
+		tflog.Error(ctx, "failed to list instances, releasing the reachable hosts", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	for _, instance := range instances {
+		if err := c.release(ctx, instance); err != nil {
+			if !c.Meta.IgnoreErrorsOnDestroy {
+				return errors.Wrapf(err, "release host %s", instance.PublicIpAddress)
+			}
+			tflog.Error(ctx, "failed to release host", map[string]interface{}{
+				"host": instance.PublicIpAddress, "error": err.Error(),
+			})
+		}
+	}
+	return nil
+}
+
+// CreateInstances allocates free hosts which match the labels.
+func (c *Cloud) CreateInstances(ctx context.Context, resourceID string, size int64, labels map[string]string) ([]cloud.Instance, error) {
+	labels = utils.MapMerge(labels, map[string]string{
+		resource.LabelKeyResourceID: resourceID,
+	})
+	allocation, err := json.Marshal(labels)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal labels")
+	}
+
+	c.allocMu.Lock()
+	defer c.allocMu.Unlock()
+	allocations, err := c.allocations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var free []Host
+	for i, host := range c.Hosts {
+		// Unreachable hosts are not free, as they can be allocated for other instances
+		if allocations[i].err == nil && allocations[i].labels == nil && matchLabels(labels, host.Labels) {
+			free = append(free, host)
+		}
+	}
+	if int64(len(free)) < size {
+		return nil, errors.Errorf("%d instances requested, but only %d free hosts match labels %v", size, len(free), labels)
+	}
+
+	instances := make([]cloud.Instance, 0, size)
+	for _, host := range free[:size] {
+		instance := host.instance()
+		_, err := c.RunCommand(ctx, resourceID, instance, fmt.Sprintf(`
+			set -o errexit
+			sudo mkdir -p %s
+			echo '%s' | sudo tee %s > /dev/null
+		`, path.Dir(allocationPath), allocation, allocationPath))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to allocate host %s", host.Address)
+		}
+		instances = append(instances, instance)
+	}
+	return instances, nil
+}
+
+// ListInstances returns the hosts allocated for the resource which have the labels.
+// It fails if some host is unreachable, as the host can be allocated for the resource and a shorter list would shrink the cluster.
+func (c *Cloud) ListInstances(ctx context.Context, resourceID string, labels map[string]string) ([]cloud.Instance, error) {
+	instances, err := c.listInstances(ctx, resourceID, labels)
+	if err != nil {
+		return nil, err
+	}
+	return instances, nil
+}
+
+// listInstances returns the reachable hosts allocated for the resource along with the error which lists the unreachable hosts.
+func (c *Cloud) listInstances(ctx context.Context, resourceID string, labels map[string]string) ([]cloud.Instance, error) {
+	labels = utils.MapMerge(labels, map[string]string{
+		resource.LabelKeyResourceID: resourceID,
+	})
+	allocations, err := c.allocations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var instances []cloud.Instance
+	var unreachable []string
+	for i, host := range c.Hosts {
+		switch {
+		case allocations[i].err != nil:
+			tflog.Warn(ctx, "host is unreachable", map[string]interface{}{
+				"host": host.Address, "error": allocations[i].err.Error(),
+			})
+			unreachable = append(unreachable, host.Address)
+		case allocations[i].labels != nil && matchLabels(allocations[i].labels, labels):
+			instances = append(instances, host.instance())
+		}
+	}
+	if len(unreachable) > 0 {
+		return instances, errors.Errorf("hosts %s are unreachable, their allocation can't be checked", strings.Join(unreachable, ", "))
+	}
+	return instances, nil
+}
+
+// DeleteInstances releases the hosts.
+func (c *Cloud) DeleteInstances(ctx context.Context, resourceID string, instances []cloud.Instance) error {
+	allocated, err := c.ListInstances(ctx, resourceID, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to list instances")
+	}
+	addresses := make(map[string]struct{}, len(allocated))
+	for _, instance := range allocated {
+		addresses[instance.PublicIpAddress] = struct{}{}
+	}
+	for _, instance := range instances {
+		if _, ok := addresses[instance.PublicIpAddress]; !ok {
+			return errors.Errorf("host %s is not allocated for resource %s", instance.PublicIpAddress, resourceID)
+		}
+	}
+	for _, instance := range instances {
+		if err := c.release(ctx, instance); err != nil {
+			return errors.Wrapf(err, "release host %s", instance.PublicIpAddress)
+		}
+	}
+	return nil
+}
+
+func (c *Cloud) RunCommand(ctx context.Context, _ string, instance cloud.Instance, cmd string) (string, error) {
+	sshConfig, err := c.sshConfig(instance)
+	if err != nil {
+		return "", err
+	}
+	if c.runCommand != nil {
+		return c.runCommand(ctx, cmd, instance.PublicIpAddress, sshConfig)
+	}
+	return utils.RunCommand(ctx, cmd, instance.PublicIpAddress, sshConfig)
+}
+
+func (c *Cloud) SendFile(ctx context.Context, _ string, instance cloud.Instance, file io.Reader, remotePath string) error {
+	sshConfig, err := c.sshConfig(instance)
+	if err != nil {
+		return err
+	}
+	return utils.SendFile(ctx, file, remotePath, instance.PublicIpAddress, sshConfig)
+}
+
+func (c *Cloud) EditFile(ctx context.Context, _ string, instance cloud.Instance, path string, editFunc func(io.ReadWriteSeeker) error) error {
+	sshConfig, err := c.sshConfig(instance)
+	if err != nil {
+		return err
+	}
+	return utils.EditFile(ctx, instance.PublicIpAddress, path, sshConfig, editFunc)
+}
+
+// allocations returns the allocation of each host, in the order of the inventory.
+// Unreachable hosts are returned with the error, so that they are neither free nor silently left out of the instances.
+func (c *Cloud) allocations(ctx context.Context) ([]allocation, error) {
+	allocations := make([]allocation, len(c.Hosts))
+	g, gCtx := errgroup.WithContext(ctx)
+	for i, host := range c.Hosts {
+		i, host := i, host
+		g.Go(func() error {
+			out, err := c.RunCommand(gCtx, "", host.instance(), fmt.Sprintf("sudo cat %s 2>/dev/null || true", allocationPath))
+			if err != nil {
+				allocations[i].err = err
+				return nil
+			}
+			out = strings.TrimSpace(out)
+			if out == "" {
+				return nil
+			}
+			labels := make(map[string]string)
+			if err := json.Unmarshal([]byte(out), &labels); err != nil {
+				return errors.Wrapf(err, "failed to parse %s on host %s", allocationPath, host.Address)
+			}
+			allocations[i].labels = labels
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return allocations, nil
+}
+
+// release wipes the host if WipeOnDestroy is set and makes it free for other instances.
+func (c *Cloud) release(ctx context.Context, instance cloud.Instance) error {
+	if c.WipeOnDestroy {
+		if _, err := c.RunCommand(ctx, "", instance, wipe()); err != nil {
+			return errors.Wrap(err, "failed to wipe host")
+		}
+	}
+	if _, err := c.RunCommand(ctx, "", instance, fmt.Sprintf("sudo rm -f %s", allocationPath)); err != nil {
+		return errors.Wrap(err, "failed to remove allocation")
+	}
+	return nil
+}
+
+// wipe stops the services installed by the provider and removes their packages, configs and data.
+func wipe() string {
+	return `
+	#!/usr/bin/env bash
+	sudo pmm-admin unregister --force 2>/dev/null
+	for service in mysql@bootstrap.service mysql orchestrator; do
+		sudo systemctl stop "$service" 2>/dev/null
+	done
+	if command -v docker > /dev/null; then
+		sudo docker rm -f pmm-server 2>/dev/null
+	fi
+	DEBIAN_FRONTEND=noninteractive sudo -E apt-get purge -y 'percona-*' orchestrator orchestrator-client pmm2-client 2>/dev/null
+	sudo rm -rf /var/lib/mysql /var/log/mysql /etc/mysql /var/lib/orchestrator /etc/orchestrator.conf.json /opt/percona /pmm
+	true
+	`
+}
+
+func (c *Cloud) sshConfig(instance cloud.Instance) (*ssh.ClientConfig, error) {
+	for _, host := range c.Hosts {
+		if host.Address != instance.PublicIpAddress {
+			continue
+		}
+		sshConfig, err := utils.SSHConfig(host.user(), host.KeyPath)
+		if err != nil {
+			return nil, errors.Wrapf(err, "get ssh config of host %s", host.Address)
+		}
+		return sshConfig, nil
+	}
+	return nil, errors.Errorf("host %s is not in the inventory", instance.PublicIpAddress)
+}
+
+func (h Host) instance() cloud.Instance {
+	privateAddress := h.PrivateAddress
+	if privateAddress == "" {
+		privateAddress = h.Address
+	}
+	return cloud.Instance{
+		PublicIpAddress:  h.Address,
+		PrivateIpAddress: privateAddress,
+	}
+}
+
+func (h Host) user() string {
+	if h.User == "" {
+		return defaultUser
+	}
+	return h.User
+}
+
+// matchLabels reports whether labels contain all of the required labels.
+func matchLabels(labels, required map[string]string) bool {
+	for k, v := range required {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,191 @@
+package static
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+
+	"terraform-percona/internal/cloud"
+	"terraform-percona/internal/resource"
+)
+
+var allocateCommand = regexp.MustCompile(`echo '(.*)' \| sudo tee`)
+
+// hosts emulates the allocation files of the hosts, the commands run on unreachable hosts fail.
+type hosts struct {
+	mu          sync.Mutex
+	files       map[string]string
+	unreachable map[string]bool
+}
+
+func (h *hosts) runCommand(_ context.Context, cmd, host string, _ *ssh.ClientConfig) (string, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.unreachable[host] {
+		return "", errors.New("ssh dial: connection refused")
+	}
+	switch {
+	case strings.HasPrefix(cmd, "sudo cat "+allocationPath):
+		return h.files[host], nil
+	case strings.HasPrefix(cmd, "sudo rm -f "+allocationPath):
+		delete(h.files, host)
+	case allocateCommand.MatchString(cmd):
+		h.files[host] = allocateCommand.FindStringSubmatch(cmd)[1]
+	}
+	return "", nil
+}
+
+func newTestCloud(t *testing.T, inventory ...Host) (*Cloud, *hosts) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(t.TempDir(), "id_rsa")
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := os.WriteFile(keyPath, keyPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for i := range inventory {
+		inventory[i].KeyPath = keyPath
+	}
+	h := &hosts{files: make(map[string]string), unreachable: make(map[string]bool)}
+	c := &Cloud{Hosts: inventory, runCommand: h.runCommand}
+	if err := c.Configure(context.Background(), "", nil); err != nil {
+		t.Fatal(err)
+	}
+	return c, h
+}
+
+func addresses(instances []cloud.Instance) []string {
+	var addresses []string
+	for _, instance := range instances {
+		addresses = append(addresses, instance.PublicIpAddress)
+	}
+	sort.Strings(addresses)
+	return addresses
+}
+
+// TestCreateInstances allocates the free hosts which match the labels, hosts with labels are used only for the instances with them.
+func TestCreateInstances(t *testing.T) {
+	ctx := context.Background()
+	c, _ := newTestCloud(t,
+		Host{Address: "192.0.2.1", Labels: map[string]string{resource.LabelKeyInstanceType: resource.LabelValueInstanceTypeOrchestrator}},
+		Host{Address: "192.0.2.2"},
+		Host{Address: "192.0.2.3"},
+	)
+	mysqlLabels := map[string]string{resource.LabelKeyInstanceType: resource.LabelValueInstanceTypeMySQL}
+	orchestratorLabels := map[string]string{resource.LabelKeyInstanceType: resource.LabelValueInstanceTypeOrchestrator}
+
+	instances, err := c.CreateInstances(ctx, "a", 2, mysqlLabels)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(addresses(instances), ","); got != "192.0.2.2,192.0.2.3" {
+		t.Errorf("mysql instances are allocated on %s, want 192.0.2.2,192.0.2.3", got)
+	}
+	instances, err = c.CreateInstances(ctx, "a", 1, orchestratorLabels)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(addresses(instances), ","); got != "192.0.2.1" {
+		t.Errorf("orchestrator instance is allocated on %s, want 192.0.2.1", got)
+	}
+	if _, err := c.CreateInstances(ctx, "b", 1, nil); err == nil {
+		t.Error("instance is allocated on a host which is already allocated")
+	}
+
+	for _, tt := range []struct {
+		labels map[string]string
+		want   string
+	}{
+		{mysqlLabels, "192.0.2.2,192.0.2.3"},
+		{orchestratorLabels, "192.0.2.1"},
+	} {
+		instances, err := c.ListInstances(ctx, "a", tt.labels)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Join(addresses(instances), ","); got != tt.want {
+			t.Errorf("ListInstances(%v) = %s, want %s", tt.labels, got, tt.want)
+		}
+	}
+	if instances, err := c.ListInstances(ctx, "b", nil); err != nil || len(instances) != 0 {
+		t.Errorf("ListInstances() of another resource = %v, %v, want no instances", instances, err)
+	}
+}
+
+// TestDeleteInstancesOfAnotherResource refuses to release the hosts which aren't allocated for the resource.
+func TestDeleteInstancesOfAnotherResource(t *testing.T) {
+	ctx := context.Background()
+	c, h := newTestCloud(t, Host{Address: "192.0.2.1"}, Host{Address: "192.0.2.2"})
+	a, err := c.CreateInstances(ctx, "a", 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := c.CreateInstances(ctx, "b", 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DeleteInstances(ctx, "a", append(a, b...)); err == nil || !strings.Contains(err.Error(), "is not allocated for resource a") {
+		t.Fatalf("DeleteInstances() error = %v, want not allocated error", err)
+	}
+	if len(h.files) != 2 {
+		t.Errorf("%d hosts are allocated after the refused delete, want 2", len(h.files))
+	}
+	if err := c.DeleteInstances(ctx, "a", a); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := h.files[b[0].PublicIpAddress]; !ok || len(h.files) != 1 {
+		t.Errorf("allocated hosts = %v, want only the host of resource b", h.files)
+	}
+}
+
+// TestUnreachableHost fails to list and release the instances if some host can't be checked, a shorter list would shrink the cluster.
+func TestUnreachableHost(t *testing.T) {
+	ctx := context.Background()
+	c, h := newTestCloud(t, Host{Address: "192.0.2.1"}, Host{Address: "192.0.2.2"}, Host{Address: "192.0.2.3"})
+	if _, err := c.CreateInstances(ctx, "a", 2, nil); err != nil {
+		t.Fatal(err)
+	}
+	h.unreachable["192.0.2.2"] = true
+
+	if _, err := c.ListInstances(ctx, "a", nil); err == nil || !strings.Contains(err.Error(), "192.0.2.2") {
+		t.Errorf("ListInstances() error = %v, want unreachable host error", err)
+	}
+	if err := c.DeleteInfrastructure(ctx, "a"); err == nil {
+		t.Error("DeleteInfrastructure() succeeded with an unreachable host")
+	}
+	if _, ok := h.files["192.0.2.1"]; !ok {
+		t.Error("host 192.0.2.1 is released although the infrastructure isn't deleted")
+	}
+
+	// Unreachable hosts are neither free nor used for new instances
+	instances, err := c.CreateInstances(ctx, "b", 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(addresses(instances), ","); got != "192.0.2.3" {
+		t.Errorf("instance is allocated on %s, want 192.0.2.3", got)
+	}
+
+	c.Meta.IgnoreErrorsOnDestroy = true
+	if err := c.DeleteInfrastructure(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := h.files["192.0.2.1"]; ok {
+		t.Error("reachable host 192.0.2.1 isn't released with ignored errors on destroy")
+	}
+}
@@ -5,4 +5,5 @@ const (
 	UserRoot         = "root"
 	UserPMM          = "pmm"
 	UserOrchestrator = "orchestrator"
+	UserAutomation   = "percona_automation"
 )
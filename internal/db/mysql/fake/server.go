@@ -0,0 +1,381 @@
+package fake
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"terraform-percona/internal/utils"
+)
+
+// Capability flags of the client/server protocol
+const (
+	clientLongPassword  = 0x1
+	clientLongFlag      = 0x4
+	clientConnectWithDB = 0x8
+	clientProtocol41    = 0x200
+	clientSSL           = 0x800
+	clientTransactions  = 0x2000
+	clientSecureConn    = 0x8000
+	clientMultiResults  = 0x20000
+	clientPluginAuth    = 0x80000
+)
+
+const (
+	comQuit  = 0x01
+	comQuery = 0x03
+	comPing  = 0x0e
+)
+
+const serverStatusAutocommit = 0x2
+
+// Query is a query run against a simulated server, Host is the address of the instance without the port.
+type Query struct {
+	Host string
+	SQL  string
+}
+
+// Server simulates the MySQL servers of the instances: it listens on the same port of the loopback addresses of all instances.
+// Queries are not interpreted: their result is the one set with OnQuery, OnHostQuery or FailOn, other queries succeed without rows.
+type Server struct {
+	port      int
+	listeners []net.Listener
+
+	mu      sync.Mutex
+	results []result
+	queries []Query
+	caCert  string
+	caKey   string
+	certs   map[string]tls.Certificate
+}
+
+type result struct {
+	// host limits the result to the queries of the instance, the result is used for all instances if it's empty
+	host    string
+	match   string
+	columns []string
+	rows    [][]string
+	errCode uint16
+	errMsg  string
+}
+
+// defaultResults are the results of the queries run by the driver and by version checks, queries set with OnQuery take precedence.
+var defaultResults = []result{
+	{match: "SELECT @@max_allowed_packet", columns: []string{"@@max_allowed_packet"}, rows: [][]string{{"67108864"}}},
+	{match: "SELECT @@version", columns: []string{"@@version"}, rows: [][]string{{"8.0.33-25"}}},
+}
+
+// New starts the server on the hosts, which should be loopback addresses, e.g. the public addresses of the instances of the fake cloud.
+// The port is chosen on the first host, Close should be called to stop the server.
+func New(hosts ...string) (*Server, error) {
+	s := &Server{certs: make(map[string]tls.Certificate)}
+	for _, host := range hosts {
+		l, err := net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(s.port)))
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+		s.port = l.Addr().(*net.TCPAddr).Port
+		s.listeners = append(s.listeners, l)
+		go s.accept(l, host)
+	}
+	return s, nil
+}
+
+// Port returns the port the server listens on.
+func (s *Server) Port() int {
+	return s.port
+}
+
+// Close stops accepting new connections.
+func (s *Server) Close() {
+	for _, l := range s.listeners {
+		_ = l.Close()
+	}
+}
+
+// TLS makes the server accept TLS connections with the certificate of the instance signed by the CA.
+func (s *Server) TLS(caCert, caKey string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.caCert = caCert
+	s.caKey = caKey
+}
+
+// OnQuery sets the rows returned by the queries which contain the match, the first match wins.
+func (s *Server) OnQuery(match string, columns []string, rows ...[]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results = append(s.results, result{match: match, columns: columns, rows: rows})
+}
+
+// OnHostQuery sets the rows returned by the queries of the instance with the host address which contain the match.
+func (s *Server) OnHostQuery(host, match string, columns []string, rows ...[]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results = append(s.results, result{host: host, match: match, columns: columns, rows: rows})
+}
+
+// FailOn makes the queries which contain the match fail with the MySQL error.
+func (s *Server) FailOn(match string, code uint16, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results = append(s.results, result{match: match, errCode: code, errMsg: message})
+}
+
+// Queries returns the queries run against all instances in the order they were received.
+func (s *Server) Queries() []Query {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Query(nil), s.queries...)
+}
+
+// HostQueries returns the queries run against the instance with the host address.
+func (s *Server) HostQueries(host string) []string {
+	var queries []string
+	for _, q := range s.Queries() {
+		if q.Host == host {
+			queries = append(queries, q.SQL)
+		}
+	}
+	return queries
+}
+
+func (s *Server) accept(l net.Listener, host string) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go s.serve(conn, host)
+	}
+}
+
+func (s *Server) serve(netConn net.Conn, host string) {
+	c := &conn{Conn: netConn}
+	defer func() {
+		_ = netConn.Close()
+	}()
+	if err := s.handshake(c, host); err != nil {
+		return
+	}
+	for {
+		c.seq = 0
+		packet, err := c.readPacket()
+		if err != nil || len(packet) == 0 {
+			return
+		}
+		switch packet[0] {
+		case comQuit:
+			// The client closes the connection, TLS connections send close_notify first
+			_, _ = io.Copy(io.Discard, c)
+			return
+		case comPing:
+			err = c.writeOK()
+		case comQuery:
+			err = s.query(c, host, string(packet[1:]))
+		default:
+			err = c.writeError(1047, "Unknown command")
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// handshake authenticates any user with mysql_native_password, the connection is upgraded to TLS if the client requests it.
+func (s *Server) handshake(c *conn, host string) error {
+	s.mu.Lock()
+	capabilities := uint32(clientLongPassword | clientLongFlag | clientConnectWithDB | clientProtocol41 |
+		clientTransactions | clientSecureConn | clientMultiResults | clientPluginAuth)
+	if s.caCert != "" {
+		capabilities |= clientSSL
+	}
+	s.mu.Unlock()
+
+	packet := []byte{10}
+	packet = append(packet, "8.0.33-25\x00"...)
+	packet = binary.LittleEndian.AppendUint32(packet, 1)
+	packet = append(packet, "01234567\x00"...)
+	packet = binary.LittleEndian.AppendUint16(packet, uint16(capabilities))
+	packet = append(packet, 33)
+	packet = binary.LittleEndian.AppendUint16(packet, serverStatusAutocommit)
+	packet = binary.LittleEndian.AppendUint16(packet, uint16(capabilities>>16))
+	packet = append(packet, 21)
+	packet = append(packet, make([]byte, 10)...)
+	packet = append(packet, "89abcdefghij\x00"...)
+	packet = append(packet, "mysql_native_password\x00"...)
+	if err := c.writePacket(packet); err != nil {
+		return err
+	}
+
+	response, err := c.readPacket()
+	if err != nil {
+		return err
+	}
+	// SSL request is the beginning of the handshake response, the rest of it is sent over TLS
+	if len(response) == 32 && binary.LittleEndian.Uint32(response)&clientSSL != 0 {
+		cert, err := s.certificate(host)
+		if err != nil {
+			return err
+		}
+		seq := c.seq
+		c.Conn = tls.Server(c.Conn, &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12})
+		c.seq = seq
+		if _, err := c.readPacket(); err != nil {
+			return err
+		}
+	}
+	return c.writeOK()
+}
+
+// certificate returns the certificate of the instance, it's issued once for each host.
+func (s *Server) certificate(host string) (tls.Certificate, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cert, ok := s.certs[host]; ok {
+		return cert, nil
+	}
+	certPEM, keyPEM, err := utils.GenerateCertificate(s.caCert, s.caKey, host, []string{host}, nil)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	cert, err := tls.X509KeyPair([]byte(certPEM), []byte(keyPEM))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	s.certs[host] = cert
+	return cert, nil
+}
+
+func (s *Server) query(c *conn, host, query string) error {
+	s.mu.Lock()
+	s.queries = append(s.queries, Query{Host: host, SQL: query})
+	res, ok := findResult(s.results, host, query)
+	s.mu.Unlock()
+	if !ok {
+		res, ok = findResult(defaultResults, host, query)
+	}
+	switch {
+	case !ok:
+		return c.writeOK()
+	case res.errCode != 0:
+		return c.writeError(res.errCode, res.errMsg)
+	default:
+		return c.writeResultSet(res.columns, res.rows)
+	}
+}
+
+func findResult(results []result, host, query string) (result, bool) {
+	for _, res := range results {
+		if (res.host == "" || res.host == host) && strings.Contains(query, res.match) {
+			return res, true
+		}
+	}
+	return result{}, false
+}
+
+// conn reads and writes the packets of the protocol, seq is the sequence id of the next packet.
+type conn struct {
+	net.Conn
+	seq byte
+}
+
+func (c *conn) readPacket() ([]byte, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(c, header); err != nil {
+		return nil, err
+	}
+	length := int(header[0]) | int(header[1])<<8 | int(header[2])<<16
+	c.seq = header[3] + 1
+	packet := make([]byte, length)
+	if _, err := io.ReadFull(c, packet); err != nil {
+		return nil, err
+	}
+	return packet, nil
+}
+
+func (c *conn) writePacket(packet []byte) error {
+	length := len(packet)
+	header := []byte{byte(length), byte(length >> 8), byte(length >> 16), c.seq}
+	c.seq++
+	_, err := c.Write(append(header, packet...))
+	return err
+}
+
+func (c *conn) writeOK() error {
+	packet := []byte{0x00, 0x00, 0x00}
+	packet = binary.LittleEndian.AppendUint16(packet, serverStatusAutocommit)
+	packet = binary.LittleEndian.AppendUint16(packet, 0)
+	return c.writePacket(packet)
+}
+
+func (c *conn) writeEOF() error {
+	packet := []byte{0xfe}
+	packet = binary.LittleEndian.AppendUint16(packet, 0)
+	packet = binary.LittleEndian.AppendUint16(packet, serverStatusAutocommit)
+	return c.writePacket(packet)
+}
+
+func (c *conn) writeError(code uint16, message string) error {
+	packet := []byte{0xff}
+	packet = binary.LittleEndian.AppendUint16(packet, code)
+	packet = append(packet, "#HY000"...)
+	packet = append(packet, message...)
+	return c.writePacket(packet)
+}
+
+// writeResultSet writes the rows in the text protocol, all columns are strings.
+func (c *conn) writeResultSet(columns []string, rows [][]string) error {
+	if err := c.writePacket(appendLengthEncoded(nil, uint64(len(columns)))); err != nil {
+		return err
+	}
+	for _, column := range columns {
+		var packet []byte
+		for _, s := range []string{"def", "", "", "", column, column} {
+			packet = appendString(packet, s)
+		}
+		packet = append(packet, 0x0c)
+		packet = binary.LittleEndian.AppendUint16(packet, 33)
+		packet = binary.LittleEndian.AppendUint32(packet, 1024)
+		// VAR_STRING type, no flags and decimals
+		packet = append(packet, 0xfd, 0x00, 0x00, 0x00, 0x00, 0x00)
+		if err := c.writePacket(packet); err != nil {
+			return err
+		}
+	}
+	if err := c.writeEOF(); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		var packet []byte
+		for _, value := range row {
+			packet = appendString(packet, value)
+		}
+		if err := c.writePacket(packet); err != nil {
+			return err
+		}
+	}
+	return c.writeEOF()
+}
+
+func appendString(b []byte, s string) []byte {
+	return append(appendLengthEncoded(b, uint64(len(s))), s...)
+}
+
+func appendLengthEncoded(b []byte, n uint64) []byte {
+	switch {
+	case n < 251:
+		return append(b, byte(n))
+	case n < 1<<16:
+		return append(b, 0xfc, byte(n), byte(n>>8))
+	case n < 1<<24:
+		return append(b, 0xfd, byte(n), byte(n>>8), byte(n>>16))
+	default:
+		return binary.LittleEndian.AppendUint64(append(b, 0xfe), n)
+	}
+}
@@ -2,36 +2,103 @@ package mysql
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
 	"database/sql"
+	"encoding/hex"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	mysql "github.com/go-sql-driver/mysql"
 	"github.com/pkg/errors"
 
 	internaldb "terraform-percona/internal/db"
+	"terraform-percona/internal/utils"
 )
 
+const (
+	GroupReplicationMemberStateOnline  = "ONLINE"
+	GroupReplicationMemberStateOffline = "OFFLINE"
+	GroupReplicationMemberRolePrimary  = "PRIMARY"
+)
+
+// ErrVariableNotDynamic is returned if the variable can't be changed at runtime and requires a restart.
+var ErrVariableNotDynamic = errors.New("variable can't be changed at runtime")
+
+// Errors returned by SET for unknown variables, invalid values and read-only variables, only the last ones are set in the config file.
+const (
+	errUnknownSystemVariable   = 1193
+	errWrongTypeForVar         = 1232
+	errIncorrectGlobalLocalVar = 1238
+)
+
+// errNonexistingGrant is returned by REVOKE if the privilege is not granted.
+const errNonexistingGrant = 1141
+
 type DB struct {
 	*sql.DB
 	cfg mysql.Config
+
+	version string
 }
 
 func NewClient(host, user, pass string) (*DB, error) {
-	db := &DB{
-		cfg: mysql.Config{
-			User:   user,
-			Passwd: pass,
-			Net:    "tcp",
-			Addr:   host,
-			Params: map[string]string{
-				"interpolateParams": "true",
-			},
-			Timeout:              time.Second * 135,
-			ReadTimeout:          time.Second * 135,
-			WriteTimeout:         time.Second * 135,
-			AllowNativePasswords: true,
+	return newClient(clientConfig(host, user, pass))
+}
+
+// NewTLSClient returns a client which connects over TLS and verifies the certificate of the server with the CA certificate.
+// The host in the address must be one of the names or addresses in the certificate.
+func NewTLSClient(host, user, pass string, caCert []byte) (*DB, error) {
+	tlsConfig, err := registerTLSConfig(caCert)
+	if err != nil {
+		return nil, err
+	}
+	cfg := clientConfig(host, user, pass)
+	cfg.TLSConfig = tlsConfig
+	return newClient(cfg)
+}
+
+func clientConfig(host, user, pass string) mysql.Config {
+	return mysql.Config{
+		User:   user,
+		Passwd: pass,
+		Net:    "tcp",
+		Addr:   host,
+		Params: map[string]string{
+			"interpolateParams": "true",
 		},
+		Timeout:              time.Second * 135,
+		ReadTimeout:          time.Second * 135,
+		WriteTimeout:         time.Second * 135,
+		AllowNativePasswords: true,
+	}
+}
+
+// registerTLSConfig registers the TLS config trusting the CA certificate in the driver and returns its name.
+// Configs are registered by the hash of the certificate, so the clients of the same cluster share the config.
+func registerTLSConfig(caCert []byte) (string, error) {
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(caCert) {
+		return "", errors.New("failed to parse ca certificate")
 	}
+	sum := sha256.Sum256(caCert)
+	name := "ca-" + hex.EncodeToString(sum[:])
+	err := mysql.RegisterTLSConfig(name, &tls.Config{
+		RootCAs:    roots,
+		MinVersion: tls.VersionTLS12,
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "register tls config")
+	}
+	return name, nil
+}
+
+func newClient(cfg mysql.Config) (*DB, error) {
+	db := &DB{cfg: cfg}
 	err := db.Open()
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to open connection")
@@ -48,36 +115,106 @@ func (db *DB) Open() error {
 	return nil
 }
 
-func (db *DB) InstallPerconaServerUDF(ctx context.Context) error {
-	_, err := db.ExecContext(ctx, "CREATE FUNCTION fnv1a_64 RETURNS INTEGER SONAME 'libfnv1a_udf.so'")
-	if err != nil {
-		return errors.Wrap(err, "create function fnv1a_64")
+// atLeast reports whether the server version is the same or newer than the given one.
+// The suffix of the server version is ignored, e.g. 8.4.0-1 is at least 8.4.0.
+func (db *DB) atLeast(ctx context.Context, version string) (bool, error) {
+	if db.version == "" {
+		v, err := db.Version(ctx)
+		if err != nil {
+			return false, err
+		}
+		db.version = v
 	}
-	_, err = db.ExecContext(ctx, "CREATE FUNCTION fnv_64 RETURNS INTEGER SONAME 'libfnv_udf.so'")
+	return utils.CompareVersions(db.version, version) >= 0, nil
+}
+
+// authPlugin returns the authentication plugin of the created users: mysql_native_password is disabled by default in 8.4
+// and removed in 9.0, so caching_sha2_password is used since 8.4. Older servers keep mysql_native_password,
+// so that replicas without TLS don't need the public key of the source.
+func (db *DB) authPlugin(ctx context.Context) (string, error) {
+	ok, err := db.atLeast(ctx, "8.4.0")
 	if err != nil {
-		return errors.Wrap(err, "create function fnv_64")
+		return "", errors.Wrap(err, "get server version")
 	}
-	_, err = db.ExecContext(ctx, "CREATE FUNCTION murmur_hash RETURNS INTEGER SONAME 'libmurmur_udf.so'")
+	if ok {
+		return "caching_sha2_password", nil
+	}
+	return "mysql_native_password", nil
+}
+
+// replicationSyntax returns the statement using SOURCE/REPLICA keywords, or MASTER/SLAVE ones for servers before 8.0.23.
+func (db *DB) replicationSyntax(ctx context.Context, query string) (string, error) {
+	ok, err := db.atLeast(ctx, "8.0.23")
 	if err != nil {
-		return errors.Wrap(err, "create function murmur_hash")
+		return "", errors.Wrap(err, "get server version")
 	}
-	return nil
+	if ok {
+		return query, nil
+	}
+	return strings.NewReplacer(
+		"REPLICATION SOURCE", "MASTER",
+		"SOURCE_", "MASTER_",
+		"REPLICA", "SLAVE",
+	).Replace(query), nil
 }
 
-func (db *DB) ChangeReplicationSource(ctx context.Context, sourceHost string, sourcePort int, sourceUser, sourcePassword string) error {
-	_, err := db.ExecContext(ctx, `CHANGE REPLICATION SOURCE TO SOURCE_HOST=?, SOURCE_PORT=?, SOURCE_USER=?, SOURCE_PASSWORD=?, SOURCE_AUTO_POSITION=1`, sourceHost, sourcePort, sourceUser, sourcePassword)
+func (db *DB) execReplication(ctx context.Context, query string, args ...any) error {
+	query, err := db.replicationSyntax(ctx, query)
 	if err != nil {
+		return err
+	}
+	if _, err := db.ExecContext(ctx, query, args...); err != nil {
 		return errors.Wrap(err, "exec")
 	}
 	return nil
 }
 
-func (db *DB) StartReplica(ctx context.Context) error {
-	_, err := db.ExecContext(ctx, "START REPLICA")
+// ChangeReplicationSource configures the replication channel, the replica connects to the source over TLS if ssl is set.
+// Without TLS the replica requests the public key of the source, as the replication user may use caching_sha2_password since 8.4.
+func (db *DB) ChangeReplicationSource(ctx context.Context, sourceHost string, sourcePort int, sourceUser, sourcePassword string, ssl bool) error {
+	if ssl {
+		return db.execReplication(ctx, `CHANGE REPLICATION SOURCE TO SOURCE_HOST=?, SOURCE_PORT=?, SOURCE_USER=?, SOURCE_PASSWORD=?, SOURCE_AUTO_POSITION=1, SOURCE_SSL=1`, sourceHost, sourcePort, sourceUser, sourcePassword)
+	}
+	publicKey, err := db.publicKeyOption(ctx)
 	if err != nil {
-		return errors.Wrap(err, "exec")
+		return err
 	}
-	return nil
+	return db.execReplication(ctx, `CHANGE REPLICATION SOURCE TO SOURCE_HOST=?, SOURCE_PORT=?, SOURCE_USER=?, SOURCE_PASSWORD=?, SOURCE_AUTO_POSITION=1, SOURCE_SSL=0`+publicKey, sourceHost, sourcePort, sourceUser, sourcePassword)
+}
+
+// ChangeReplicationSourceCredentials changes the credentials of the replication channel, replica should be stopped.
+// The public key of the source is requested, as the changed password switches the replication user to caching_sha2_password since 8.4.
+func (db *DB) ChangeReplicationSourceCredentials(ctx context.Context, sourceUser, sourcePassword string) error {
+	publicKey, err := db.publicKeyOption(ctx)
+	if err != nil {
+		return err
+	}
+	return db.execReplication(ctx, `CHANGE REPLICATION SOURCE TO SOURCE_USER=?, SOURCE_PASSWORD=?`+publicKey, sourceUser, sourcePassword)
+}
+
+// publicKeyOption returns the option of CHANGE REPLICATION SOURCE, which requests the public key of the source
+// for caching_sha2_password authentication without TLS, it's empty before 8.4, where users have mysql_native_password.
+func (db *DB) publicKeyOption(ctx context.Context) (string, error) {
+	plugin, err := db.authPlugin(ctx)
+	if err != nil {
+		return "", err
+	}
+	if plugin != "caching_sha2_password" {
+		return "", nil
+	}
+	return ", GET_SOURCE_PUBLIC_KEY=1", nil
+}
+
+func (db *DB) StartReplica(ctx context.Context) error {
+	return db.execReplication(ctx, "START REPLICA")
+}
+
+func (db *DB) StopReplica(ctx context.Context) error {
+	return db.execReplication(ctx, "STOP REPLICA")
+}
+
+func (db *DB) ResetReplicaAll(ctx context.Context) error {
+	return db.execReplication(ctx, "RESET REPLICA ALL")
 }
 
 func (db *DB) SetGroupReplicationBootstrapGroup(ctx context.Context, on bool) error {
@@ -93,13 +230,117 @@ func (db *DB) SetGroupReplicationBootstrapGroup(ctx context.Context, on bool) er
 }
 
 func (db *DB) createUser(ctx context.Context, user, host, pass string) error {
-	_, err := db.ExecContext(ctx, `CREATE USER IF NOT EXISTS ?@? IDENTIFIED WITH mysql_native_password BY ?`, user, host, pass)
+	plugin, err := db.authPlugin(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, `CREATE USER IF NOT EXISTS ?@? IDENTIFIED WITH `+plugin+` BY ?`, user, host, pass)
 	if err != nil {
 		return errors.Wrap(err, "exec")
 	}
 	return nil
 }
 
+// AlterUserPassword changes the password of the user if it exists, the user is switched to the authentication plugin of the server version.
+// The statement is written to the binary log, so that replicas and group members apply it too.
+func (db *DB) AlterUserPassword(ctx context.Context, user, host, pass string) error {
+	plugin, err := db.authPlugin(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, `ALTER USER IF EXISTS ?@? IDENTIFIED WITH `+plugin+` BY ?`, user, host, pass)
+	if err != nil {
+		return errors.Wrap(err, "exec")
+	}
+	return nil
+}
+
+// CreateApplicationUser creates the user or changes its password, and replaces its privileges with the given ones on the database.
+func (db *DB) CreateApplicationUser(ctx context.Context, user, host, pass, privileges, database string) error {
+	if err := db.createUser(ctx, user, host, pass); err != nil {
+		return errors.Wrap(err, "create user")
+	}
+	if err := db.AlterUserPassword(ctx, user, host, pass); err != nil {
+		return errors.Wrap(err, "alter user")
+	}
+	if _, err := db.ExecContext(ctx, "REVOKE ALL PRIVILEGES, GRANT OPTION FROM ?@?", user, host); err != nil {
+		return errors.Wrap(err, "revoke privileges")
+	}
+	if _, err := db.ExecContext(ctx, "GRANT "+privileges+" ON "+DatabaseLevel(database)+" TO ?@?", user, host); err != nil {
+		return errors.Wrap(err, "grant privileges")
+	}
+	return nil
+}
+
+// DropUser drops the user if it exists.
+func (db *DB) DropUser(ctx context.Context, user, host string) error {
+	if _, err := db.ExecContext(ctx, "DROP USER IF EXISTS ?@?", user, host); err != nil {
+		return errors.Wrap(err, "exec")
+	}
+	return nil
+}
+
+// DatabaseLevel returns the privilege level of all tables of the database as it's reported by SHOW GRANTS, "*" stands for all databases.
+func DatabaseLevel(database string) string {
+	if database == "*" {
+		return "*.*"
+	}
+	return quoteIdentifier(database) + ".*"
+}
+
+func quoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// QuoteString returns the string literal of the value for the statements which are run by the mysql client and can't use placeholders.
+func QuoteString(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", "''").Replace(value) + "'"
+}
+
+// dataPrivileges are the privileges of the database level, which are granted to the application users.
+var dataPrivileges = []string{
+	"SELECT", "INSERT", "UPDATE", "DELETE", "CREATE", "DROP", "REFERENCES", "INDEX", "ALTER", "CREATE TEMPORARY TABLES",
+	"LOCK TABLES", "EXECUTE", "CREATE VIEW", "SHOW VIEW", "CREATE ROUTINE", "ALTER ROUTINE", "EVENT", "TRIGGER",
+}
+
+// automationPrivileges returns the global privileges, which the provider needs to manage replication, variables and users,
+// and to grant them to replica_user, pmm and orchestrator. Dynamic privileges of 8.0 replace SUPER.
+func automationPrivileges(version string) []string {
+	privileges := []string{"CREATE USER", "RELOAD", "PROCESS", "REPLICATION SLAVE", "REPLICATION CLIENT"}
+	if utils.CompareVersions(version, "8.0.0") < 0 {
+		return append(privileges, "SUPER")
+	}
+	privileges = append(privileges, "REPLICATION_SLAVE_ADMIN", "SYSTEM_VARIABLES_ADMIN", "PERSIST_RO_VARIABLES_ADMIN",
+		"GROUP_REPLICATION_ADMIN", "CONNECTION_ADMIN", "BACKUP_ADMIN")
+	if utils.CompareVersions(version, "8.0.27") >= 0 {
+		privileges = append(privileges, "GROUP_REPLICATION_STREAM")
+	}
+	return privileges
+}
+
+// PartialRevokes reports whether the server version supports the revokes of global privileges on single databases.
+func PartialRevokes(version string) bool {
+	return utils.CompareVersions(version, "8.0.16") >= 0
+}
+
+// AutomationGrants returns the statements, which replace the privileges of the account used by the provider, for the server version.
+// The account can grant the data privileges only on the application databases: they are partially revoked on the system schemas
+// except SELECT, so that it can't change the grant tables. Servers before 8.0.16 don't support partial revokes.
+func AutomationGrants(account, version string) string {
+	statements := []string{
+		"REVOKE ALL PRIVILEGES, GRANT OPTION FROM " + account,
+		"GRANT " + strings.Join(append(dataPrivileges, automationPrivileges(version)...), ", ") + " ON *.* TO " + account + " WITH GRANT OPTION",
+	}
+	if PartialRevokes(version) {
+		revoked := strings.Join(dataPrivileges[1:], ", ")
+		statements = append([]string{"SET PERSIST partial_revokes=ON"}, statements...)
+		statements = append(statements,
+			"REVOKE "+revoked+" ON mysql.* FROM "+account,
+			"REVOKE "+revoked+" ON sys.* FROM "+account)
+	}
+	return strings.Join(statements, "; ") + ";"
+}
+
 func (db *DB) CreateReplicaUser(ctx context.Context, password string, isGR bool) error {
 	if isGR {
 		if _, err := db.ExecContext(ctx, "SET SQL_LOG_BIN=0"); err != nil {
@@ -114,14 +355,27 @@ func (db *DB) CreateReplicaUser(ctx context.Context, password string, isGR bool)
 	}
 
 	if isGR {
-		if _, err := db.ExecContext(ctx, "GRANT CONNECTION_ADMIN ON *.* TO ?@?", internaldb.UserReplica, "%"); err != nil {
-			return errors.Wrap(err, "grant connection admin")
+		// Dynamic privileges don't exist in 5.7
+		dynamicPrivileges, err := db.atLeast(ctx, "8.0.0")
+		if err != nil {
+			return errors.Wrap(err, "get server version")
+		}
+		if dynamicPrivileges {
+			if _, err := db.ExecContext(ctx, "GRANT CONNECTION_ADMIN ON *.* TO ?@?", internaldb.UserReplica, "%"); err != nil {
+				return errors.Wrap(err, "grant connection admin")
+			}
+			if _, err := db.ExecContext(ctx, "GRANT BACKUP_ADMIN ON *.* TO ?@?", internaldb.UserReplica, "%"); err != nil {
+				return errors.Wrap(err, "grant backup admin")
+			}
 		}
-		if _, err := db.ExecContext(ctx, "GRANT BACKUP_ADMIN ON *.* TO ?@?", internaldb.UserReplica, "%"); err != nil {
-			return errors.Wrap(err, "grant backup admin")
+		groupReplicationStream, err := db.atLeast(ctx, "8.0.27")
+		if err != nil {
+			return errors.Wrap(err, "get server version")
 		}
-		if _, err := db.ExecContext(ctx, "GRANT GROUP_REPLICATION_STREAM ON *.* TO ?@?", internaldb.UserReplica, "%"); err != nil {
-			return errors.Wrap(err, "grant group replication string")
+		if groupReplicationStream {
+			if _, err := db.ExecContext(ctx, "GRANT GROUP_REPLICATION_STREAM ON *.* TO ?@?", internaldb.UserReplica, "%"); err != nil {
+				return errors.Wrap(err, "grant group replication string")
+			}
 		}
 		if _, err := db.ExecContext(ctx, "SET SQL_LOG_BIN=1"); err != nil {
 			return errors.Wrap(err, "set sql log bin 1")
@@ -146,7 +400,14 @@ func (db *DB) CreatePMMUser(ctx context.Context, password string) error {
 	if err := db.setMaxUserConnections(ctx, internaldb.UserPMM, "localhost", 10); err != nil {
 		return errors.Wrap(err, "max user connections")
 	}
-	if _, err := db.ExecContext(ctx, "GRANT SELECT, PROCESS, REPLICATION CLIENT, RELOAD, BACKUP_ADMIN ON *.* TO ?@?", internaldb.UserPMM, "localhost"); err != nil {
+	privileges := "SELECT, PROCESS, REPLICATION CLIENT, RELOAD"
+	// BACKUP_ADMIN doesn't exist in 5.7
+	if ok, err := db.atLeast(ctx, "8.0.0"); err != nil {
+		return errors.Wrap(err, "get server version")
+	} else if ok {
+		privileges += ", BACKUP_ADMIN"
+	}
+	if _, err := db.ExecContext(ctx, "GRANT "+privileges+" ON *.* TO ?@?", internaldb.UserPMM, "localhost"); err != nil {
 		return errors.Wrap(err, "grant replication slave")
 	}
 	return nil
@@ -175,7 +436,14 @@ func (db *DB) CreateOrchestratorUser(ctx context.Context, password string, isGro
 	if _, err := db.ExecContext(ctx, "GRANT SELECT ON meta.* TO ?@?", internaldb.UserOrchestrator, "%"); err != nil {
 		return errors.Wrap(err, "grant select")
 	}
-	if _, err := db.ExecContext(ctx, "GRANT SUPER, PROCESS, REPLICATION SLAVE, REPLICATION CLIENT, RELOAD ON *.* TO ?@?", internaldb.UserOrchestrator, "%"); err != nil {
+	privileges := "SUPER, PROCESS, REPLICATION SLAVE, REPLICATION CLIENT, RELOAD"
+	// Dynamic privileges replace SUPER in 8.0, the provider doesn't hold SUPER there
+	if ok, err := db.atLeast(ctx, "8.0.0"); err != nil {
+		return errors.Wrap(err, "get server version")
+	} else if ok {
+		privileges = "SYSTEM_VARIABLES_ADMIN, REPLICATION_SLAVE_ADMIN, CONNECTION_ADMIN, PROCESS, REPLICATION SLAVE, REPLICATION CLIENT, RELOAD"
+	}
+	if _, err := db.ExecContext(ctx, "GRANT "+privileges+" ON *.* TO ?@?", internaldb.UserOrchestrator, "%"); err != nil {
 		return errors.Wrap(err, "grant select")
 	}
 	if isGroupReplication {
@@ -193,9 +461,403 @@ func (db *DB) StartGroupReplication(ctx context.Context) error {
 	return nil
 }
 
+func (db *DB) StopGroupReplication(ctx context.Context) error {
+	if _, err := db.ExecContext(ctx, "STOP GROUP_REPLICATION"); err != nil {
+		return errors.Wrap(err, "stop group replication")
+	}
+	return nil
+}
+
+func (db *DB) SetGroupReplicationSeeds(ctx context.Context, seeds string) error {
+	if _, err := db.ExecContext(ctx, "SET GLOBAL group_replication_group_seeds=?", seeds); err != nil {
+		return errors.Wrap(err, "set group replication group seeds")
+	}
+	return nil
+}
+
+func (db *DB) SetGroupReplicationIPAllowlist(ctx context.Context, allowlist string) error {
+	variable := "group_replication_ip_allowlist"
+	// group_replication_ip_allowlist replaced group_replication_ip_whitelist in 8.0.22
+	if ok, err := db.atLeast(ctx, "8.0.22"); err != nil {
+		return errors.Wrap(err, "get server version")
+	} else if !ok {
+		variable = "group_replication_ip_whitelist"
+	}
+	if _, err := db.ExecContext(ctx, "SET GLOBAL "+variable+"=?", allowlist); err != nil {
+		return errors.Wrap(err, "set group replication ip allowlist")
+	}
+	return nil
+}
+
 func (db *DB) ChangeGroupReplicationSource(ctx context.Context, sourceUser, sourcePassword string) error {
-	if _, err := db.ExecContext(ctx, "CHANGE REPLICATION SOURCE TO SOURCE_USER=?, SOURCE_PASSWORD=? FOR CHANNEL 'group_replication_recovery'", sourceUser, sourcePassword); err != nil {
+	if err := db.execReplication(ctx, "CHANGE REPLICATION SOURCE TO SOURCE_USER=?, SOURCE_PASSWORD=? FOR CHANNEL 'group_replication_recovery'", sourceUser, sourcePassword); err != nil {
 		return errors.Wrap(err, "change group replication source")
 	}
 	return nil
 }
+
+// ReplicaStatus returns the row of SHOW REPLICA STATUS keyed by column name.
+// Columns of SHOW SLAVE STATUS are renamed to their SOURCE/REPLICA names on older servers.
+// A nil map is returned if the server is not configured as a replica.
+func (db *DB) ReplicaStatus(ctx context.Context) (map[string]string, error) {
+	query, err := db.replicationSyntax(ctx, "SHOW REPLICA STATUS")
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, errors.Wrap(err, "show replica status")
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, errors.Wrap(err, "columns")
+	}
+	if !rows.Next() {
+		return nil, errors.Wrap(rows.Err(), "next")
+	}
+	values := make([]sql.NullString, len(columns))
+	dest := make([]any, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return nil, errors.Wrap(err, "scan")
+	}
+	status := make(map[string]string, len(columns))
+	columnName := strings.NewReplacer("Master", "Source", "Slave", "Replica")
+	for i, column := range columns {
+		status[columnName.Replace(column)] = values[i].String
+	}
+	return status, nil
+}
+
+// GroupReplicationMember returns the state and the role of the server in the replication group.
+func (db *DB) GroupReplicationMember(ctx context.Context) (state string, role string, err error) {
+	err = db.QueryRowContext(ctx, "SELECT MEMBER_STATE, MEMBER_ROLE FROM performance_schema.replication_group_members WHERE MEMBER_ID=@@server_uuid").Scan(&state, &role)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return GroupReplicationMemberStateOffline, "", nil
+		}
+		return "", "", errors.Wrap(err, "select replication group member")
+	}
+	return state, role, nil
+}
+
+func (db *DB) Status(ctx context.Context, name string) (string, error) {
+	var value string
+	if err := db.QueryRowContext(ctx, "SHOW GLOBAL STATUS LIKE ?", name).Scan(&name, &value); err != nil {
+		return "", errors.Wrapf(err, "show global status %s", name)
+	}
+	return value, nil
+}
+
+func (db *DB) Variable(ctx context.Context, name string) (string, error) {
+	var value string
+	if err := db.QueryRowContext(ctx, "SHOW GLOBAL VARIABLES LIKE ?", name).Scan(&name, &value); err != nil {
+		return "", errors.Wrapf(err, "show global variables %s", name)
+	}
+	return value, nil
+}
+
+// SetPersistVariable sets the global variable and persists it, 5.7 doesn't support SET PERSIST, so the variable is only set at runtime there.
+// ErrVariableNotDynamic is returned for read-only variables, unknown variables and invalid values are reported as errors.
+func (db *DB) SetPersistVariable(ctx context.Context, name, value string) error {
+	scope, err := db.persistScope(ctx)
+	if err != nil {
+		return err
+	}
+	var arg any = value
+	// Numeric variables don't accept string values, size suffixes are valid only in the config file
+	if size, ok := sizeValue(value); ok {
+		arg = size
+	} else if i, err := strconv.ParseInt(value, 10, 64); err == nil {
+		arg = i
+	} else if f, err := strconv.ParseFloat(value, 64); err == nil {
+		arg = f
+	}
+	if _, err := db.ExecContext(ctx, "SET "+scope+" "+variableName(name)+" = ?", arg); err != nil {
+		return variableError(err)
+	}
+	return nil
+}
+
+// ApplyVariables sets the changed variables and resets the removed ones at runtime.
+// It returns the sorted names of the variables, which can't be changed at runtime and require a restart.
+func (db *DB) ApplyVariables(ctx context.Context, changed map[string]string, removed []string) ([]string, error) {
+	var static []string
+	for name, value := range changed {
+		err := db.SetPersistVariable(ctx, name, value)
+		if errors.Is(err, ErrVariableNotDynamic) {
+			static = append(static, name)
+		} else if err != nil {
+			return nil, errors.Wrapf(err, "failed to set %s", name)
+		}
+	}
+	for _, name := range removed {
+		err := db.ResetPersistVariable(ctx, name)
+		if errors.Is(err, ErrVariableNotDynamic) {
+			static = append(static, name)
+		} else if err != nil {
+			return nil, errors.Wrapf(err, "failed to reset %s", name)
+		}
+	}
+	sort.Strings(static)
+	return static, nil
+}
+
+// ResetPersistVariable sets the global variable to its default value and removes it from the persisted variables.
+func (db *DB) ResetPersistVariable(ctx context.Context, name string) error {
+	if _, err := db.ExecContext(ctx, "SET GLOBAL "+variableName(name)+" = DEFAULT"); err != nil {
+		return variableError(err)
+	}
+	if scope, err := db.persistScope(ctx); err != nil {
+		return err
+	} else if scope == "PERSIST" {
+		if _, err := db.ExecContext(ctx, "RESET PERSIST IF EXISTS "+variableName(name)); err != nil {
+			return errors.Wrap(err, "reset persist")
+		}
+	}
+	return nil
+}
+
+func (db *DB) persistScope(ctx context.Context) (string, error) {
+	ok, err := db.atLeast(ctx, "8.0.0")
+	if err != nil {
+		return "", errors.Wrap(err, "get server version")
+	}
+	if !ok {
+		return "GLOBAL", nil
+	}
+	return "PERSIST", nil
+}
+
+// variableName converts the option name used in the config file to the system variable name.
+func variableName(option string) string {
+	return strings.ReplaceAll(option, "-", "_")
+}
+
+// sizeValue converts the value with K, M, G or T size suffix to bytes.
+func sizeValue(value string) (int64, bool) {
+	if len(value) < 2 {
+		return 0, false
+	}
+	shift := strings.Index("KMGT", strings.ToUpper(value[len(value)-1:])) + 1
+	if shift == 0 {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(value[:len(value)-1], 10, 64)
+	if err != nil || n < 0 || n > math.MaxInt64>>(10*shift) {
+		return 0, false
+	}
+	return n << (10 * shift), true
+}
+
+func variableError(err error) error {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		switch mysqlErr.Number {
+		case errIncorrectGlobalLocalVar:
+			return ErrVariableNotDynamic
+		case errUnknownSystemVariable:
+			return errors.Wrap(err, "only system variables can be set")
+		case errWrongTypeForVar:
+			return errors.Wrap(err, "invalid value")
+		}
+	}
+	return errors.Wrap(err, "exec")
+}
+
+// Version returns the server version without the build suffix, e.g. 8.0.33 for 8.0.33-25.
+func (db *DB) Version(ctx context.Context) (string, error) {
+	var version string
+	if err := db.QueryRowContext(ctx, "SELECT @@version").Scan(&version); err != nil {
+		return "", errors.Wrap(err, "select version")
+	}
+	version, _, _ = strings.Cut(version, "-")
+	return version, nil
+}
+
+// UserTablesExist reports whether there are tables outside of the system schemas.
+func (db *DB) UserTablesExist(ctx context.Context) (bool, error) {
+	var count int
+	err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM information_schema.TABLES WHERE TABLE_SCHEMA NOT IN ('mysql', 'sys', 'information_schema', 'performance_schema')").Scan(&count)
+	if err != nil {
+		return false, errors.Wrap(err, "select tables")
+	}
+	return count > 0, nil
+}
+
+func (db *DB) EngineSupported(ctx context.Context, engine string) (bool, error) {
+	var support string
+	err := db.QueryRowContext(ctx, "SELECT SUPPORT FROM information_schema.ENGINES WHERE ENGINE=?", engine).Scan(&support)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, errors.Wrap(err, "select engine")
+	}
+	return support == "YES" || support == "DEFAULT", nil
+}
+
+// IsWriter reports whether the server accepts writes of the cluster:
+// a synced PXC node, the primary member of the replication group, or an instance which is not a replica.
+func (db *DB) IsWriter(ctx context.Context) (bool, error) {
+	localState, err := db.Status(ctx, "wsrep_local_state_comment")
+	if err == nil {
+		return localState == "Synced", nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return false, err
+	}
+	readOnly, err := db.Variable(ctx, "super_read_only")
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, err
+	}
+	if readOnly == "ON" {
+		return false, nil
+	}
+	state, role, err := db.GroupReplicationMember(ctx)
+	if err != nil {
+		return false, err
+	}
+	if state != GroupReplicationMemberStateOffline {
+		return state == GroupReplicationMemberStateOnline && role == GroupReplicationMemberRolePrimary, nil
+	}
+	status, err := db.ReplicaStatus(ctx)
+	if err != nil {
+		return false, err
+	}
+	return status == nil, nil
+}
+
+// CreateUser creates the user, it fails if the user exists.
+func (db *DB) CreateUser(ctx context.Context, user, host, pass string) error {
+	plugin, err := db.authPlugin(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := db.ExecContext(ctx, `CREATE USER ?@? IDENTIFIED WITH `+plugin+` BY ?`, user, host, pass); err != nil {
+		return errors.Wrap(err, "exec")
+	}
+	return nil
+}
+
+func (db *DB) UserExists(ctx context.Context, user, host string) (bool, error) {
+	var count int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM mysql.user WHERE User=? AND Host=?", user, host).Scan(&count); err != nil {
+		return false, errors.Wrap(err, "select user")
+	}
+	return count > 0, nil
+}
+
+// CreateDatabase creates the database, it fails if the database exists. The server defaults are used for empty charset and collation.
+func (db *DB) CreateDatabase(ctx context.Context, name, charset, collation string) error {
+	if _, err := db.ExecContext(ctx, "CREATE DATABASE "+quoteIdentifier(name)+databaseOptions(charset, collation)); err != nil {
+		return errors.Wrap(err, "exec")
+	}
+	return nil
+}
+
+func (db *DB) AlterDatabase(ctx context.Context, name, charset, collation string) error {
+	if _, err := db.ExecContext(ctx, "ALTER DATABASE "+quoteIdentifier(name)+databaseOptions(charset, collation)); err != nil {
+		return errors.Wrap(err, "exec")
+	}
+	return nil
+}
+
+func databaseOptions(charset, collation string) string {
+	var options string
+	if charset != "" {
+		options += " CHARACTER SET " + quoteIdentifier(charset)
+	}
+	if collation != "" {
+		options += " COLLATE " + quoteIdentifier(collation)
+	}
+	return options
+}
+
+// Database returns the default charset and collation of the database, sql.ErrNoRows is returned if it doesn't exist.
+func (db *DB) Database(ctx context.Context, name string) (charset string, collation string, err error) {
+	err = db.QueryRowContext(ctx, "SELECT DEFAULT_CHARACTER_SET_NAME, DEFAULT_COLLATION_NAME FROM information_schema.SCHEMATA WHERE SCHEMA_NAME=?", name).Scan(&charset, &collation)
+	if err != nil {
+		return "", "", errors.Wrap(err, "select schema")
+	}
+	return charset, collation, nil
+}
+
+func (db *DB) DropDatabase(ctx context.Context, name string) error {
+	if _, err := db.ExecContext(ctx, "DROP DATABASE IF EXISTS "+quoteIdentifier(name)); err != nil {
+		return errors.Wrap(err, "exec")
+	}
+	return nil
+}
+
+// Grant grants the privileges on all tables of the database, "*" stands for all databases.
+func (db *DB) Grant(ctx context.Context, user, host string, privileges []string, database string) error {
+	if len(privileges) == 0 {
+		return nil
+	}
+	if _, err := db.ExecContext(ctx, "GRANT "+strings.Join(privileges, ", ")+" ON "+DatabaseLevel(database)+" TO ?@?", user, host); err != nil {
+		return errors.Wrap(err, "exec")
+	}
+	return nil
+}
+
+// Revoke revokes the privileges on all tables of the database, privileges which are not granted are ignored.
+func (db *DB) Revoke(ctx context.Context, user, host string, privileges []string, database string) error {
+	if len(privileges) == 0 {
+		return nil
+	}
+	_, err := db.ExecContext(ctx, "REVOKE "+strings.Join(privileges, ", ")+" ON "+DatabaseLevel(database)+" FROM ?@?", user, host)
+	var mysqlErr *mysql.MySQLError
+	if err != nil && !(errors.As(err, &mysqlErr) && mysqlErr.Number == errNonexistingGrant) {
+		return errors.Wrap(err, "exec")
+	}
+	return nil
+}
+
+// Grants returns the privileges of the user by the privilege level, e.g. `app`.* or *.*, as they are reported by SHOW GRANTS.
+func (db *DB) Grants(ctx context.Context, user, host string) (map[string][]string, error) {
+	rows, err := db.QueryContext(ctx, "SHOW GRANTS FOR ?@?", user, host)
+	if err != nil {
+		return nil, errors.Wrap(err, "show grants")
+	}
+	defer rows.Close()
+	grants := make(map[string][]string)
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return nil, errors.Wrap(err, "scan")
+		}
+		level, privileges, ok := ParseGrant(line)
+		if !ok {
+			continue
+		}
+		grants[level] = append(grants[level], privileges...)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "next")
+	}
+	return grants, nil
+}
+
+// ParseGrant returns the privilege level and the privileges of the GRANT statement.
+// Grants of roles and proxy users don't have privilege levels and are not reported.
+func ParseGrant(line string) (level string, privileges []string, ok bool) {
+	if !strings.HasPrefix(line, "GRANT ") {
+		return "", nil, false
+	}
+	list, rest, ok := strings.Cut(strings.TrimPrefix(line, "GRANT "), " ON ")
+	if !ok {
+		return "", nil, false
+	}
+	level, _, ok = strings.Cut(rest, " TO ")
+	if !ok || strings.HasPrefix(list, "PROXY") {
+		return "", nil, false
+	}
+	for _, privilege := range strings.Split(list, ",") {
+		privileges = append(privileges, strings.TrimSpace(privilege))
+	}
+	return level, privileges, true
+}
@@ -0,0 +1,226 @@
+package mysql_test
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"terraform-percona/internal/db/mysql"
+	fakedb "terraform-percona/internal/db/mysql/fake"
+)
+
+func TestParseGrant(t *testing.T) {
+	tests := []struct {
+		line           string
+		wantLevel      string
+		wantPrivileges []string
+		wantOK         bool
+	}{
+		{
+			line:           "GRANT USAGE ON *.* TO `app`@`%`",
+			wantLevel:      "*.*",
+			wantPrivileges: []string{"USAGE"},
+			wantOK:         true,
+		},
+		{
+			line:           "GRANT SELECT, INSERT, UPDATE ON `app`.* TO `app`@`10.0.0.0/255.255.0.0` WITH GRANT OPTION",
+			wantLevel:      mysql.DatabaseLevel("app"),
+			wantPrivileges: []string{"SELECT", "INSERT", "UPDATE"},
+			wantOK:         true,
+		},
+		{
+			line:           "GRANT ALL PRIVILEGES ON `my``db`.* TO `app`@`%`",
+			wantLevel:      mysql.DatabaseLevel("my`db"),
+			wantPrivileges: []string{"ALL PRIVILEGES"},
+			wantOK:         true,
+		},
+		{
+			line:           "GRANT BACKUP_ADMIN,SYSTEM_VARIABLES_ADMIN ON *.* TO `app`@`%`",
+			wantLevel:      mysql.DatabaseLevel("*"),
+			wantPrivileges: []string{"BACKUP_ADMIN", "SYSTEM_VARIABLES_ADMIN"},
+			wantOK:         true,
+		},
+		{line: "GRANT `reader`@`%` TO `app`@`%`"},
+		{line: "GRANT PROXY ON ''@'' TO 'root'@'localhost' WITH GRANT OPTION"},
+	}
+	for _, tt := range tests {
+		level, privileges, ok := mysql.ParseGrant(tt.line)
+		if level != tt.wantLevel || !reflect.DeepEqual(privileges, tt.wantPrivileges) || ok != tt.wantOK {
+			t.Errorf("ParseGrant(%q) = %q, %v, %v, want %q, %v, %v", tt.line, level, privileges, ok, tt.wantLevel, tt.wantPrivileges, tt.wantOK)
+		}
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	script := "-- schema\n" +
+		"CREATE TABLE t (id INT, s VARCHAR(10) DEFAULT ';');\n" +
+		"\n" +
+		"INSERT INTO t VALUES (1, 'it''s; \\'quoted\\''), # comment;\n" +
+		"  (2, \"a;b\");\n" +
+		"/*!40101 SET NAMES utf8mb4 */;\n" +
+		"DELIMITER //\n" +
+		"CREATE PROCEDURE p()\n" +
+		"BEGIN\n" +
+		"  SELECT 1;\n" +
+		"END//\n" +
+		"delimiter ;\n" +
+		"SELECT `a;b` FROM t"
+	want := []mysql.Statement{
+		{Line: 2, Query: "CREATE TABLE t (id INT, s VARCHAR(10) DEFAULT ';')"},
+		{Line: 4, Query: "INSERT INTO t VALUES (1, 'it''s; \\'quoted\\''), \n  (2, \"a;b\")"},
+		{Line: 6, Query: "/*!40101 SET NAMES utf8mb4 */"},
+		{Line: 8, Query: "CREATE PROCEDURE p()\nBEGIN\n  SELECT 1;\nEND"},
+		{Line: 13, Query: "SELECT `a;b` FROM t"},
+	}
+	got, err := mysql.SplitStatements(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SplitStatements() = %q, want %q", got, want)
+	}
+
+	if _, err := mysql.SplitStatements("SELECT 1;\nSELECT 'a;\n"); err == nil || err.Error() != "line 2: unterminated quoted string" {
+		t.Errorf("SplitStatements() error = %v, want unterminated quoted string at line 2", err)
+	}
+}
+
+func TestQuoteString(t *testing.T) {
+	tests := map[string]string{
+		"password":   `'password'`,
+		`it's`:       `'it''s'`,
+		`back\`:      `'back\\'`,
+		`'; DROP --`: `'''; DROP --'`,
+	}
+	for value, want := range tests {
+		if got := mysql.QuoteString(value); got != want {
+			t.Errorf("QuoteString(%q) = %s, want %s", value, got, want)
+		}
+	}
+}
+
+// TestAutomationGrants grants explicit privileges instead of ALL, the system schemas are writable only before 8.0.16.
+func TestAutomationGrants(t *testing.T) {
+	tests := []struct {
+		version    string
+		want       []string
+		notWant    []string
+		restricted bool
+	}{
+		{
+			version: "5.7.42-46-1.focal",
+			want:    []string{"SUPER"},
+			notWant: []string{"SYSTEM_VARIABLES_ADMIN", "partial_revokes"},
+		},
+		{
+			version: "8.0.15-6-1.focal",
+			want:    []string{"SYSTEM_VARIABLES_ADMIN", "GROUP_REPLICATION_ADMIN"},
+			notWant: []string{"SUPER", "GROUP_REPLICATION_STREAM", "partial_revokes"},
+		},
+		{
+			version:    "8.0.33-25-1.focal",
+			want:       []string{"SYSTEM_VARIABLES_ADMIN", "PERSIST_RO_VARIABLES_ADMIN", "GROUP_REPLICATION_STREAM", "SET PERSIST partial_revokes=ON"},
+			notWant:    []string{"SUPER"},
+			restricted: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			statements, err := mysql.SplitStatements(mysql.AutomationGrants("'percona_automation'@'%'", tt.version))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var all string
+			for _, statement := range statements {
+				all += statement.Query + ";\n"
+			}
+			if !strings.Contains(all, "REVOKE ALL PRIVILEGES, GRANT OPTION FROM 'percona_automation'@'%';\nGRANT ") {
+				t.Errorf("previous privileges aren't revoked:\n%s", all)
+			}
+			if strings.Contains(all, "GRANT ALL") {
+				t.Errorf("all privileges are granted:\n%s", all)
+			}
+			for _, want := range append(tt.want, "CREATE USER", "REPLICATION SLAVE", "WITH GRANT OPTION") {
+				if !strings.Contains(all, want) {
+					t.Errorf("statements don't contain %q:\n%s", want, all)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(all, notWant) {
+					t.Errorf("statements contain %q:\n%s", notWant, all)
+				}
+			}
+			for _, schema := range []string{"mysql", "sys"} {
+				revoke := "REVOKE INSERT, UPDATE, DELETE, CREATE, DROP, REFERENCES, INDEX, ALTER, CREATE TEMPORARY TABLES, LOCK TABLES, EXECUTE, " +
+					"CREATE VIEW, SHOW VIEW, CREATE ROUTINE, ALTER ROUTINE, EVENT, TRIGGER ON " + schema + ".* FROM 'percona_automation'@'%'"
+				if restricted := strings.Contains(all, revoke); restricted != tt.restricted {
+					t.Errorf("writes to %s are revoked: %v, want %v:\n%s", schema, restricted, tt.restricted, all)
+				}
+			}
+			if strings.Contains(all, "REVOKE SELECT") {
+				t.Errorf("system schemas aren't readable:\n%s", all)
+			}
+		})
+	}
+}
+
+// TestAuthPlugin creates users with caching_sha2_password since 8.4, where mysql_native_password is disabled by default,
+// replicas without TLS request the public key of the source then.
+func TestAuthPlugin(t *testing.T) {
+	tests := []struct {
+		version   string
+		plugin    string
+		publicKey bool
+	}{
+		{version: "8.0.33-25", plugin: "mysql_native_password"},
+		{version: "8.4.0-1", plugin: "caching_sha2_password", publicKey: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			server, err := fakedb.New("127.0.0.1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(server.Close)
+			server.OnQuery("SELECT @@version", []string{"@@version"}, []string{tt.version})
+			db, err := mysql.NewClient(net.JoinHostPort("127.0.0.1", strconv.Itoa(server.Port())), "root", "password")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			ctx := context.Background()
+			if err := db.CreateUser(ctx, "user", "%", "password"); err != nil {
+				t.Fatal(err)
+			}
+			if err := db.AlterUserPassword(ctx, "user", "%", "password"); err != nil {
+				t.Fatal(err)
+			}
+			if err := db.ChangeReplicationSource(ctx, "10.0.0.1", 3306, "replica", "password", false); err != nil {
+				t.Fatal(err)
+			}
+			var users int
+			var publicKey bool
+			for _, query := range server.Queries() {
+				if strings.HasPrefix(query.SQL, "CREATE USER") || strings.HasPrefix(query.SQL, "ALTER USER") {
+					users++
+					if !strings.Contains(query.SQL, "IDENTIFIED WITH "+tt.plugin+" BY") {
+						t.Errorf("%s, want %s", query.SQL, tt.plugin)
+					}
+				}
+				if strings.HasPrefix(query.SQL, "CHANGE REPLICATION SOURCE") {
+					publicKey = strings.Contains(query.SQL, "GET_SOURCE_PUBLIC_KEY=1")
+				}
+			}
+			if users != 2 {
+				t.Errorf("%d users are created or altered, want 2", users)
+			}
+			if publicKey != tt.publicKey {
+				t.Errorf("public key of the source is requested: %v, want %v", publicKey, tt.publicKey)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package mysql
+
+import (
+	"context"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+const defaultDelimiter = ";"
+
+// Statement is a statement of the SQL script.
+type Statement struct {
+	// Line is the line of the script where the statement starts
+	Line  int
+	Query string
+}
+
+// SplitStatements splits the SQL script into statements the same way as the mysql client does.
+// Delimiters inside of quotes and comments are ignored, the DELIMITER command changes the delimiter.
+// Line comments are removed, block comments are kept, as /*! */ comments are executed by the server.
+func SplitStatements(script string) ([]Statement, error) {
+	var (
+		statements []Statement
+		query      strings.Builder
+		delimiter  = defaultDelimiter
+		line       = 1
+		startLine  int
+		quote      byte
+		quoteLine  int
+		inComment  bool
+	)
+	flush := func() {
+		if q := strings.TrimSpace(query.String()); q != "" {
+			statements = append(statements, Statement{Line: startLine, Query: q})
+		}
+		query.Reset()
+	}
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+		switch {
+		case quote != 0:
+			query.WriteByte(c)
+			switch {
+			case c == '\\' && quote != '`' && i+1 < len(script):
+				i++
+				query.WriteByte(script[i])
+				if script[i] == '\n' {
+					line++
+				}
+			case c == quote && i+1 < len(script) && script[i+1] == quote:
+				i++
+				query.WriteByte(script[i])
+			case c == quote:
+				quote = 0
+			}
+		case inComment:
+			query.WriteByte(c)
+			if c == '*' && i+1 < len(script) && script[i+1] == '/' {
+				i++
+				query.WriteByte(script[i])
+				inComment = false
+			}
+		case query.Len() == 0 && unicode.IsSpace(rune(c)):
+			// Leading whitespace isn't a part of the statement
+		case query.Len() == 0 && isDelimiterCommand(script[i:]):
+			end := strings.IndexByte(script[i:], '\n')
+			if end == -1 {
+				end = len(script) - i
+			}
+			fields := strings.Fields(script[i : i+end])
+			if len(fields) != 2 {
+				return nil, errors.Errorf("line %d: DELIMITER requires a single argument", line)
+			}
+			delimiter = fields[1]
+			i += end - 1
+			continue
+		case strings.HasPrefix(script[i:], delimiter):
+			flush()
+			i += len(delimiter) - 1
+			continue
+		case c == '#' || isDashComment(script[i:]):
+			end := strings.IndexByte(script[i:], '\n')
+			if end == -1 {
+				end = len(script) - i
+			}
+			i += end - 1
+			continue
+		case c == '/' && i+1 < len(script) && script[i+1] == '*':
+			if query.Len() == 0 {
+				startLine = line
+			}
+			query.WriteString("/*")
+			i++
+			inComment = true
+			continue
+		default:
+			if query.Len() == 0 {
+				startLine = line
+			}
+			query.WriteByte(c)
+			if c == '\'' || c == '"' || c == '`' {
+				quote, quoteLine = c, line
+			}
+		}
+		if c == '\n' {
+			line++
+		}
+	}
+	switch {
+	case quote != 0:
+		return nil, errors.Errorf("line %d: unterminated quoted string", quoteLine)
+	case inComment:
+		return nil, errors.Errorf("line %d: unterminated comment", startLine)
+	}
+	flush()
+	return statements, nil
+}
+
+func isDelimiterCommand(s string) bool {
+	const command = "delimiter"
+	return len(s) > len(command) && strings.EqualFold(s[:len(command)], command) && (s[len(command)] == ' ' || s[len(command)] == '\t')
+}
+
+// isDashComment reports whether s starts with a -- comment, which requires a whitespace after the dashes.
+func isDashComment(s string) bool {
+	return strings.HasPrefix(s, "--") && (len(s) == 2 || unicode.IsSpace(rune(s[2])))
+}
+
+// ExecScript executes the statements of the SQL script one by one in the same session.
+// Errors are reported with the name of the script and the line of the failed statement.
+func (db *DB) ExecScript(ctx context.Context, name, script string) error {
+	statements, err := SplitStatements(script)
+	if err != nil {
+		return errors.Wrap(err, name)
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return errors.Wrap(err, "get connection")
+	}
+	defer conn.Close()
+	for _, statement := range statements {
+		if _, err := conn.ExecContext(ctx, statement.Query); err != nil {
+			return errors.Wrapf(err, "%s:%d", name, statement.Line)
+		}
+	}
+	return nil
+}
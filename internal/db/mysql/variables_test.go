@@ -0,0 +1,51 @@
+package mysql
+
+import (
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/pkg/errors"
+)
+
+func TestSizeValue(t *testing.T) {
+	tests := map[string]struct {
+		want int64
+		ok   bool
+	}{
+		"1G":    {want: 1 << 30, ok: true},
+		"128M":  {want: 128 << 20, ok: true},
+		"64k":   {want: 64 << 10, ok: true},
+		"2T":    {want: 2 << 40, ok: true},
+		"1024":  {ok: false},
+		"G":     {ok: false},
+		"1.5G":  {ok: false},
+		"ON":    {ok: false},
+		"-1M":   {ok: false},
+		"9e18T": {ok: false},
+	}
+	for value, tt := range tests {
+		t.Run(value, func(t *testing.T) {
+			got, ok := sizeValue(value)
+			if ok != tt.ok || got != tt.want {
+				t.Errorf("sizeValue(%q) = %d, %t, want %d, %t", value, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestVariableError(t *testing.T) {
+	tests := map[uint16]bool{
+		errIncorrectGlobalLocalVar: true,
+		errUnknownSystemVariable:   false,
+		errWrongTypeForVar:         false,
+	}
+	for number, notDynamic := range tests {
+		err := variableError(&mysql.MySQLError{Number: number})
+		if err == nil {
+			t.Fatalf("variableError(%d) = nil", number)
+		}
+		if got := errors.Is(err, ErrVariableNotDynamic); got != notDynamic {
+			t.Errorf("variableError(%d) is ErrVariableNotDynamic = %t, want %t", number, got, notDynamic)
+		}
+	}
+}
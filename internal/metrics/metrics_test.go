@@ -86,7 +86,10 @@ func TestMetrics(t *testing.T) {
 		{new(ps.PerconaServer), []metrics.Metric{
 			{Key: "product", Value: "terraform-provider"},
 			{Key: "resource", Value: "ps"},
+			{Key: "replication_type", Value: "async"},
+			{Key: "tuning_profile", Value: "none"},
 			{Key: "version", Value: "somestring"},
+			{Key: "release_series", Value: "somestring"},
 			{Key: "cluster_size", Value: "3"},
 			{Key: "volume_type", Value: "somestring"},
 			{Key: "volume_size", Value: "20"},
@@ -98,12 +101,16 @@ func TestMetrics(t *testing.T) {
 			{Key: "vpc_name", Value: "somestring"},
 			{Key: "key_pair_name", Value: "somestring"},
 			{Key: "pmm_address", Value: "somestring"},
+			{Key: "secret_store", Value: "somestring"},
+			{Key: "secret_path", Value: "somestring"},
 			{Key: "volume_throughput", Value: "1234"},
 		}},
 		{new(pxc.PerconaXtraDBCluster), []metrics.Metric{
 			{Key: "product", Value: "terraform-provider"},
 			{Key: "resource", Value: "pxc"},
+			{Key: "tuning_profile", Value: "none"},
 			{Key: "version", Value: "somestring"},
+			{Key: "release_series", Value: "somestring"},
 			{Key: "cluster_size", Value: "3"},
 			{Key: "volume_type", Value: "somestring"},
 			{Key: "volume_size", Value: "20"},
@@ -116,6 +123,8 @@ func TestMetrics(t *testing.T) {
 			{Key: "pmm_address", Value: "somestring"},
 			{Key: "galera_port", Value: "4567"},
 			{Key: "key_pair_name", Value: "somestring"},
+			{Key: "secret_store", Value: "somestring"},
+			{Key: "secret_path", Value: "somestring"},
 			{Key: "volume_throughput", Value: "1234"},
 		}},
 		{new(pmm.PMM), []metrics.Metric{
@@ -3,19 +3,24 @@ package provider
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/hashicorp/go-cty/cty"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 
 	"terraform-percona/internal/cloud"
 	"terraform-percona/internal/resource"
+	"terraform-percona/internal/resource/mysql"
 	"terraform-percona/internal/resource/pmm"
 	"terraform-percona/internal/resource/ps"
 	"terraform-percona/internal/resource/pxc"
 
 	awsCloud "terraform-percona/internal/cloud/aws"
+	"terraform-percona/internal/cloud/azure"
 	"terraform-percona/internal/cloud/gcp"
+	"terraform-percona/internal/cloud/static"
 )
 
 const (
@@ -23,13 +28,40 @@ const (
 
 	schemaKeyCloudRegion = "region"
 
-	schemaKeyAWSProfile = "profile"
+	schemaKeyAWSProfile               = "profile"
+	schemaKeyAWSAccessKey             = "access_key"
+	schemaKeyAWSSecretKey             = "secret_key"
+	schemaKeyAWSSessionToken          = "session_token"
+	schemaKeyAWSAssumeRole            = "assume_role"
+	schemaKeyAWSAssumeRoleWebIdentity = "assume_role_with_web_identity"
+	schemaKeyAWSRoleARN               = "role_arn"
+	schemaKeyAWSExternalID            = "external_id"
+	schemaKeyAWSSessionName           = "session_name"
+	schemaKeyAWSDuration              = "duration"
+	schemaKeyAWSWebIdentityTokenFile  = "web_identity_token_file"
 
-	schemaKeyGCPProject = "project"
-	schemaKeyGCPZone    = "zone"
+	schemaKeyGCPProject                   = "project"
+	schemaKeyGCPZone                      = "zone"
+	schemaKeyGCPCredentials               = "credentials"
+	schemaKeyGCPAccessToken               = "access_token"
+	schemaKeyGCPImpersonateServiceAccount = "impersonate_service_account"
+
+	schemaKeyAzureSubscriptionID = "subscription_id"
+	schemaKeyAzureResourceGroup  = "resource_group"
+
+	schemaKeyStaticHosts              = "hosts"
+	schemaKeyStaticHostAddress        = "address"
+	schemaKeyStaticHostPrivateAddress = "private_address"
+	schemaKeyStaticHostSSHUser        = "ssh_user"
+	schemaKeyStaticHostSSHKeyPath     = "ssh_key_path"
+	schemaKeyStaticHostLabels         = "labels"
+	schemaKeyStaticWipeOnDestroy      = "wipe_on_destroy"
 
 	schemaKeyIgnoreErrorsOnDestroy = "ignore_errors_on_destroy"
 	schemaKeyDisableTelemetry      = "disable_telemetry"
+
+	schemaKeyVaultAddress = "vault_address"
+	schemaKeyVaultToken   = "vault_token"
 )
 
 func New() *schema.Provider {
@@ -37,7 +69,7 @@ func New() *schema.Provider {
 		Schema: map[string]*schema.Schema{
 			schemaKeyCloudRegion: {
 				Type:     schema.TypeString,
-				Required: true,
+				Optional: true,
 			},
 			schemaKeyGCPProject: {
 				Type:     schema.TypeString,
@@ -47,15 +79,143 @@ func New() *schema.Provider {
 				Type:     schema.TypeString,
 				Optional: true,
 			},
+			schemaKeyGCPCredentials: {
+				Type:          schema.TypeString,
+				Optional:      true,
+				Sensitive:     true,
+				DefaultFunc:   schema.EnvDefaultFunc("GOOGLE_CREDENTIALS", ""),
+				ConflictsWith: []string{schemaKeyGCPAccessToken},
+			},
+			schemaKeyGCPAccessToken: {
+				Type:          schema.TypeString,
+				Optional:      true,
+				Sensitive:     true,
+				DefaultFunc:   schema.EnvDefaultFunc("GOOGLE_OAUTH_ACCESS_TOKEN", ""),
+				ConflictsWith: []string{schemaKeyGCPCredentials},
+			},
+			schemaKeyGCPImpersonateServiceAccount: {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("GOOGLE_IMPERSONATE_SERVICE_ACCOUNT", ""),
+			},
+			schemaKeyAzureSubscriptionID: {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("ARM_SUBSCRIPTION_ID", ""),
+			},
+			schemaKeyAzureResourceGroup: {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
 			schemaKeyAWSProfile: {
 				Type:     schema.TypeString,
 				Optional: true,
-				Default:  "default",
+			},
+			schemaKeyAWSAccessKey: {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			schemaKeyAWSSecretKey: {
+				Type:      schema.TypeString,
+				Optional:  true,
+				Sensitive: true,
+			},
+			schemaKeyAWSSessionToken: {
+				Type:      schema.TypeString,
+				Optional:  true,
+				Sensitive: true,
+			},
+			schemaKeyAWSAssumeRole: {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						schemaKeyAWSRoleARN: {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+						schemaKeyAWSExternalID: {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+						schemaKeyAWSSessionName: {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+						schemaKeyAWSDuration: {
+							Type:             schema.TypeString,
+							Optional:         true,
+							ValidateDiagFunc: validateDuration,
+						},
+					},
+				},
+			},
+			schemaKeyAWSAssumeRoleWebIdentity: {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						schemaKeyAWSRoleARN: {
+							Type:        schema.TypeString,
+							Optional:    true,
+							DefaultFunc: schema.EnvDefaultFunc("AWS_ROLE_ARN", ""),
+						},
+						schemaKeyAWSSessionName: {
+							Type:        schema.TypeString,
+							Optional:    true,
+							DefaultFunc: schema.EnvDefaultFunc("AWS_ROLE_SESSION_NAME", ""),
+						},
+						schemaKeyAWSWebIdentityTokenFile: {
+							Type:        schema.TypeString,
+							Optional:    true,
+							DefaultFunc: schema.EnvDefaultFunc("AWS_WEB_IDENTITY_TOKEN_FILE", ""),
+						},
+					},
+				},
 			},
 			schemaKeyCloud: {
 				Type:     schema.TypeString,
 				Required: true,
 			},
+			schemaKeyStaticHosts: {
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						schemaKeyStaticHostAddress: {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+						schemaKeyStaticHostPrivateAddress: {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+						schemaKeyStaticHostSSHUser: {
+							Type:     schema.TypeString,
+							Optional: true,
+							Default:  "ubuntu",
+						},
+						schemaKeyStaticHostSSHKeyPath: {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+						schemaKeyStaticHostLabels: {
+							Type:     schema.TypeMap,
+							Optional: true,
+							Elem: &schema.Schema{
+								Type: schema.TypeString,
+							},
+						},
+					},
+				},
+			},
+			schemaKeyStaticWipeOnDestroy: {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
 			schemaKeyIgnoreErrorsOnDestroy: {
 				Type:     schema.TypeBool,
 				Optional: true,
@@ -66,39 +226,135 @@ func New() *schema.Provider {
 				Optional: true,
 				Default:  false,
 			},
+			schemaKeyVaultAddress: {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("VAULT_ADDR", ""),
+			},
+			schemaKeyVaultToken: {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Sensitive:   true,
+				DefaultFunc: schema.EnvDefaultFunc("VAULT_TOKEN", ""),
+			},
 		},
 		ResourcesMap: resource.ResourcesMap(
 			new(ps.PerconaServer),
 			new(pmm.PMM),
 			new(pxc.PerconaXtraDBCluster),
 			new(pmm.RDS),
+			new(mysql.User),
+			new(mysql.Database),
+			new(mysql.Grant),
 		),
 		ConfigureContextFunc: Configure,
 	}
 }
 
 func Configure(_ context.Context, data *schema.ResourceData) (interface{}, diag.Diagnostics) {
+	meta := cloud.Metadata{
+		IgnoreErrorsOnDestroy: data.Get(schemaKeyIgnoreErrorsOnDestroy).(bool),
+		DisableTelemetry:      data.Get(schemaKeyDisableTelemetry).(bool),
+		VaultAddress:          data.Get(schemaKeyVaultAddress).(string),
+		VaultToken:            data.Get(schemaKeyVaultToken).(string),
+	}
 	cloudOpt := data.Get(schemaKeyCloud).(string)
+	if cloudOpt != "static" && data.Get(schemaKeyCloudRegion).(string) == "" {
+		return nil, diag.Errorf("%s is required for %s cloud", schemaKeyCloudRegion, cloudOpt)
+	}
 	switch cloudOpt {
 	case "aws":
 		return cloud.Cloud(&awsCloud.Cloud{
-			Region:  aws.String(data.Get(schemaKeyCloudRegion).(string)),
-			Profile: aws.String(data.Get(schemaKeyAWSProfile).(string)),
-			Meta: cloud.Metadata{
-				IgnoreErrorsOnDestroy: data.Get(schemaKeyIgnoreErrorsOnDestroy).(bool),
-				DisableTelemetry:      data.Get(schemaKeyDisableTelemetry).(bool),
-			},
+			Region:       aws.String(data.Get(schemaKeyCloudRegion).(string)),
+			Profile:      aws.String(data.Get(schemaKeyAWSProfile).(string)),
+			AccessKey:    aws.String(data.Get(schemaKeyAWSAccessKey).(string)),
+			SecretKey:    aws.String(data.Get(schemaKeyAWSSecretKey).(string)),
+			SessionToken: aws.String(data.Get(schemaKeyAWSSessionToken).(string)),
+			AssumeRole:   awsAssumeRole(data),
+			WebIdentity:  awsWebIdentity(data),
+			Meta:         meta,
 		}), nil
 	case "gcp":
 		return cloud.Cloud(&gcp.Cloud{
-			Project: data.Get(schemaKeyGCPProject).(string),
-			Region:  data.Get(schemaKeyCloudRegion).(string),
-			Zone:    data.Get(schemaKeyGCPZone).(string),
-			Meta: cloud.Metadata{
-				IgnoreErrorsOnDestroy: data.Get(schemaKeyIgnoreErrorsOnDestroy).(bool),
-				DisableTelemetry:      data.Get(schemaKeyDisableTelemetry).(bool),
-			},
+			Project:                   data.Get(schemaKeyGCPProject).(string),
+			Region:                    data.Get(schemaKeyCloudRegion).(string),
+			Zone:                      data.Get(schemaKeyGCPZone).(string),
+			CredentialsFile:           data.Get(schemaKeyGCPCredentials).(string),
+			AccessToken:               data.Get(schemaKeyGCPAccessToken).(string),
+			ImpersonateServiceAccount: data.Get(schemaKeyGCPImpersonateServiceAccount).(string),
+			Meta:                      meta,
+		}), nil
+	case "azure":
+		return cloud.Cloud(&azure.Cloud{
+			SubscriptionID: data.Get(schemaKeyAzureSubscriptionID).(string),
+			Location:       data.Get(schemaKeyCloudRegion).(string),
+			ResourceGroup:  data.Get(schemaKeyAzureResourceGroup).(string),
+			Meta:           meta,
+		}), nil
+	case "static":
+		return cloud.Cloud(&static.Cloud{
+			Hosts:         staticHosts(data),
+			WipeOnDestroy: data.Get(schemaKeyStaticWipeOnDestroy).(bool),
+			Meta:          meta,
 		}), nil
 	}
 	return nil, diag.FromErr(errors.New("cloud is not supported"))
 }
+
+func awsAssumeRole(data *schema.ResourceData) *awsCloud.AssumeRole {
+	v := data.Get(schemaKeyAWSAssumeRole).([]interface{})
+	if len(v) == 0 || v[0] == nil {
+		return nil
+	}
+	r := v[0].(map[string]interface{})
+	// duration is validated by the schema
+	duration, _ := time.ParseDuration(r[schemaKeyAWSDuration].(string))
+	return &awsCloud.AssumeRole{
+		RoleARN:     r[schemaKeyAWSRoleARN].(string),
+		ExternalID:  r[schemaKeyAWSExternalID].(string),
+		SessionName: r[schemaKeyAWSSessionName].(string),
+		Duration:    duration,
+	}
+}
+
+func awsWebIdentity(data *schema.ResourceData) *awsCloud.WebIdentity {
+	v := data.Get(schemaKeyAWSAssumeRoleWebIdentity).([]interface{})
+	if len(v) == 0 || v[0] == nil {
+		return nil
+	}
+	r := v[0].(map[string]interface{})
+	return &awsCloud.WebIdentity{
+		RoleARN:     r[schemaKeyAWSRoleARN].(string),
+		SessionName: r[schemaKeyAWSSessionName].(string),
+		TokenFile:   r[schemaKeyAWSWebIdentityTokenFile].(string),
+	}
+}
+
+func validateDuration(v interface{}, path cty.Path) diag.Diagnostics {
+	if v.(string) == "" {
+		return nil
+	}
+	if _, err := time.ParseDuration(v.(string)); err != nil {
+		return diag.Errorf("invalid duration: %s, use Go duration format, e.g. 1h", v.(string))
+	}
+	return nil
+}
+
+func staticHosts(data *schema.ResourceData) []static.Host {
+	var hosts []static.Host
+	for _, v := range data.Get(schemaKeyStaticHosts).([]interface{}) {
+		h := v.(map[string]interface{})
+		labels := make(map[string]string)
+		for k, v := range h[schemaKeyStaticHostLabels].(map[string]interface{}) {
+			labels[k] = v.(string)
+		}
+		hosts = append(hosts, static.Host{
+			Address:        h[schemaKeyStaticHostAddress].(string),
+			PrivateAddress: h[schemaKeyStaticHostPrivateAddress].(string),
+			User:           h[schemaKeyStaticHostSSHUser].(string),
+			KeyPath:        h[schemaKeyStaticHostSSHKeyPath].(string),
+			Labels:         labels,
+		})
+	}
+	return hosts
+}
@@ -0,0 +1,83 @@
+package resource
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"regexp"
+	"sort"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/pkg/errors"
+)
+
+// optionNameRegexp matches names of mysqld options, which are used as is in SET statements.
+var optionNameRegexp = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_.-]*$`)
+
+// MySQLDOptions returns mysqld_options of the resource.
+func MySQLDOptions(data *schema.ResourceData) map[string]string {
+	return stringMap(data.Get(SchemaKeyMySQLDOptions))
+}
+
+// PreviousMySQLDOptions returns tuning_profile and mysqld_options before the change.
+func PreviousMySQLDOptions(data *schema.ResourceData) (profile string, options map[string]string) {
+	p, _ := data.GetChange(SchemaKeyTuningProfile)
+	o, _ := data.GetChange(SchemaKeyMySQLDOptions)
+	return p.(string), stringMap(o)
+}
+
+// ConfigChanges returns added or changed options and sorted names of the removed ones.
+func ConfigChanges(oldOptions, newOptions map[string]string) (changed map[string]string, removed []string) {
+	changed = make(map[string]string)
+	for name, value := range newOptions {
+		if oldValue, ok := oldOptions[name]; !ok || oldValue != value {
+			changed[name] = value
+		}
+	}
+	for name := range oldOptions {
+		if _, ok := newOptions[name]; !ok {
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(removed)
+	return changed, removed
+}
+
+func stringMap(v interface{}) map[string]string {
+	m, _ := v.(map[string]interface{})
+	result := make(map[string]string, len(m))
+	for k, v := range m {
+		result[k] = v.(string)
+	}
+	return result
+}
+
+// ConfigFileHash returns SHA-256 hash of the config file content, the hash of an empty path is empty.
+func ConfigFileHash(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read config file")
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// customizeConfigFileHash plans the update of config_file_hash if the content of the config file was changed,
+// so that the change shows up in the plan even if config_file_path is the same.
+func customizeConfigFileHash(ctx context.Context, diff *schema.ResourceDiff, meta interface{}) error {
+	if !diff.NewValueKnown(SchemaKeyConfigFilePath) {
+		return diff.SetNewComputed(SchemaKeyConfigFileHash)
+	}
+	hash, err := ConfigFileHash(diff.Get(SchemaKeyConfigFilePath).(string))
+	if err != nil {
+		return err
+	}
+	if diff.Get(SchemaKeyConfigFileHash).(string) == hash {
+		return nil
+	}
+	return diff.SetNew(SchemaKeyConfigFileHash, hash)
+}
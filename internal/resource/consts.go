@@ -1,11 +1,14 @@
 package resource
 
 import (
+	"strings"
+
 	"github.com/hashicorp/go-cty/cty"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"golang.org/x/mod/semver"
 
+	"terraform-percona/internal/secrets"
 	"terraform-percona/internal/utils"
 )
 
@@ -24,8 +27,12 @@ const (
 	SchemaKeyPathToKeyPairStorage = "path_to_key_pair_storage"
 	SchemaKeyClusterSize          = "cluster_size"
 	SchemaKeyConfigFilePath       = "config_file_path"
+	SchemaKeyConfigFileHash       = "config_file_hash"
+	SchemaKeyMySQLDOptions        = "mysqld_options"
+	SchemaKeyTuningProfile        = "tuning_profile"
 	SchemaKeyInstanceType         = "instance_type"
 	SchemaKeyVersion              = "version"
+	SchemaKeyReleaseSeries        = "release_series"
 	SchemaKeyVolumeType           = "volume_type"
 	SchemaKeyVolumeSize           = "volume_size"
 	SchemaKeyVolumeIOPS           = "volume_iops"
@@ -35,6 +42,16 @@ const (
 	SchemaKeyRootPassword         = "password"
 	SchemaKeyPMMAddress           = "pmm_address"
 	SchemaKeyPMMPassword          = "pmm_password"
+	SchemaKeySecretStore          = "secret_store"
+	SchemaKeySecretPath           = "secret_path"
+	SchemaKeySecretVersion        = "secret_version"
+	SchemaKeyAutomationPassword   = "automation_password"
+	SchemaKeyAutomationCIDR       = "automation_cidr"
+	SchemaKeyProviderCIDR         = "provider_cidr"
+	SchemaKeyUsers                = "users"
+	SchemaKeyInitSQL              = "init_sql"
+	SchemaKeyCACertificate        = "ca_certificate"
+	SchemaKeyCAPrivateKey         = "ca_private_key"
 )
 
 func DefaultSchema() map[string]*schema.Schema {
@@ -84,6 +101,37 @@ func DefaultMySQLSchema() map[string]*schema.Schema {
 			Type:     schema.TypeString,
 			Optional: true,
 		},
+		SchemaKeyConfigFileHash: {
+			Type:     schema.TypeString,
+			Computed: true,
+		},
+		SchemaKeyMySQLDOptions: {
+			Type:     schema.TypeMap,
+			Optional: true,
+			Elem: &schema.Schema{
+				Type: schema.TypeString,
+			},
+			ValidateDiagFunc: func(v interface{}, path cty.Path) diag.Diagnostics {
+				for name := range v.(map[string]interface{}) {
+					if !optionNameRegexp.MatchString(name) {
+						return diag.Errorf("invalid mysqld option name: %s", name)
+					}
+				}
+				return nil
+			},
+		},
+		SchemaKeyTuningProfile: {
+			Type:     schema.TypeString,
+			Optional: true,
+			Default:  TuningProfileNone,
+			ValidateDiagFunc: func(v interface{}, path cty.Path) diag.Diagnostics {
+				profile := v.(string)
+				if profile != TuningProfileNone && profile != TuningProfileAuto {
+					return diag.Errorf("supported values for %s are: %s and %s", SchemaKeyTuningProfile, TuningProfileNone, TuningProfileAuto)
+				}
+				return nil
+			},
+		},
 		SchemaKeyVersion: {
 			Type:     schema.TypeString,
 			Optional: true,
@@ -98,27 +146,153 @@ func DefaultMySQLSchema() map[string]*schema.Schema {
 				return nil
 			},
 		},
+		SchemaKeyReleaseSeries: {
+			Type:     schema.TypeString,
+			Optional: true,
+			ValidateDiagFunc: func(v interface{}, path cty.Path) diag.Diagnostics {
+				series := v.(string)
+				for _, s := range releaseSeries {
+					if series == s {
+						return nil
+					}
+				}
+				return diag.Errorf("supported release series are: %s", strings.Join(releaseSeries, ", "))
+			},
+		},
 		SchemaKeyPort: {
 			Type:     schema.TypeInt,
 			Optional: true,
 			Default:  3306,
 		},
 		SchemaKeyRootPassword: {
-			Type:      schema.TypeString,
-			Optional:  true,
-			Default:   "password",
-			Sensitive: true,
+			Type:          schema.TypeString,
+			Optional:      true,
+			Computed:      true,
+			Sensitive:     true,
+			ConflictsWith: []string{SchemaKeySecretStore},
 		},
 		SchemaKeyPMMAddress: {
 			Type:     schema.TypeString,
 			Optional: true,
 		},
 		SchemaKeyPMMPassword: {
+			Type:          schema.TypeString,
+			Optional:      true,
+			Computed:      true,
+			Sensitive:     true,
+			ConflictsWith: []string{SchemaKeySecretStore},
+		},
+		SchemaKeyAutomationPassword: {
+			Type:          schema.TypeString,
+			Optional:      true,
+			Computed:      true,
+			Sensitive:     true,
+			ConflictsWith: []string{SchemaKeySecretStore},
+		},
+		SchemaKeyAutomationCIDR: {
+			Type:     schema.TypeString,
+			Optional: true,
+			Computed: true,
+			ValidateDiagFunc: func(v interface{}, path cty.Path) diag.Diagnostics {
+				if _, err := MySQLHost(v.(string)); err != nil {
+					return diag.Errorf("invalid %s: %s", SchemaKeyAutomationCIDR, err)
+				}
+				return nil
+			},
+		},
+		// Address of the provider as it's seen by the instances on the last refresh
+		SchemaKeyProviderCIDR: {
+			Type:     schema.TypeString,
+			Computed: true,
+		},
+		SchemaKeyUsers: {
+			Type:     schema.TypeList,
+			Optional: true,
+			// Passwords of the users are nested, so the whole list is kept out of logs and telemetry
+			Sensitive: true,
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					SchemaKeyUsersName: {
+						Type:     schema.TypeString,
+						Required: true,
+						ValidateDiagFunc: func(v interface{}, path cty.Path) diag.Diagnostics {
+							if !userNameRegexp.MatchString(v.(string)) {
+								return diag.Errorf("invalid user name: %s", v.(string))
+							}
+							return nil
+						},
+					},
+					SchemaKeyUsersHost: {
+						Type:     schema.TypeString,
+						Optional: true,
+						Default:  "%",
+					},
+					SchemaKeyUsersPassword: {
+						Type:      schema.TypeString,
+						Required:  true,
+						Sensitive: true,
+					},
+					SchemaKeyUsersPrivileges: {
+						Type:     schema.TypeString,
+						Optional: true,
+						Default:  "ALL PRIVILEGES",
+						ValidateDiagFunc: func(v interface{}, path cty.Path) diag.Diagnostics {
+							if !privilegesRegexp.MatchString(v.(string)) {
+								return diag.Errorf("invalid privileges: %s", v.(string))
+							}
+							return nil
+						},
+					},
+					SchemaKeyUsersDatabase: {
+						Type:     schema.TypeString,
+						Optional: true,
+						Default:  "*",
+					},
+				},
+			},
+		},
+		SchemaKeyInitSQL: {
+			Type:             schema.TypeList,
+			Optional:         true,
+			DiffSuppressFunc: suppressInitSQLDiff,
+			// Inline statements may contain credentials, so they are kept out of logs and telemetry
+			Sensitive: true,
+			Elem: &schema.Schema{
+				Type: schema.TypeString,
+			},
+		},
+		SchemaKeyCACertificate: {
+			Type:     schema.TypeString,
+			Computed: true,
+		},
+		SchemaKeyCAPrivateKey: {
 			Type:      schema.TypeString,
-			Optional:  true,
-			Default:   "password",
+			Computed:  true,
 			Sensitive: true,
 		},
+		SchemaKeySecretStore: {
+			Type:         schema.TypeString,
+			Optional:     true,
+			RequiredWith: []string{SchemaKeySecretPath},
+			ValidateDiagFunc: func(v interface{}, path cty.Path) diag.Diagnostics {
+				store := v.(string)
+				if store != secrets.StoreVault && store != secrets.StoreAWS && store != secrets.StoreGCP {
+					return diag.Errorf("supported secret stores are: %s, %s and %s", secrets.StoreVault, secrets.StoreAWS, secrets.StoreGCP)
+				}
+				return nil
+			},
+		},
+		SchemaKeySecretPath: {
+			Type:         schema.TypeString,
+			Optional:     true,
+			RequiredWith: []string{SchemaKeySecretStore},
+		},
+		SchemaKeySecretVersion: {
+			Type:         schema.TypeString,
+			Optional:     true,
+			Computed:     true,
+			RequiredWith: []string{SchemaKeySecretStore},
+		},
 	})
 }
 
@@ -0,0 +1,46 @@
+package resource
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/pkg/errors"
+)
+
+// InitScript is an item of init_sql: the content of a local file or inline statements.
+type InitScript struct {
+	// Name is the path of the file or init_sql[i] for inline statements, it's used in errors
+	Name string
+	SQL  string
+}
+
+// initSQLFilePrefix marks the items of init_sql, which are paths of local files.
+const initSQLFilePrefix = "file://"
+
+// InitScripts returns the scripts of init_sql in their order.
+// Items with file:// prefix are read from the local files, others are inline statements.
+func InitScripts(data *schema.ResourceData) ([]InitScript, error) {
+	items, _ := data.Get(SchemaKeyInitSQL).([]interface{})
+	scripts := make([]InitScript, 0, len(items))
+	for i, item := range items {
+		value, _ := item.(string)
+		if strings.HasPrefix(value, initSQLFilePrefix) {
+			path := strings.TrimPrefix(value, initSQLFilePrefix)
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to read init sql file %s", path)
+			}
+			scripts = append(scripts, InitScript{Name: path, SQL: string(content)})
+			continue
+		}
+		scripts = append(scripts, InitScript{Name: SchemaKeyInitSQL + "[" + strconv.Itoa(i) + "]", SQL: value})
+	}
+	return scripts, nil
+}
+
+// suppressInitSQLDiff hides changes of init_sql of existing resources, as the scripts are executed only on creation.
+func suppressInitSQLDiff(_, _, _ string, d *schema.ResourceData) bool {
+	return d.Id() != ""
+}
@@ -0,0 +1,75 @@
+package resource
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestInitScripts(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "schema.sql")
+	if err := os.WriteFile(file, []byte("CREATE DATABASE app;"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	schemaMap := map[string]*schema.Schema{SchemaKeyInitSQL: DefaultMySQLSchema()[SchemaKeyInitSQL]}
+
+	data := schema.TestResourceDataRaw(t, schemaMap, map[string]interface{}{
+		// A path without the prefix is an inline statement, even if the file exists
+		SchemaKeyInitSQL: []interface{}{"file://" + file, file},
+	})
+	scripts, err := InitScripts(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []InitScript{
+		{Name: file, SQL: "CREATE DATABASE app;"},
+		{Name: "init_sql[1]", SQL: file},
+	}
+	if !reflect.DeepEqual(scripts, want) {
+		t.Errorf("InitScripts() = %v, want %v", scripts, want)
+	}
+
+	data = schema.TestResourceDataRaw(t, schemaMap, map[string]interface{}{
+		SchemaKeyInitSQL: []interface{}{"file://" + filepath.Join(dir, "missing.sql")},
+	})
+	if _, err := InitScripts(data); err == nil {
+		t.Error("InitScripts() succeeded with a missing file, want error")
+	}
+}
+
+// TestInitSQLDiff checks that init_sql changes of an existing resource are not planned, as the scripts are executed only on creation.
+func TestInitSQLDiff(t *testing.T) {
+	r := &schema.Resource{Schema: map[string]*schema.Schema{SchemaKeyInitSQL: DefaultMySQLSchema()[SchemaKeyInitSQL]}}
+	config := terraform.NewResourceConfigRaw(map[string]interface{}{
+		SchemaKeyInitSQL: []interface{}{"SELECT 1", "SELECT 2"},
+	})
+
+	diff, err := r.Diff(context.Background(), nil, config, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff.Empty() {
+		t.Error("init_sql of a new resource is not planned")
+	}
+
+	state := &terraform.InstanceState{
+		ID: "test",
+		Attributes: map[string]string{
+			SchemaKeyInitSQL + ".#": "1",
+			SchemaKeyInitSQL + ".0": "SELECT 1",
+		},
+	}
+	diff, err = r.Diff(context.Background(), state, config, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !diff.Empty() {
+		t.Errorf("init_sql change of an existing resource is planned: %v", diff)
+	}
+}
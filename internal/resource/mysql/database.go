@@ -0,0 +1,151 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/pkg/errors"
+
+	"terraform-percona/internal/cloud"
+	"terraform-percona/internal/utils"
+)
+
+const (
+	schemaKeyDatabaseName      = "name"
+	schemaKeyDatabaseCharset   = "charset"
+	schemaKeyDatabaseCollation = "collation"
+)
+
+// Database is a database of the percona_ps or percona_pxc cluster, its id is <cluster_id>/<name>.
+type Database struct {
+}
+
+func (r *Database) Name() string {
+	return "mysql_database"
+}
+
+func (r *Database) Schema() map[string]*schema.Schema {
+	return utils.MergeSchemas(connectionSchema(), map[string]*schema.Schema{
+		schemaKeyDatabaseName: {
+			Type:             schema.TypeString,
+			Required:         true,
+			ForceNew:         true,
+			ValidateDiagFunc: validateDatabaseName,
+		},
+		schemaKeyDatabaseCharset: {
+			Type:     schema.TypeString,
+			Optional: true,
+			Computed: true,
+		},
+		schemaKeyDatabaseCollation: {
+			Type:     schema.TypeString,
+			Optional: true,
+			Computed: true,
+		},
+	})
+}
+
+func (r *Database) Create(ctx context.Context, data *schema.ResourceData, c cloud.Cloud) diag.Diagnostics {
+	db, err := connectWriter(ctx, data, c)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	defer db.Close()
+
+	name := data.Get(schemaKeyDatabaseName).(string)
+	// Existing databases aren't taken over, as they would be dropped with the resource
+	_, _, err = db.Database(ctx, name)
+	if err == nil {
+		return diag.Errorf("database %s already exists, use terraform import with %s/%s id to manage it", name, data.Get(schemaKeyClusterID).(string), name)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return diag.FromErr(errors.Wrap(err, "can't check database"))
+	}
+	err = db.CreateDatabase(ctx, name, data.Get(schemaKeyDatabaseCharset).(string), data.Get(schemaKeyDatabaseCollation).(string))
+	if err != nil {
+		return diag.FromErr(errors.Wrapf(err, "can't create database %s", name))
+	}
+	data.SetId(data.Get(schemaKeyClusterID).(string) + "/" + name)
+	tflog.Info(ctx, "MySQL database created", map[string]interface{}{"id": data.Id()})
+	return r.Read(ctx, data, c)
+}
+
+func (r *Database) Read(ctx context.Context, data *schema.ResourceData, c cloud.Cloud) diag.Diagnostics {
+	if !adminPasswordKnown(ctx, data) {
+		return nil
+	}
+	db, err := connectWriter(ctx, data, c)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	defer db.Close()
+
+	charset, collation, err := db.Database(ctx, data.Get(schemaKeyDatabaseName).(string))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			tflog.Warn(ctx, "Database is not found, removing resource from state", map[string]interface{}{"id": data.Id()})
+			data.SetId("")
+			return nil
+		}
+		return diag.FromErr(errors.Wrap(err, "can't read database"))
+	}
+	if err := data.Set(schemaKeyDatabaseCharset, charset); err != nil {
+		return diag.FromErr(errors.Wrap(err, "can't set charset"))
+	}
+	if err := data.Set(schemaKeyDatabaseCollation, collation); err != nil {
+		return diag.FromErr(errors.Wrap(err, "can't set collation"))
+	}
+	return nil
+}
+
+func (r *Database) Update(ctx context.Context, data *schema.ResourceData, c cloud.Cloud) diag.Diagnostics {
+	if !data.HasChanges(schemaKeyDatabaseCharset, schemaKeyDatabaseCollation) {
+		return nil
+	}
+	db, err := connectWriter(ctx, data, c)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	defer db.Close()
+
+	charset := data.Get(schemaKeyDatabaseCharset).(string)
+	collation := data.Get(schemaKeyDatabaseCollation).(string)
+	if !data.HasChange(schemaKeyDatabaseCollation) {
+		// The collation in the state belongs to the previous charset, the default one of the new charset is used
+		collation = ""
+	}
+	if err := db.AlterDatabase(ctx, data.Get(schemaKeyDatabaseName).(string), charset, collation); err != nil {
+		return diag.FromErr(errors.Wrap(err, "can't alter database"))
+	}
+	return r.Read(ctx, data, c)
+}
+
+func (r *Database) Delete(ctx context.Context, data *schema.ResourceData, c cloud.Cloud) diag.Diagnostics {
+	db, err := connectWriter(ctx, data, c)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	defer db.Close()
+
+	if err := db.DropDatabase(ctx, data.Get(schemaKeyDatabaseName).(string)); err != nil {
+		return diag.FromErr(errors.Wrap(err, "can't drop database"))
+	}
+	return nil
+}
+
+func (r *Database) Import(_ context.Context, data *schema.ResourceData, _ cloud.Cloud) error {
+	parts, err := splitID(data.Id(), 2)
+	if err != nil {
+		return errors.Wrap(err, "import id should be in the form of <cluster_id>/<name>")
+	}
+	if err := data.Set(schemaKeyClusterID, parts[0]); err != nil {
+		return errors.Wrap(err, "can't set cluster id")
+	}
+	if err := data.Set(schemaKeyDatabaseName, parts[1]); err != nil {
+		return errors.Wrap(err, "can't set name")
+	}
+	return nil
+}
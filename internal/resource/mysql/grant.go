@@ -0,0 +1,224 @@
+package mysql
+
+import (
+	"context"
+	"sort"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/pkg/errors"
+
+	"terraform-percona/internal/cloud"
+	internalmysql "terraform-percona/internal/db/mysql"
+	"terraform-percona/internal/utils"
+)
+
+const (
+	schemaKeyGrantUser       = "user"
+	schemaKeyGrantHost       = "host"
+	schemaKeyGrantDatabase   = "database"
+	schemaKeyGrantPrivileges = "privileges"
+)
+
+const privilegeAll = "ALL PRIVILEGES"
+
+// Grant is a set of privileges of the user on all tables of the database, its id is <cluster_id>/<database>/<user>@<host>.
+type Grant struct {
+}
+
+func (r *Grant) Name() string {
+	return "mysql_grant"
+}
+
+func (r *Grant) Schema() map[string]*schema.Schema {
+	return utils.MergeSchemas(connectionSchema(), map[string]*schema.Schema{
+		schemaKeyGrantUser: {
+			Type:             schema.TypeString,
+			Required:         true,
+			ForceNew:         true,
+			ValidateDiagFunc: validateUserName,
+		},
+		schemaKeyGrantHost: {
+			Type:     schema.TypeString,
+			Optional: true,
+			ForceNew: true,
+			Default:  "%",
+		},
+		schemaKeyGrantDatabase: {
+			Type:     schema.TypeString,
+			Optional: true,
+			ForceNew: true,
+			Default:  "*",
+		},
+		schemaKeyGrantPrivileges: {
+			Type:     schema.TypeSet,
+			Required: true,
+			MinItems: 1,
+			Elem: &schema.Schema{
+				Type: schema.TypeString,
+				ValidateDiagFunc: func(v interface{}, path cty.Path) diag.Diagnostics {
+					if !privilegeRegexp.MatchString(v.(string)) {
+						return diag.Errorf("invalid privilege %q, privileges should be upper case, e.g. SELECT or %s", v.(string), privilegeAll)
+					}
+					return nil
+				},
+			},
+		},
+	})
+}
+
+func (r *Grant) Create(ctx context.Context, data *schema.ResourceData, c cloud.Cloud) diag.Diagnostics {
+	db, err := connectWriter(ctx, data, c)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	defer db.Close()
+
+	user, host, database := grantTarget(data)
+	if err := db.Grant(ctx, user, host, privilegesOf(data.Get(schemaKeyGrantPrivileges)), database); err != nil {
+		return diag.FromErr(errors.Wrapf(err, "can't grant privileges to %s@%s", user, host))
+	}
+	data.SetId(data.Get(schemaKeyClusterID).(string) + "/" + database + "/" + user + "@" + host)
+	tflog.Info(ctx, "MySQL grant created", map[string]interface{}{"id": data.Id()})
+	return nil
+}
+
+func (r *Grant) Read(ctx context.Context, data *schema.ResourceData, c cloud.Cloud) diag.Diagnostics {
+	if !adminPasswordKnown(ctx, data) {
+		return nil
+	}
+	db, err := connectWriter(ctx, data, c)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	defer db.Close()
+
+	user, host, database := grantTarget(data)
+	exists, err := db.UserExists(ctx, user, host)
+	if err != nil {
+		return diag.FromErr(errors.Wrap(err, "can't read user"))
+	}
+	var privileges []string
+	if exists {
+		grants, err := db.Grants(ctx, user, host)
+		if err != nil {
+			return diag.FromErr(errors.Wrap(err, "can't read grants"))
+		}
+		privileges = grantedPrivileges(privilegesOf(data.Get(schemaKeyGrantPrivileges)), grants, database)
+	}
+	if len(privileges) == 0 {
+		tflog.Warn(ctx, "Grant is not found, removing resource from state", map[string]interface{}{"id": data.Id()})
+		data.SetId("")
+		return nil
+	}
+	if err := data.Set(schemaKeyGrantPrivileges, privileges); err != nil {
+		return diag.FromErr(errors.Wrap(err, "can't set privileges"))
+	}
+	return nil
+}
+
+// grantedPrivileges returns the privileges of the state which are still granted on the database.
+// ALL PRIVILEGES is reported as the list of privileges on *.* since 8.0, so it's considered granted if any of them is.
+func grantedPrivileges(privileges []string, grants map[string][]string, database string) []string {
+	level := internalmysql.DatabaseLevel(database)
+	granted := make(map[string]bool, len(grants[level]))
+	for _, privilege := range grants[level] {
+		if privilege != "USAGE" {
+			granted[privilege] = true
+		}
+	}
+	result := make([]string, 0, len(privileges))
+	for _, privilege := range privileges {
+		if granted[privilege] || privilege == privilegeAll && database == "*" && len(granted) > 0 {
+			result = append(result, privilege)
+		}
+	}
+	return result
+}
+
+// Update revokes the removed privileges and grants the added ones.
+func (r *Grant) Update(ctx context.Context, data *schema.ResourceData, c cloud.Cloud) diag.Diagnostics {
+	if !data.HasChange(schemaKeyGrantPrivileges) {
+		return nil
+	}
+	db, err := connectWriter(ctx, data, c)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	defer db.Close()
+
+	user, host, database := grantTarget(data)
+	o, n := data.GetChange(schemaKeyGrantPrivileges)
+	oldSet, newSet := o.(*schema.Set), n.(*schema.Set)
+	if err := db.Revoke(ctx, user, host, privilegesOf(oldSet.Difference(newSet)), database); err != nil {
+		return diag.FromErr(errors.Wrapf(err, "can't revoke privileges from %s@%s", user, host))
+	}
+	if err := db.Grant(ctx, user, host, privilegesOf(newSet.Difference(oldSet)), database); err != nil {
+		return diag.FromErr(errors.Wrapf(err, "can't grant privileges to %s@%s", user, host))
+	}
+	return nil
+}
+
+func (r *Grant) Delete(ctx context.Context, data *schema.ResourceData, c cloud.Cloud) diag.Diagnostics {
+	db, err := connectWriter(ctx, data, c)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	defer db.Close()
+
+	user, host, database := grantTarget(data)
+	exists, err := db.UserExists(ctx, user, host)
+	if err != nil {
+		return diag.FromErr(errors.Wrap(err, "can't read user"))
+	}
+	if !exists {
+		return nil
+	}
+	if err := db.Revoke(ctx, user, host, privilegesOf(data.Get(schemaKeyGrantPrivileges)), database); err != nil {
+		return diag.FromErr(errors.Wrapf(err, "can't revoke privileges from %s@%s", user, host))
+	}
+	return nil
+}
+
+// Import sets the user and the database from the id, privileges of the configuration are granted on the next apply.
+func (r *Grant) Import(_ context.Context, data *schema.ResourceData, _ cloud.Cloud) error {
+	parts, err := splitID(data.Id(), 3)
+	if err != nil {
+		return errors.Wrap(err, "import id should be in the form of <cluster_id>/<database>/<user>@<host>")
+	}
+	user, host, err := splitAccount(parts[2])
+	if err != nil {
+		return err
+	}
+	values := map[string]string{
+		schemaKeyClusterID:     parts[0],
+		schemaKeyGrantDatabase: parts[1],
+		schemaKeyGrantUser:     user,
+		schemaKeyGrantHost:     host,
+	}
+	for key, value := range values {
+		if err := data.Set(key, value); err != nil {
+			return errors.Wrapf(err, "can't set %s", key)
+		}
+	}
+	return nil
+}
+
+func grantTarget(data *schema.ResourceData) (user, host, database string) {
+	return data.Get(schemaKeyGrantUser).(string), data.Get(schemaKeyGrantHost).(string), data.Get(schemaKeyGrantDatabase).(string)
+}
+
+func privilegesOf(v interface{}) []string {
+	set, ok := v.(*schema.Set)
+	if !ok {
+		return nil
+	}
+	privileges := make([]string, 0, set.Len())
+	for _, p := range set.List() {
+		privileges = append(privileges, p.(string))
+	}
+	sort.Strings(privileges)
+	return privileges
+}
@@ -0,0 +1,192 @@
+package mysql
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/pkg/errors"
+
+	"terraform-percona/internal/cloud"
+	internaldb "terraform-percona/internal/db"
+	internalmysql "terraform-percona/internal/db/mysql"
+	"terraform-percona/internal/resource"
+)
+
+const (
+	schemaKeyClusterID     = "cluster_id"
+	schemaKeyAdminUser     = "admin_user"
+	schemaKeyAdminPassword = "admin_password"
+)
+
+var (
+	userNameRegexp  = regexp.MustCompile(`^[a-zA-Z0-9_.-]{1,32}$`)
+	privilegeRegexp = regexp.MustCompile(`^[A-Z][A-Z_]*( [A-Z_]+)*$`)
+)
+
+// connectionSchema returns the attributes which locate the percona_ps or percona_pxc cluster and the account used to manage it.
+func connectionSchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		schemaKeyClusterID: {
+			Type:     schema.TypeString,
+			Required: true,
+			ForceNew: true,
+		},
+		resource.SchemaKeyPort: {
+			Type:     schema.TypeInt,
+			Optional: true,
+			Default:  3306,
+		},
+		schemaKeyAdminUser: {
+			Type:     schema.TypeString,
+			Optional: true,
+			Default:  internaldb.UserAutomation,
+		},
+		schemaKeyAdminPassword: {
+			Type:      schema.TypeString,
+			Required:  true,
+			Sensitive: true,
+		},
+		resource.SchemaKeyCACertificate: {
+			Type:     schema.TypeString,
+			Optional: true,
+		},
+	}
+}
+
+// reservedUsers are the accounts the provider uses to manage the cluster, changing or dropping them breaks the cluster.
+var reservedUsers = []string{
+	internaldb.UserRoot,
+	internaldb.UserAutomation,
+	internaldb.UserReplica,
+	internaldb.UserPMM,
+	internaldb.UserOrchestrator,
+}
+
+// systemSchemas are the databases of the server itself.
+var systemSchemas = []string{"mysql", "sys", "information_schema", "performance_schema"}
+
+func validateUserName(v interface{}, path cty.Path) diag.Diagnostics {
+	name := v.(string)
+	if !userNameRegexp.MatchString(name) {
+		return diag.Errorf("invalid user name: %s", name)
+	}
+	// mysql.sys, mysql.session and other mysql.* accounts belong to the server
+	if strings.HasPrefix(name, "mysql.") {
+		return diag.Errorf("user %s is managed by the server and can't be used", name)
+	}
+	for _, reserved := range reservedUsers {
+		if name == reserved {
+			return diag.Errorf("user %s is managed by the provider and can't be used", name)
+		}
+	}
+	return nil
+}
+
+func validateDatabaseName(v interface{}, path cty.Path) diag.Diagnostics {
+	name := v.(string)
+	if name == "" {
+		return diag.Errorf("database name can't be empty")
+	}
+	for _, systemSchema := range systemSchemas {
+		if strings.EqualFold(name, systemSchema) {
+			return diag.Errorf("database %s is a system schema and can't be managed", name)
+		}
+	}
+	return nil
+}
+
+// connectWriter opens a connection to the node of the cluster which accepts writes:
+// the source instance of percona_ps or a synced node of percona_pxc.
+func connectWriter(ctx context.Context, data *schema.ResourceData, c cloud.Cloud) (*internalmysql.DB, error) {
+	clusterID := data.Get(schemaKeyClusterID).(string)
+	if err := c.Configure(ctx, clusterID, nil); err != nil {
+		return nil, errors.Wrap(err, "can't configure cloud")
+	}
+	instances, err := c.ListInstances(ctx, clusterID, map[string]string{
+		resource.LabelKeyInstanceType: resource.LabelValueInstanceTypeMySQL,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list instances")
+	}
+	if len(instances) == 0 {
+		return nil, errors.Errorf("no instances found with %s=%s", resource.LabelKeyResourceID, clusterID)
+	}
+	port := strconv.Itoa(data.Get(resource.SchemaKeyPort).(int))
+	user := data.Get(schemaKeyAdminUser).(string)
+	password := data.Get(schemaKeyAdminPassword).(string)
+	caCert := data.Get(resource.SchemaKeyCACertificate).(string)
+	for _, instance := range instances {
+		db, err := func() (*internalmysql.DB, error) {
+			db, err := newClient(instance.PublicIpAddress+":"+port, user, password, caCert)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to create new mysql client")
+			}
+			writer, err := db.IsWriter(ctx)
+			if err != nil || !writer {
+				db.Close()
+				return nil, err
+			}
+			return db, nil
+		}()
+		if err != nil {
+			tflog.Warn(ctx, "failed to check instance role", map[string]interface{}{
+				resource.LogArgInstanceIP: instance.PublicIpAddress,
+				"error":                   err,
+			})
+			continue
+		}
+		if db != nil {
+			return db, nil
+		}
+	}
+	return nil, errors.Errorf("failed to find writer node of cluster %s", clusterID)
+}
+
+// newClient opens a connection which verifies the server certificate with the CA certificate of the cluster if it's set.
+func newClient(host, user, password, caCert string) (*internalmysql.DB, error) {
+	if caCert != "" {
+		return internalmysql.NewTLSClient(host, user, password, []byte(caCert))
+	}
+	return internalmysql.NewClient(host, user, password)
+}
+
+// adminPasswordKnown reports whether the cluster can be accessed.
+// Imported resources don't have the admin password until the next apply, so they are read as is.
+func adminPasswordKnown(ctx context.Context, data *schema.ResourceData) bool {
+	if data.Get(schemaKeyAdminPassword).(string) != "" {
+		return true
+	}
+	tflog.Warn(ctx, "Admin password is unknown, skipping cluster check", map[string]interface{}{
+		"id": data.Id(),
+	})
+	return false
+}
+
+// splitID splits the resource id into the cluster id and the id of the object in the cluster.
+func splitID(id string, n int) ([]string, error) {
+	parts := strings.SplitN(id, "/", n)
+	if len(parts) != n {
+		return nil, errors.Errorf("invalid id %q", id)
+	}
+	for _, part := range parts {
+		if part == "" {
+			return nil, errors.Errorf("invalid id %q", id)
+		}
+	}
+	return parts, nil
+}
+
+// splitAccount splits the account in the form of name@host, the host can't be empty.
+func splitAccount(account string) (name, host string, err error) {
+	name, host, ok := strings.Cut(account, "@")
+	if !ok || name == "" || host == "" {
+		return "", "", errors.Errorf("invalid account %q, expected name@host", account)
+	}
+	return name, host, nil
+}
@@ -0,0 +1,42 @@
+package mysql
+
+import (
+	"testing"
+
+	"github.com/hashicorp/go-cty/cty"
+)
+
+func TestValidateNames(t *testing.T) {
+	tests := map[string]struct {
+		validate func(interface{}, cty.Path) bool
+		value    string
+		valid    bool
+	}{
+		"user":               {validate: userValid, value: "app", valid: true},
+		"invalid user":       {validate: userValid, value: "app user", valid: false},
+		"root":               {validate: userValid, value: "root", valid: false},
+		"automation user":    {validate: userValid, value: "percona_automation", valid: false},
+		"replica user":       {validate: userValid, value: "replica_user", valid: false},
+		"server user":        {validate: userValid, value: "mysql.sys", valid: false},
+		"database":           {validate: databaseValid, value: "app", valid: true},
+		"mysql schema":       {validate: databaseValid, value: "mysql", valid: false},
+		"upper case schema":  {validate: databaseValid, value: "Performance_Schema", valid: false},
+		"empty database":     {validate: databaseValid, value: "", valid: false},
+		"schema name prefix": {validate: databaseValid, value: "sys_app", valid: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if valid := tt.validate(tt.value, cty.Path{}); valid != tt.valid {
+				t.Errorf("valid = %t, want %t", valid, tt.valid)
+			}
+		})
+	}
+}
+
+func userValid(v interface{}, path cty.Path) bool {
+	return !validateUserName(v, path).HasError()
+}
+
+func databaseValid(v interface{}, path cty.Path) bool {
+	return !validateDatabaseName(v, path).HasError()
+}
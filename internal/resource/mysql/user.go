@@ -0,0 +1,148 @@
+package mysql
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/pkg/errors"
+
+	"terraform-percona/internal/cloud"
+	"terraform-percona/internal/utils"
+)
+
+const (
+	schemaKeyUserName     = "name"
+	schemaKeyUserHost     = "host"
+	schemaKeyUserPassword = "password"
+)
+
+// User is an account of the percona_ps or percona_pxc cluster, its id is <cluster_id>/<name>@<host>.
+// Privileges of the user are managed by percona_mysql_grant.
+type User struct {
+}
+
+func (r *User) Name() string {
+	return "mysql_user"
+}
+
+func (r *User) Schema() map[string]*schema.Schema {
+	return utils.MergeSchemas(connectionSchema(), map[string]*schema.Schema{
+		schemaKeyUserName: {
+			Type:             schema.TypeString,
+			Required:         true,
+			ForceNew:         true,
+			ValidateDiagFunc: validateUserName,
+		},
+		schemaKeyUserHost: {
+			Type:     schema.TypeString,
+			Optional: true,
+			ForceNew: true,
+			Default:  "%",
+		},
+		schemaKeyUserPassword: {
+			Type:      schema.TypeString,
+			Required:  true,
+			Sensitive: true,
+		},
+	})
+}
+
+func (r *User) Create(ctx context.Context, data *schema.ResourceData, c cloud.Cloud) diag.Diagnostics {
+	db, err := connectWriter(ctx, data, c)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	defer db.Close()
+
+	name, host := data.Get(schemaKeyUserName).(string), data.Get(schemaKeyUserHost).(string)
+	// Existing users aren't taken over, as they would be dropped with the resource
+	exists, err := db.UserExists(ctx, name, host)
+	if err != nil {
+		return diag.FromErr(errors.Wrap(err, "can't check user"))
+	}
+	if exists {
+		return diag.Errorf("user %s@%s already exists, use terraform import with %s/%s@%s id to manage it", name, host, data.Get(schemaKeyClusterID).(string), name, host)
+	}
+	if err := db.CreateUser(ctx, name, host, data.Get(schemaKeyUserPassword).(string)); err != nil {
+		return diag.FromErr(errors.Wrapf(err, "can't create user %s@%s", name, host))
+	}
+	data.SetId(data.Get(schemaKeyClusterID).(string) + "/" + name + "@" + host)
+	tflog.Info(ctx, "MySQL user created", map[string]interface{}{"id": data.Id()})
+	return nil
+}
+
+// Read checks that the user exists, the password can't be inspected.
+func (r *User) Read(ctx context.Context, data *schema.ResourceData, c cloud.Cloud) diag.Diagnostics {
+	if !adminPasswordKnown(ctx, data) {
+		return nil
+	}
+	db, err := connectWriter(ctx, data, c)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	defer db.Close()
+
+	exists, err := db.UserExists(ctx, data.Get(schemaKeyUserName).(string), data.Get(schemaKeyUserHost).(string))
+	if err != nil {
+		return diag.FromErr(errors.Wrap(err, "can't read user"))
+	}
+	if !exists {
+		tflog.Warn(ctx, "User is not found, removing resource from state", map[string]interface{}{"id": data.Id()})
+		data.SetId("")
+	}
+	return nil
+}
+
+func (r *User) Update(ctx context.Context, data *schema.ResourceData, c cloud.Cloud) diag.Diagnostics {
+	if !data.HasChange(schemaKeyUserPassword) {
+		return nil
+	}
+	db, err := connectWriter(ctx, data, c)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	defer db.Close()
+
+	name, host := data.Get(schemaKeyUserName).(string), data.Get(schemaKeyUserHost).(string)
+	if err := db.AlterUserPassword(ctx, name, host, data.Get(schemaKeyUserPassword).(string)); err != nil {
+		return diag.FromErr(errors.Wrapf(err, "can't change password of user %s@%s", name, host))
+	}
+	return nil
+}
+
+func (r *User) Delete(ctx context.Context, data *schema.ResourceData, c cloud.Cloud) diag.Diagnostics {
+	db, err := connectWriter(ctx, data, c)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	defer db.Close()
+
+	if err := db.DropUser(ctx, data.Get(schemaKeyUserName).(string), data.Get(schemaKeyUserHost).(string)); err != nil {
+		return diag.FromErr(errors.Wrap(err, "can't drop user"))
+	}
+	return nil
+}
+
+// Import sets the account from the id, the password is set from the configuration on the next apply.
+func (r *User) Import(_ context.Context, data *schema.ResourceData, _ cloud.Cloud) error {
+	parts, err := splitID(data.Id(), 2)
+	if err != nil {
+		return errors.Wrap(err, "import id should be in the form of <cluster_id>/<name>@<host>")
+	}
+	name, host, err := splitAccount(parts[1])
+	if err != nil {
+		return err
+	}
+	if err := data.Set(schemaKeyClusterID, parts[0]); err != nil {
+		return errors.Wrap(err, "can't set cluster id")
+	}
+	if err := data.Set(schemaKeyUserName, name); err != nil {
+		return errors.Wrap(err, "can't set name")
+	}
+	if err := data.Set(schemaKeyUserHost, host); err != nil {
+		return errors.Wrap(err, "can't set host")
+	}
+	return nil
+}
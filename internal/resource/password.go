@@ -0,0 +1,38 @@
+package resource
+
+import (
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/pkg/errors"
+
+	"terraform-percona/internal/utils"
+)
+
+const generatedPasswordLength = 24
+
+// GeneratePasswords sets random passwords for the attributes which are not set in the configuration.
+func GeneratePasswords(data *schema.ResourceData, keys ...string) error {
+	for _, key := range keys {
+		if _, ok := data.GetOk(key); ok {
+			continue
+		}
+		password, err := utils.GeneratePassword(generatedPasswordLength)
+		if err != nil {
+			return errors.Wrapf(err, "failed to generate %s", key)
+		}
+		if err := data.Set(key, password); err != nil {
+			return errors.Wrapf(err, "failed to set %s", key)
+		}
+	}
+	return nil
+}
+
+// RequirePasswords returns an error if any of the passwords is not set.
+// Passwords of imported resources can't be inspected, so they should be set in the configuration or the secret store.
+func RequirePasswords(data *schema.ResourceData, keys ...string) error {
+	for _, key := range keys {
+		if data.Get(key).(string) == "" {
+			return errors.Errorf("%s is unknown, passwords of imported clusters should be set in the configuration or the secret store", key)
+		}
+	}
+	return nil
+}
@@ -12,49 +12,54 @@ import (
 	"github.com/pkg/errors"
 )
 
-func (c *Client) DeleteServicesByResourceID(resourceID string) error {
+type Service struct {
+	ServiceID   string
+	ServiceName string
+}
+
+func (c *Client) ServicesByResourceID(resourceID string) ([]Service, error) {
 	resp, err := c.ServicesList(&ServicesListRequest{})
 	if err != nil {
-		return errors.Wrap(err, "services list")
+		return nil, errors.Wrap(err, "services list")
 	}
-	var serviceIDs []string
-	for _, service := range resp.Mysql {
-		if v, ok := service.CustomLabels[resource.LabelKeyResourceID]; ok && v == resourceID {
-			serviceIDs = append(serviceIDs, service.ServiceID)
+	var services []Service
+	add := func(id, name string, labels map[string]string) {
+		if v, ok := labels[resource.LabelKeyResourceID]; ok && v == resourceID {
+			services = append(services, Service{ServiceID: id, ServiceName: name})
 		}
 	}
+	for _, service := range resp.Mysql {
+		add(service.ServiceID, service.ServiceName, service.CustomLabels)
+	}
 	for _, service := range resp.Mongodb {
-		if v, ok := service.CustomLabels[resource.LabelKeyResourceID]; ok && v == resourceID {
-			serviceIDs = append(serviceIDs, service.ServiceID)
-		}
+		add(service.ServiceID, service.ServiceName, service.CustomLabels)
 	}
 	for _, service := range resp.Postgresql {
-		if v, ok := service.CustomLabels[resource.LabelKeyResourceID]; ok && v == resourceID {
-			serviceIDs = append(serviceIDs, service.ServiceID)
-		}
+		add(service.ServiceID, service.ServiceName, service.CustomLabels)
 	}
 	for _, service := range resp.Proxysql {
-		if v, ok := service.CustomLabels[resource.LabelKeyResourceID]; ok && v == resourceID {
-			serviceIDs = append(serviceIDs, service.ServiceID)
-		}
+		add(service.ServiceID, service.ServiceName, service.CustomLabels)
 	}
 	for _, service := range resp.Haproxy {
-		if v, ok := service.CustomLabels[resource.LabelKeyResourceID]; ok && v == resourceID {
-			serviceIDs = append(serviceIDs, service.ServiceID)
-		}
+		add(service.ServiceID, service.ServiceName, service.CustomLabels)
 	}
 	for _, service := range resp.External {
-		if v, ok := service.CustomLabels[resource.LabelKeyResourceID]; ok && v == resourceID {
-			serviceIDs = append(serviceIDs, service.ServiceID)
-		}
+		add(service.ServiceID, service.ServiceName, service.CustomLabels)
 	}
+	return services, nil
+}
 
-	for _, id := range serviceIDs {
+func (c *Client) DeleteServicesByResourceID(resourceID string) error {
+	services, err := c.ServicesByResourceID(resourceID)
+	if err != nil {
+		return err
+	}
+	for _, service := range services {
 		if err := c.ServicesRemove(&ServicesRemoveRequest{
-			ServiceID: id,
+			ServiceID: service.ServiceID,
 			Force:     true,
 		}); err != nil {
-			return errors.Wrapf(err, "failed to remove service %s", id)
+			return errors.Wrapf(err, "failed to remove service %s", service.ServiceID)
 		}
 	}
 	return nil
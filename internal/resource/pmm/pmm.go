@@ -69,6 +69,17 @@ func (r *PMM) Create(ctx context.Context, data *schema.ResourceData, c cloud.Clo
 	if err != nil {
 		return diag.FromErr(errors.Wrap(err, "can't configure cloud"))
 	}
+	rdsUsername := data.Get(schemaKeyRDSUsername).(string)
+	rdsPassword := data.Get(schemaKeyRDSPassword).(string)
+	rdsPMMUserPassword := data.Get(schemaKeyRDSPMMUserPassword).(string)
+	discoverRDS := rdsUsername != "" && rdsPassword != ""
+	var creds cloud.Credentials
+	if discoverRDS {
+		// Credentials are checked before the instance is created, as PMM can't use all of them
+		if creds, err = rdsCredentials(c); err != nil {
+			return diag.FromErr(err)
+		}
+	}
 	data.SetId(resourceID)
 	err = c.CreateInfrastructure(ctx, resourceID)
 	if err != nil {
@@ -95,11 +106,7 @@ func (r *PMM) Create(ctx context.Context, data *schema.ResourceData, c cloud.Clo
 		return diag.FromErr(errors.Wrap(err, "can't set instances"))
 	}
 
-	rdsUsername := data.Get(schemaKeyRDSUsername).(string)
-	rdsPassword := data.Get(schemaKeyRDSPassword).(string)
-	rdsPMMUserPassword := data.Get(schemaKeyRDSPMMUserPassword).(string)
-
-	if rdsUsername != "" && rdsPassword != "" {
+	if discoverRDS {
 		pmmAddress, err := utils.ParsePMMAddress("http://" + instance.PublicIpAddress)
 		if err != nil {
 			return diag.FromErr(errors.Wrap(err, "failed to parse pmm address"))
@@ -108,10 +115,6 @@ func (r *PMM) Create(ctx context.Context, data *schema.ResourceData, c cloud.Clo
 		if err != nil {
 			return diag.FromErr(err)
 		}
-		creds, err := c.Credentials()
-		if err != nil {
-			return diag.FromErr(err)
-		}
 		time.Sleep(time.Second * 30)
 		instances, err := pmmClient.RDSDiscover(creds.AccessKey, creds.SecretKey)
 		if err != nil {
@@ -135,8 +138,39 @@ func (r *PMM) Create(ctx context.Context, data *schema.ResourceData, c cloud.Clo
 	return nil
 }
 
-func (r *PMM) Read(_ context.Context, _ *schema.ResourceData, _ cloud.Cloud) diag.Diagnostics {
-	//TODO
+func (r *PMM) Read(ctx context.Context, data *schema.ResourceData, c cloud.Cloud) diag.Diagnostics {
+	resourceID := data.Id()
+	if resourceID == "" {
+		return diag.FromErr(errors.New("empty resource id"))
+	}
+
+	err := c.Configure(ctx, resourceID, data)
+	if err != nil {
+		return diag.FromErr(errors.Wrap(err, "can't configure cloud"))
+	}
+
+	instances, err := c.ListInstances(ctx, resourceID, nil)
+	if err != nil {
+		return diag.FromErr(errors.Wrap(err, "failed to list instances"))
+	}
+	if len(instances) == 0 {
+		tflog.Warn(ctx, "PMM instance is not found, removing resource from state", map[string]interface{}{
+			"resource_id": resourceID,
+		})
+		data.SetId("")
+		return nil
+	}
+
+	pmmInstances := make([]interface{}, 0, len(instances))
+	for _, instance := range instances {
+		pmmInstances = append(pmmInstances, map[string]interface{}{
+			resource.SchemaKeyInstancesPublicIP:  instance.PublicIpAddress,
+			resource.SchemaKeyInstancesPrivateIP: instance.PrivateIpAddress,
+		})
+	}
+	if err := data.Set(resource.SchemaKeyInstances, pmmInstances); err != nil {
+		return diag.FromErr(errors.Wrap(err, "can't set instances"))
+	}
 	return nil
 }
 
@@ -145,6 +179,11 @@ func (r *PMM) Update(_ context.Context, _ *schema.ResourceData, _ cloud.Cloud) d
 	return nil
 }
 
+// Import has nothing to inspect, as RDS credentials are not stored in the PMM instance.
+func (r *PMM) Import(_ context.Context, _ *schema.ResourceData, _ cloud.Cloud) error {
+	return nil
+}
+
 func (r *PMM) Delete(ctx context.Context, data *schema.ResourceData, c cloud.Cloud) diag.Diagnostics {
 	resourceID := data.Id()
 	if resourceID == "" {
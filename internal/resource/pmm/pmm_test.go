@@ -0,0 +1,94 @@
+package pmm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+
+	"terraform-percona/internal/cloud/fake"
+	"terraform-percona/internal/resource"
+	"terraform-percona/internal/resource/pmm/cmd"
+)
+
+var errInjected = errors.New("injected failure")
+
+func testConfig() map[string]interface{} {
+	return map[string]interface{}{
+		resource.SchemaKeyInstanceType: "t3.micro",
+		resource.SchemaKeyKeyPairName:  "key",
+	}
+}
+
+// TestCreate runs the initial setup on the PMM instance, the resource is kept in the state if it fails,
+// so that the instance is deleted on destroy.
+func TestCreate(t *testing.T) {
+	tests := []struct {
+		name    string
+		failOn  string
+		wantErr string
+	}{
+		{name: "create"},
+		{name: "initial setup failure", failOn: cmd.Initial(), wantErr: "failed initial setup"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.New()
+			if tt.failOn != "" {
+				c.FailOn(tt.failOn, errInjected)
+			}
+			data := schema.TestResourceDataRaw(t, new(PMM).Schema(), testConfig())
+			diags := new(PMM).Create(context.Background(), data, c)
+			if tt.wantErr == "" && diags.HasError() {
+				t.Fatalf("Create() diagnostics = %v", diags)
+			}
+			if tt.wantErr != "" && (!diags.HasError() || !strings.Contains(diags[0].Summary, tt.wantErr)) {
+				t.Fatalf("Create() diagnostics = %v, want error containing %q", diags, tt.wantErr)
+			}
+			if data.Id() == "" {
+				t.Fatal("resource id isn't set")
+			}
+			instances, _ := c.ListInstances(context.Background(), data.Id(), nil)
+			if len(instances) != 1 {
+				t.Fatalf("%d instances are created, want 1", len(instances))
+			}
+			if commands := c.Commands(instances[0]); len(commands) != 1 || commands[0] != cmd.Initial() {
+				t.Errorf("commands = %q, want only the initial setup", commands)
+			}
+			if set := data.Get(resource.SchemaKeyInstances).(*schema.Set); (set.Len() == 1) != (tt.wantErr == "") {
+				t.Errorf("%d instances are set in the state", set.Len())
+			}
+		})
+	}
+}
+
+// TestUpdate doesn't change the PMM instance, all attributes are kept in the state as they are configured.
+func TestUpdate(t *testing.T) {
+	c := fake.New()
+	r := resource.ResourcesMap(new(PMM))["percona_pmm"]
+	data := schema.TestResourceDataRaw(t, r.Schema, testConfig())
+	if diags := new(PMM).Create(context.Background(), data, c); diags.HasError() {
+		t.Fatal(diags)
+	}
+	calls := len(c.Calls())
+
+	config := testConfig()
+	config[schemaKeyRDSPMMUserPassword] = "newPassword"
+	diff, err := r.Diff(context.Background(), data.State(), terraform.NewResourceConfigRaw(config), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, diags := r.Apply(context.Background(), data.State(), diff, c)
+	if diags.HasError() {
+		t.Fatalf("update diagnostics = %v", diags)
+	}
+	if got := state.Attributes[schemaKeyRDSPMMUserPassword]; got != "newPassword" {
+		t.Errorf("%s = %q in the new state, want newPassword", schemaKeyRDSPMMUserPassword, got)
+	}
+	if got := c.Calls()[calls:]; len(got) != 0 {
+		t.Errorf("update runs %v on the instance", got)
+	}
+}
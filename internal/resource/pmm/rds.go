@@ -2,6 +2,7 @@ package pmm
 
 import (
 	"context"
+	"strings"
 	"terraform-percona/internal/cloud"
 	"terraform-percona/internal/resource"
 	"terraform-percona/internal/resource/pmm/api"
@@ -80,9 +81,9 @@ func (r *RDS) Create(ctx context.Context, data *schema.ResourceData, c cloud.Clo
 	if err != nil {
 		return diag.FromErr(errors.Wrap(err, "failed to create pmm client"))
 	}
-	creds, err := c.Credentials()
+	creds, err := rdsCredentials(c)
 	if err != nil {
-		return diag.FromErr(errors.Wrap(err, "failed to get aws credentials"))
+		return diag.FromErr(err)
 	}
 	instances, err := pmmClient.RDSDiscover(creds.AccessKey, creds.SecretKey)
 	if err != nil {
@@ -106,8 +107,39 @@ func (r *RDS) Create(ctx context.Context, data *schema.ResourceData, c cloud.Clo
 	return nil
 }
 
-func (r *RDS) Read(_ context.Context, _ *schema.ResourceData, _ cloud.Cloud) diag.Diagnostics {
-	//TODO
+func (r *RDS) Read(ctx context.Context, data *schema.ResourceData, _ cloud.Cloud) diag.Diagnostics {
+	resourceID := data.Id()
+	if resourceID == "" {
+		return diag.FromErr(errors.New("empty resource id"))
+	}
+	pmmAddress := data.Get(resource.SchemaKeyPMMAddress).(string)
+	if pmmAddress == "" {
+		tflog.Warn(ctx, "PMM address is unknown, skipping PMM services check")
+		return nil
+	}
+	pmmAddress, err := utils.ParsePMMAddress(pmmAddress)
+	if err != nil {
+		return diag.FromErr(errors.Wrap(err, "failed to parse pmm address"))
+	}
+	pmmClient, err := api.NewClient(pmmAddress)
+	if err != nil {
+		return diag.FromErr(errors.Wrap(err, "failed to create pmm client"))
+	}
+	services, err := pmmClient.ServicesByResourceID(resourceID)
+	if err != nil {
+		return diag.FromErr(errors.Wrap(err, "failed to list services"))
+	}
+	if len(services) == 0 {
+		tflog.Warn(ctx, "PMM services are not found, removing resource from state", map[string]interface{}{
+			"resource_id": resourceID,
+		})
+		data.SetId("")
+		return nil
+	}
+	// RDS services are added with the instance id as a service name
+	if err := data.Set(schemaKeyRDSIdentifier, services[0].ServiceName); err != nil {
+		return diag.FromErr(errors.Wrap(err, "can't set rds id"))
+	}
 	return nil
 }
 
@@ -116,6 +148,19 @@ func (r *RDS) Update(_ context.Context, _ *schema.ResourceData, _ cloud.Cloud) d
 	return nil
 }
 
+// Import accepts an id in the form of <resource_id>,<pmm_address>, as the services can be found only through the PMM API.
+func (r *RDS) Import(_ context.Context, data *schema.ResourceData, _ cloud.Cloud) error {
+	resourceID, pmmAddress, ok := strings.Cut(data.Id(), ",")
+	if !ok {
+		return errors.New("import id should be in the form of <resource_id>,<pmm_address>")
+	}
+	data.SetId(resourceID)
+	if err := data.Set(resource.SchemaKeyPMMAddress, pmmAddress); err != nil {
+		return errors.Wrap(err, "can't set pmm address")
+	}
+	return nil
+}
+
 func (r *RDS) Delete(ctx context.Context, data *schema.ResourceData, c cloud.Cloud) diag.Diagnostics {
 	resourceID := data.Id()
 	if resourceID == "" {
@@ -136,3 +181,20 @@ func (r *RDS) Delete(ctx context.Context, data *schema.ResourceData, c cloud.Clo
 
 	return nil
 }
+
+// rdsCredentials returns the credentials passed to PMM to discover and monitor RDS instances.
+// PMM accepts only an access key and a secret key, and keeps them to collect metrics,
+// so temporary credentials of assumed roles can't be used.
+func rdsCredentials(c cloud.Cloud) (cloud.Credentials, error) {
+	creds, err := c.Credentials()
+	if err != nil {
+		return cloud.Credentials{}, errors.Wrap(err, "failed to get aws credentials")
+	}
+	if creds.AccessKey == "" {
+		return cloud.Credentials{}, errors.New("rds instances can be discovered only with aws credentials")
+	}
+	if creds.SessionToken != "" {
+		return cloud.Credentials{}, errors.New("rds instances can't be discovered with temporary aws credentials, pmm doesn't support session tokens, use the access key of an iam user")
+	}
+	return creds, nil
+}
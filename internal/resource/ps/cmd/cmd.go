@@ -2,15 +2,45 @@ package cmd
 
 import (
 	"fmt"
+	"strings"
 	"terraform-percona/internal/db"
+	"terraform-percona/internal/db/mysql"
+	"terraform-percona/internal/utils"
 )
 
 func Restart() string {
 	return "sudo systemctl restart mysql"
 }
 
-func RetrieveVersions() string {
-	return `apt-cache show percona-server-server | grep 'Version' | sed 's/Version: //'`
+func RetrieveVersions(series string) string {
+	return fmt.Sprintf(`apt-cache show %s | grep 'Version' | sed 's/Version: //'`, packageName("percona-server-server", series))
+}
+
+func InstalledVersion() string {
+	return `dpkg-query --show --showformat='${db:Status-Abbrev} ${Version}\n' 'percona-server-server*' 2>/dev/null | awk '$1 == "ii" {print $2}'`
+}
+
+// MyRocksInstalled prints the version of the installed MyRocks package, the output is empty if it's not installed.
+func MyRocksInstalled() string {
+	return `dpkg-query --show --showformat='${db:Status-Abbrev} ${Version}\n' 'percona-server-rocksdb*' 2>/dev/null | awk '$1 == "ii" {print $2}'`
+}
+
+// ReadFile prints the content of the file, which may be readable only by root.
+func ReadFile(path string) string {
+	return fmt.Sprintf("sudo cat %s", path)
+}
+
+// FileHash prints SHA-256 hash of the file followed by its path, nothing is printed if the file doesn't exist.
+func FileHash(path string) string {
+	return fmt.Sprintf("sudo sh -c 'test ! -f %[1]s || sha256sum %[1]s'", path)
+}
+
+// packageName returns the name of the package for the version or release series, 5.7 packages have the series suffix.
+func packageName(name, version string) string {
+	if strings.HasPrefix(version, "5.7") {
+		return name + "-5.7"
+	}
+	return name
 }
 
 func Init() string {
@@ -22,8 +52,8 @@ func Init() string {
 		sudo apt-get update
 		sudo apt-get upgrade -y
 
-		sudo mkdir /opt/percona
-		sudo chown ubuntu /opt/percona
+		sudo mkdir -p /opt/percona
+		sudo chown $(id -un) /opt/percona
 	`
 }
 
@@ -61,18 +91,65 @@ func InstallOrchestratorClient() string {
 	`, version, version)
 }
 
-func InstallPerconaServer(password, version string, port int) string {
+func InstallPerconaServer(version string) string {
+	return fmt.Sprintf(`
+	#!/usr/bin/env bash
+
+	set -o errexit
+
+	DEBIAN_FRONTEND=noninteractive sudo -E bash -c 'apt-get install -y %s=%s %s=%s %s=%s'
+	`, packageName("percona-server-client", version), version,
+		packageName("percona-server-common", version), version,
+		packageName("percona-server-server", version), version)
+}
+
+// CreateAutomationUser creates the account which is used by the provider or resets its password and privileges,
+// root is accessible only locally. Statements are not written to the binary log, as the account is created on every instance.
+func CreateAutomationUser(rootPassword, host, password, version string) string {
+	account := mysql.QuoteString(db.UserAutomation) + "@" + mysql.QuoteString(host)
+	return rootStatements(rootPassword, fmt.Sprintf("SET SESSION sql_log_bin=0; CREATE USER IF NOT EXISTS %[1]s IDENTIFIED BY %[2]s; ALTER USER %[1]s IDENTIFIED BY %[2]s; %[3]s",
+		account, mysql.QuoteString(password), mysql.AutomationGrants(account, version)))
+}
+
+// InstallUDF creates the functions of the UDF libraries, which are shipped with Percona Server.
+// It requires writes to the mysql schema, which the automation user doesn't have. Statements are not written to the binary log,
+// as the functions are created on every instance.
+func InstallUDF(rootPassword string) string {
+	return rootStatements(rootPassword, "SET SESSION sql_log_bin=0; "+
+		"CREATE FUNCTION fnv1a_64 RETURNS INTEGER SONAME 'libfnv1a_udf.so'; "+
+		"CREATE FUNCTION fnv_64 RETURNS INTEGER SONAME 'libfnv_udf.so'; "+
+		"CREATE FUNCTION murmur_hash RETURNS INTEGER SONAME 'libmurmur_udf.so';")
+}
+
+// RenameAutomationUser changes the host of the account which is used by the provider on the instance.
+func RenameAutomationUser(rootPassword, oldHost, newHost string) string {
+	user := mysql.QuoteString(db.UserAutomation)
+	return rootStatements(rootPassword, fmt.Sprintf("SET SESSION sql_log_bin=0; RENAME USER %[1]s@%[2]s TO %[1]s@%[3]s;",
+		user, mysql.QuoteString(oldHost), mysql.QuoteString(newHost)))
+}
+
+// rootStatements runs the statements as root through the socket.
+// The password is passed in the environment, so that it isn't interpreted by the shell and isn't visible in the process list.
+func rootStatements(rootPassword, statements string) string {
+	return fmt.Sprintf("MYSQL_PWD=%s mysql -uroot -e %s", utils.ShellQuote(rootPassword), utils.ShellQuote(statements))
+}
+
+// RestrictRootUser renames root@% back to root@localhost on the instances which were created with remote root access.
+func RestrictRootUser(rootPassword string) string {
 	return fmt.Sprintf(`
 	#!/usr/bin/env bash
 
 	set -o errexit
+	export MYSQL_PWD=%[1]s
 
-	DEBIAN_FRONTEND=noninteractive sudo -E bash -c 'apt-get install -y percona-server-client=%s percona-server-common=%s percona-server-server=%s'
-	mysql -uroot -p%s -e "RENAME USER 'root'@'localhost' TO 'root'@'%%';FLUSH PRIVILEGES;"
-	`, version, version, version, password)
+	if [ "$(mysql -uroot -N -e "SELECT COUNT(*) FROM mysql.user WHERE user='root' AND host='%%'")" = "1" ]; then
+		mysql -uroot -e "SET SESSION sql_log_bin=0; RENAME USER 'root'@'%%' TO 'root'@'localhost';"
+	fi
+	`, utils.ShellQuote(rootPassword))
 }
 
-func Configure(password string) string {
+func Configure(password, repository, series string) string {
+	pkg := packageName("percona-server-server", series)
 	return fmt.Sprintf(`
 	#!/usr/bin/env bash
 
@@ -86,12 +163,60 @@ func Configure(password string) string {
 	sudo dpkg -i percona-release_latest.$(lsb_release -sc)_all.deb
 
 	sudo apt-get update
-	sudo percona-release setup ps80
+	sudo percona-release enable-only %[1]s release
+	sudo percona-release enable tools release
+	sudo apt-get update
 	export MYSQL_SELECTION_DEFAULT_AUTH_OVERRIDE="select Use Strong Password Encryption (RECOMMENDED)"
-	echo "percona-server-server   percona-server-server/re-root-pass password %s" | sudo debconf-set-selections
-	echo "percona-server-server   percona-server-server/root-pass password %s" | sudo debconf-set-selections
-	echo "percona-server-server   percona-server-server/default-auth-override ${MYSQL_SELECTION_DEFAULT_AUTH_OVERRIDE}" | sudo debconf-set-selections
-	`, password, password)
+	echo %[3]s | sudo debconf-set-selections
+	echo %[4]s | sudo debconf-set-selections
+	echo "%[2]s   %[2]s/default-auth-override ${MYSQL_SELECTION_DEFAULT_AUTH_OVERRIDE}" | sudo debconf-set-selections
+	`, repository, pkg,
+		utils.ShellQuote(fmt.Sprintf("%[1]s   %[1]s/re-root-pass password %[2]s", pkg, password)),
+		utils.ShellQuote(fmt.Sprintf("%[1]s   %[1]s/root-pass password %[2]s", pkg, password)))
+}
+
+// UpdateRepository switches to the repository of the release series.
+// It also re-enables the repository, which is disabled after pmm client installation.
+func UpdateRepository(repository string) string {
+	return fmt.Sprintf(`
+	#!/usr/bin/env bash
+
+	set -o errexit
+
+	sudo percona-release enable-only %s release
+	sudo percona-release enable tools release
+	sudo apt-get update
+	`, repository)
+}
+
+func UpgradePerconaServer(version string, myRocks bool) string {
+	packages := fmt.Sprintf("%s=%s %s=%s %s=%s",
+		packageName("percona-server-client", version), version,
+		packageName("percona-server-common", version), version,
+		packageName("percona-server-server", version), version)
+	if myRocks {
+		packages += fmt.Sprintf(" %s=%s", packageName("percona-server-rocksdb", version), version)
+	}
+	return fmt.Sprintf(`
+	#!/usr/bin/env bash
+
+	set -o errexit
+
+	DEBIAN_FRONTEND=noninteractive sudo -E apt-get install -y -o Dpkg::Options::="--force-confold" %s
+	sudo systemctl restart mysql
+	`, packages)
+}
+
+func InstallMySQLShell() string {
+	return "sudo apt-get install -y percona-mysql-shell"
+}
+
+// CheckForServerUpgrade runs MySQL Shell upgrade checker, which reports problems in JSON format.
+// The checker exits with an error if there are problems, so its exit code is ignored.
+// Root is accessible only locally, so that the checker connects through the socket, the password is read from stdin.
+func CheckForServerUpgrade(password string, targetVersion string) string {
+	return fmt.Sprintf(`printf '%%s\n' %s | mysqlsh --user=root --passwords-from-stdin --socket=/var/run/mysqld/mysqld.sock -- util check-for-server-upgrade --target-version=%s --output-format=JSON 2>/dev/null || true`,
+		utils.ShellQuote(password), targetVersion)
 }
 
 func InstallMyRocks(password, version string) string {
@@ -100,8 +225,8 @@ func InstallMyRocks(password, version string) string {
 
 	set -o errexit
 
-	sudo apt-get install -y percona-server-rocksdb=%s
-	sudo ps-admin --enable-rocksdb -uroot -p%s`, version, password)
+	sudo apt-get install -y %s=%s
+	sudo ps-admin --enable-rocksdb -uroot -p%s`, packageName("percona-server-rocksdb", version), version, utils.ShellQuote(password))
 }
 
 func InstallPMMClient(addr string) string {
@@ -116,6 +241,33 @@ func InstallPMMClient(addr string) string {
 	sudo pmm-admin config --server-insecure-tls --server-url="%s"`, addr)
 }
 
-func AddServiceToPMM(password string, port int) string {
-	return fmt.Sprintf(`pmm-admin add mysql --query-source=slowlog --username="%s" --password="%s" --port=%d`, db.UserPMM, password, port)
+// AddServiceToPMM adds the local mysql service to PMM, the service is connected over TLS verified with the CA certificate if tlsCA is set.
+func AddServiceToPMM(password string, port int, tlsCA string) string {
+	c := fmt.Sprintf(`pmm-admin add mysql --query-source=slowlog --username=%s --password=%s --port=%d`, db.UserPMM, utils.ShellQuote(password), port)
+	if tlsCA != "" {
+		c += fmt.Sprintf(` --tls --tls-ca=%s`, tlsCA)
+	}
+	return c
+}
+
+// SystemResources prints total memory in kB and the number of CPUs on separate lines.
+func SystemResources() string {
+	return `awk '/^MemTotal:/ {print $2}' /proc/meminfo && nproc`
+}
+
+// RemoveServiceFromPMM removes the mysql service added by AddServiceToPMM, which is named after the node.
+func RemoveServiceFromPMM() string {
+	return `pmm-admin remove mysql "$(hostname)-mysql" || true`
+}
+
+func RemoveFromPMM() string {
+	return "sudo pmm-admin unregister --force"
+}
+
+func ForgetInOrchestrator(orchestratorAPI string, host string, port int) string {
+	return fmt.Sprintf(`ORCHESTRATOR_API="%s" orchestrator-client -c forget -i %s:%d`, orchestratorAPI, host, port)
+}
+
+func DiscoverInOrchestrator(orchestratorAPI string, host string, port int) string {
+	return fmt.Sprintf(`ORCHESTRATOR_API="%s" orchestrator-client -c discover -i %s:%d`, orchestratorAPI, host, port)
 }
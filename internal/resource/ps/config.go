@@ -0,0 +1,128 @@
+package ps
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/pkg/errors"
+
+	"terraform-percona/internal/cloud"
+	"terraform-percona/internal/resource"
+	"terraform-percona/internal/resource/ps/cmd"
+	"terraform-percona/internal/utils"
+)
+
+// pushConfig sends the config file to every instance and restarts them one by one: replicas first, the source is the last one.
+// Each instance should be back online before the next one is restarted.
+func (m *manager) pushConfig(ctx context.Context) error {
+	states, err := m.instanceStates(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get instance states")
+	}
+	for _, state := range states {
+		if state.replicationState != replicationStateOnline {
+			return errors.Errorf("instance %s is not online: %s, all instances should be online before config change", state.PrivateIpAddress, state.replicationState)
+		}
+	}
+	for _, state := range rollingOrder(states) {
+		tflog.Info(ctx, "Updating config file", map[string]interface{}{
+			resource.LogArgInstanceIP: state.PublicIpAddress,
+		})
+		if err := m.sendConfig(ctx, state.Instance); err != nil {
+			return errors.Wrapf(err, "failed to send config file to instance %s", state.PrivateIpAddress)
+		}
+		if _, err := m.runCommand(ctx, state.Instance, cmd.Restart()); err != nil {
+			return errors.Wrapf(err, "failed to restart instance %s", state.PrivateIpAddress)
+		}
+		if err := m.rejoin(ctx, state.Instance); err != nil {
+			return errors.Wrapf(err, "instance %s failed to rejoin the cluster after restart, check /var/log/mysql/error.log on the instance", state.PrivateIpAddress)
+		}
+	}
+	return nil
+}
+
+// sendConfig replaces the custom config file of the instance, the file is removed if config_file_path is not set.
+func (m *manager) sendConfig(ctx context.Context, instance cloud.Instance) error {
+	if m.cfgPath == "" {
+		if _, err := m.runCommand(ctx, instance, fmt.Sprintf("sudo rm -f %s", customMysqlConfigPath)); err != nil {
+			return errors.Wrap(err, "failed to remove config file")
+		}
+		return nil
+	}
+	cfgFile, err := os.Open(m.cfgPath)
+	if err != nil {
+		return errors.Wrap(err, "failed to open config file")
+	}
+	defer cfgFile.Close()
+	if err := m.sendFile(ctx, instance, cfgFile, customMysqlConfigPath); err != nil {
+		return errors.Wrap(err, "failed to send config file")
+	}
+	return nil
+}
+
+// setConfigFileHash sets config_file_hash from the custom config file of the first instance which can be read,
+// so that imported clusters and clusters created without the hash aren't restarted if the file is the same. Failures to read the file are only logged.
+func (m *manager) setConfigFileHash(ctx context.Context, data *schema.ResourceData, states []instanceState) error {
+	for _, state := range states {
+		if state.PublicIpAddress == "" {
+			continue
+		}
+		out, err := m.runCommand(ctx, state.Instance, cmd.FileHash(customMysqlConfigPath))
+		if err != nil {
+			tflog.Warn(ctx, "failed to hash config file", map[string]interface{}{
+				resource.LogArgInstanceIP: state.PublicIpAddress,
+				"error":                   err,
+			})
+			continue
+		}
+		// The hash is empty if the file doesn't exist, as well as the hash of unset config_file_path
+		hash, _, _ := strings.Cut(strings.TrimSpace(out), " ")
+		return data.Set(resource.SchemaKeyConfigFileHash, hash)
+	}
+	return nil
+}
+
+// defaultConfig returns the mysqld options of the default config file of the instance.
+func (m *manager) defaultConfig(ctx context.Context, instance cloud.Instance) (map[string]string, error) {
+	out, err := m.runCommand(ctx, instance, cmd.ReadFile(defaultMysqlConfigPath))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read config file")
+	}
+	return utils.IniSection([]byte(out), "mysqld")
+}
+
+// rejoin starts replication on the restarted instance and waits until it's online.
+func (m *manager) rejoin(ctx context.Context, instance cloud.Instance) error {
+	// Group replication is not started on boot
+	if m.replicationType == replicationTypeGR {
+		db, err := m.newAdminClient(instance)
+		if err != nil {
+			return errors.Wrap(err, "new client")
+		}
+		defer db.Close()
+		if err := db.StartGroupReplication(ctx); err != nil {
+			return errors.Wrap(err, "start group replication")
+		}
+	}
+	return m.waitForState(ctx, instance, func(state instanceState) bool {
+		return state.replicationState == replicationStateOnline
+	})
+}
+
+// rollingOrder returns replicas first and the source instances last.
+func rollingOrder(states []instanceState) []instanceState {
+	ordered := make([]instanceState, 0, len(states))
+	var sources []instanceState
+	for _, state := range states {
+		if state.isReplica {
+			ordered = append(ordered, state)
+		} else {
+			sources = append(sources, state)
+		}
+	}
+	return append(ordered, sources...)
+}
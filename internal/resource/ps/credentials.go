@@ -0,0 +1,194 @@
+package ps
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/pkg/errors"
+
+	"terraform-percona/internal/cloud"
+	internaldb "terraform-percona/internal/db"
+	"terraform-percona/internal/db/mysql"
+	"terraform-percona/internal/resource"
+	"terraform-percona/internal/resource/ps/cmd"
+)
+
+type credentials struct {
+	root         string
+	replica      string
+	orchestrator string
+	pmm          string
+	automation   string
+}
+
+// previousCredentials returns the passwords before the change.
+func previousCredentials(previous map[string]string) credentials {
+	return credentials{
+		root:         previous[resource.SchemaKeyRootPassword],
+		replica:      previous[schemaKeyReplicaPassword],
+		orchestrator: previous[schemaKeyOrchestatorPassword],
+		pmm:          previous[resource.SchemaKeyPMMPassword],
+		automation:   previous[resource.SchemaKeyAutomationPassword],
+	}
+}
+
+// rotatePasswords changes the passwords of the users on the source instance, the statements are replicated to the other instances.
+// Replication channels, orchestrator and PMM services are reconfigured to use the new passwords.
+func (m *manager) rotatePasswords(ctx context.Context, previous credentials) error {
+	m.previousPass = previous.automation
+	states, err := m.instanceStates(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get instance states")
+	}
+	for _, state := range states {
+		if state.replicationState != replicationStateOnline {
+			return errors.Errorf("instance %s is not online: %s, all instances should be online before password change", state.PrivateIpAddress, state.replicationState)
+		}
+	}
+	source, ok := sourceInstance(states)
+	if !ok {
+		return errors.New("failed to find online source instance")
+	}
+
+	tflog.Info(ctx, "Changing passwords")
+	err = func() error {
+		db, err := m.connect(ctx, source.Instance)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		automationHost, err := resource.MySQLHost(m.automationCIDR)
+		if err != nil {
+			return errors.Wrap(err, "automation user host")
+		}
+		users := []struct {
+			user, host   string
+			old, current string
+		}{
+			{internaldb.UserRoot, "localhost", previous.root, m.pass},
+			{internaldb.UserAutomation, automationHost, previous.automation, m.automationPass},
+			{internaldb.UserReplica, "%", previous.replica, m.replicaPass},
+			{internaldb.UserOrchestrator, "%", previous.orchestrator, m.orchestratorPassword},
+			{internaldb.UserPMM, "localhost", previous.pmm, m.pmmPassword},
+		}
+		for _, u := range users {
+			if u.old == u.current {
+				continue
+			}
+			if err := db.AlterUserPassword(ctx, u.user, u.host, u.current); err != nil {
+				return errors.Wrapf(err, "failed to change password of %s user", u.user)
+			}
+		}
+		return nil
+	}()
+	if err != nil {
+		return errors.Wrapf(err, "source instance %s", source.PrivateIpAddress)
+	}
+
+	if previous.replica != m.replicaPass {
+		for _, state := range states {
+			if err := m.updateReplicationCredentials(ctx, state); err != nil {
+				return errors.Wrapf(err, "failed to update replication credentials of instance %s", state.PrivateIpAddress)
+			}
+		}
+	}
+	if previous.orchestrator != m.orchestratorPassword && m.orchestratorSize > 0 {
+		if err := m.updateOrchestratorCredentials(ctx); err != nil {
+			return err
+		}
+	}
+	if previous.pmm != m.pmmPassword && m.pmmAddress != "" {
+		for _, state := range states {
+			if _, err := m.runCommand(ctx, state.Instance, cmd.RemoveServiceFromPMM()); err != nil {
+				return errors.Wrapf(err, "failed to remove pmm service of instance %s", state.PrivateIpAddress)
+			}
+		}
+		if err := m.addServicesToPMM(ctx, instancesOf(states)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *manager) updateReplicationCredentials(ctx context.Context, state instanceState) error {
+	db, err := m.connect(ctx, state.Instance)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	switch m.replicationType {
+	case replicationTypeGR:
+		return db.ChangeGroupReplicationSource(ctx, internaldb.UserReplica, m.replicaPass)
+	default:
+		if !state.isReplica {
+			return nil
+		}
+		if err := db.StopReplica(ctx); err != nil {
+			return errors.Wrap(err, "stop replica")
+		}
+		if err := db.ChangeReplicationSourceCredentials(ctx, internaldb.UserReplica, m.replicaPass); err != nil {
+			return errors.Wrap(err, "change replication source")
+		}
+		if err := db.StartReplica(ctx); err != nil {
+			return errors.Wrap(err, "start replica")
+		}
+		return nil
+	}
+}
+
+func (m *manager) updateOrchestratorCredentials(ctx context.Context) error {
+	orcInstances, err := m.cloud.ListInstances(ctx, m.resourceID, map[string]string{
+		resource.LabelKeyInstanceType: resource.LabelValueInstanceTypeOrchestrator,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to list orchestrator instances")
+	}
+	for _, instance := range orcInstances {
+		creds, err := orchestratorTopologyCredentials(m.orchestratorPassword)
+		if err != nil {
+			return errors.Wrap(err, "failed to create orchestrator credentials file")
+		}
+		if err := m.sendFile(ctx, instance, creds, defaultOrchestratorCredentialsPath); err != nil {
+			return errors.Wrap(err, "failed to send orchestrator credentials file")
+		}
+		if _, err := m.runCommand(ctx, instance, "sudo systemctl restart orchestrator"); err != nil {
+			return errors.Wrap(err, "failed to restart orchestrator")
+		}
+	}
+	return nil
+}
+
+// connect opens a connection as the automation user with the current password,
+// or with the previous one if it's not changed on the instance yet.
+func (m *manager) connect(ctx context.Context, instance cloud.Instance) (*mysql.DB, error) {
+	user, passwords := internaldb.UserAutomation, []string{m.automationPass}
+	if m.automationPass == "" {
+		// Clusters created before the automation user was introduced are accessed as root until they are updated
+		user, passwords = internaldb.UserRoot, []string{m.pass}
+	}
+	if m.previousPass != "" && m.previousPass != passwords[0] {
+		passwords = append(passwords, m.previousPass)
+	}
+	var lastErr error
+	for _, pass := range passwords {
+		db, err := m.newClient(instance, user, pass)
+		if err != nil {
+			return nil, errors.Wrap(err, "new client")
+		}
+		if err := db.PingContext(ctx); err != nil {
+			db.Close()
+			lastErr = err
+			continue
+		}
+		return db, nil
+	}
+	return nil, errors.Wrapf(lastErr, "failed to connect as %s with the current and previous passwords", user)
+}
+
+func instancesOf(states []instanceState) []cloud.Instance {
+	instances := make([]cloud.Instance, 0, len(states))
+	for _, state := range states {
+		instances = append(instances, state.Instance)
+	}
+	return instances
+}
@@ -0,0 +1,79 @@
+package ps
+
+import (
+	"context"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/pkg/errors"
+
+	"terraform-percona/internal/resource"
+	"terraform-percona/internal/resource/ps/cmd"
+)
+
+// inspect restores the attributes of the existing cluster from the cloud labels and the instances.
+// Passwords of the imported cluster are unknown, so the packages and config files are inspected over ssh without changing anything.
+func (m *manager) inspect(ctx context.Context, data *schema.ResourceData) error {
+	orcInstances, err := m.cloud.ListInstances(ctx, m.resourceID, map[string]string{
+		resource.LabelKeyInstanceType: resource.LabelValueInstanceTypeOrchestrator,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to list orchestrator instances")
+	}
+	if err := data.Set(schemaKeyOrchestatorSize, len(orcInstances)); err != nil {
+		return errors.Wrap(err, "can't set orchestrator size")
+	}
+
+	instances, err := m.cloud.ListInstances(ctx, m.resourceID, map[string]string{
+		resource.LabelKeyInstanceType: resource.LabelValueInstanceTypeMySQL,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to list instances")
+	}
+	if len(instances) == 0 {
+		return errors.Errorf("no instances found with %s=%s", resource.LabelKeyResourceID, m.resourceID)
+	}
+
+	for _, instance := range instances {
+		err := func() error {
+			version, err := m.installedVersion(ctx, instance)
+			if err != nil {
+				return err
+			}
+			myRocks, err := m.runCommand(ctx, instance, cmd.MyRocksInstalled())
+			if err != nil {
+				return errors.Wrap(err, "failed to check myrocks package")
+			}
+			cfg, err := m.defaultConfig(ctx, instance)
+			if err != nil {
+				return err
+			}
+			replicationType := replicationTypeAsync
+			if _, ok := cfg["group_replication_group_name"]; ok {
+				replicationType = replicationTypeGR
+			}
+			version, _, _ = strings.Cut(version, "-")
+			for key, value := range map[string]interface{}{
+				resource.SchemaKeyVersion: version,
+				schemaKeyMyRocksInstall:   strings.TrimSpace(myRocks) != "",
+				schemaKeyReplicationType:  replicationType,
+			} {
+				if err := data.Set(key, value); err != nil {
+					return errors.Wrapf(err, "can't set %s", key)
+				}
+			}
+			m.replicationType = replicationType
+			return nil
+		}()
+		if err == nil {
+			return nil
+		}
+		tflog.Warn(ctx, "failed to inspect instance", map[string]interface{}{
+			resource.LogArgInstanceIP: instance.PublicIpAddress,
+			"error":                   err,
+		})
+	}
+	tflog.Warn(ctx, "none of the instances could be inspected, default values are kept in the state")
+	return nil
+}
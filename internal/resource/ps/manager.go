@@ -5,7 +5,6 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"os"
 	"path"
 	"strconv"
 	"strings"
@@ -31,13 +30,26 @@ type manager struct {
 	replicaPass          string
 	installMyRocks       bool
 	cfgPath              string
+	options              map[string]string
+	tuningProfile        string
+	volumeIOPS           int
 	version              string
+	series               string
 	port                 int
 	pmmAddress           string
 	pmmPassword          string
 	orchestratorSize     int
 	orchestratorPassword string
 	replicationType      string
+	automationPass       string
+	automationCIDR       string
+	users                []resource.User
+	initScripts          []resource.InitScript
+	caCert               string
+	caKey                string
+
+	// previousPass is the automation user password before the change, it is accepted until the change is applied
+	previousPass string
 
 	resourceID string
 
@@ -54,10 +66,19 @@ func newManager(cloud cloud.Cloud, resourceID string, data *schema.ResourceData)
 		orchestratorSize:     data.Get(schemaKeyOrchestatorSize).(int),
 		orchestratorPassword: data.Get(schemaKeyOrchestatorPassword).(string),
 		cfgPath:              data.Get(resource.SchemaKeyConfigFilePath).(string),
+		options:              resource.MySQLDOptions(data),
+		tuningProfile:        data.Get(resource.SchemaKeyTuningProfile).(string),
+		volumeIOPS:           data.Get(resource.SchemaKeyVolumeIOPS).(int),
 		version:              data.Get(resource.SchemaKeyVersion).(string),
+		series:               data.Get(resource.SchemaKeyReleaseSeries).(string),
 		port:                 data.Get(resource.SchemaKeyPort).(int),
 		pmmAddress:           data.Get(resource.SchemaKeyPMMAddress).(string),
 		pmmPassword:          data.Get(resource.SchemaKeyPMMPassword).(string),
+		automationPass:       data.Get(resource.SchemaKeyAutomationPassword).(string),
+		automationCIDR:       data.Get(resource.SchemaKeyAutomationCIDR).(string),
+		users:                resource.Users(data),
+		caCert:               data.Get(resource.SchemaKeyCACertificate).(string),
+		caKey:                data.Get(resource.SchemaKeyCAPrivateKey).(string),
 		resourceID:           resourceID,
 		cloud:                cloud,
 	}
@@ -69,6 +90,9 @@ const (
 )
 
 func (m *manager) createCluster(ctx context.Context) error {
+	if err := resource.CheckReleaseSeries(m.series, m.version); err != nil {
+		return err
+	}
 	g, gCtx := errgroup.WithContext(ctx)
 	g.Go(func() error {
 		return m.setupPerconaServer(gCtx)
@@ -82,7 +106,10 @@ func (m *manager) createCluster(ctx context.Context) error {
 	if err := m.addPSInstancesToOrchestrator(ctx); err != nil {
 		return err
 	}
-	return nil
+	if err := m.applyUsers(ctx, nil); err != nil {
+		return err
+	}
+	return m.runInitSQL(ctx)
 }
 
 func (m *manager) addPSInstancesToOrchestrator(ctx context.Context) error {
@@ -103,25 +130,35 @@ func (m *manager) addPSInstancesToOrchestrator(ctx context.Context) error {
 		return errors.Wrap(err, "failed to list orchestrator instances")
 	}
 
-	orchestratorAPIHosts := make([]string, 0, len(orcInstances))
 	for _, i := range orcInstances {
 		if _, err = m.runCommand(ctx, i, "sudo systemctl restart orchestrator"); err != nil {
 			return errors.Wrap(err, "failed to restart orchestrator")
 		}
-		orchestratorAPIHosts = append(orchestratorAPIHosts, fmt.Sprintf("http://%s:%d/%s", i.PrivateIpAddress, defaultOrchestratorListenPort, defaultOrchestratorURLPrefix))
 	}
 
 	time.Sleep(30 * time.Second)
+	m.discoverInOrchestrator(ctx, orchestratorAPI(orcInstances), instances)
+	return nil
+}
+
+func orchestratorAPI(orcInstances []cloud.Instance) string {
+	orchestratorAPIHosts := make([]string, 0, len(orcInstances))
+	for _, i := range orcInstances {
+		orchestratorAPIHosts = append(orchestratorAPIHosts, fmt.Sprintf("http://%s:%d/%s", i.PrivateIpAddress, defaultOrchestratorListenPort, defaultOrchestratorURLPrefix))
+	}
+	return strings.Join(orchestratorAPIHosts, " ")
+}
+
+func (m *manager) discoverInOrchestrator(ctx context.Context, api string, instances []cloud.Instance) {
 	for _, i := range instances {
 		tflog.Info(ctx, fmt.Sprintf("Adding instance %s to orhestrator", i.PublicIpAddress), map[string]any{})
-		_, err := m.runCommand(ctx, i, fmt.Sprintf(`ORCHESTRATOR_API="%s" orchestrator-client -c discover -i %s:%d`, strings.Join(orchestratorAPIHosts, " "), i.PrivateIpAddress, m.port))
+		_, err := m.runCommand(ctx, i, cmd.DiscoverInOrchestrator(api, i.PrivateIpAddress, m.port))
 		if err != nil {
 			tflog.Info(ctx, fmt.Sprintf("failed to add instance %s to orchestrator", i.PublicIpAddress), map[string]any{
 				"error": err,
 			})
 		}
 	}
-	return nil
 }
 
 func (m *manager) setupOrchestrator(ctx context.Context) error {
@@ -151,7 +188,14 @@ func (m *manager) setupOrchestrator(ctx context.Context) error {
 				return errors.Wrap(err, "run command")
 			}
 			tflog.Info(ctx, "Orchestrator installed")
-			cfg, err := orchestratorConfig(instance, instances)
+			caPath := ""
+			if m.tlsEnabled() {
+				caPath = defaultOrchestratorCAPath
+				if err := m.sendFile(gCtx, instance, strings.NewReader(m.caCert), caPath); err != nil {
+					return errors.Wrap(err, "failed to send orchestrator ca certificate")
+				}
+			}
+			cfg, err := orchestratorConfig(instance, instances, caPath)
 			if err != nil {
 				return errors.Wrap(err, "failed to create orchestrator config")
 			}
@@ -191,46 +235,66 @@ func (m *manager) setupPerconaServer(ctx context.Context) error {
 	if err != nil {
 		return errors.Wrap(err, "create instances")
 	}
+	if err := m.detectAutomationCIDR(ctx, instances[0]); err != nil {
+		return err
+	}
+	tflog.Info(ctx, "Configuring instances")
+	if err = m.installInstances(ctx, instances); err != nil {
+		return errors.Wrap(err, "configure instances")
+	}
+	tflog.Info(ctx, "Starting instances")
+	if err := m.setupInstances(ctx, instances); err != nil {
+		return errors.Wrap(err, "setup instances")
+	}
+	return nil
+}
+
+// installInstances installs Percona Server on the instances in parallel.
+// The version is resolved on the first instance beforehand, so that all instances get the same repository and packages
+// and the goroutines don't change the manager.
+func (m *manager) installInstances(ctx context.Context, instances []cloud.Instance) error {
+	repository, series := m.repository(), m.releaseSeries()
+	if err := m.prepareInstance(ctx, instances[0], repository, series); err != nil {
+		return err
+	}
+	version, err := m.resolveVersion(ctx, instances[0])
+	if err != nil {
+		return errors.Wrap(err, "install percona server")
+	}
+	m.version = version
+
 	g, gCtx := errgroup.WithContext(ctx)
 	g.SetLimit(len(instances))
-	for _, instance := range instances {
-		instance := instance
+	for i, instance := range instances {
+		i, instance := i, instance
 		g.Go(func() error {
-			_, err := m.runCommand(gCtx, instance, cmd.Init())
-			if err != nil {
-				return errors.Wrap(err, "init")
-			}
-			_, err = m.runCommand(gCtx, instance, cmd.Configure(m.pass))
-			if err != nil {
-				return errors.Wrap(err, "run command")
+			if i > 0 {
+				if err := m.prepareInstance(gCtx, instance, repository, series); err != nil {
+					return err
+				}
 			}
-			if err := m.installPerconaServer(gCtx, instance); err != nil {
+			if err := m.installPerconaServer(gCtx, instance, version); err != nil {
 				return errors.Wrap(err, "install percona server")
 			}
-			_, err = m.runCommand(gCtx, instance, cmd.Restart())
+			_, err := m.runCommand(gCtx, instance, cmd.Restart())
 			if err != nil {
 				return errors.Wrap(err, "restart mysql")
 			}
-			db, err := m.newClient(instance, internaldb.UserRoot, m.pass)
+			if _, err := m.runCommand(gCtx, instance, cmd.InstallUDF(m.pass)); err != nil {
+				return errors.Wrap(err, "failed to install percona server UDF")
+			}
+			db, err := m.newAdminClient(instance)
 			if err != nil {
 				return errors.Wrap(err, "failed to establish sql connection")
 			}
 			defer db.Close()
-			if err := db.InstallPerconaServerUDF(gCtx); err != nil {
-				return errors.Wrap(err, "failed to install percona server UDF")
-			}
 			if m.cfgPath != "" {
-				cfgFile, err := os.Open(m.cfgPath)
-				if err != nil {
-					return errors.Wrap(err, "failed to open config file")
-				}
-				defer cfgFile.Close()
-				if err = m.sendFile(gCtx, instance, cfgFile, customMysqlConfigPath); err != nil {
-					return errors.Wrap(err, "failed to send config file")
+				if err := m.sendConfig(gCtx, instance); err != nil {
+					return err
 				}
 			}
 			if m.installMyRocks {
-				_, err = m.runCommand(gCtx, instance, cmd.InstallMyRocks(m.pass, m.version))
+				_, err = m.runCommand(gCtx, instance, cmd.InstallMyRocks(m.pass, version))
 				if err != nil {
 					return errors.Wrap(err, "install myrocks")
 				}
@@ -254,16 +318,16 @@ func (m *manager) setupPerconaServer(ctx context.Context) error {
 				}
 				err = m.editDefaultCfg(gCtx, instance, "mysqld", map[string]string{
 					// Slow query log
-					"slow_query_log":                    "ON",
-					"log_output":                        "FILE",
-					"long_query_time":                   "0",
-					"log_slow_admin_statements":         "ON",
-					"log_slow_slave_statements":         "ON",
-					"log_slow_rate_limit":               "100",
-					"log_slow_rate_type":                "query",
-					"slow_query_log_always_write_time":  "1",
-					"log_slow_verbosity":                "full",
-					"slow_query_log_use_global_control": "all",
+					"slow_query_log":            "ON",
+					"log_output":                "FILE",
+					"long_query_time":           "0",
+					"log_slow_admin_statements": "ON",
+					m.replicaVariable("log_slow_replica_statements"): "ON",
+					"log_slow_rate_limit":                            "100",
+					"log_slow_rate_type":                             "query",
+					"slow_query_log_always_write_time":               "1",
+					"log_slow_verbosity":                             "full",
+					"slow_query_log_use_global_control":              "all",
 					// While you can use both slow query log and performance schema at the same time it's recommended to use only one
 					// There is some overlap in the data reported, and each incurs a small performance penalty
 					// https://docs.percona.com/percona-monitoring-and-management/setting-up/client/mysql.html#choose-and-configure-a-source
@@ -288,49 +352,97 @@ func (m *manager) setupPerconaServer(ctx context.Context) error {
 			return nil
 		})
 	}
-	tflog.Info(ctx, "Configuring instances")
-	if err = g.Wait(); err != nil {
-		return errors.Wrap(err, "configure instances")
-	}
-	tflog.Info(ctx, "Starting instances")
-	if err := m.setupInstances(ctx, instances); err != nil {
-		return errors.Wrap(err, "setup instances")
-	}
-	return nil
+	return g.Wait()
 }
 
 func (m *manager) editDefaultCfg(ctx context.Context, instance cloud.Instance, section string, keysAndValues map[string]string) error {
 	return m.editFile(ctx, instance, defaultMysqlConfigPath, utils.SetIniFields(section, keysAndValues))
 }
 
-func (m *manager) installPerconaServer(ctx context.Context, instance cloud.Instance) error {
+// prepareInstance enables the package repository of the release series on the instance.
+func (m *manager) prepareInstance(ctx context.Context, instance cloud.Instance, repository, series string) error {
+	if _, err := m.runCommand(ctx, instance, cmd.Init()); err != nil {
+		return errors.Wrap(err, "init")
+	}
+	if _, err := m.runCommand(ctx, instance, cmd.Configure(m.pass, repository, series)); err != nil {
+		return errors.Wrap(err, "run command")
+	}
+	return nil
+}
+
+// resolveVersion returns the full version of the package matching the configured version, or the latest one if the version isn't set.
+func (m *manager) resolveVersion(ctx context.Context, instance cloud.Instance) (string, error) {
 	availableVersions, err := m.versionList(ctx, instance)
 	if err != nil {
-		return errors.Wrap(err, "retrieve versions")
+		return "", errors.Wrap(err, "retrieve versions")
 	}
-	if m.version != "" {
-		fullVersion := utils.SelectVersion(availableVersions, m.version)
-		if fullVersion == "" {
-			return errors.Errorf("version not found, available versions: %v", availableVersions)
-		}
-		m.version = fullVersion
-	} else {
-		m.version = availableVersions[0]
+	if m.version == "" {
+		return availableVersions[0], nil
+	}
+	fullVersion := utils.SelectVersion(availableVersions, m.version)
+	if fullVersion == "" {
+		return "", errors.Errorf("version not found, available versions: %v", availableVersions)
 	}
+	return fullVersion, nil
+}
+
+func (m *manager) installPerconaServer(ctx context.Context, instance cloud.Instance, version string) error {
 	tflog.Info(ctx, "Installing Percona Server", map[string]interface{}{
-		resource.LogArgVersion:    m.version,
+		resource.LogArgVersion:    version,
 		resource.LogArgInstanceIP: instance.PublicIpAddress,
 	})
-	_, err = m.runCommand(ctx, instance, cmd.InstallPerconaServer(m.pass, m.version, m.port))
+	_, err := m.runCommand(ctx, instance, cmd.InstallPerconaServer(version))
 	if err != nil {
 		return errors.Wrap(err, "install percona server")
 	}
-	if err := m.editDefaultCfg(ctx, instance, "mysqld", map[string]string{"port": strconv.Itoa(m.port)}); err != nil {
+	if err := m.createAutomationUser(ctx, instance, version); err != nil {
+		return err
+	}
+	mysqldCfg, err := m.mysqldConfig(ctx, instance, m.tuningProfile, m.options, version)
+	if err != nil {
+		return err
+	}
+	cfg := m.versionConfig(version)
+	cfg["port"] = strconv.Itoa(m.port)
+	if m.tlsEnabled() {
+		if err := resource.SendCertificates(ctx, m.cloud, m.resourceID, instance, m.caCert, m.caKey); err != nil {
+			return errors.Wrap(err, "send certificates")
+		}
+		cfg = utils.MapMerge(cfg, resource.TLSConfig())
+	}
+	if err := m.editDefaultCfg(ctx, instance, "mysqld", utils.MapMerge(cfg, mysqldCfg)); err != nil {
 		return errors.Wrap(err, "set port")
 	}
 	return nil
 }
 
+// versionConfig returns the config required by the release series of the version.
+func (m *manager) versionConfig(version string) map[string]string {
+	cfg := make(map[string]string)
+	if resource.ReleaseSeries(version) == resource.ReleaseSeries84 {
+		// Users created before the upgrade to 8.4 keep mysql_native_password, which is disabled by default since 8.4
+		cfg["mysql_native_password"] = "ON"
+	}
+	return cfg
+}
+
+// replicaVariable returns the name of the variable with REPLICA/SOURCE terminology,
+// or its MASTER/SLAVE name for versions before 8.0.26, where the variables were renamed.
+func (m *manager) replicaVariable(name string) string {
+	if m.version == "" || utils.CompareVersions(m.version, "8.0.26") >= 0 {
+		return name
+	}
+	return strings.NewReplacer("replica", "slave", "source", "master").Replace(name)
+}
+
+// allowlistVariable returns the name of group replication allowlist variable, which was renamed in 8.0.22.
+func (m *manager) allowlistVariable() string {
+	if m.releaseSeries() == resource.ReleaseSeries57 {
+		return "group_replication_ip_whitelist"
+	}
+	return "group_replication_ip_allowlist"
+}
+
 const defaultMySQLGroupReplicationPort = 33061
 
 func (m *manager) instanceConfig(ctx context.Context, instance cloud.Instance, instances []cloud.Instance, serverID int, uuid string) map[string]string {
@@ -362,19 +474,38 @@ func (m *manager) instanceConfig(ctx context.Context, instance cloud.Instance, i
 			"group_replication_local_address":   fmt.Sprintf("%s:%d", instance.PrivateIpAddress, defaultMySQLGroupReplicationPort),
 			"group_replication_bootstrap_group": "off",
 		}
-		seeds := make([]string, 0, len(instances))
-		allowList := make([]string, 0, len(instances))
-		for _, i := range instances {
-			seeds = append(seeds, fmt.Sprintf("%s:%d", i.PrivateIpAddress, defaultMySQLGroupReplicationPort))
-			allowList = append(allowList, i.PrivateIpAddress)
+		if m.tlsEnabled() {
+			// Distributed recovery connects to the donor as a regular client, which must use TLS
+			cfg["group_replication_recovery_use_ssl"] = "ON"
+		} else if m.releaseSeries() == resource.ReleaseSeries84 {
+			// The replication user has caching_sha2_password since 8.4, its password is sent only with the public key of the donor
+			cfg["group_replication_recovery_get_public_key"] = "ON"
+		}
+		cfg["group_replication_group_seeds"], cfg[m.allowlistVariable()] = groupReplicationAddresses(instances)
+		if m.releaseSeries() == resource.ReleaseSeries57 {
+			// Defaults of these variables were changed to the values required by group replication in 8.0
+			cfg["binlog_checksum"] = "NONE"
+			cfg["binlog_format"] = "ROW"
+			cfg["log_slave_updates"] = "ON"
+			cfg["master_info_repository"] = "TABLE"
+			cfg["relay_log_info_repository"] = "TABLE"
+			cfg["transaction_write_set_extraction"] = "XXHASH64"
 		}
-		cfg["group_replication_group_seeds"] = strings.Join(seeds, ",")
-		cfg["group_replication_ip_allowlist"] = strings.Join(allowList, ",")
 		return cfg
 	}
 	return nil
 }
 
+func groupReplicationAddresses(instances []cloud.Instance) (seeds string, allowList string) {
+	seedList := make([]string, 0, len(instances))
+	ipList := make([]string, 0, len(instances))
+	for _, i := range instances {
+		seedList = append(seedList, fmt.Sprintf("%s:%d", i.PrivateIpAddress, defaultMySQLGroupReplicationPort))
+		ipList = append(ipList, i.PrivateIpAddress)
+	}
+	return strings.Join(seedList, ","), strings.Join(ipList, ",")
+}
+
 func (m *manager) setupInstances(ctx context.Context, instances []cloud.Instance) error {
 	switch m.replicationType {
 	case replicationTypeAsync:
@@ -388,12 +519,17 @@ func (m *manager) setupInstances(ctx context.Context, instances []cloud.Instance
 	default:
 		return errors.New("unknown replication type")
 	}
+	return m.addServicesToPMM(ctx, instances)
+}
+
+func (m *manager) addServicesToPMM(ctx context.Context, instances []cloud.Instance) error {
+	if m.pmmAddress == "" {
+		return nil
+	}
 	for _, instance := range instances {
-		if m.pmmAddress != "" {
-			_, err := m.runCommand(ctx, instance, cmd.AddServiceToPMM(m.pmmPassword, m.port))
-			if err != nil {
-				return errors.Wrap(err, "add service to pmm")
-			}
+		_, err := m.runCommand(ctx, instance, cmd.AddServiceToPMM(m.pmmPassword, m.port, m.pmmTLSCA()))
+		if err != nil {
+			return errors.Wrap(err, "add service to pmm")
 		}
 	}
 	return nil
@@ -401,7 +537,7 @@ func (m *manager) setupInstances(ctx context.Context, instances []cloud.Instance
 
 func (m *manager) setupAsyncInstances(ctx context.Context, instances []cloud.Instance) error {
 	masterInstance := instances[0]
-	db, err := m.newClient(masterInstance, internaldb.UserRoot, m.pass)
+	db, err := m.newAdminClient(masterInstance)
 	if err != nil {
 		return errors.Wrap(err, "new client")
 	}
@@ -452,11 +588,11 @@ func (m *manager) setupGRInstances(ctx context.Context, instances []cloud.Instan
 			if err := m.editDefaultCfg(gCtx, instance, "mysqld", cfg); err != nil {
 				return errors.Wrap(err, "edit default cfg for replication")
 			}
-			_, err = m.runCommand(gCtx, instance, cmd.Restart())
+			_, err := m.runCommand(gCtx, instance, cmd.Restart())
 			if err != nil {
 				return errors.Wrap(err, "restart mysql")
 			}
-			db, err := m.newClient(instance, internaldb.UserRoot, m.pass)
+			db, err := m.newAdminClient(instance)
 			if err != nil {
 				return errors.Wrap(err, "new client")
 			}
@@ -494,7 +630,7 @@ func (m *manager) setupGRInstances(ctx context.Context, instances []cloud.Instan
 			if i == 0 {
 				return nil
 			}
-			db, err := m.newClient(instance, internaldb.UserRoot, m.pass)
+			db, err := m.newAdminClient(instance)
 			if err != nil {
 				return errors.Wrap(err, "new client")
 			}
@@ -515,12 +651,12 @@ func (m *manager) setupGRInstances(ctx context.Context, instances []cloud.Instan
 }
 
 func (m *manager) startReplica(ctx context.Context, instance cloud.Instance, masterIP string) error {
-	db, err := m.newClient(instance, internaldb.UserRoot, m.pass)
+	db, err := m.newAdminClient(instance)
 	if err != nil {
 		return errors.Wrap(err, "failed to establish sql connection")
 	}
 	defer db.Close()
-	if err := db.ChangeReplicationSource(ctx, masterIP, m.port, internaldb.UserReplica, m.replicaPass); err != nil {
+	if err := db.ChangeReplicationSource(ctx, masterIP, m.port, internaldb.UserReplica, m.replicaPass, m.tlsEnabled()); err != nil {
 		return errors.Wrap(err, "change replication source")
 	}
 	if err := db.StartReplica(ctx); err != nil {
@@ -529,8 +665,20 @@ func (m *manager) startReplica(ctx context.Context, instance cloud.Instance, mas
 	return nil
 }
 
+// releaseSeries returns the release series set in the config, or the one of the version.
+func (m *manager) releaseSeries() string {
+	if m.series != "" {
+		return m.series
+	}
+	return resource.ReleaseSeries(m.version)
+}
+
+func (m *manager) repository() string {
+	return resource.ReleaseRepository("ps", m.releaseSeries(), m.version)
+}
+
 func (m *manager) versionList(ctx context.Context, instance cloud.Instance) ([]string, error) {
-	out, err := m.runCommand(ctx, instance, cmd.RetrieveVersions())
+	out, err := m.runCommand(ctx, instance, cmd.RetrieveVersions(m.releaseSeries()))
 	if err != nil {
 		return nil, errors.Wrap(err, "retrieve versions")
 	}
@@ -546,7 +694,24 @@ func (m *manager) runCommand(ctx context.Context, instance cloud.Instance, cmd s
 }
 
 func (m *manager) newClient(instance cloud.Instance, user, pass string) (*mysql.DB, error) {
-	return mysql.NewClient(instance.PublicIpAddress+":"+strconv.Itoa(m.port), user, pass)
+	host := instance.PublicIpAddress + ":" + strconv.Itoa(m.port)
+	if m.tlsEnabled() {
+		return mysql.NewTLSClient(host, user, pass, []byte(m.caCert))
+	}
+	return mysql.NewClient(host, user, pass)
+}
+
+// pmmTLSCA returns the CA certificate for the connection of PMM client, or empty string if TLS isn't enabled.
+func (m *manager) pmmTLSCA() string {
+	if !m.tlsEnabled() {
+		return ""
+	}
+	return resource.CACertPath
+}
+
+// tlsEnabled reports whether the cluster has a CA, clusters created before it was introduced accept unencrypted connections.
+func (m *manager) tlsEnabled() bool {
+	return m.caCert != ""
 }
 
 func (m *manager) sendFile(ctx context.Context, instance cloud.Instance, file io.Reader, remotePath string) error {
@@ -582,7 +747,7 @@ func (m *manager) editFile(ctx context.Context, instance cloud.Instance, remoteP
 		return errors.Wrapf(err, "failed to copy file from %s to %s", remotePath, tmpPath)
 	}
 
-	_, err = m.runCommand(ctx, instance, fmt.Sprintf("sudo chown ubuntu %s", tmpPath))
+	_, err = m.runCommand(ctx, instance, fmt.Sprintf("sudo chown $(id -un) %s", tmpPath))
 	if err != nil {
 		return errors.Wrapf(err, "failed to change permissions for %s", tmpPath)
 	}
@@ -0,0 +1,451 @@
+package ps
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"path"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"terraform-percona/internal/cloud"
+	"terraform-percona/internal/cloud/fake"
+	fakedb "terraform-percona/internal/db/mysql/fake"
+	"terraform-percona/internal/resource"
+	"terraform-percona/internal/resource/ps/cmd"
+)
+
+var errInjected = errors.New("injected failure")
+
+func newTestManager(t *testing.T, c *fake.Cloud) *manager {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The port is closed, so that database connections fail immediately
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	data := schema.TestResourceDataRaw(t, new(PerconaServer).Schema(), map[string]interface{}{
+		resource.SchemaKeyInstanceType:       "t3.micro",
+		resource.SchemaKeyKeyPairName:        "key",
+		resource.SchemaKeyRootPassword:       "rootPassword",
+		resource.SchemaKeyAutomationPassword: "automationPassword",
+		resource.SchemaKeyAutomationCIDR:     "203.0.113.1/32",
+		resource.SchemaKeyVersion:            "8.0.33",
+		resource.SchemaKeyClusterSize:        2,
+		resource.SchemaKeyPort:               port,
+	})
+	if err := resource.GenerateCA(data, "test"); err != nil {
+		t.Fatal(err)
+	}
+	c.OnCommand("apt-cache show", "8.0.33-25-1.focal\n8.0.32-24-1.focal")
+	return newManager(c, "test", data)
+}
+
+// TestSetupPerconaServer runs the setup until the first database connection, which is refused.
+func TestSetupPerconaServer(t *testing.T) {
+	c := fake.New()
+	m := newTestManager(t, c)
+	err := m.setupPerconaServer(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "create replica user") {
+		t.Fatalf("setupPerconaServer() error = %v, want database connection error", err)
+	}
+	if m.version != "8.0.33-25-1.focal" {
+		t.Errorf("version = %s, want 8.0.33-25-1.focal", m.version)
+	}
+
+	instances, err := c.ListInstances(context.Background(), "test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(instances) != 2 {
+		t.Fatalf("%d instances created, want 2", len(instances))
+	}
+	for _, instance := range instances {
+		want := []string{
+			cmd.Init(),
+			cmd.InstallPerconaServer(m.version),
+			cmd.CreateAutomationUser("rootPassword", "203.0.113.1", "automationPassword", m.version),
+			"sudo mv /opt/percona/mysqld.cnf " + defaultMysqlConfigPath,
+			cmd.Restart(),
+			// The automation user can't write to the mysql schema
+			cmd.InstallUDF("rootPassword"),
+		}
+		commands := c.Commands(instance)
+		for _, command := range commands {
+			if len(want) > 0 && command == want[0] {
+				want = want[1:]
+			}
+		}
+		if len(want) > 0 {
+			t.Errorf("command %q is not run on instance %s in order, commands: %q", want[0], instance.PrivateIpAddress, commands)
+		}
+
+		cfg, ok := c.File(instance, path.Join("/opt/percona", path.Base(defaultMysqlConfigPath)))
+		if !ok {
+			t.Fatalf("config of instance %s is not edited", instance.PrivateIpAddress)
+		}
+		// Values are aligned in the config
+		fields := strings.Join(strings.Fields(cfg), " ")
+		for _, want := range []string{"port = ", "ssl_ca = " + resource.CACertPath, "require_secure_transport = ON"} {
+			if !strings.Contains(fields, want) {
+				t.Errorf("config of instance %s doesn't contain %q:\n%s", instance.PrivateIpAddress, want, cfg)
+			}
+		}
+	}
+}
+
+func TestSetupPerconaServerFailures(t *testing.T) {
+	tests := []struct {
+		name    string
+		failOn  string
+		wantErr string
+	}{
+		{"init", cmd.Init(), "init"},
+		{"repository", "percona-release enable-only", "run command"},
+		{"versions", "apt-cache show", "retrieve versions"},
+		{"install", cmd.InstallPerconaServer("8.0.33-25-1.focal"), "install percona server"},
+		{"automation user", "CREATE USER IF NOT EXISTS", "create automation user"},
+		{"certificates", "percona-certs/server-key.pem", "send certificates"},
+		{"config", "/opt/percona/mysqld.cnf", "set port"},
+		{"restart", cmd.Restart(), "restart mysql"},
+		{"udf", cmd.InstallUDF("rootPassword"), "install percona server UDF"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.New()
+			c.FailOn(tt.failOn, errInjected)
+			err := newTestManager(t, c).setupPerconaServer(context.Background())
+			if !errors.Is(err, errInjected) {
+				t.Fatalf("setupPerconaServer() error = %v, want %v", err, errInjected)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("setupPerconaServer() error = %v, want error containing %q", err, tt.wantErr)
+			}
+			if tt.failOn == cmd.Restart() || tt.failOn == cmd.InstallUDF("rootPassword") {
+				return
+			}
+			for _, call := range c.Calls() {
+				if call.Arg == cmd.Restart() {
+					t.Errorf("mysql is restarted on instance %s after the failure", call.Instance.PrivateIpAddress)
+				}
+			}
+		})
+	}
+}
+
+// newTestServer returns the database server of the instances, it accepts the connections over TLS with the CA of the manager.
+func newTestServer(t *testing.T, m *manager, instances []cloud.Instance) *fakedb.Server {
+	t.Helper()
+	hosts := make([]string, 0, len(instances))
+	for _, instance := range instances {
+		hosts = append(hosts, instance.PublicIpAddress)
+	}
+	db, err := fakedb.New(hosts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(db.Close)
+	db.TLS(m.caCert, m.caKey)
+	m.port = db.Port()
+	return db
+}
+
+// inOrder returns the first of the wanted queries which isn't run in order, or empty string if all of them are run.
+func inOrder(queries []string, want ...string) string {
+	for _, query := range queries {
+		if len(want) > 0 && strings.Contains(query, want[0]) {
+			want = want[1:]
+		}
+	}
+	if len(want) > 0 {
+		return want[0]
+	}
+	return ""
+}
+
+// TestSetupAsyncInstances creates the replica user on the source and starts the replicas from it, each instance gets its own server_id.
+func TestSetupAsyncInstances(t *testing.T) {
+	c := fake.New()
+	m := newTestManager(t, c)
+	instances, err := c.CreateInstances(context.Background(), "test", 3, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := newTestServer(t, m, instances)
+	if err := m.setupAsyncInstances(context.Background(), instances); err != nil {
+		t.Fatal(err)
+	}
+
+	source := instances[0]
+	queries := db.HostQueries(source.PublicIpAddress)
+	if missing := inOrder(queries, "CREATE USER IF NOT EXISTS 'replica_user'@'%'", "GRANT REPLICATION SLAVE ON *.* TO 'replica_user'@'%'"); missing != "" {
+		t.Errorf("query %q is not run on the source in order, queries: %q", missing, queries)
+	}
+	if missing := inOrder(queries, "CHANGE REPLICATION SOURCE"); missing == "" {
+		t.Errorf("replication source is changed on the source, queries: %q", queries)
+	}
+	for i, instance := range instances {
+		cfg, _ := c.File(instance, path.Join("/opt/percona", path.Base(defaultMysqlConfigPath)))
+		if fields := strings.Join(strings.Fields(cfg), " "); !strings.Contains(fields, "server_id = "+strconv.Itoa(i+1)) {
+			t.Errorf("config of instance %s doesn't contain server_id = %d:\n%s", instance.PrivateIpAddress, i+1, cfg)
+		}
+		if i == 0 {
+			continue
+		}
+		queries := db.HostQueries(instance.PublicIpAddress)
+		changeSource := fmt.Sprintf("CHANGE REPLICATION SOURCE TO SOURCE_HOST='%s', SOURCE_PORT=%d, SOURCE_USER='replica_user', SOURCE_PASSWORD='%s', SOURCE_AUTO_POSITION=1, SOURCE_SSL=1",
+			source.PrivateIpAddress, m.port, m.replicaPass)
+		if missing := inOrder(queries, changeSource, "START REPLICA"); missing != "" {
+			t.Errorf("query %q is not run on replica %s in order, queries: %q", missing, instance.PrivateIpAddress, queries)
+		}
+	}
+}
+
+// TestSetupGRInstances bootstraps the group on the first instance, other instances join it after the bootstrap is turned off.
+func TestSetupGRInstances(t *testing.T) {
+	c := fake.New()
+	m := newTestManager(t, c)
+	m.replicationType = replicationTypeGR
+	instances, err := c.CreateInstances(context.Background(), "test", 3, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := newTestServer(t, m, instances)
+	if err := m.setupGRInstances(context.Background(), instances); err != nil {
+		t.Fatal(err)
+	}
+
+	var groupName string
+	for i, instance := range instances {
+		cfg, _ := c.File(instance, path.Join("/opt/percona", path.Base(defaultMysqlConfigPath)))
+		fields := strings.Join(strings.Fields(cfg), " ")
+		for _, want := range []string{"server_id = " + strconv.Itoa(i+1), "group_replication_recovery_use_ssl = ON"} {
+			if !strings.Contains(fields, want) {
+				t.Errorf("config of instance %s doesn't contain %q:\n%s", instance.PrivateIpAddress, want, cfg)
+			}
+		}
+		_, name, _ := strings.Cut(fields, "group_replication_group_name = ")
+		name, _, _ = strings.Cut(name, " ")
+		if name == "" || groupName != "" && name != groupName {
+			t.Errorf("group_replication_group_name of instance %s = %q, want the same uuid on all instances", instance.PrivateIpAddress, name)
+		}
+		groupName = name
+
+		queries := db.HostQueries(instance.PublicIpAddress)
+		want := []string{"SET SQL_LOG_BIN=0", "CREATE USER IF NOT EXISTS 'replica_user'@'%'", "FOR CHANNEL 'group_replication_recovery'"}
+		if i == 0 {
+			want = append(want, "group_replication_bootstrap_group='ON'", "START GROUP_REPLICATION", "group_replication_bootstrap_group='OFF'")
+		} else {
+			want = append(want, "START GROUP_REPLICATION")
+			if missing := inOrder(queries, "group_replication_bootstrap_group"); missing == "" {
+				t.Errorf("instance %s bootstraps the group, queries: %q", instance.PrivateIpAddress, queries)
+			}
+		}
+		if missing := inOrder(queries, want...); missing != "" {
+			t.Errorf("query %q is not run on instance %s in order, queries: %q", missing, instance.PrivateIpAddress, queries)
+		}
+	}
+
+	var bootstrapped bool
+	for _, query := range db.Queries() {
+		switch {
+		case query.Host == instances[0].PublicIpAddress:
+			bootstrapped = bootstrapped || strings.Contains(query.SQL, "group_replication_bootstrap_group='OFF'")
+		case query.SQL == "START GROUP_REPLICATION" && !bootstrapped:
+			t.Errorf("instance %s starts group replication before the group is bootstrapped", query.Host)
+		}
+	}
+}
+
+// TestSetupInstancesFailures stops the setup at the first failed query.
+func TestSetupInstancesFailures(t *testing.T) {
+	tests := []struct {
+		name            string
+		replicationType string
+		failOn          string
+		wantErr         string
+	}{
+		{"replica user", replicationTypeAsync, "GRANT REPLICATION SLAVE", "create replica user"},
+		{"start replica", replicationTypeAsync, "START REPLICA", "start replica"},
+		{"group replication source", replicationTypeGR, "FOR CHANNEL 'group_replication_recovery'", "change group replication source"},
+		{"bootstrap", replicationTypeGR, "group_replication_bootstrap_group='ON'", "set group_replication_bootstrap_group=ON"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.New()
+			m := newTestManager(t, c)
+			m.replicationType = tt.replicationType
+			instances, err := c.CreateInstances(context.Background(), "test", 2, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			db := newTestServer(t, m, instances)
+			db.FailOn(tt.failOn, 1045, "Access denied")
+			err = m.setupInstances(context.Background(), instances)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("setupInstances() error = %v, want error containing %q", err, tt.wantErr)
+			}
+			if tt.replicationType == replicationTypeGR {
+				return
+			}
+			// Replicas are set up one by one, nothing is run after the failure
+			if queries := db.HostQueries(instances[len(instances)-1].PublicIpAddress); tt.failOn != "START REPLICA" && len(queries) > 0 {
+				t.Errorf("queries are run on replica after the failure: %q", queries)
+			}
+		})
+	}
+}
+
+// TestInspect restores the attributes of an imported cluster from its instances, nothing should be changed on them.
+func TestInspect(t *testing.T) {
+	c := fake.New()
+	m := newTestManager(t, c)
+	if _, err := c.CreateInstances(context.Background(), "test", 2, map[string]string{
+		resource.LabelKeyInstanceType: resource.LabelValueInstanceTypeMySQL,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	c.OnCommand("'percona-server-server*'", "8.0.32-24-1.focal\n")
+	c.OnCommand("'percona-server-rocksdb*'", "8.0.32-24-1.focal\n")
+	c.OnCommand(cmd.ReadFile(defaultMysqlConfigPath), "[mysqld]\nserver_id = 1\ngroup_replication_group_name = aaaaaaaa-bbbb-cccc-dddd-eeeeeeeeeeee\n")
+
+	data := schema.TestResourceDataRaw(t, new(PerconaServer).Schema(), map[string]interface{}{})
+	if err := m.inspect(context.Background(), data); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]interface{}{
+		resource.SchemaKeyVersion: "8.0.32",
+		schemaKeyMyRocksInstall:   true,
+		schemaKeyReplicationType:  replicationTypeGR,
+		schemaKeyOrchestatorSize:  0,
+	} {
+		if got := data.Get(key); got != want {
+			t.Errorf("%s = %v, want %v", key, got, want)
+		}
+	}
+	for _, call := range c.Calls() {
+		if call.Method != fake.MethodRunCommand {
+			t.Errorf("inspect changed the instance: %s %s", call.Method, call.Arg)
+		}
+	}
+}
+
+// TestSetConfigFileHash reads the hash of the config file on the instances, so that imported clusters aren't restarted by the first apply.
+func TestSetConfigFileHash(t *testing.T) {
+	tests := map[string]struct {
+		out  string
+		want string
+	}{
+		"config file": {
+			out:  "0123abcd  " + customMysqlConfigPath + "\n",
+			want: "0123abcd",
+		},
+		"no config file": {},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := fake.New()
+			m := newTestManager(t, c)
+			instances, err := c.CreateInstances(context.Background(), "test", 1, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.OnCommand(cmd.FileHash(customMysqlConfigPath), tt.out)
+			data := schema.TestResourceDataRaw(t, new(PerconaServer).Schema(), map[string]interface{}{})
+			if err := data.Set(resource.SchemaKeyConfigFileHash, "stale"); err != nil {
+				t.Fatal(err)
+			}
+			if err := m.setConfigFileHash(context.Background(), data, []instanceState{{Instance: instances[0]}}); err != nil {
+				t.Fatal(err)
+			}
+			if got := data.Get(resource.SchemaKeyConfigFileHash).(string); got != tt.want {
+				t.Errorf("%s = %q, want %q", resource.SchemaKeyConfigFileHash, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestReadWithoutInstances removes the resource from the state only if the cloud has no instances of it at all.
+func TestReadWithoutInstances(t *testing.T) {
+	tests := map[string]struct {
+		orchestrator bool
+		wantID       string
+	}{
+		"no instances": {},
+		"orchestrator instance": {
+			orchestrator: true,
+			wantID:       "test",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := fake.New()
+			if tt.orchestrator {
+				if _, err := c.CreateInstances(context.Background(), "test", 1, map[string]string{
+					resource.LabelKeyInstanceType: resource.LabelValueInstanceTypeOrchestrator,
+				}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			data := schema.TestResourceDataRaw(t, new(PerconaServer).Schema(), map[string]interface{}{})
+			data.SetId("test")
+			diags := new(PerconaServer).Read(context.Background(), data, c)
+			if diags.HasError() != (tt.wantID != "") {
+				t.Errorf("Read() diagnostics = %v", diags)
+			}
+			if data.Id() != tt.wantID {
+				t.Errorf("resource id = %q, want %q", data.Id(), tt.wantID)
+			}
+		})
+	}
+}
+
+// TestUpgradeRefusedByChecker enables the repository of the installed version again if the upgrade checker finds blocking issues.
+func TestUpgradeRefusedByChecker(t *testing.T) {
+	c := fake.New()
+	c.OnCommand(cmd.RetrieveVersions(resource.ReleaseSeries84), "8.4.0-1-1.focal")
+	c.OnCommand(cmd.InstalledVersion(), "8.0.33-25-1.focal")
+	c.OnCommand("check-for-server-upgrade", `{"errorCount": 1, "checksPerformed": [{"id": "removedSysVars", "title": "Removed system variables",`+
+		`"detectedProblems": [{"level": "Error", "dbObject": "expire_logs_days", "description": "removed in 8.4"}]}]}`)
+	m := newTestManager(t, c)
+	instances, err := c.CreateInstances(context.Background(), "test", 2, map[string]string{
+		resource.LabelKeyInstanceType: resource.LabelValueInstanceTypeMySQL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	newTestServer(t, m, instances)
+	m.version = "8.4.0"
+
+	if err := m.upgrade(context.Background()); err == nil || !strings.Contains(err.Error(), "upgrade check failed") {
+		t.Fatalf("upgrade() error = %v, want upgrade check error", err)
+	}
+	for _, instance := range instances {
+		cmds := c.Commands(instance)
+		enabled := ""
+		for _, command := range cmds {
+			switch command {
+			case cmd.UpdateRepository("ps-84-lts"):
+				enabled = "ps-84-lts"
+			case cmd.UpdateRepository("ps-80"):
+				enabled = "ps-80"
+			}
+			if strings.Contains(command, "percona-server-server=") {
+				t.Errorf("percona server is upgraded on instance %s: %s", instance.PrivateIpAddress, command)
+			}
+		}
+		if enabled == "ps-84-lts" {
+			t.Errorf("repository ps-84-lts is left enabled on instance %s", instance.PrivateIpAddress)
+		}
+	}
+	if got := c.Commands(instances[0]); !strings.Contains(strings.Join(got, "\n"), cmd.UpdateRepository("ps-80")) {
+		t.Errorf("repository ps-80 isn't enabled again on instance %s", instances[0].PrivateIpAddress)
+	}
+}
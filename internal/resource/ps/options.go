@@ -0,0 +1,110 @@
+package ps
+
+import (
+	"context"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/pkg/errors"
+
+	"terraform-percona/internal/cloud"
+	"terraform-percona/internal/resource"
+	"terraform-percona/internal/resource/ps/cmd"
+	"terraform-percona/internal/utils"
+)
+
+// applyOptions applies the changes of tuning_profile and mysqld_options to the config file of every instance, dynamic variables are set at runtime.
+// Instances are restarted one by one only if some variables can't be changed at runtime: replicas first, the source is the last one.
+func (m *manager) applyOptions(ctx context.Context, oldProfile string, oldOptions map[string]string) error {
+	states, err := m.instanceStates(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get instance states")
+	}
+	for _, state := range states {
+		if state.replicationState != replicationStateOnline {
+			return errors.Errorf("instance %s is not online: %s, all instances should be online before config change", state.PrivateIpAddress, state.replicationState)
+		}
+	}
+	for _, state := range rollingOrder(states) {
+		version, err := m.installedVersion(ctx, state.Instance)
+		if err != nil {
+			return err
+		}
+		oldCfg, err := m.mysqldConfig(ctx, state.Instance, oldProfile, oldOptions, version)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get mysqld options of instance %s", state.PrivateIpAddress)
+		}
+		newCfg, err := m.mysqldConfig(ctx, state.Instance, m.tuningProfile, m.options, version)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get mysqld options of instance %s", state.PrivateIpAddress)
+		}
+		changed, removed := resource.ConfigChanges(oldCfg, newCfg)
+		if len(changed) == 0 && len(removed) == 0 {
+			continue
+		}
+		restart, err := m.applyInstanceOptions(ctx, state.Instance, changed, removed)
+		if err != nil {
+			return errors.Wrapf(err, "failed to apply mysqld options to instance %s", state.PrivateIpAddress)
+		}
+		if !restart {
+			continue
+		}
+		if _, err := m.runCommand(ctx, state.Instance, cmd.Restart()); err != nil {
+			return errors.Wrapf(err, "failed to restart instance %s", state.PrivateIpAddress)
+		}
+		if err := m.rejoin(ctx, state.Instance); err != nil {
+			return errors.Wrapf(err, "instance %s failed to rejoin the cluster after restart, check /var/log/mysql/error.log on the instance", state.PrivateIpAddress)
+		}
+	}
+	return nil
+}
+
+// mysqldConfig returns the options set by the tuning profile and mysqld_options, mysqld_options take precedence.
+func (m *manager) mysqldConfig(ctx context.Context, instance cloud.Instance, profile string, options map[string]string, version string) (map[string]string, error) {
+	cfg := make(map[string]string)
+	if profile == resource.TuningProfileAuto {
+		out, err := m.runCommand(ctx, instance, cmd.SystemResources())
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get system resources")
+		}
+		res, err := resource.ParseSystemResources(out)
+		if err != nil {
+			return nil, err
+		}
+		cfg = resource.TuningConfig(res, m.volumeIOPS, version)
+	}
+	return utils.MapMerge(cfg, options), nil
+}
+
+// applyInstanceOptions sets the variables at runtime and then updates the config file of the instance.
+// It reports whether the instance should be restarted to apply the rest of them.
+func (m *manager) applyInstanceOptions(ctx context.Context, instance cloud.Instance, changed map[string]string, removed []string) (bool, error) {
+	db, err := m.newAdminClient(instance)
+	if err != nil {
+		return false, errors.Wrap(err, "new client")
+	}
+	defer db.Close()
+	// Unknown variables and invalid values fail before the config file is changed, so that the instance can still start
+	static, err := db.ApplyVariables(ctx, changed, removed)
+	if err != nil {
+		return false, err
+	}
+	if len(changed) > 0 {
+		if err := m.editDefaultCfg(ctx, instance, "mysqld", changed); err != nil {
+			return false, errors.Wrap(err, "edit default cfg")
+		}
+	}
+	if len(removed) > 0 {
+		if err := m.editFile(ctx, instance, defaultMysqlConfigPath, utils.DeleteIniFields("mysqld", removed)); err != nil {
+			return false, errors.Wrap(err, "edit default cfg")
+		}
+	}
+	if len(static) == 0 {
+		return false, nil
+	}
+	tflog.Info(ctx, "Restart is required to apply mysqld options", map[string]interface{}{
+		resource.LogArgInstanceIP: instance.PublicIpAddress,
+		"options":                 strings.Join(static, ","),
+	})
+	return true, nil
+}
@@ -210,9 +210,12 @@ const (
 	defaultOrchestratorListenPort      = 3000
 	defaultOrchestratorCredentialsPath = "/etc/mysql/orchestrator-topology.cnf"
 	defaultOrchestratorConfigPath      = "/etc/orchestrator.conf.json"
+	defaultOrchestratorCAPath          = "/etc/mysql/orchestrator-ca.pem"
 )
 
-func orchestratorConfig(instance cloud.Instance, instances []cloud.Instance) ([]byte, error) {
+// orchestratorConfig returns the config of the orchestrator instance, topology instances are connected over TLS
+// verified with the CA certificate at caPath if it's set.
+func orchestratorConfig(instance cloud.Instance, instances []cloud.Instance, caPath string) ([]byte, error) {
 	raftNodes := []string{}
 	for _, i := range instances {
 		raftNodes = append(raftNodes, i.PrivateIpAddress)
@@ -235,6 +238,10 @@ func orchestratorConfig(instance cloud.Instance, instances []cloud.Instance) ([]
 		MySQLHostnameResolveMethod:         "@@hostname",
 		InstanceFlushIntervalMilliseconds:  100,
 	}
+	if caPath != "" {
+		cfg.MySQLTopologyUseMutualTLS = true
+		cfg.MySQLTopologySSLCAFile = caPath
+	}
 	return json.Marshal(cfg)
 }
 
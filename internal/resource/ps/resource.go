@@ -25,6 +25,12 @@ const (
 	schemaKeyOrchestatorInstances = "orchestrator_instances"
 )
 
+// passwordKeys are the attributes which can be kept in the secret store.
+var passwordKeys = []string{resource.SchemaKeyRootPassword, schemaKeyReplicaPassword, schemaKeyOrchestatorPassword, resource.SchemaKeyPMMPassword, resource.SchemaKeyAutomationPassword}
+
+// secretKeys are kept in the secret store along with the passwords, they are needed only to change the cluster.
+var secretKeys = append(append([]string{}, passwordKeys...), resource.SchemaKeyCAPrivateKey)
+
 const (
 	replicationTypeAsync = "async"
 	replicationTypeGR    = "group-replication"
@@ -52,10 +58,11 @@ func (r *PerconaServer) Schema() map[string]*schema.Schema {
 			},
 		},
 		schemaKeyReplicaPassword: {
-			Type:      schema.TypeString,
-			Optional:  true,
-			Default:   "replicaPassword",
-			Sensitive: true,
+			Type:          schema.TypeString,
+			Optional:      true,
+			Computed:      true,
+			Sensitive:     true,
+			ConflictsWith: []string{resource.SchemaKeySecretStore},
 		},
 		schemaKeyMyRocksInstall: {
 			Type:     schema.TypeBool,
@@ -75,10 +82,11 @@ func (r *PerconaServer) Schema() map[string]*schema.Schema {
 			},
 		},
 		schemaKeyOrchestatorPassword: {
-			Type:      schema.TypeString,
-			Optional:  true,
-			Default:   "password",
-			Sensitive: true,
+			Type:          schema.TypeString,
+			Optional:      true,
+			Computed:      true,
+			Sensitive:     true,
+			ConflictsWith: []string{resource.SchemaKeySecretStore},
 		},
 		resource.SchemaKeyInstances: {
 			Type:     schema.TypeSet,
@@ -93,10 +101,14 @@ func (r *PerconaServer) Schema() map[string]*schema.Schema {
 						Type:     schema.TypeString,
 						Computed: true,
 					},
-					"is_replica": {
+					schemaKeyInstancesIsReplica: {
 						Type:     schema.TypeBool,
 						Computed: true,
 					},
+					schemaKeyInstancesReplicationState: {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
 				},
 			},
 		},
@@ -130,6 +142,27 @@ func (r *PerconaServer) Create(ctx context.Context, data *schema.ResourceData, c
 		return diag.FromErr(errors.Wrap(err, "can't configure cloud"))
 	}
 
+	defer resource.ClearSecrets(data, secretKeys...)
+	if err := resource.PullSecrets(ctx, data, c, secretKeys...); err != nil {
+		return diag.FromErr(errors.Wrap(err, "can't pull passwords"))
+	}
+	err = resource.GeneratePasswords(data, passwordKeys...)
+	if err != nil {
+		return diag.FromErr(errors.Wrap(err, "can't generate passwords"))
+	}
+	if err := resource.GenerateCA(data, resourceID); err != nil {
+		return diag.FromErr(errors.Wrap(err, "can't generate ca"))
+	}
+	if err := resource.PushSecrets(ctx, data, c, secretKeys...); err != nil {
+		return diag.FromErr(errors.Wrap(err, "can't push passwords"))
+	}
+
+	// Scripts are read before the cluster is created, so that a missing file fails fast
+	initScripts, err := resource.InitScripts(data)
+	if err != nil {
+		return diag.FromErr(errors.Wrap(err, "can't read init sql"))
+	}
+
 	data.SetId(resourceID)
 	err = c.CreateInfrastructure(ctx, resourceID)
 	if err != nil {
@@ -137,12 +170,16 @@ func (r *PerconaServer) Create(ctx context.Context, data *schema.ResourceData, c
 	}
 
 	manager := newManager(c, resourceID, data)
+	manager.initScripts = initScripts
 	err = manager.createCluster(ctx)
+	if err := data.Set(resource.SchemaKeyAutomationCIDR, manager.automationCIDR); err != nil {
+		return diag.FromErr(errors.Wrap(err, "can't set automation cidr"))
+	}
 	if err != nil {
 		return diag.FromErr(errors.Wrap(err, "can't create ps cluster"))
 	}
 
-	if err := setOutputValues(ctx, c, resourceID, data); err != nil {
+	if err := setOutputValues(ctx, manager, data); err != nil {
 		return diag.FromErr(errors.Wrap(err, "failed to set output values"))
 	}
 
@@ -150,61 +187,219 @@ func (r *PerconaServer) Create(ctx context.Context, data *schema.ResourceData, c
 	return nil
 }
 
-func setOutputValues(ctx context.Context, c cloud.Cloud, resourceID string, data *schema.ResourceData) error {
-	instances, err := c.ListInstances(ctx, resourceID, map[string]string{
-		resource.LabelKeyInstanceType: resource.LabelValueInstanceTypeMySQL,
-	})
+func setOutputValues(ctx context.Context, manager *manager, data *schema.ResourceData) error {
+	instances, err := manager.instanceStates(ctx)
 	if err != nil {
-		diag.FromErr(errors.Wrap(err, "failed to list instances"))
+		return errors.Wrap(err, "failed to get instance states")
 	}
+	return setInstances(ctx, manager, data, instances)
+}
 
-	set := data.Get(resource.SchemaKeyInstances).(*schema.Set)
-	for i, instance := range instances {
-		set.Add(map[string]interface{}{
-			"is_replica":                         i != 0,
+func setInstances(ctx context.Context, manager *manager, data *schema.ResourceData, instances []instanceState) error {
+	mysqlInstances := make([]interface{}, 0, len(instances))
+	for _, instance := range instances {
+		mysqlInstances = append(mysqlInstances, map[string]interface{}{
+			schemaKeyInstancesIsReplica:          instance.isReplica,
+			schemaKeyInstancesReplicationState:   instance.replicationState,
 			resource.SchemaKeyInstancesPublicIP:  instance.PublicIpAddress,
 			resource.SchemaKeyInstancesPrivateIP: instance.PrivateIpAddress,
 		})
 	}
-	err = data.Set(resource.SchemaKeyInstances, set)
+	err := data.Set(resource.SchemaKeyInstances, mysqlInstances)
 	if err != nil {
 		return errors.Wrap(err, "can't set mysql instances")
 	}
 
-	instances, err = c.ListInstances(ctx, resourceID, map[string]string{
+	orcInstances, err := manager.cloud.ListInstances(ctx, manager.resourceID, map[string]string{
 		resource.LabelKeyInstanceType: resource.LabelValueInstanceTypeOrchestrator,
 	})
 	if err != nil {
-		diag.FromErr(errors.Wrap(err, "failed to list instances"))
+		return errors.Wrap(err, "failed to list orchestrator instances")
 	}
 
-	set = data.Get(schemaKeyOrchestatorInstances).(*schema.Set)
-	for _, instance := range instances {
-		set.Add(map[string]interface{}{
+	orchestratorInstances := make([]interface{}, 0, len(orcInstances))
+	for _, instance := range orcInstances {
+		orchestratorInstances = append(orchestratorInstances, map[string]interface{}{
 			"url":                                fmt.Sprintf("http://%s:%d/%s", instance.PublicIpAddress, defaultOrchestratorListenPort, defaultOrchestratorURLPrefix),
 			resource.SchemaKeyInstancesPublicIP:  instance.PublicIpAddress,
 			resource.SchemaKeyInstancesPrivateIP: instance.PrivateIpAddress,
 		})
 	}
-	err = data.Set(schemaKeyOrchestatorInstances, set)
+	err = data.Set(schemaKeyOrchestatorInstances, orchestratorInstances)
 	if err != nil {
 		return errors.Wrap(err, "can't set orchestrator instances")
 	}
 	return nil
 }
 
-func (r *PerconaServer) Read(_ context.Context, _ *schema.ResourceData, _ cloud.Cloud) diag.Diagnostics {
-	// TODO
+func (r *PerconaServer) Read(ctx context.Context, data *schema.ResourceData, c cloud.Cloud) diag.Diagnostics {
+	resourceID := data.Id()
+	if resourceID == "" {
+		return diag.FromErr(errors.New("empty resource id"))
+	}
+
+	err := c.Configure(ctx, resourceID, data)
+	if err != nil {
+		return diag.FromErr(errors.Wrap(err, "can't configure cloud"))
+	}
+	defer resource.ClearSecrets(data, passwordKeys...)
+	if err := resource.PullSecrets(ctx, data, c, passwordKeys...); err != nil {
+		return diag.FromErr(errors.Wrap(err, "can't pull passwords"))
+	}
+
+	manager := newManager(c, resourceID, data)
+	instances, err := manager.instanceStates(ctx)
+	if err != nil {
+		return diag.FromErr(errors.Wrap(err, "failed to get instance states"))
+	}
+	if len(instances) == 0 {
+		// The resource is removed from the state only if the cloud has no instances of it at all, e.g. orchestrator instances are left
+		// after the MySQL instances are terminated and should be destroyed with the resource
+		all, err := c.ListInstances(ctx, resourceID, nil)
+		if err != nil {
+			return diag.FromErr(errors.Wrap(err, "failed to list instances"))
+		}
+		if len(all) > 0 {
+			return diag.Errorf("Percona Server instances are not found, but %d other instances of the resource exist, destroy the resource to delete them", len(all))
+		}
+		tflog.Warn(ctx, "Percona Server instances are not found, removing resource from state", map[string]interface{}{
+			"resource_id": resourceID,
+		})
+		data.SetId("")
+		return nil
+	}
+
+	// Terminated nodes show up as a cluster_size change in the plan.
+	if err := data.Set(resource.SchemaKeyClusterSize, len(instances)); err != nil {
+		return diag.FromErr(errors.Wrap(err, "can't set cluster size"))
+	}
+	if err := resource.SetProviderCIDR(ctx, data, c, resourceID); err != nil {
+		return diag.FromErr(errors.Wrap(err, "can't set provider cidr"))
+	}
+	if err := manager.setConfigFileHash(ctx, data, instances); err != nil {
+		return diag.FromErr(errors.Wrap(err, "can't set config file hash"))
+	}
+	if err := setInstances(ctx, manager, data, instances); err != nil {
+		return diag.FromErr(errors.Wrap(err, "failed to set output values"))
+	}
 	return nil
 }
-func (r *PerconaServer) Update(_ context.Context, _ *schema.ResourceData, _ cloud.Cloud) diag.Diagnostics {
-	// TODO
+
+func (r *PerconaServer) Update(ctx context.Context, data *schema.ResourceData, c cloud.Cloud) diag.Diagnostics {
+	resourceID := data.Id()
+	if resourceID == "" {
+		return diag.FromErr(errors.New("empty resource id"))
+	}
+
+	err := c.Configure(ctx, resourceID, data)
+	if err != nil {
+		return diag.FromErr(errors.Wrap(err, "can't configure cloud"))
+	}
+
+	defer resource.ClearSecrets(data, secretKeys...)
+	if err := resource.PullSecrets(ctx, data, c, secretKeys...); err != nil {
+		return diag.FromErr(errors.Wrap(err, "can't pull passwords"))
+	}
+	required := []string{resource.SchemaKeyRootPassword, schemaKeyReplicaPassword}
+	if data.Get(schemaKeyOrchestatorSize).(int) > 0 {
+		required = append(required, schemaKeyOrchestatorPassword)
+	}
+	if data.Get(resource.SchemaKeyPMMAddress).(string) != "" {
+		required = append(required, resource.SchemaKeyPMMPassword)
+	}
+	if err := resource.RequirePasswords(data, required...); err != nil {
+		return diag.FromErr(err)
+	}
+	previous, err := resource.PreviousPasswords(ctx, data, c, passwordKeys...)
+	if err != nil {
+		return diag.FromErr(errors.Wrap(err, "can't get previous passwords"))
+	}
+
+	manager := newManager(c, resourceID, data)
+	if oldCIDR, _ := data.GetChange(resource.SchemaKeyAutomationCIDR); oldCIDR.(string) == "" {
+		// The cluster was created before the automation user was introduced
+		if err := setupAutomationUser(ctx, manager, data); err != nil {
+			return resource.PartialError(data, err, "can't set up ps cluster automation user")
+		}
+		previous[resource.SchemaKeyAutomationPassword] = manager.automationPass
+	}
+	// The provider may be unable to connect from its new address before the automation user is renamed
+	if oldCIDR, _ := data.GetChange(resource.SchemaKeyAutomationCIDR); oldCIDR.(string) != "" && data.HasChange(resource.SchemaKeyAutomationCIDR) {
+		if err := manager.updateAutomationCIDR(ctx, oldCIDR.(string), previous[resource.SchemaKeyRootPassword]); err != nil {
+			return resource.PartialError(data, err, "can't change ps cluster automation cidr")
+		}
+	}
+	if resource.PasswordsChanged(data, previous, passwordKeys...) {
+		if err := manager.rotatePasswords(ctx, previousCredentials(previous)); err != nil {
+			return resource.PartialError(data, err, "can't change ps cluster passwords")
+		}
+	}
+	if err := resource.PushSecrets(ctx, data, c, secretKeys...); err != nil {
+		return diag.FromErr(errors.Wrap(err, "can't push passwords"))
+	}
+	if data.HasChange(resource.SchemaKeyUsers) {
+		if err := manager.applyUsers(ctx, resource.PreviousUsers(data)); err != nil {
+			return resource.PartialError(data, err, "can't apply ps cluster users")
+		}
+	}
+	if data.HasChange(resource.SchemaKeyVersion) {
+		if err := manager.upgrade(ctx); err != nil {
+			return resource.PartialError(data, err, "can't upgrade ps cluster")
+		}
+	}
+	// The file is pushed only if its content differs from the file on the instances
+	if data.HasChange(resource.SchemaKeyConfigFileHash) {
+		if err := manager.pushConfig(ctx); err != nil {
+			return resource.PartialError(data, err, "can't update ps cluster config")
+		}
+	}
+	if data.HasChanges(resource.SchemaKeyMySQLDOptions, resource.SchemaKeyTuningProfile) {
+		oldProfile, oldOptions := resource.PreviousMySQLDOptions(data)
+		if err := manager.applyOptions(ctx, oldProfile, oldOptions); err != nil {
+			return resource.PartialError(data, err, "can't apply ps mysqld options")
+		}
+	}
+	if data.HasChange(resource.SchemaKeyClusterSize) {
+		if err := manager.resize(ctx); err != nil {
+			return resource.PartialError(data, err, "can't resize ps cluster")
+		}
+	}
+
+	if err := setOutputValues(ctx, manager, data); err != nil {
+		return diag.FromErr(errors.Wrap(err, "failed to set output values"))
+	}
+
+	tflog.Info(ctx, "Percona Server resource updated")
 	return nil
 }
+
+func (r *PerconaServer) Import(ctx context.Context, data *schema.ResourceData, c cloud.Cloud) error {
+	resourceID := data.Id()
+	err := c.Configure(ctx, resourceID, data)
+	if err != nil {
+		return errors.Wrap(err, "can't configure cloud")
+	}
+	// The automation user is set up by the first apply, which is planned as automation_cidr is empty
+	return newManager(c, resourceID, data).inspect(ctx, data)
+}
+
+// setupAutomationUser creates the automation user in the cluster, which was created before it was introduced or imported.
+// Its password is generated if it's not set.
+func setupAutomationUser(ctx context.Context, manager *manager, data *schema.ResourceData) error {
+	if err := resource.GeneratePasswords(data, resource.SchemaKeyAutomationPassword); err != nil {
+		return errors.Wrap(err, "can't generate password")
+	}
+	manager.automationPass = data.Get(resource.SchemaKeyAutomationPassword).(string)
+	if err := manager.setupAutomationUser(ctx); err != nil {
+		return err
+	}
+	return data.Set(resource.SchemaKeyAutomationCIDR, manager.automationCIDR)
+}
+
 func (r *PerconaServer) Delete(ctx context.Context, data *schema.ResourceData, c cloud.Cloud) diag.Diagnostics {
 	resourceID := data.Id()
 	if resourceID == "" {
-		return diag.Errorf("empty resource id")
+		return diag.FromErr(errors.New("empty resource id"))
 	}
 
 	err := c.Configure(ctx, resourceID, data)
@@ -0,0 +1,235 @@
+package ps
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+
+	"terraform-percona/internal/cloud"
+	"terraform-percona/internal/cloud/fake"
+	fakedb "terraform-percona/internal/db/mysql/fake"
+	"terraform-percona/internal/resource"
+	"terraform-percona/internal/resource/ps/cmd"
+)
+
+// testCluster is an async cluster of the source and a replica, which are served by the fake cloud and database.
+type testCluster struct {
+	cloud     *fake.Cloud
+	db        *fakedb.Server
+	instances []cloud.Instance
+	state     *terraform.InstanceState
+}
+
+func testConfig(port int) map[string]interface{} {
+	return map[string]interface{}{
+		resource.SchemaKeyInstanceType:       "t3.micro",
+		resource.SchemaKeyKeyPairName:        "key",
+		resource.SchemaKeyRootPassword:       "rootPassword",
+		resource.SchemaKeyAutomationPassword: "automationPassword",
+		resource.SchemaKeyAutomationCIDR:     "203.0.113.1/32",
+		schemaKeyReplicaPassword:             "replicaPassword",
+		resource.SchemaKeyVersion:            "8.0.33",
+		resource.SchemaKeyClusterSize:        2,
+		resource.SchemaKeyPort:               port,
+		resource.SchemaKeyMySQLDOptions:      map[string]interface{}{"max_connections": "200"},
+	}
+}
+
+func newTestCluster(t *testing.T, c *fake.Cloud) *testCluster {
+	t.Helper()
+	instances, err := c.CreateInstances(context.Background(), "test", 2, map[string]string{
+		resource.LabelKeyInstanceType: resource.LabelValueInstanceTypeMySQL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := fakedb.New(instances[0].PublicIpAddress, instances[1].PublicIpAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(db.Close)
+	db.OnHostQuery(instances[1].PublicIpAddress, "SHOW REPLICA STATUS",
+		[]string{"Replica_IO_Running", "Replica_SQL_Running", "Last_IO_Errno", "Last_SQL_Errno"},
+		[]string{"Yes", "Yes", "0", "0"})
+
+	data := schema.TestResourceDataRaw(t, new(PerconaServer).Schema(), testConfig(db.Port()))
+	if err := resource.GenerateCA(data, "test"); err != nil {
+		t.Fatal(err)
+	}
+	db.TLS(data.Get(resource.SchemaKeyCACertificate).(string), data.Get(resource.SchemaKeyCAPrivateKey).(string))
+	data.SetId("test")
+	return &testCluster{cloud: c, db: db, instances: instances, state: data.State()}
+}
+
+// update applies the changes of the config through the terraform resource and returns the new state.
+func (tc *testCluster) update(t *testing.T, changes map[string]interface{}) (*terraform.InstanceState, diag.Diagnostics) {
+	t.Helper()
+	r := resource.ResourcesMap(new(PerconaServer))["percona_ps"]
+	config := testConfig(tc.db.Port())
+	for k, v := range changes {
+		config[k] = v
+	}
+	diff, err := r.Diff(context.Background(), tc.state, terraform.NewResourceConfigRaw(config), tc.cloud)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r.Apply(context.Background(), tc.state, diff, tc.cloud)
+}
+
+// TestUpdate applies each change of the config, failed changes are kept in the state with the previous value,
+// so that they are retried on the next apply.
+func TestUpdate(t *testing.T) {
+	tests := []struct {
+		name    string
+		changes map[string]interface{}
+		// key is the attribute which is checked in the new state
+		key       string
+		value     string
+		failQuery string
+		failCmd   string
+		wantErr   string
+		// check returns the unexpected effect of the update on the cluster
+		check func(tc *testCluster) string
+	}{
+		{
+			name:    "passwords",
+			changes: map[string]interface{}{resource.SchemaKeyRootPassword: "newRootPassword"},
+			key:     resource.SchemaKeyRootPassword,
+			value:   "newRootPassword",
+			check: func(tc *testCluster) string {
+				queries := tc.db.HostQueries(tc.instances[0].PublicIpAddress)
+				return inOrder(queries, "ALTER USER IF EXISTS 'root'@'localhost'")
+			},
+		},
+		{
+			name:      "passwords failure",
+			changes:   map[string]interface{}{resource.SchemaKeyRootPassword: "newRootPassword"},
+			key:       resource.SchemaKeyRootPassword,
+			value:     "rootPassword",
+			failQuery: "ALTER USER IF EXISTS 'root'",
+			wantErr:   "can't change ps cluster passwords",
+		},
+		{
+			name:    "upgrade",
+			changes: map[string]interface{}{resource.SchemaKeyVersion: "8.0.33"},
+			key:     resource.SchemaKeyVersion,
+			value:   "8.0.33",
+			check: func(tc *testCluster) string {
+				// The replica is upgraded before the source
+				var upgraded []string
+				for _, call := range tc.cloud.Calls() {
+					if call.Arg == cmd.UpgradePerconaServer("8.0.33-25-1.focal", false) {
+						upgraded = append(upgraded, call.Instance.PrivateIpAddress)
+					}
+				}
+				if want := tc.instances[1].PrivateIpAddress + "," + tc.instances[0].PrivateIpAddress; strings.Join(upgraded, ",") != want {
+					return "instances are upgraded in order " + strings.Join(upgraded, ",") + ", want " + want
+				}
+				return ""
+			},
+		},
+		{
+			name:    "upgrade failure",
+			changes: map[string]interface{}{resource.SchemaKeyVersion: "8.0.33"},
+			key:     resource.SchemaKeyVersion,
+			value:   "8.0.32",
+			failCmd: "percona-server-server=",
+			wantErr: "can't upgrade ps cluster",
+			check: func(tc *testCluster) string {
+				// Replicas are upgraded first, the source isn't touched after the failure
+				for _, command := range tc.cloud.Commands(tc.instances[0]) {
+					if strings.Contains(command, "percona-server-server=") {
+						return "source is upgraded after the failure of the replica"
+					}
+				}
+				return ""
+			},
+		},
+		{
+			name:    "options",
+			changes: map[string]interface{}{resource.SchemaKeyMySQLDOptions: map[string]interface{}{"max_connections": "300"}},
+			key:     resource.SchemaKeyMySQLDOptions + ".max_connections",
+			value:   "300",
+			check: func(tc *testCluster) string {
+				for _, instance := range tc.instances {
+					if missing := inOrder(tc.db.HostQueries(instance.PublicIpAddress), "max_connections"); missing != "" {
+						return "max_connections isn't set on instance " + instance.PrivateIpAddress
+					}
+				}
+				return ""
+			},
+		},
+		{
+			name:      "options failure",
+			changes:   map[string]interface{}{resource.SchemaKeyMySQLDOptions: map[string]interface{}{"max_connections": "300"}},
+			key:       resource.SchemaKeyMySQLDOptions + ".max_connections",
+			value:     "200",
+			failQuery: "max_connections",
+			wantErr:   "can't apply ps mysqld options",
+		},
+		{
+			name:    "resize",
+			changes: map[string]interface{}{resource.SchemaKeyClusterSize: 1},
+			key:     resource.SchemaKeyClusterSize,
+			value:   "1",
+			check: func(tc *testCluster) string {
+				if instances, _ := tc.cloud.ListInstances(context.Background(), "test", nil); len(instances) != 1 || instances[0] != tc.instances[0] {
+					return "only the source should remain"
+				}
+				return ""
+			},
+		},
+		{
+			name:      "resize failure",
+			changes:   map[string]interface{}{resource.SchemaKeyClusterSize: 1},
+			key:       resource.SchemaKeyClusterSize,
+			value:     "2",
+			failQuery: "STOP REPLICA",
+			wantErr:   "can't resize ps cluster",
+			check: func(tc *testCluster) string {
+				if instances, _ := tc.cloud.ListInstances(context.Background(), "test", nil); len(instances) != 2 {
+					return "replica is deleted although it isn't detached"
+				}
+				return ""
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.New()
+			c.OnCommand(cmd.InstalledVersion(), "8.0.32-24-1.focal")
+			if tt.failCmd != "" {
+				c.FailOn(tt.failCmd, errInjected)
+			}
+			c.OnCommand("apt-cache show", "8.0.33-25-1.focal\n8.0.32-24-1.focal")
+			tc := newTestCluster(t, c)
+			if tt.failQuery != "" {
+				tc.db.FailOn(tt.failQuery, 1045, "Access denied")
+			}
+			if tt.changes[resource.SchemaKeyVersion] != nil {
+				// The version of the state is the installed one
+				tc.state.Attributes[resource.SchemaKeyVersion] = "8.0.32"
+			}
+
+			state, diags := tc.update(t, tt.changes)
+			if tt.wantErr == "" && diags.HasError() {
+				t.Fatalf("update diagnostics = %v", diags)
+			}
+			if tt.wantErr != "" && (!diags.HasError() || !strings.Contains(diags[0].Summary, tt.wantErr)) {
+				t.Fatalf("update diagnostics = %v, want error containing %q", diags, tt.wantErr)
+			}
+			if got := state.Attributes[tt.key]; got != tt.value {
+				t.Errorf("%s = %q in the new state, want %q", tt.key, got, tt.value)
+			}
+			if tt.check != nil {
+				if msg := tt.check(tc); msg != "" {
+					t.Error(msg)
+				}
+			}
+		})
+	}
+}
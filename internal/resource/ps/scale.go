@@ -0,0 +1,441 @@
+package ps
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+
+	"terraform-percona/internal/cloud"
+	internaldb "terraform-percona/internal/db"
+	"terraform-percona/internal/resource"
+	"terraform-percona/internal/resource/ps/cmd"
+)
+
+// resize adds or removes instances until the cluster has m.size members.
+func (m *manager) resize(ctx context.Context) error {
+	if m.size < 1 {
+		return errors.Errorf("%s should be 1 or more", resource.SchemaKeyClusterSize)
+	}
+	states, err := m.instanceStates(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get instance states")
+	}
+	switch {
+	case m.size > len(states):
+		return m.scaleOut(ctx, states, m.size-len(states))
+	case m.size < len(states):
+		return m.scaleIn(ctx, states, len(states)-m.size)
+	}
+	return nil
+}
+
+func (m *manager) scaleOut(ctx context.Context, states []instanceState, count int) error {
+	source, ok := sourceInstance(states)
+	if !ok {
+		return errors.New("failed to find online source instance")
+	}
+	version, err := m.runCommand(ctx, source.Instance, cmd.InstalledVersion())
+	if err != nil {
+		return errors.Wrap(err, "failed to get installed version")
+	}
+	// New instances should run exactly the same version as the rest of the cluster
+	m.version = strings.TrimSpace(version)
+
+	serverID, err := m.maxServerID(ctx, states)
+	if err != nil {
+		return errors.Wrap(err, "failed to get server ids")
+	}
+	if m.replicationType == replicationTypeAsync {
+		if err := m.checkAsyncSource(ctx, source.Instance); err != nil {
+			return err
+		}
+	}
+
+	if err := m.cloud.CreateInfrastructure(ctx, m.resourceID); err != nil {
+		return errors.Wrap(err, "can't create cloud infrastructure")
+	}
+	tflog.Info(ctx, "Creating instances", map[string]interface{}{
+		"count": count,
+	})
+	instances, err := m.cloud.CreateInstances(ctx, m.resourceID, int64(count), map[string]string{
+		resource.LabelKeyInstanceType: resource.LabelValueInstanceTypeMySQL,
+	})
+	if err != nil {
+		return errors.Wrap(err, "create instances")
+	}
+	tflog.Info(ctx, "Configuring instances")
+	if err := m.installInstances(ctx, instances); err != nil {
+		return errors.Wrap(err, "configure instances")
+	}
+
+	tflog.Info(ctx, "Joining instances to the cluster")
+	switch m.replicationType {
+	case replicationTypeAsync:
+		err = m.joinAsyncInstances(ctx, source.Instance, instances, serverID+1)
+	case replicationTypeGR:
+		err = m.joinGRInstances(ctx, onlineInstances(states), instances, serverID+1)
+	default:
+		err = errors.Errorf("unknown replication type: %s", m.replicationType)
+	}
+	if err != nil {
+		return errors.Wrap(err, "join instances")
+	}
+	if err := m.addServicesToPMM(ctx, instances); err != nil {
+		return err
+	}
+
+	if m.orchestratorSize > 0 {
+		orcInstances, err := m.cloud.ListInstances(ctx, m.resourceID, map[string]string{
+			resource.LabelKeyInstanceType: resource.LabelValueInstanceTypeOrchestrator,
+		})
+		if err != nil {
+			return errors.Wrap(err, "failed to list orchestrator instances")
+		}
+		m.discoverInOrchestrator(ctx, orchestratorAPI(orcInstances), instances)
+	}
+	return nil
+}
+
+func (m *manager) joinAsyncInstances(ctx context.Context, source cloud.Instance, instances []cloud.Instance, firstServerID int) error {
+	if err := m.enableSourceBinlog(ctx, source); err != nil {
+		return errors.Wrap(err, "failed to configure source instance")
+	}
+	g, gCtx := errgroup.WithContext(ctx)
+	for i, instance := range instances {
+		instance := instance
+		serverID := firstServerID + i
+		g.Go(func() error {
+			cfg := m.instanceConfig(gCtx, instance, nil, serverID, "")
+			if err := m.editDefaultCfg(gCtx, instance, "mysqld", cfg); err != nil {
+				return errors.Wrap(err, "edit default cfg for replication")
+			}
+			if _, err := m.runCommand(gCtx, instance, cmd.Restart()); err != nil {
+				return errors.Wrap(err, "restart mysql")
+			}
+			if err := m.startReplica(gCtx, instance, source.PrivateIpAddress); err != nil {
+				return errors.Wrapf(err, "failed to start replica %s", instance.PrivateIpAddress)
+			}
+			return nil
+		})
+	}
+	return g.Wait()
+}
+
+// checkAsyncSource checks that the binary log of the source contains all the data, as new replicas start empty and get it only from the binary log.
+// Group replication members don't need it, they are seeded by the distributed recovery.
+func (m *manager) checkAsyncSource(ctx context.Context, source cloud.Instance) error {
+	db, err := m.newAdminClient(source)
+	if err != nil {
+		return errors.Wrap(err, "new client")
+	}
+	defer db.Close()
+	gtidMode, err := db.Variable(ctx, "gtid_mode")
+	if err != nil {
+		return errors.Wrap(err, "get gtid_mode")
+	}
+	if gtidMode != "ON" {
+		// Single-instance clusters are created without GTIDs, which are enabled by enableSourceBinlog
+		exists, err := db.UserTablesExist(ctx)
+		if err != nil {
+			return errors.Wrap(err, "failed to check tables of source instance")
+		}
+		if exists {
+			return errors.New("source instance has data written before GTIDs were enabled, new replicas can't get it from the binary log, they should be seeded from a backup")
+		}
+		return nil
+	}
+	purged, err := db.Variable(ctx, "gtid_purged")
+	if err != nil {
+		return errors.Wrap(err, "get gtid_purged")
+	}
+	if purged != "" {
+		return errors.Errorf("binary logs of source instance are purged (gtid_purged=%s), new replicas can't get the purged transactions, they should be seeded from a backup", purged)
+	}
+	return nil
+}
+
+// enableSourceBinlog applies the replication config to the source of a single-node cluster,
+// which was created without it.
+func (m *manager) enableSourceBinlog(ctx context.Context, source cloud.Instance) error {
+	db, err := m.newAdminClient(source)
+	if err != nil {
+		return errors.Wrap(err, "new client")
+	}
+	defer db.Close()
+	gtidMode, err := db.Variable(ctx, "gtid_mode")
+	if err != nil {
+		return errors.Wrap(err, "get gtid_mode")
+	}
+	if gtidMode == "ON" {
+		return nil
+	}
+	if err := db.CreateReplicaUser(ctx, m.replicaPass, false); err != nil {
+		return errors.Wrap(err, "create replica user")
+	}
+	db.Close()
+	if err := m.editDefaultCfg(ctx, source, "mysqld", m.instanceConfig(ctx, source, nil, 1, "")); err != nil {
+		return errors.Wrap(err, "edit default cfg for replication")
+	}
+	if _, err := m.runCommand(ctx, source, cmd.Restart()); err != nil {
+		return errors.Wrap(err, "restart mysql")
+	}
+	return nil
+}
+
+func (m *manager) joinGRInstances(ctx context.Context, members []cloud.Instance, instances []cloud.Instance, firstServerID int) error {
+	db, err := m.newAdminClient(members[0])
+	if err != nil {
+		return errors.Wrap(err, "new client")
+	}
+	groupName, err := db.Variable(ctx, "group_replication_group_name")
+	db.Close()
+	if err != nil {
+		return errors.Wrap(err, "get group name")
+	}
+
+	allInstances := append(append([]cloud.Instance{}, members...), instances...)
+	// Existing members should accept connections from the new ones before they join
+	if err := m.updateGRMembers(ctx, members, allInstances); err != nil {
+		return err
+	}
+
+	// Members join the group one by one, as group replication doesn't allow to join several instances concurrently
+	for i, instance := range instances {
+		serverID := firstServerID + i
+		err := func() error {
+			cfg := m.instanceConfig(ctx, instance, allInstances, serverID, groupName)
+			if err := m.editDefaultCfg(ctx, instance, "mysqld", cfg); err != nil {
+				return errors.Wrap(err, "edit default cfg for replication")
+			}
+			if _, err := m.runCommand(ctx, instance, cmd.Restart()); err != nil {
+				return errors.Wrap(err, "restart mysql")
+			}
+			db, err := m.newAdminClient(instance)
+			if err != nil {
+				return errors.Wrap(err, "new client")
+			}
+			defer db.Close()
+			if err := db.CreateReplicaUser(ctx, m.replicaPass, true); err != nil {
+				return errors.Wrap(err, "failed to create replica user")
+			}
+			if err := db.ChangeGroupReplicationSource(ctx, internaldb.UserReplica, m.replicaPass); err != nil {
+				return errors.Wrap(err, "failed to change group replication source")
+			}
+			if err := db.StartGroupReplication(ctx); err != nil {
+				return errors.Wrap(err, "start group replication")
+			}
+			return nil
+		}()
+		if err != nil {
+			return errors.Wrapf(err, "failed to join instance %s to the group", instance.PrivateIpAddress)
+		}
+	}
+	return nil
+}
+
+// updateGRMembers sets group seeds and allowlist of the running members both in the config file and at runtime.
+func (m *manager) updateGRMembers(ctx context.Context, members []cloud.Instance, allInstances []cloud.Instance) error {
+	seeds, allowList := groupReplicationAddresses(allInstances)
+	for _, member := range members {
+		err := func() error {
+			if err := m.editDefaultCfg(ctx, member, "mysqld", map[string]string{
+				"group_replication_group_seeds": seeds,
+				m.allowlistVariable():           allowList,
+			}); err != nil {
+				return errors.Wrap(err, "edit default cfg for replication")
+			}
+			db, err := m.newAdminClient(member)
+			if err != nil {
+				return errors.Wrap(err, "new client")
+			}
+			defer db.Close()
+			if err := db.SetGroupReplicationIPAllowlist(ctx, allowList); err != nil {
+				return err
+			}
+			return db.SetGroupReplicationSeeds(ctx, seeds)
+		}()
+		if err != nil {
+			return errors.Wrapf(err, "failed to update group member %s", member.PrivateIpAddress)
+		}
+	}
+	return nil
+}
+
+func (m *manager) scaleIn(ctx context.Context, states []instanceState, count int) error {
+	removed := replicasToRemove(states, count)
+	if len(removed) < count {
+		return errors.Errorf("can't remove %d instances: only %d replicas are available, source instance is never removed", count, len(removed))
+	}
+	source, hasSource := sourceInstance(states)
+
+	instances := make([]cloud.Instance, 0, len(removed))
+	for _, state := range removed {
+		if err := m.detachInstance(ctx, state); err != nil {
+			return errors.Wrapf(err, "failed to detach instance %s", state.PrivateIpAddress)
+		}
+		instances = append(instances, state.Instance)
+	}
+
+	if m.orchestratorSize > 0 {
+		orcInstances, err := m.cloud.ListInstances(ctx, m.resourceID, map[string]string{
+			resource.LabelKeyInstanceType: resource.LabelValueInstanceTypeOrchestrator,
+		})
+		if err != nil {
+			return errors.Wrap(err, "failed to list orchestrator instances")
+		}
+		// orchestrator-client is installed only on the percona server instances
+		if hasSource {
+			for _, instance := range instances {
+				if _, err := m.runCommand(ctx, source.Instance, cmd.ForgetInOrchestrator(orchestratorAPI(orcInstances), instance.PrivateIpAddress, m.port)); err != nil {
+					tflog.Warn(ctx, "failed to remove instance from orchestrator", map[string]interface{}{
+						resource.LogArgInstanceIP: instance.PublicIpAddress,
+						"error":                   err,
+					})
+				}
+			}
+		}
+	}
+
+	tflog.Info(ctx, "Deleting instances", map[string]interface{}{
+		"count": len(instances),
+	})
+	if err := m.cloud.DeleteInstances(ctx, m.resourceID, instances); err != nil {
+		return errors.Wrap(err, "delete instances")
+	}
+
+	if m.replicationType == replicationTypeGR {
+		remaining := make([]instanceState, 0, len(states)-len(removed))
+		for _, state := range states {
+			if !containsInstance(instances, state.Instance) {
+				remaining = append(remaining, state)
+			}
+		}
+		all := make([]cloud.Instance, 0, len(remaining))
+		for _, state := range remaining {
+			all = append(all, state.Instance)
+		}
+		if err := m.updateGRMembers(ctx, onlineInstances(remaining), all); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// detachInstance stops replication on the instance and unregisters it from PMM.
+// Unreachable instances are deleted as is.
+func (m *manager) detachInstance(ctx context.Context, state instanceState) error {
+	if state.replicationState == replicationStateUnreachable {
+		tflog.Warn(ctx, "instance is unreachable, it will be deleted without detaching", map[string]interface{}{
+			resource.LogArgInstanceIP: state.PublicIpAddress,
+		})
+		return nil
+	}
+	if m.pmmAddress != "" {
+		if _, err := m.runCommand(ctx, state.Instance, cmd.RemoveFromPMM()); err != nil {
+			return errors.Wrap(err, "remove from pmm")
+		}
+	}
+	db, err := m.newAdminClient(state.Instance)
+	if err != nil {
+		return errors.Wrap(err, "new client")
+	}
+	defer db.Close()
+	switch m.replicationType {
+	case replicationTypeGR:
+		if state.replicationState == replicationStateOffline {
+			return nil
+		}
+		return db.StopGroupReplication(ctx)
+	default:
+		if err := db.StopReplica(ctx); err != nil {
+			return err
+		}
+		return db.ResetReplicaAll(ctx)
+	}
+}
+
+func (m *manager) maxServerID(ctx context.Context, states []instanceState) (int, error) {
+	// Server ids are assigned sequentially on creation
+	maxID := len(states)
+	for _, state := range states {
+		if state.replicationState == replicationStateUnreachable {
+			continue
+		}
+		err := func() error {
+			db, err := m.newAdminClient(state.Instance)
+			if err != nil {
+				return errors.Wrap(err, "new client")
+			}
+			defer db.Close()
+			v, err := db.Variable(ctx, "server_id")
+			if err != nil {
+				return err
+			}
+			id, err := strconv.Atoi(v)
+			if err != nil {
+				return errors.Wrap(err, "parse server_id")
+			}
+			if id > maxID {
+				maxID = id
+			}
+			return nil
+		}()
+		if err != nil {
+			return 0, errors.Wrapf(err, "failed to get server id of %s", state.PrivateIpAddress)
+		}
+	}
+	return maxID, nil
+}
+
+func sourceInstance(states []instanceState) (instanceState, bool) {
+	for _, state := range states {
+		if !state.isReplica && state.replicationState == replicationStateOnline {
+			return state, true
+		}
+	}
+	return instanceState{}, false
+}
+
+func onlineInstances(states []instanceState) []cloud.Instance {
+	instances := make([]cloud.Instance, 0, len(states))
+	for _, state := range states {
+		if state.replicationState == replicationStateOnline {
+			instances = append(instances, state.Instance)
+		}
+	}
+	return instances
+}
+
+// replicasToRemove returns up to count replicas, unhealthy ones first, then the most recently listed ones.
+func replicasToRemove(states []instanceState, count int) []instanceState {
+	removed := make([]instanceState, 0, count)
+	for _, state := range states {
+		if len(removed) == count {
+			return removed
+		}
+		// Unreachable instances can't report their role, they are treated as broken replicas
+		if (state.isReplica || state.replicationState == replicationStateUnreachable) && state.replicationState != replicationStateOnline {
+			removed = append(removed, state)
+		}
+	}
+	for i := len(states) - 1; i >= 0 && len(removed) < count; i-- {
+		state := states[i]
+		if state.isReplica && state.replicationState == replicationStateOnline {
+			removed = append(removed, state)
+		}
+	}
+	return removed
+}
+
+func containsInstance(instances []cloud.Instance, instance cloud.Instance) bool {
+	for _, i := range instances {
+		if i.PrivateIpAddress == instance.PrivateIpAddress {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,275 @@
+package ps
+
+import (
+	"context"
+	"path"
+	"strconv"
+	"strings"
+	"testing"
+
+	"terraform-percona/internal/cloud"
+	"terraform-percona/internal/cloud/fake"
+	"terraform-percona/internal/resource"
+)
+
+func privateAddresses(states []instanceState) string {
+	addresses := make([]string, 0, len(states))
+	for _, state := range states {
+		addresses = append(addresses, state.PrivateIpAddress)
+	}
+	return strings.Join(addresses, ",")
+}
+
+func TestReplicasToRemove(t *testing.T) {
+	state := func(n string, isReplica bool, replicationState string) instanceState {
+		return instanceState{Instance: cloud.Instance{PrivateIpAddress: "10.0.0." + n}, isReplica: isReplica, replicationState: replicationState}
+	}
+	tests := []struct {
+		name   string
+		states []instanceState
+		count  int
+		want   string
+	}{
+		{
+			name:   "source is never removed",
+			states: []instanceState{state("1", false, replicationStateOnline), state("2", true, replicationStateOnline), state("3", true, replicationStateOnline)},
+			count:  3,
+			want:   "10.0.0.3,10.0.0.2",
+		},
+		{
+			name:   "source listed last",
+			states: []instanceState{state("1", true, replicationStateOnline), state("2", true, replicationStateOnline), state("3", false, replicationStateOnline)},
+			count:  1,
+			want:   "10.0.0.2",
+		},
+		{
+			name:   "broken replicas first",
+			states: []instanceState{state("1", false, replicationStateOnline), state("2", true, replicationStateError), state("3", true, replicationStateOnline), state("4", true, replicationStateConnecting)},
+			count:  2,
+			want:   "10.0.0.2,10.0.0.4",
+		},
+		{
+			name:   "unreachable instances are broken replicas",
+			states: []instanceState{state("1", false, replicationStateOnline), state("2", true, replicationStateOnline), state("3", false, replicationStateUnreachable)},
+			count:  1,
+			want:   "10.0.0.3",
+		},
+		{
+			name:   "offline group members",
+			states: []instanceState{state("1", false, replicationStateOnline), state("2", true, replicationStateOffline), state("3", true, replicationStateOnline)},
+			count:  2,
+			want:   "10.0.0.2,10.0.0.3",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := privateAddresses(replicasToRemove(tt.states, tt.count)); got != tt.want {
+				t.Errorf("replicasToRemove() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestScaleIn detaches and deletes the replicas, the remaining group members stop accepting the removed ones.
+func TestScaleIn(t *testing.T) {
+	tests := []struct {
+		name            string
+		replicationType string
+		count           int
+		wantErr         string
+		wantRemoved     int
+		wantDetach      []string
+	}{
+		{"async", replicationTypeAsync, 2, "", 2, []string{"STOP REPLICA", "RESET REPLICA ALL"}},
+		{"group replication", replicationTypeGR, 1, "", 1, []string{"STOP GROUP_REPLICATION"}},
+		{"source", replicationTypeAsync, 3, "only 2 replicas are available", 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.New()
+			m := newTestManager(t, c)
+			m.replicationType = tt.replicationType
+			instances, err := c.CreateInstances(context.Background(), "test", 3, map[string]string{
+				resource.LabelKeyInstanceType: resource.LabelValueInstanceTypeMySQL,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			db := newTestServer(t, m, instances)
+			states := []instanceState{
+				{Instance: instances[0], replicationState: replicationStateOnline},
+				{Instance: instances[1], isReplica: true, replicationState: replicationStateOnline},
+				{Instance: instances[2], isReplica: true, replicationState: replicationStateOnline},
+			}
+
+			err = m.scaleIn(context.Background(), states, tt.count)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("scaleIn() error = %v, want error containing %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			remaining, _ := c.ListInstances(context.Background(), "test", nil)
+			if len(remaining) != len(instances)-tt.wantRemoved {
+				t.Fatalf("%d instances remain, want %d", len(remaining), len(instances)-tt.wantRemoved)
+			}
+			if remaining[0] != instances[0] {
+				t.Errorf("source instance %s is deleted", instances[0].PrivateIpAddress)
+			}
+			if queries := db.HostQueries(instances[0].PublicIpAddress); inOrder(queries, "STOP") == "" {
+				t.Errorf("replication is stopped on the source, queries: %q", queries)
+			}
+			for _, instance := range instances[len(remaining):] {
+				queries := db.HostQueries(instance.PublicIpAddress)
+				if missing := inOrder(queries, tt.wantDetach...); missing != "" {
+					t.Errorf("query %q is not run on removed instance %s, queries: %q", missing, instance.PrivateIpAddress, queries)
+				}
+			}
+
+			if tt.replicationType != replicationTypeGR {
+				return
+			}
+			seeds, allowList := groupReplicationAddresses(remaining)
+			for _, member := range remaining {
+				queries := db.HostQueries(member.PublicIpAddress)
+				if missing := inOrder(queries, "group_replication_ip_allowlist='"+allowList+"'", "group_replication_group_seeds='"+seeds+"'"); missing != "" {
+					t.Errorf("query %q is not run on member %s, queries: %q", missing, member.PrivateIpAddress, queries)
+				}
+				cfg, _ := c.File(member, path.Join("/opt/percona", path.Base(defaultMysqlConfigPath)))
+				if fields := strings.Join(strings.Fields(cfg), " "); !strings.Contains(fields, "group_replication_group_seeds = "+seeds) {
+					t.Errorf("config of member %s doesn't contain the seeds %s:\n%s", member.PrivateIpAddress, seeds, cfg)
+				}
+			}
+		})
+	}
+}
+
+// TestScaleOutAsyncSource refuses to add replicas if they can't get all the data of the source from its binary log.
+func TestScaleOutAsyncSource(t *testing.T) {
+	tests := []struct {
+		name       string
+		gtidMode   string
+		gtidPurged string
+		tables     string
+		wantErr    string
+	}{
+		{name: "binlog", gtidMode: "ON", tables: "1"},
+		{name: "purged binlog", gtidMode: "ON", gtidPurged: "3E11FA47-71CA-11E1-9E33-C80AA9429562:1-5", tables: "1", wantErr: "binary logs of source instance are purged"},
+		{name: "no gtids", gtidMode: "OFF", tables: "0"},
+		{name: "data without gtids", gtidMode: "OFF", tables: "1", wantErr: "data written before GTIDs were enabled"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.New()
+			m := newTestManager(t, c)
+			m.size = 2
+			instances, err := c.CreateInstances(context.Background(), "test", 1, map[string]string{
+				resource.LabelKeyInstanceType: resource.LabelValueInstanceTypeMySQL,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			db := newTestServer(t, m, instances)
+			variables := []string{"Variable_name", "Value"}
+			db.OnQuery("'server_id'", variables, []string{"server_id", "1"})
+			db.OnQuery("'gtid_mode'", variables, []string{"gtid_mode", tt.gtidMode})
+			db.OnQuery("'gtid_purged'", variables, []string{"gtid_purged", tt.gtidPurged})
+			db.OnQuery("information_schema.TABLES", []string{"COUNT(*)"}, []string{tt.tables})
+
+			// The scale-out of allowed cases fails later, as the new instance has no database server
+			err = m.resize(context.Background())
+			all, _ := c.ListInstances(context.Background(), "test", nil)
+			if tt.wantErr == "" {
+				if len(all) != 2 {
+					t.Errorf("%d instances exist after the scale-out, want 2, error: %v", len(all), err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("resize() error = %v, want error containing %q", err, tt.wantErr)
+			}
+			if len(all) != 1 {
+				t.Errorf("%d instances exist after the refused scale-out, want 1", len(all))
+			}
+		})
+	}
+}
+
+// TestJoinGRInstances updates the seeds and the allowlist of the members before the new instances join the group.
+func TestJoinGRInstances(t *testing.T) {
+	tests := []struct {
+		name    string
+		failOn  string
+		wantErr string
+	}{
+		{name: "join"},
+		{name: "member update failure", failOn: "SET GLOBAL group_replication_group_seeds", wantErr: "failed to update group member"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.New()
+			m := newTestManager(t, c)
+			m.replicationType = replicationTypeGR
+			instances, err := c.CreateInstances(context.Background(), "test", 4, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			db := newTestServer(t, m, instances)
+			if tt.failOn != "" {
+				db.FailOn(tt.failOn, 1231, "Variable can't be set")
+			}
+			db.OnQuery("'group_replication_group_name'", []string{"Variable_name", "Value"},
+				[]string{"group_replication_group_name", "aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa"})
+			members, joined := instances[:2], instances[2:]
+
+			err = m.joinGRInstances(context.Background(), members, joined, 3)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("joinGRInstances() error = %v, want error containing %q", err, tt.wantErr)
+				}
+				for _, instance := range joined {
+					if queries := db.HostQueries(instance.PublicIpAddress); len(queries) > 0 {
+						t.Errorf("instance %s joins the group after the failed member update, queries: %q", instance.PrivateIpAddress, queries)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			seeds, allowList := groupReplicationAddresses(instances)
+			updated := make(map[string]bool)
+			for _, query := range db.Queries() {
+				switch {
+				case strings.Contains(query.SQL, "group_replication_group_seeds='"+seeds+"'"):
+					updated[query.Host] = true
+				case query.SQL == "START GROUP_REPLICATION" && len(updated) < len(members):
+					t.Errorf("instance %s joins the group before the members accept it", query.Host)
+				}
+			}
+			for _, member := range members {
+				if !updated[member.PublicIpAddress] {
+					t.Errorf("seeds of member %s aren't updated to %s", member.PrivateIpAddress, seeds)
+				}
+				if queries := db.HostQueries(member.PublicIpAddress); inOrder(queries, "group_replication_ip_allowlist='"+allowList+"'") != "" {
+					t.Errorf("allowlist of member %s isn't updated to %s, queries: %q", member.PrivateIpAddress, allowList, queries)
+				}
+			}
+			for i, instance := range joined {
+				if missing := inOrder(db.HostQueries(instance.PublicIpAddress), "START GROUP_REPLICATION"); missing != "" {
+					t.Errorf("instance %s doesn't join the group", instance.PrivateIpAddress)
+				}
+				cfg, _ := c.File(instance, path.Join("/opt/percona", path.Base(defaultMysqlConfigPath)))
+				fields := strings.Join(strings.Fields(cfg), " ")
+				for _, want := range []string{"server_id = " + strconv.Itoa(3+i), "group_replication_group_seeds = " + seeds} {
+					if !strings.Contains(fields, want) {
+						t.Errorf("config of instance %s doesn't contain %q:\n%s", instance.PrivateIpAddress, want, cfg)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package ps
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/pkg/errors"
+
+	"terraform-percona/internal/cloud"
+	"terraform-percona/internal/db/mysql"
+	"terraform-percona/internal/resource"
+)
+
+const (
+	schemaKeyInstancesIsReplica        = "is_replica"
+	schemaKeyInstancesReplicationState = "replication_state"
+)
+
+const (
+	replicationStateOnline      = "ONLINE"
+	replicationStateConnecting  = "CONNECTING"
+	replicationStateOffline     = "OFFLINE"
+	replicationStateError       = "ERROR"
+	replicationStateUnreachable = "UNREACHABLE"
+)
+
+const instanceStateTimeout = time.Second * 30
+
+type instanceState struct {
+	cloud.Instance
+
+	isReplica        bool
+	replicationState string
+}
+
+func (m *manager) instanceStates(ctx context.Context) ([]instanceState, error) {
+	instances, err := m.cloud.ListInstances(ctx, m.resourceID, map[string]string{
+		resource.LabelKeyInstanceType: resource.LabelValueInstanceTypeMySQL,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list instances")
+	}
+	states := make([]instanceState, len(instances))
+	wg := new(sync.WaitGroup)
+	for i, instance := range instances {
+		i, instance := i, instance
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			states[i] = m.instanceState(ctx, instance)
+		}()
+	}
+	wg.Wait()
+	return states, nil
+}
+
+func (m *manager) instanceState(ctx context.Context, instance cloud.Instance) instanceState {
+	state := instanceState{
+		Instance:         instance,
+		replicationState: replicationStateUnreachable,
+	}
+	if instance.PublicIpAddress == "" {
+		return state
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, instanceStateTimeout)
+	defer cancel()
+
+	db, err := m.connect(ctx, instance)
+	if err != nil {
+		tflog.Warn(ctx, "failed to establish sql connection", map[string]interface{}{
+			resource.LogArgInstanceIP: instance.PublicIpAddress,
+			"error":                   err,
+		})
+		return state
+	}
+	defer db.Close()
+
+	switch m.replicationType {
+	case replicationTypeGR:
+		memberState, role, err := db.GroupReplicationMember(ctx)
+		if err != nil {
+			tflog.Warn(ctx, "failed to get group replication member state", map[string]interface{}{
+				resource.LogArgInstanceIP: instance.PublicIpAddress,
+				"error":                   err,
+			})
+			return state
+		}
+		state.isReplica = role != mysql.GroupReplicationMemberRolePrimary
+		state.replicationState = memberState
+	default:
+		status, err := db.ReplicaStatus(ctx)
+		if err != nil {
+			tflog.Warn(ctx, "failed to get replica status", map[string]interface{}{
+				resource.LogArgInstanceIP: instance.PublicIpAddress,
+				"error":                   err,
+			})
+			return state
+		}
+		state.isReplica = status != nil
+		state.replicationState = asyncReplicationState(status)
+	}
+	return state
+}
+
+func asyncReplicationState(replicaStatus map[string]string) string {
+	if replicaStatus == nil {
+		return replicationStateOnline
+	}
+	ioRunning := replicaStatus["Replica_IO_Running"]
+	sqlRunning := replicaStatus["Replica_SQL_Running"]
+	switch {
+	case ioRunning == "Yes" && sqlRunning == "Yes":
+		return replicationStateOnline
+	case replicaStatus["Last_IO_Errno"] != "0" || replicaStatus["Last_SQL_Errno"] != "0":
+		return replicationStateError
+	case ioRunning == "Connecting":
+		return replicationStateConnecting
+	}
+	return replicationStateOffline
+}
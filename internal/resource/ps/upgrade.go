@@ -0,0 +1,209 @@
+package ps
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/pkg/errors"
+
+	"terraform-percona/internal/cloud"
+	"terraform-percona/internal/resource"
+	"terraform-percona/internal/resource/ps/cmd"
+	"terraform-percona/internal/utils"
+)
+
+const (
+	stateWaitTimeout  = time.Minute * 30
+	stateWaitInterval = time.Second * 10
+)
+
+// upgrade performs a rolling upgrade of the cluster to m.version: replicas are upgraded first, the source is the last one.
+// Each instance should be back online with the target version before the next one is upgraded.
+// Major upgrades are allowed only if the upgrade checker reports no errors on every instance,
+// the repository of the installed version is enabled again if the upgrade is refused.
+func (m *manager) upgrade(ctx context.Context) error {
+	// Unset version means the version installed on creation
+	if m.version == "" {
+		return nil
+	}
+	if err := resource.CheckReleaseSeries(m.series, m.version); err != nil {
+		return err
+	}
+	states, err := m.instanceStates(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get instance states")
+	}
+	if len(states) == 0 {
+		return errors.New("no instances found")
+	}
+	for _, state := range states {
+		if state.replicationState != replicationStateOnline {
+			return errors.Errorf("instance %s is not online: %s, all instances should be online before upgrade", state.PrivateIpAddress, state.replicationState)
+		}
+	}
+
+	installed := make([]string, len(states))
+	for i, state := range states {
+		if installed[i], err = m.installedVersion(ctx, state.Instance); err != nil {
+			return err
+		}
+	}
+	repository := m.repository()
+	version, err := m.checkUpgrade(ctx, states, installed, repository)
+	if err != nil {
+		// Nothing is upgraded yet, so that the instances keep the packages of their installed series
+		m.restoreRepositories(ctx, states, installed, repository)
+		return err
+	}
+
+	for _, state := range rollingOrder(states) {
+		if err := m.upgradeInstance(ctx, state.Instance, version, repository); err != nil {
+			return errors.Wrapf(err, "failed to upgrade instance %s", state.PrivateIpAddress)
+		}
+	}
+	return nil
+}
+
+// checkUpgrade returns the full target version and checks that every instance can be upgraded to it.
+// The repository of the target series is enabled on the first instance to list the versions and on the instances which are
+// checked for a major upgrade, as the upgrade checker of the target version is installed from it.
+func (m *manager) checkUpgrade(ctx context.Context, states []instanceState, installed []string, repository string) (string, error) {
+	if _, err := m.runCommand(ctx, states[0].Instance, cmd.UpdateRepository(repository)); err != nil {
+		return "", errors.Wrap(err, "update repository")
+	}
+	availableVersions, err := m.versionList(ctx, states[0].Instance)
+	if err != nil {
+		return "", errors.Wrap(err, "retrieve versions")
+	}
+	version := utils.SelectVersion(availableVersions, m.version)
+	if version == "" {
+		return "", errors.Errorf("version not found, available versions: %v", availableVersions)
+	}
+	for i, state := range states {
+		if err := resource.CheckUpgradePath(installed[i], version); err != nil {
+			return "", errors.Wrapf(err, "instance %s", state.PrivateIpAddress)
+		}
+		if !resource.IsMajorUpgrade(installed[i], version) {
+			continue
+		}
+		if err := m.checkForServerUpgrade(ctx, state.Instance, version, repository); err != nil {
+			return "", errors.Wrapf(err, "upgrade check failed on instance %s", state.PrivateIpAddress)
+		}
+	}
+	return version, nil
+}
+
+// restoreRepositories enables the repository of the installed version on the instances again after the upgrade is refused.
+// Failures are only logged, as the error of the upgrade is returned.
+func (m *manager) restoreRepositories(ctx context.Context, states []instanceState, installed []string, repository string) {
+	for i, state := range states {
+		original := resource.ReleaseRepository("ps", resource.ReleaseSeries(installed[i]), installed[i])
+		if original == repository {
+			continue
+		}
+		if _, err := m.runCommand(ctx, state.Instance, cmd.UpdateRepository(original)); err != nil {
+			tflog.Warn(ctx, "failed to restore the repository", map[string]interface{}{
+				resource.LogArgInstanceIP: state.PublicIpAddress,
+				"repository":              original,
+				"error":                   err,
+			})
+		}
+	}
+}
+
+func (m *manager) installedVersion(ctx context.Context, instance cloud.Instance) (string, error) {
+	out, err := m.runCommand(ctx, instance, cmd.InstalledVersion())
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get installed version")
+	}
+	version := strings.TrimSpace(out)
+	if version == "" {
+		return "", errors.Errorf("percona server is not installed on instance %s", instance.PrivateIpAddress)
+	}
+	return version, nil
+}
+
+func (m *manager) checkForServerUpgrade(ctx context.Context, instance cloud.Instance, version, repository string) error {
+	tflog.Info(ctx, "Checking for server upgrade", map[string]interface{}{
+		resource.LogArgVersion:    version,
+		resource.LogArgInstanceIP: instance.PublicIpAddress,
+	})
+	if _, err := m.runCommand(ctx, instance, cmd.UpdateRepository(repository)); err != nil {
+		return errors.Wrap(err, "update repository")
+	}
+	if _, err := m.runCommand(ctx, instance, cmd.InstallMySQLShell()); err != nil {
+		return errors.Wrap(err, "install mysql shell")
+	}
+	out, err := m.runCommand(ctx, instance, cmd.CheckForServerUpgrade(m.pass, strings.Split(version, "-")[0]))
+	if err != nil {
+		return errors.Wrap(err, "run upgrade checker")
+	}
+	problems, err := resource.UpgradeCheckErrors(out)
+	if err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return errors.Errorf("blocking issues found:\n%s", strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+func (m *manager) upgradeInstance(ctx context.Context, instance cloud.Instance, version, repository string) error {
+	installed, err := m.installedVersion(ctx, instance)
+	if err != nil {
+		return err
+	}
+	if installed == version {
+		return nil
+	}
+
+	tflog.Info(ctx, "Upgrading Percona Server", map[string]interface{}{
+		resource.LogArgVersion:    version,
+		resource.LogArgInstanceIP: instance.PublicIpAddress,
+	})
+	if resource.IsMajorUpgrade(installed, version) {
+		if err := m.editDefaultCfg(ctx, instance, "mysqld", m.versionConfig(version)); err != nil {
+			return errors.Wrap(err, "edit default cfg for upgrade")
+		}
+	}
+	if _, err := m.runCommand(ctx, instance, cmd.UpdateRepository(repository)); err != nil {
+		return errors.Wrap(err, "update repository")
+	}
+	if _, err := m.runCommand(ctx, instance, cmd.UpgradePerconaServer(version, m.installMyRocks)); err != nil {
+		return errors.Wrap(err, "upgrade percona server")
+	}
+
+	db, err := m.newAdminClient(instance)
+	if err != nil {
+		return errors.Wrap(err, "new client")
+	}
+	defer db.Close()
+	running, err := db.Version(ctx)
+	if err != nil {
+		return errors.Wrap(err, "get running version")
+	}
+	if utils.CompareVersions(running, version) != 0 {
+		return errors.Errorf("instance is running %s after upgrade to %s", running, version)
+	}
+	return m.rejoin(ctx, instance)
+}
+
+func (m *manager) waitForState(ctx context.Context, instance cloud.Instance, ok func(instanceState) bool) error {
+	ctx, cancel := context.WithTimeout(ctx, stateWaitTimeout)
+	defer cancel()
+	ticker := time.NewTicker(stateWaitInterval)
+	defer ticker.Stop()
+	for {
+		state := m.instanceState(ctx, instance)
+		if ok(state) {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return errors.Errorf("timeout waiting for instance state: %s", state.replicationState)
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package ps
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/pkg/errors"
+
+	"terraform-percona/internal/cloud"
+	internaldb "terraform-percona/internal/db"
+	"terraform-percona/internal/db/mysql"
+	"terraform-percona/internal/resource"
+	"terraform-percona/internal/resource/ps/cmd"
+)
+
+// newAdminClient returns a client of the automation user.
+// Clusters created before the automation user was introduced are accessed as root until they are updated.
+func (m *manager) newAdminClient(instance cloud.Instance) (*mysql.DB, error) {
+	if m.automationPass == "" {
+		return m.newClient(instance, internaldb.UserRoot, m.pass)
+	}
+	return m.newClient(instance, internaldb.UserAutomation, m.automationPass)
+}
+
+// detectAutomationCIDR restricts the automation user to the address of the provider, if the CIDR is not set in the configuration.
+func (m *manager) detectAutomationCIDR(ctx context.Context, instance cloud.Instance) error {
+	if m.automationCIDR != "" {
+		return nil
+	}
+	cidr, err := resource.DetectAutomationCIDR(ctx, m.cloud, m.resourceID, instance)
+	if err != nil {
+		return errors.Wrap(err, "failed to detect automation cidr")
+	}
+	m.automationCIDR = cidr
+	return nil
+}
+
+// createAutomationUser creates the automation user with the privileges of the installed version.
+func (m *manager) createAutomationUser(ctx context.Context, instance cloud.Instance, version string) error {
+	host, err := resource.MySQLHost(m.automationCIDR)
+	if err != nil {
+		return errors.Wrap(err, "automation user host")
+	}
+	if _, err := m.runCommand(ctx, instance, cmd.CreateAutomationUser(m.pass, host, m.automationPass, version)); err != nil {
+		return errors.Wrap(err, "create automation user")
+	}
+	return nil
+}
+
+// setupAutomationUser creates the automation user on the instances of the cluster,
+// which was created with remote root access or imported, and makes root accessible only locally.
+func (m *manager) setupAutomationUser(ctx context.Context) error {
+	instances, err := m.cloud.ListInstances(ctx, m.resourceID, map[string]string{
+		resource.LabelKeyInstanceType: resource.LabelValueInstanceTypeMySQL,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to list instances")
+	}
+	if len(instances) == 0 {
+		return errors.New("no instances found")
+	}
+	if err := m.detectAutomationCIDR(ctx, instances[0]); err != nil {
+		return err
+	}
+	tflog.Info(ctx, "Creating automation user")
+	for _, instance := range instances {
+		version, err := m.installedVersion(ctx, instance)
+		if err != nil {
+			return err
+		}
+		if err := m.createAutomationUser(ctx, instance, version); err != nil {
+			return errors.Wrapf(err, "instance %s", instance.PrivateIpAddress)
+		}
+		if _, err := m.runCommand(ctx, instance, cmd.RestrictRootUser(m.pass)); err != nil {
+			return errors.Wrapf(err, "failed to restrict root user on instance %s", instance.PrivateIpAddress)
+		}
+	}
+	return nil
+}
+
+// updateAutomationCIDR changes the host of the automation user on every instance.
+// Root is used locally with the password before the change, as the provider may be unable to connect from its new address.
+func (m *manager) updateAutomationCIDR(ctx context.Context, oldCIDR, rootPassword string) error {
+	oldHost, err := resource.MySQLHost(oldCIDR)
+	if err != nil {
+		return errors.Wrap(err, "previous automation user host")
+	}
+	newHost, err := resource.MySQLHost(m.automationCIDR)
+	if err != nil {
+		return errors.Wrap(err, "automation user host")
+	}
+	instances, err := m.cloud.ListInstances(ctx, m.resourceID, map[string]string{
+		resource.LabelKeyInstanceType: resource.LabelValueInstanceTypeMySQL,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to list instances")
+	}
+	for _, instance := range instances {
+		if _, err := m.runCommand(ctx, instance, cmd.RenameAutomationUser(rootPassword, oldHost, newHost)); err != nil {
+			return errors.Wrapf(err, "failed to rename automation user on instance %s", instance.PrivateIpAddress)
+		}
+	}
+	return nil
+}
+
+// applyUsers creates, changes and drops the application users on the source instance, the statements are replicated to the other instances.
+func (m *manager) applyUsers(ctx context.Context, previous []resource.User) error {
+	changed, removed := resource.UserChanges(previous, m.users)
+	if len(changed) == 0 && len(removed) == 0 {
+		return nil
+	}
+	states, err := m.instanceStates(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get instance states")
+	}
+	source, ok := sourceInstance(states)
+	if !ok {
+		return errors.New("failed to find online source instance")
+	}
+	db, err := m.connect(ctx, source.Instance)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	for _, u := range removed {
+		if err := db.DropUser(ctx, u.Name, u.Host); err != nil {
+			return errors.Wrapf(err, "failed to drop user %s@%s", u.Name, u.Host)
+		}
+	}
+	for _, u := range changed {
+		if err := db.CreateApplicationUser(ctx, u.Name, u.Host, u.Password, u.Privileges, u.Database); err != nil {
+			return errors.Wrapf(err, "failed to create user %s@%s", u.Name, u.Host)
+		}
+	}
+	return nil
+}
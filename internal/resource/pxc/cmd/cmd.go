@@ -2,24 +2,96 @@ package cmd
 
 import (
 	"fmt"
+	"strings"
 	"terraform-percona/internal/db"
+	"terraform-percona/internal/db/mysql"
+	"terraform-percona/internal/utils"
 )
 
-func RetrieveVersions() string {
-	return `apt-cache show percona-xtradb-cluster | grep 'Version' | sed 's/Version: 1://'`
+func RetrieveVersions(series string) string {
+	pkg := "percona-xtradb-cluster"
+	if series == "5.7" {
+		pkg = "percona-xtradb-cluster-57"
+	}
+	return fmt.Sprintf(`apt-cache show %s | grep 'Version' | sed 's/Version: \(1:\)\?//'`, pkg)
+}
+
+func InstalledVersion() string {
+	return `dpkg-query --show --showformat='${db:Status-Abbrev} ${Version}\n' 'percona-xtradb-cluster-server*' 2>/dev/null | awk '$1 == "ii" {print $2}' | sed 's/^1://'`
+}
+
+// ReadFile prints the content of the file, which may be readable only by root.
+func ReadFile(path string) string {
+	return fmt.Sprintf("sudo cat %s", path)
+}
+
+// FileHash prints SHA-256 hash of the file followed by its path, nothing is printed if the file doesn't exist.
+func FileHash(path string) string {
+	return fmt.Sprintf("sudo sh -c 'test ! -f %[1]s || sha256sum %[1]s'", path)
+}
+
+// packages returns the list of packages of the version to install, 5.7 packages have the series suffix and no epoch.
+func packages(version string) string {
+	if strings.HasPrefix(version, "5.7") {
+		return fmt.Sprintf("percona-xtradb-cluster-common-5.7=%s percona-xtradb-cluster-server-5.7=%s percona-xtradb-cluster-client-5.7=%s percona-xtradb-cluster-57=%s",
+			version, version, version, version)
+	}
+	return fmt.Sprintf("percona-xtradb-cluster-common=1:%s percona-xtradb-cluster-server=1:%s percona-xtradb-cluster-client=1:%s percona-xtradb-cluster=1:%s",
+		version, version, version, version)
 }
 
 func InstallPerconaXtraDBCluster(version string) string {
 	return fmt.Sprintf(`
 	#!/usr/bin/env bash
-	DEBIAN_FRONTEND=noninteractive sudo -E bash -c 'apt-get install -y percona-xtradb-cluster-common=1:%s percona-xtradb-cluster-server=1:%s percona-xtradb-cluster-client=1:%s percona-xtradb-cluster=1:%s'
+	DEBIAN_FRONTEND=noninteractive sudo -E bash -c 'apt-get install -y %s'
+
+	sudo chown $(id -un) /etc/mysql/mysql.conf.d/
+	sudo chown $(id -un) /etc/mysql/mysql.conf.d/mysqld.cnf
+	`, packages(version))
+}
+
+// UpdateRepository switches to the repository of the release series.
+// It also re-enables the repository, which is disabled after pmm client installation.
+func UpdateRepository(repository string) string {
+	return fmt.Sprintf(`
+	#!/usr/bin/env bash
+	set -o errexit
+	sudo percona-release enable-only %s release
+	sudo percona-release enable tools release
+	sudo apt-get update
+	`, repository)
+}
+
+func UpgradePerconaXtraDBCluster(version string) string {
+	return fmt.Sprintf(`
+	#!/usr/bin/env bash
+	set -o errexit
+	DEBIAN_FRONTEND=noninteractive sudo -E bash -c 'apt-get install -y -o Dpkg::Options::="--force-confold" %s'
+	`, packages(version))
+}
+
+func InstallMySQLShell() string {
+	return "sudo apt-get install -y percona-mysql-shell"
+}
 
-	sudo chown ubuntu /etc/mysql/mysql.conf.d/
-	sudo chown ubuntu /etc/mysql/mysql.conf.d/mysqld.cnf
-	`, version, version, version, version)
+// CheckForServerUpgrade runs MySQL Shell upgrade checker, which reports problems in JSON format.
+// The checker exits with an error if there are problems, so its exit code is ignored.
+// Root is accessible only locally, so that the checker connects through the socket, the password is read from stdin.
+func CheckForServerUpgrade(password string, targetVersion string) string {
+	return fmt.Sprintf(`printf '%%s\n' %s | mysqlsh --user=root --passwords-from-stdin --socket=/var/run/mysqld/mysqld.sock -- util check-for-server-upgrade --target-version=%s --output-format=JSON 2>/dev/null || true`,
+		utils.ShellQuote(password), targetVersion)
 }
 
-func Configure(password string) string {
+// GrastateSeqno prints the seqno saved in grastate.dat, which is -1 while the node is running or if it crashed.
+func GrastateSeqno() string {
+	return `sudo awk '$1 == "seqno:" {print $2}' /var/lib/mysql/grastate.dat`
+}
+
+func Configure(password, repository, series string) string {
+	pkg := "percona-xtradb-cluster-server"
+	if series == "5.7" {
+		pkg = "percona-xtradb-cluster-server-5.7"
+	}
 	return fmt.Sprintf(`
 	#!/usr/bin/env bash
 	sudo apt-get update
@@ -29,12 +101,16 @@ func Configure(password string) string {
 	wget https://repo.percona.com/apt/percona-release_latest.generic_all.deb
 	sudo dpkg -i percona-release_latest.generic_all.deb
 	sudo apt-get update
-	sudo percona-release setup pxc80
+	sudo percona-release enable-only %[1]s release
+	sudo percona-release enable tools release
+	sudo apt-get update
 	export MYSQL_SELECTION_DEFAULT_AUTH_OVERRIDE="select Use Strong Password Encryption (RECOMMENDED)"
-	echo "percona-xtradb-cluster-server   percona-xtradb-cluster-server/re-root-pass password %s" | sudo debconf-set-selections
-	echo "percona-xtradb-cluster-server   percona-xtradb-cluster-server/root-pass password %s" | sudo debconf-set-selections
-	echo "percona-xtradb-cluster-server   percona-xtradb-cluster-server/default-auth-override ${MYSQL_SELECTION_DEFAULT_AUTH_OVERRIDE}" | sudo debconf-set-selections
-	`, password, password)
+	echo %[3]s | sudo debconf-set-selections
+	echo %[4]s | sudo debconf-set-selections
+	echo "%[2]s   %[2]s/default-auth-override ${MYSQL_SELECTION_DEFAULT_AUTH_OVERRIDE}" | sudo debconf-set-selections
+	`, repository, pkg,
+		utils.ShellQuote(fmt.Sprintf("%[1]s   %[1]s/re-root-pass password %[2]s", pkg, password)),
+		utils.ShellQuote(fmt.Sprintf("%[1]s   %[1]s/root-pass password %[2]s", pkg, password)))
 }
 
 func Start(bootstrap bool) string {
@@ -44,8 +120,45 @@ func Start(bootstrap bool) string {
 	return "sudo systemctl start mysql"
 }
 
-func FixRootUser(rootPassword string) string {
-	return fmt.Sprintf(`mysql -uroot -p%s -e "RENAME USER 'root'@'localhost' TO 'root'@'%%';FLUSH PRIVILEGES;"`, rootPassword)
+// CreateAutomationUser creates the account which is used by the provider or resets its password and privileges,
+// root is accessible only locally.
+func CreateAutomationUser(rootPassword, host, password, version string) string {
+	account := mysql.QuoteString(db.UserAutomation) + "@" + mysql.QuoteString(host)
+	return rootStatements(rootPassword, fmt.Sprintf("CREATE USER IF NOT EXISTS %[1]s IDENTIFIED BY %[2]s; ALTER USER %[1]s IDENTIFIED BY %[2]s; %[3]s",
+		account, mysql.QuoteString(password), mysql.AutomationGrants(account, version)))
+}
+
+// EnablePartialRevokes allows the revokes of global privileges on single databases, galera replicates such revokes
+// only to the nodes which allow them.
+func EnablePartialRevokes(rootPassword string) string {
+	return rootStatements(rootPassword, "SET PERSIST partial_revokes=ON;")
+}
+
+// RenameAutomationUser changes the host of the account which is used by the provider, galera replicates the statement to the other nodes.
+func RenameAutomationUser(rootPassword, oldHost, newHost string) string {
+	user := mysql.QuoteString(db.UserAutomation)
+	return rootStatements(rootPassword, fmt.Sprintf("RENAME USER %[1]s@%[2]s TO %[1]s@%[3]s;",
+		user, mysql.QuoteString(oldHost), mysql.QuoteString(newHost)))
+}
+
+// rootStatements runs the statements as root through the socket.
+// The password is passed in the environment, so that it isn't interpreted by the shell and isn't visible in the process list.
+func rootStatements(rootPassword, statements string) string {
+	return fmt.Sprintf("MYSQL_PWD=%s mysql -uroot -e %s", utils.ShellQuote(rootPassword), utils.ShellQuote(statements))
+}
+
+// RestrictRootUser renames root@% back to root@localhost on the clusters which were created with remote root access.
+func RestrictRootUser(rootPassword string) string {
+	return fmt.Sprintf(`
+	#!/usr/bin/env bash
+
+	set -o errexit
+	export MYSQL_PWD=%[1]s
+
+	if [ "$(mysql -uroot -N -e "SELECT COUNT(*) FROM mysql.user WHERE user='root' AND host='%%'")" = "1" ]; then
+		mysql -uroot -e "RENAME USER 'root'@'%%' TO 'root'@'localhost';"
+	fi
+	`, utils.ShellQuote(rootPassword))
 }
 
 func Stop(bootstrap bool) string {
@@ -64,6 +177,25 @@ func InstallPMMClient(addr string) string {
 	sudo pmm-admin config --server-insecure-tls --server-url="%s"`, addr)
 }
 
-func AddServiceToPMM(password string, port int) string {
-	return fmt.Sprintf(`pmm-admin add mysql --query-source=slowlog --username="%s" --password="%s" --port=%d`, db.UserPMM, password, port)
+// AddServiceToPMM adds the local mysql service to PMM, the service is connected over TLS verified with the CA certificate if tlsCA is set.
+func AddServiceToPMM(password string, port int, tlsCA string) string {
+	c := fmt.Sprintf(`pmm-admin add mysql --query-source=slowlog --username=%s --password=%s --port=%d`, db.UserPMM, utils.ShellQuote(password), port)
+	if tlsCA != "" {
+		c += fmt.Sprintf(` --tls --tls-ca=%s`, tlsCA)
+	}
+	return c
+}
+
+// SystemResources prints total memory in kB and the number of CPUs on separate lines.
+func SystemResources() string {
+	return `awk '/^MemTotal:/ {print $2}' /proc/meminfo && nproc`
+}
+
+// RemoveServiceFromPMM removes the mysql service added by AddServiceToPMM, which is named after the node.
+func RemoveServiceFromPMM() string {
+	return `pmm-admin remove mysql "$(hostname)-mysql" || true`
+}
+
+func RemoveFromPMM() string {
+	return "sudo pmm-admin unregister --force"
 }
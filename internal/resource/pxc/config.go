@@ -0,0 +1,102 @@
+package pxc
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/pkg/errors"
+
+	"terraform-percona/internal/cloud"
+	"terraform-percona/internal/resource"
+	"terraform-percona/internal/resource/pxc/cmd"
+	"terraform-percona/internal/utils"
+)
+
+// pushConfig sends the config file to every node and restarts them one by one.
+// Each node should be synced with the cluster before the next one is restarted.
+func (m *manager) pushConfig(ctx context.Context) error {
+	states, err := m.instanceStates(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get instance states")
+	}
+	for _, state := range states {
+		if !state.isHealthy() {
+			return errors.Errorf("node %s is not a synced member of the primary component: wsrep_cluster_status=%s, wsrep_local_state_comment=%s, all nodes should be synced before config change",
+				state.PrivateIpAddress, state.clusterStatus, state.localState)
+		}
+	}
+	// The only node of the cluster is running with the bootstrap service
+	bootstrap := len(states) == 1
+	for _, state := range states {
+		tflog.Info(ctx, "Updating config file", map[string]interface{}{
+			resource.LogArgInstanceIP: state.PublicIpAddress,
+		})
+		if err := m.sendConfig(ctx, state.Instance); err != nil {
+			return errors.Wrapf(err, "failed to send config file to node %s", state.PrivateIpAddress)
+		}
+		if _, err := m.runCommand(ctx, state.Instance, cmd.Stop(bootstrap)); err != nil {
+			return errors.Wrapf(err, "failed to stop node %s", state.PrivateIpAddress)
+		}
+		if _, err := m.runCommand(ctx, state.Instance, cmd.Start(bootstrap)); err != nil {
+			return errors.Wrapf(err, "failed to start node %s", state.PrivateIpAddress)
+		}
+		if err := m.waitForState(ctx, state.Instance, instanceState.isHealthy); err != nil {
+			return errors.Wrapf(err, "node %s failed to rejoin the cluster after restart, check /var/log/mysql/error.log on the node", state.PrivateIpAddress)
+		}
+	}
+	return nil
+}
+
+// sendConfig replaces the custom config file of the node, the file is removed if config_file_path is not set.
+func (m *manager) sendConfig(ctx context.Context, instance cloud.Instance) error {
+	if m.cfgPath == "" {
+		if _, err := m.runCommand(ctx, instance, fmt.Sprintf("sudo rm -f %s", customMysqlConfigPath)); err != nil {
+			return errors.Wrap(err, "failed to remove config file")
+		}
+		return nil
+	}
+	cfgFile, err := os.Open(m.cfgPath)
+	if err != nil {
+		return errors.Wrap(err, "failed to open config file")
+	}
+	defer cfgFile.Close()
+	if err := m.cloud.SendFile(ctx, m.resourceID, instance, cfgFile, customMysqlConfigPath); err != nil {
+		return errors.Wrap(err, "failed to send config file")
+	}
+	return nil
+}
+
+// setConfigFileHash sets config_file_hash from the custom config file of the first node which can be read,
+// so that imported clusters and clusters created without the hash aren't restarted if the file is the same. Failures to read the file are only logged.
+func (m *manager) setConfigFileHash(ctx context.Context, data *schema.ResourceData, states []instanceState) error {
+	for _, state := range states {
+		if state.PublicIpAddress == "" {
+			continue
+		}
+		out, err := m.runCommand(ctx, state.Instance, cmd.FileHash(customMysqlConfigPath))
+		if err != nil {
+			tflog.Warn(ctx, "failed to hash config file", map[string]interface{}{
+				resource.LogArgInstanceIP: state.PublicIpAddress,
+				"error":                   err,
+			})
+			continue
+		}
+		// The hash is empty if the file doesn't exist, as well as the hash of unset config_file_path
+		hash, _, _ := strings.Cut(strings.TrimSpace(out), " ")
+		return data.Set(resource.SchemaKeyConfigFileHash, hash)
+	}
+	return nil
+}
+
+// defaultConfig returns the mysqld options of the default config file of the node.
+func (m *manager) defaultConfig(ctx context.Context, instance cloud.Instance) (map[string]string, error) {
+	out, err := m.runCommand(ctx, instance, cmd.ReadFile(defaultMysqlConfigPath))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read config file")
+	}
+	return utils.IniSection([]byte(out), "mysqld")
+}
@@ -0,0 +1,119 @@
+package pxc
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/pkg/errors"
+
+	"terraform-percona/internal/cloud"
+	internaldb "terraform-percona/internal/db"
+	"terraform-percona/internal/db/mysql"
+	"terraform-percona/internal/resource"
+	"terraform-percona/internal/resource/pxc/cmd"
+)
+
+type credentials struct {
+	root       string
+	pmm        string
+	automation string
+}
+
+// previousCredentials returns the passwords before the change.
+func previousCredentials(previous map[string]string) credentials {
+	return credentials{
+		root:       previous[resource.SchemaKeyRootPassword],
+		pmm:        previous[resource.SchemaKeyPMMPassword],
+		automation: previous[resource.SchemaKeyAutomationPassword],
+	}
+}
+
+// rotatePasswords changes the passwords of the users on one of the nodes, galera replicates the statements to the other nodes.
+// PMM services are re-added with the new password.
+func (m *manager) rotatePasswords(ctx context.Context, previous credentials) error {
+	m.previousPassword = previous.automation
+	states, err := m.instanceStates(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get instance states")
+	}
+	if len(states) == 0 {
+		return errors.New("no instances found")
+	}
+	for _, state := range states {
+		if !state.isHealthy() {
+			return errors.Errorf("node %s is not a synced member of the primary component: wsrep_cluster_status=%s, wsrep_local_state_comment=%s, all nodes should be synced before password change",
+				state.PrivateIpAddress, state.clusterStatus, state.localState)
+		}
+	}
+
+	tflog.Info(ctx, "Changing passwords")
+	err = func() error {
+		db, err := m.connect(ctx, states[0].Instance)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		if previous.root != m.password {
+			if err := db.AlterUserPassword(ctx, internaldb.UserRoot, "localhost", m.password); err != nil {
+				return errors.Wrapf(err, "failed to change password of %s user", internaldb.UserRoot)
+			}
+		}
+		if previous.automation != m.automationPassword {
+			host, err := resource.MySQLHost(m.automationCIDR)
+			if err != nil {
+				return errors.Wrap(err, "automation user host")
+			}
+			if err := db.AlterUserPassword(ctx, internaldb.UserAutomation, host, m.automationPassword); err != nil {
+				return errors.Wrapf(err, "failed to change password of %s user", internaldb.UserAutomation)
+			}
+		}
+		if previous.pmm != m.pmmPassword {
+			if err := db.AlterUserPassword(ctx, internaldb.UserPMM, "localhost", m.pmmPassword); err != nil {
+				return errors.Wrapf(err, "failed to change password of %s user", internaldb.UserPMM)
+			}
+		}
+		return nil
+	}()
+	if err != nil {
+		return errors.Wrapf(err, "node %s", states[0].PrivateIpAddress)
+	}
+
+	if previous.pmm != m.pmmPassword && m.pmmAddress != "" {
+		for _, state := range states {
+			if _, err := m.runCommand(ctx, state.Instance, cmd.RemoveServiceFromPMM()); err != nil {
+				return errors.Wrapf(err, "failed to remove pmm service of node %s", state.PrivateIpAddress)
+			}
+			if _, err := m.runCommand(ctx, state.Instance, cmd.AddServiceToPMM(m.pmmPassword, m.mysqlPort, m.pmmTLSCA())); err != nil {
+				return errors.Wrapf(err, "failed to add pmm service of node %s", state.PrivateIpAddress)
+			}
+		}
+	}
+	return nil
+}
+
+// connect opens a connection as the automation user with the current password,
+// or with the previous one if it's not changed on the node yet.
+func (m *manager) connect(ctx context.Context, instance cloud.Instance) (*mysql.DB, error) {
+	user, passwords := internaldb.UserAutomation, []string{m.automationPassword}
+	if m.automationPassword == "" {
+		// Clusters created before the automation user was introduced are accessed as root until they are updated
+		user, passwords = internaldb.UserRoot, []string{m.password}
+	}
+	if m.previousPassword != "" && m.previousPassword != passwords[0] {
+		passwords = append(passwords, m.previousPassword)
+	}
+	var lastErr error
+	for _, pass := range passwords {
+		db, err := m.newClient(instance, user, pass)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create new mysql client")
+		}
+		if err := db.PingContext(ctx); err != nil {
+			db.Close()
+			lastErr = err
+			continue
+		}
+		return db, nil
+	}
+	return nil, errors.Wrapf(lastErr, "failed to connect as %s with the current and previous passwords", user)
+}
@@ -0,0 +1,150 @@
+package pxc
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/pkg/errors"
+
+	"terraform-percona/internal/resource"
+	"terraform-percona/internal/resource/pxc/cmd"
+	"terraform-percona/internal/utils"
+)
+
+// encryptionConfig returns the config of galera replication and state transfers.
+// Encrypted traffic uses the certificates placed by resource.SendCertificates, which should be sent before the node is started.
+func (m *manager) encryptionConfig() (map[string]string, error) {
+	if !m.encryptTraffic {
+		return map[string]string{
+			"wsrep_provider_options":      fmt.Sprintf("base_port=%d", m.galeraPort),
+			"pxc-encrypt-cluster-traffic": "OFF",
+		}, nil
+	}
+	if !m.tlsEnabled() {
+		return nil, errors.Errorf("%s requires the certificates of the cluster ca", schemaKeyEncryptClusterTraffic)
+	}
+	return map[string]string{
+		"wsrep_provider_options": fmt.Sprintf("base_port=%d;socket.ssl=yes;socket.ssl_ca=%s;socket.ssl_cert=%s;socket.ssl_key=%s",
+			m.galeraPort, resource.CACertPath, resource.ServerCertPath, resource.ServerKeyPath),
+		// SST and IST use ssl_ca, ssl_cert and ssl_key of mysqld
+		"pxc-encrypt-cluster-traffic": "ON",
+	}, nil
+}
+
+// setTrafficEncryption sets encrypt_cluster_traffic from the config of the first node which can be read,
+// so that existing clusters keep their encryption unless it's set in the configuration. Failures to read the config are only logged.
+func (m *manager) setTrafficEncryption(ctx context.Context, data *schema.ResourceData, states []instanceState) error {
+	for _, state := range states {
+		if state.PublicIpAddress == "" {
+			continue
+		}
+		cfg, err := m.defaultConfig(ctx, state.Instance)
+		if err != nil {
+			tflog.Warn(ctx, "failed to read node config", map[string]interface{}{
+				resource.LogArgInstanceIP: state.PublicIpAddress,
+				"error":                   err,
+			})
+			continue
+		}
+		return data.Set(schemaKeyEncryptClusterTraffic, trafficEncrypted(cfg))
+	}
+	return nil
+}
+
+// trafficEncrypted returns pxc-encrypt-cluster-traffic of the mysqld options, the traffic is encrypted by default.
+func trafficEncrypted(cfg map[string]string) bool {
+	for _, name := range []string{"pxc-encrypt-cluster-traffic", "pxc_encrypt_cluster_traffic"} {
+		if v, ok := cfg[name]; ok {
+			return !strings.EqualFold(v, "OFF") && v != "0" && !strings.EqualFold(v, "false")
+		}
+	}
+	return true
+}
+
+// applyTrafficEncryption turns the encryption of galera traffic on or off.
+// Nodes with and without encryption can't communicate, so the whole cluster is stopped and bootstrapped again.
+// Clusters created before the CA was introduced get the CA and TLS for client connections along with the encryption.
+func (m *manager) applyTrafficEncryption(ctx context.Context, data *schema.ResourceData) error {
+	states, err := m.instanceStates(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get instance states")
+	}
+	if len(states) == 0 {
+		return errors.New("no instances found")
+	}
+	for _, state := range states {
+		if !state.isHealthy() {
+			return errors.Errorf("node %s is not a synced member of the primary component: wsrep_cluster_status=%s, wsrep_local_state_comment=%s, all nodes should be synced before the cluster restart",
+				state.PrivateIpAddress, state.clusterStatus, state.localState)
+		}
+	}
+
+	sendCertificates := m.encryptTraffic && !m.tlsEnabled()
+	if sendCertificates {
+		if err := resource.GenerateCA(data, m.resourceID); err != nil {
+			return err
+		}
+		m.caCert = data.Get(resource.SchemaKeyCACertificate).(string)
+		m.caKey = data.Get(resource.SchemaKeyCAPrivateKey).(string)
+	}
+	cfg, err := m.encryptionConfig()
+	if err != nil {
+		return err
+	}
+	if sendCertificates {
+		cfg = utils.MapMerge(cfg, resource.TLSConfig())
+	}
+	for _, state := range states {
+		if sendCertificates {
+			if err := resource.SendCertificates(ctx, m.cloud, m.resourceID, state.Instance, m.caCert, m.caKey); err != nil {
+				return errors.Wrapf(err, "failed to send certificates to node %s", state.PrivateIpAddress)
+			}
+		}
+		if err := m.editDefaultCfg(ctx, state.Instance, "mysqld", cfg); err != nil {
+			return errors.Wrapf(err, "failed to edit config of node %s", state.PrivateIpAddress)
+		}
+	}
+
+	tflog.Info(ctx, "Restarting the cluster", map[string]interface{}{
+		schemaKeyEncryptClusterTraffic: m.encryptTraffic,
+	})
+	// The only node of the cluster is running with the bootstrap service
+	bootstrap := len(states) == 1
+	// The first node is stopped last, so that it has the latest state and is safe to bootstrap
+	for i := len(states) - 1; i >= 0; i-- {
+		if _, err := m.runCommand(ctx, states[i].Instance, cmd.Stop(bootstrap)); err != nil {
+			return errors.Wrapf(err, "failed to stop node %s", states[i].PrivateIpAddress)
+		}
+	}
+	if _, err := m.runCommand(ctx, states[0].Instance, cmd.Start(true)); err != nil {
+		return errors.Wrapf(err, "failed to bootstrap node %s", states[0].PrivateIpAddress)
+	}
+	if err := m.waitForState(ctx, states[0].Instance, instanceState.isHealthy); err != nil {
+		return errors.Wrapf(err, "node %s failed to bootstrap the cluster, check /var/log/mysql/error.log on the node", states[0].PrivateIpAddress)
+	}
+	for _, state := range states[1:] {
+		if _, err := m.runCommand(ctx, state.Instance, cmd.Start(false)); err != nil {
+			return errors.Wrapf(err, "failed to start node %s", state.PrivateIpAddress)
+		}
+		if err := m.waitForState(ctx, state.Instance, instanceState.isHealthy); err != nil {
+			return errors.Wrapf(err, "node %s failed to join the cluster, check /var/log/mysql/error.log on the node", state.PrivateIpAddress)
+		}
+	}
+	if bootstrap {
+		return nil
+	}
+	// The bootstrap node is restarted as a regular member now that the other nodes are running
+	if _, err := m.runCommand(ctx, states[0].Instance, cmd.Stop(true)); err != nil {
+		return errors.Wrap(err, "run command bootstrap stop")
+	}
+	if _, err := m.runCommand(ctx, states[0].Instance, cmd.Start(false)); err != nil {
+		return errors.Wrap(err, "run command first node pxc start")
+	}
+	if err := m.waitForState(ctx, states[0].Instance, instanceState.isHealthy); err != nil {
+		return errors.Wrapf(err, "node %s failed to rejoin the cluster", states[0].PrivateIpAddress)
+	}
+	return nil
+}
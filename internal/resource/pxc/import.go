@@ -0,0 +1,69 @@
+package pxc
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/pkg/errors"
+
+	"terraform-percona/internal/resource"
+)
+
+// inspect restores the attributes of the existing cluster from the cloud labels and the nodes.
+// Passwords of the imported cluster are unknown, so the packages and config files are inspected over ssh without changing anything.
+func (m *manager) inspect(ctx context.Context, data *schema.ResourceData) error {
+	instances, err := m.cloud.ListInstances(ctx, m.resourceID, map[string]string{
+		resource.LabelKeyInstanceType: resource.LabelValueInstanceTypeMySQL,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to list instances")
+	}
+	if len(instances) == 0 {
+		return errors.Errorf("no instances found with %s=%s", resource.LabelKeyResourceID, m.resourceID)
+	}
+
+	for _, instance := range instances {
+		err := func() error {
+			version, err := m.installedVersion(ctx, instanceState{Instance: instance})
+			if err != nil {
+				return err
+			}
+			cfg, err := m.defaultConfig(ctx, instance)
+			if err != nil {
+				return err
+			}
+			galeraPort := m.galeraPort
+			if nodeAddress := cfg["wsrep_node_address"]; nodeAddress != "" {
+				if i := strings.LastIndex(nodeAddress, ":"); i != -1 {
+					galeraPort, err = strconv.Atoi(nodeAddress[i+1:])
+					if err != nil {
+						return errors.Wrap(err, "parse wsrep_node_address")
+					}
+				}
+			}
+			version, _, _ = strings.Cut(version, "-")
+			if err := data.Set(resource.SchemaKeyVersion, version); err != nil {
+				return errors.Wrap(err, "can't set version")
+			}
+			if err := data.Set(schemaKeyGaleraPort, galeraPort); err != nil {
+				return errors.Wrap(err, "can't set galera port")
+			}
+			if err := data.Set(schemaKeyEncryptClusterTraffic, trafficEncrypted(cfg)); err != nil {
+				return errors.Wrap(err, "can't set cluster traffic encryption")
+			}
+			return nil
+		}()
+		if err == nil {
+			return nil
+		}
+		tflog.Warn(ctx, "failed to inspect instance", map[string]interface{}{
+			resource.LogArgInstanceIP: instance.PublicIpAddress,
+			"error":                   err,
+		})
+	}
+	tflog.Warn(ctx, "none of the instances could be inspected, default values are kept in the state")
+	return nil
+}
@@ -0,0 +1,35 @@
+package pxc
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/pkg/errors"
+)
+
+// runInitSQL executes init_sql scripts on one of the synced nodes after the cluster is created, galera replicates the statements to the other nodes.
+func (m *manager) runInitSQL(ctx context.Context) error {
+	if len(m.initScripts) == 0 {
+		return nil
+	}
+	states, err := m.instanceStates(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get instance states")
+	}
+	node, ok := syncedNode(states)
+	if !ok {
+		return errors.New("failed to find synced node")
+	}
+	db, err := m.connect(ctx, node.Instance)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	for _, script := range m.initScripts {
+		tflog.Info(ctx, "Running init sql", map[string]interface{}{"script": script.Name})
+		if err := db.ExecScript(ctx, script.Name, script.SQL); err != nil {
+			return errors.Wrap(err, "failed to run init sql")
+		}
+	}
+	return nil
+}
@@ -2,8 +2,6 @@ package pxc
 
 import (
 	"context"
-	"fmt"
-	"os"
 	"strconv"
 	"strings"
 
@@ -13,7 +11,6 @@ import (
 	"golang.org/x/sync/errgroup"
 
 	"terraform-percona/internal/cloud"
-	internaldb "terraform-percona/internal/db"
 	"terraform-percona/internal/db/mysql"
 	"terraform-percona/internal/resource"
 	"terraform-percona/internal/resource/pxc/cmd"
@@ -21,16 +18,31 @@ import (
 )
 
 type manager struct {
-	size       int
-	password   string
-	cfgPath    string
-	version    string
-	mysqlPort  int
-	galeraPort int
+	size          int
+	password      string
+	cfgPath       string
+	options       map[string]string
+	tuningProfile string
+	volumeIOPS    int
+	version       string
+	series        string
+	mysqlPort     int
+	galeraPort    int
 
 	pmmAddress  string
 	pmmPassword string
 
+	automationPassword string
+	automationCIDR     string
+	users              []resource.User
+	initScripts        []resource.InitScript
+	caCert             string
+	caKey              string
+	encryptTraffic     bool
+
+	// previousPassword is the automation user password before the change, it is accepted until the change is applied
+	previousPassword string
+
 	resourceID string
 
 	cloud cloud.Cloud
@@ -38,20 +50,34 @@ type manager struct {
 
 func newManager(cloud cloud.Cloud, resourceID string, data *schema.ResourceData) *manager {
 	return &manager{
-		size:        data.Get(resource.SchemaKeyClusterSize).(int),
-		password:    data.Get(resource.SchemaKeyRootPassword).(string),
-		cfgPath:     data.Get(resource.SchemaKeyConfigFilePath).(string),
-		version:     data.Get(resource.SchemaKeyVersion).(string),
-		mysqlPort:   data.Get(resource.SchemaKeyPort).(int),
-		galeraPort:  data.Get(schemaKeyGaleraPort).(int),
-		resourceID:  resourceID,
-		cloud:       cloud,
-		pmmAddress:  data.Get(resource.SchemaKeyPMMAddress).(string),
-		pmmPassword: data.Get(resource.SchemaKeyPMMPassword).(string),
+		size:          data.Get(resource.SchemaKeyClusterSize).(int),
+		password:      data.Get(resource.SchemaKeyRootPassword).(string),
+		cfgPath:       data.Get(resource.SchemaKeyConfigFilePath).(string),
+		options:       resource.MySQLDOptions(data),
+		tuningProfile: data.Get(resource.SchemaKeyTuningProfile).(string),
+		volumeIOPS:    data.Get(resource.SchemaKeyVolumeIOPS).(int),
+		version:       data.Get(resource.SchemaKeyVersion).(string),
+		series:        data.Get(resource.SchemaKeyReleaseSeries).(string),
+		mysqlPort:     data.Get(resource.SchemaKeyPort).(int),
+		galeraPort:    data.Get(schemaKeyGaleraPort).(int),
+		resourceID:    resourceID,
+		cloud:         cloud,
+		pmmAddress:    data.Get(resource.SchemaKeyPMMAddress).(string),
+		pmmPassword:   data.Get(resource.SchemaKeyPMMPassword).(string),
+
+		automationPassword: data.Get(resource.SchemaKeyAutomationPassword).(string),
+		automationCIDR:     data.Get(resource.SchemaKeyAutomationCIDR).(string),
+		users:              resource.Users(data),
+		caCert:             data.Get(resource.SchemaKeyCACertificate).(string),
+		caKey:              data.Get(resource.SchemaKeyCAPrivateKey).(string),
+		encryptTraffic:     data.Get(schemaKeyEncryptClusterTraffic).(bool),
 	}
 }
 
 func (m *manager) Create(ctx context.Context) ([]cloud.Instance, error) {
+	if err := resource.CheckReleaseSeries(m.series, m.version); err != nil {
+		return nil, err
+	}
 	tflog.Info(ctx, "Creating instances")
 	instances, err := m.cloud.CreateInstances(ctx, m.resourceID, int64(m.size), map[string]string{
 		resource.LabelKeyInstanceType: resource.LabelValueInstanceTypeMySQL,
@@ -59,37 +85,11 @@ func (m *manager) Create(ctx context.Context) ([]cloud.Instance, error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "create instances")
 	}
-	clusterHosts := make([]string, 0, len(instances))
-	for _, instance := range instances {
-		clusterHosts = append(clusterHosts, instance.PrivateIpAddress+":"+strconv.Itoa(m.galeraPort))
-	}
-	g, gCtx := errgroup.WithContext(ctx)
-	g.SetLimit(len(instances))
-	for _, instance := range instances {
-		instance := instance
-		g.Go(func() error {
-			_, err = m.cloud.RunCommand(gCtx, m.resourceID, instance, cmd.Configure(m.password))
-			if err != nil {
-				return errors.Wrap(err, "run command pxc configure")
-			}
-			if err := m.installPXC(gCtx, instance, clusterHosts); err != nil {
-				return errors.Wrap(err, "install pxc")
-			}
-			if m.cfgPath != "" {
-				cfgFile, err := os.Open(m.cfgPath)
-				if err != nil {
-					return errors.Wrap(err, "failed to open config file")
-				}
-				defer cfgFile.Close()
-				if err = m.cloud.SendFile(gCtx, m.resourceID, instance, cfgFile, customMysqlConfigPath); err != nil {
-					return errors.Wrap(err, "failed to send config file")
-				}
-			}
-			return nil
-		})
+	if err := m.detectAutomationCIDR(ctx, instances[0]); err != nil {
+		return nil, err
 	}
 	tflog.Info(ctx, "Configuring instances")
-	if err = g.Wait(); err != nil {
+	if err = m.installInstances(ctx, instances, clusterHosts(instances, m.galeraPort)); err != nil {
 		return nil, errors.Wrap(err, "configure instances")
 	}
 	tflog.Info(ctx, "Starting instances")
@@ -98,49 +98,8 @@ func (m *manager) Create(ctx context.Context) ([]cloud.Instance, error) {
 		if err != nil {
 			return nil, errors.Wrap(err, "run command pxc start")
 		}
-		if m.pmmAddress != "" {
-			db, err := m.newClient(instance, internaldb.UserRoot, m.password)
-			if err != nil {
-				return nil, errors.Wrap(err, "failed to create new mysql client")
-			}
-			defer db.Close()
-			if err := db.CreatePMMUser(ctx, m.pmmPassword); err != nil {
-				return nil, errors.Wrap(err, "failed to create pmm user")
-			}
-			addr, err := utils.ParsePMMAddress(m.pmmAddress)
-			if err != nil {
-				return nil, errors.Wrap(err, "failed to parse pmm address")
-			}
-			_, err = m.runCommand(ctx, instance, cmd.InstallPMMClient(addr))
-			if err != nil {
-				return nil, errors.Wrap(err, "install pmm client")
-			}
-			err = m.editDefaultCfg(ctx, instance, "mysqld", map[string]string{
-				// Slow query log
-				"slow_query_log":                    "ON",
-				"log_output":                        "FILE",
-				"long_query_time":                   "0",
-				"log_slow_admin_statements":         "ON",
-				"log_slow_slave_statements":         "ON",
-				"log_slow_rate_limit":               "100",
-				"log_slow_rate_type":                "query",
-				"slow_query_log_always_write_time":  "1",
-				"log_slow_verbosity":                "full",
-				"slow_query_log_use_global_control": "all",
-				// While you can use both slow query log and performance schema at the same time it's recommended to use only one
-				// There is some overlap in the data reported, and each incurs a small performance penalty
-				// https://docs.percona.com/percona-monitoring-and-management/setting-up/client/mysql.html#choose-and-configure-a-source
-				// We should disable performance schema
-				"performance_schema": "OFF",
-				"userstat":           "ON", // User statistics
-			})
-			if err != nil {
-				return nil, errors.Wrap(err, "failed to edit default cfg for pmm")
-			}
-			_, err = m.runCommand(ctx, instance, cmd.AddServiceToPMM(m.pmmPassword, m.mysqlPort))
-			if err != nil {
-				return nil, errors.Wrap(err, "add service to pmm")
-			}
+		if err := m.setupPMM(ctx, instance); err != nil {
+			return nil, err
 		}
 	}
 
@@ -152,53 +111,200 @@ func (m *manager) Create(ctx context.Context) ([]cloud.Instance, error) {
 			return nil, errors.Wrap(err, "run command first node pxc start")
 		}
 	}
+	if err := m.applyUsers(ctx, nil); err != nil {
+		return nil, err
+	}
+	if err := m.runInitSQL(ctx); err != nil {
+		return nil, err
+	}
 	return instances, nil
 }
 
-func (m *manager) installPXC(ctx context.Context, instance cloud.Instance, clusterHosts []string) error {
+func clusterHosts(instances []cloud.Instance, galeraPort int) []string {
+	hosts := make([]string, 0, len(instances))
+	for _, instance := range instances {
+		hosts = append(hosts, instance.PrivateIpAddress+":"+strconv.Itoa(galeraPort))
+	}
+	return hosts
+}
+
+// installInstances installs the nodes in parallel.
+// The version is resolved on the first node beforehand, so that all nodes get the same repository and packages
+// and the goroutines don't change the manager.
+func (m *manager) installInstances(ctx context.Context, instances []cloud.Instance, clusterHosts []string) error {
+	repository, series := m.repository(), m.releaseSeries()
+	if _, err := m.cloud.RunCommand(ctx, m.resourceID, instances[0], cmd.Configure(m.password, repository, series)); err != nil {
+		return errors.Wrap(err, "run command pxc configure")
+	}
+	version, err := m.resolveVersion(ctx, instances[0])
+	if err != nil {
+		return errors.Wrap(err, "install pxc")
+	}
+	m.version = version
+
+	g, gCtx := errgroup.WithContext(ctx)
+	g.SetLimit(len(instances))
+	for i, instance := range instances {
+		i, instance := i, instance
+		g.Go(func() error {
+			if i > 0 {
+				if _, err := m.cloud.RunCommand(gCtx, m.resourceID, instance, cmd.Configure(m.password, repository, series)); err != nil {
+					return errors.Wrap(err, "run command pxc configure")
+				}
+			}
+			if err := m.installPXC(gCtx, instance, clusterHosts, version); err != nil {
+				return errors.Wrap(err, "install pxc")
+			}
+			if m.cfgPath != "" {
+				if err := m.sendConfig(gCtx, instance); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	return g.Wait()
+}
+
+func (m *manager) setupPMM(ctx context.Context, instance cloud.Instance) error {
+	if m.pmmAddress == "" {
+		return nil
+	}
+	db, err := m.newAdminClient(instance)
+	if err != nil {
+		return errors.Wrap(err, "failed to create new mysql client")
+	}
+	defer db.Close()
+	if err := db.CreatePMMUser(ctx, m.pmmPassword); err != nil {
+		return errors.Wrap(err, "failed to create pmm user")
+	}
+	addr, err := utils.ParsePMMAddress(m.pmmAddress)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse pmm address")
+	}
+	_, err = m.runCommand(ctx, instance, cmd.InstallPMMClient(addr))
+	if err != nil {
+		return errors.Wrap(err, "install pmm client")
+	}
+	err = m.editDefaultCfg(ctx, instance, "mysqld", map[string]string{
+		// Slow query log
+		"slow_query_log":            "ON",
+		"log_output":                "FILE",
+		"long_query_time":           "0",
+		"log_slow_admin_statements": "ON",
+		m.replicaVariable("log_slow_replica_statements"): "ON",
+		"log_slow_rate_limit":                            "100",
+		"log_slow_rate_type":                             "query",
+		"slow_query_log_always_write_time":               "1",
+		"log_slow_verbosity":                             "full",
+		"slow_query_log_use_global_control":              "all",
+		// While you can use both slow query log and performance schema at the same time it's recommended to use only one
+		// There is some overlap in the data reported, and each incurs a small performance penalty
+		// https://docs.percona.com/percona-monitoring-and-management/setting-up/client/mysql.html#choose-and-configure-a-source
+		// We should disable performance schema
+		"performance_schema": "OFF",
+		"userstat":           "ON", // User statistics
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to edit default cfg for pmm")
+	}
+	_, err = m.runCommand(ctx, instance, cmd.AddServiceToPMM(m.pmmPassword, m.mysqlPort, m.pmmTLSCA()))
+	if err != nil {
+		return errors.Wrap(err, "add service to pmm")
+	}
+	return nil
+}
+
+// resolveVersion returns the full version of the package matching the configured version, or the latest one if the version isn't set.
+func (m *manager) resolveVersion(ctx context.Context, instance cloud.Instance) (string, error) {
 	availableVersions, err := m.versionList(ctx, instance)
 	if err != nil {
-		return errors.Wrap(err, "retrieve versions")
+		return "", errors.Wrap(err, "retrieve versions")
 	}
-	if m.version != "" {
-		fullVersion := utils.SelectVersion(availableVersions, m.version)
-		if fullVersion == "" {
-			return errors.Errorf("version not found, available versions: %v", availableVersions)
-		}
-		m.version = fullVersion
-	} else {
-		m.version = availableVersions[0]
+	if m.version == "" {
+		return availableVersions[0], nil
+	}
+	fullVersion := utils.SelectVersion(availableVersions, m.version)
+	if fullVersion == "" {
+		return "", errors.Errorf("version not found, available versions: %v", availableVersions)
 	}
-	_, err = m.runCommand(ctx, instance, cmd.InstallPerconaXtraDBCluster(m.version))
+	return fullVersion, nil
+}
+
+func (m *manager) installPXC(ctx context.Context, instance cloud.Instance, clusterHosts []string, version string) error {
+	_, err := m.runCommand(ctx, instance, cmd.InstallPerconaXtraDBCluster(version))
 	if err != nil {
 		return errors.Wrap(err, "failed to run pxc install cmd")
 	}
 	if _, err = m.cloud.RunCommand(ctx, m.resourceID, instance, cmd.Start(false)); err != nil {
 		return errors.Wrap(err, "pxc start")
 	}
-	_, err = m.cloud.RunCommand(ctx, m.resourceID, instance, cmd.FixRootUser(m.password))
-	if err != nil {
-		return errors.Wrap(err, "pxc fix root user")
+	if err := m.createAutomationUser(ctx, instance, version); err != nil {
+		return err
 	}
 	if _, err = m.cloud.RunCommand(ctx, m.resourceID, instance, cmd.Stop(false)); err != nil {
 		return errors.Wrap(err, "pxc stop")
 	}
-	err = m.editDefaultCfg(ctx, instance, "mysqld", map[string]string{
-		"port":                        strconv.Itoa(m.mysqlPort),
-		"wsrep_cluster_address":       "gcomm://" + strings.Join(clusterHosts, ","),
-		"wsrep_node_name":             instance.PrivateIpAddress,
-		"wsrep_node_address":          instance.PrivateIpAddress + ":" + strconv.Itoa(m.galeraPort),
-		"wsrep_provider_options":      fmt.Sprintf("base_port=%d", m.galeraPort),
-		"pxc-encrypt-cluster-traffic": "OFF",
-	})
+	mysqldCfg, err := m.mysqldConfig(ctx, instance, m.tuningProfile, m.options, version)
+	if err != nil {
+		return err
+	}
+	cfg := m.versionConfig(version)
+	if m.tlsEnabled() {
+		if err := resource.SendCertificates(ctx, m.cloud, m.resourceID, instance, m.caCert, m.caKey); err != nil {
+			return errors.Wrap(err, "send certificates")
+		}
+		cfg = utils.MapMerge(cfg, resource.TLSConfig())
+	}
+	encryptionCfg, err := m.encryptionConfig()
+	if err != nil {
+		return err
+	}
+	err = m.editDefaultCfg(ctx, instance, "mysqld", utils.MapMerge(cfg, map[string]string{
+		"port":                  strconv.Itoa(m.mysqlPort),
+		"wsrep_cluster_address": "gcomm://" + strings.Join(clusterHosts, ","),
+		"wsrep_node_name":       instance.PrivateIpAddress,
+		"wsrep_node_address":    instance.PrivateIpAddress + ":" + strconv.Itoa(m.galeraPort),
+	}, encryptionCfg, mysqldCfg))
 	if err != nil {
 		return errors.Wrap(err, "failed to edit default config")
 	}
 	return nil
 }
 
+// versionConfig returns the config required by the release series of the version.
+func (m *manager) versionConfig(version string) map[string]string {
+	cfg := make(map[string]string)
+	if resource.ReleaseSeries(version) == resource.ReleaseSeries84 {
+		// Users created before the upgrade to 8.4 keep mysql_native_password, which is disabled by default since 8.4
+		cfg["mysql_native_password"] = "ON"
+	}
+	return cfg
+}
+
+// replicaVariable returns the name of the variable with REPLICA/SOURCE terminology,
+// or its MASTER/SLAVE name for versions before 8.0.26, where the variables were renamed.
+func (m *manager) replicaVariable(name string) string {
+	if m.version == "" || utils.CompareVersions(m.version, "8.0.26") >= 0 {
+		return name
+	}
+	return strings.NewReplacer("replica", "slave", "source", "master").Replace(name)
+}
+
+// releaseSeries returns the release series set in the config, or the one of the version.
+func (m *manager) releaseSeries() string {
+	if m.series != "" {
+		return m.series
+	}
+	return resource.ReleaseSeries(m.version)
+}
+
+func (m *manager) repository() string {
+	return resource.ReleaseRepository("pxc", m.releaseSeries(), m.version)
+}
+
 func (m *manager) versionList(ctx context.Context, instance cloud.Instance) ([]string, error) {
-	out, err := m.cloud.RunCommand(ctx, m.resourceID, instance, cmd.RetrieveVersions())
+	out, err := m.cloud.RunCommand(ctx, m.resourceID, instance, cmd.RetrieveVersions(m.releaseSeries()))
 	if err != nil {
 		return nil, errors.Wrap(err, "retrieve versions")
 	}
@@ -223,5 +329,22 @@ func (m *manager) editDefaultCfg(ctx context.Context, instance cloud.Instance, s
 }
 
 func (m *manager) newClient(instance cloud.Instance, user, pass string) (*mysql.DB, error) {
-	return mysql.NewClient(instance.PublicIpAddress+":"+strconv.Itoa(m.mysqlPort), user, pass)
+	host := instance.PublicIpAddress + ":" + strconv.Itoa(m.mysqlPort)
+	if m.tlsEnabled() {
+		return mysql.NewTLSClient(host, user, pass, []byte(m.caCert))
+	}
+	return mysql.NewClient(host, user, pass)
+}
+
+// pmmTLSCA returns the CA certificate for the connection of PMM client, or empty string if TLS isn't enabled.
+func (m *manager) pmmTLSCA() string {
+	if !m.tlsEnabled() {
+		return ""
+	}
+	return resource.CACertPath
+}
+
+// tlsEnabled reports whether the cluster has a CA, clusters created before it was introduced accept unencrypted connections.
+func (m *manager) tlsEnabled() bool {
+	return m.caCert != ""
 }
@@ -0,0 +1,434 @@
+package pxc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"terraform-percona/internal/cloud"
+	"terraform-percona/internal/cloud/fake"
+	fakedb "terraform-percona/internal/db/mysql/fake"
+	"terraform-percona/internal/resource"
+	"terraform-percona/internal/resource/pxc/cmd"
+)
+
+var errInjected = errors.New("injected failure")
+
+func newTestManager(t *testing.T, c *fake.Cloud, raw map[string]interface{}) *manager {
+	t.Helper()
+	cfg := map[string]interface{}{
+		resource.SchemaKeyInstanceType:       "t3.micro",
+		resource.SchemaKeyKeyPairName:        "key",
+		resource.SchemaKeyRootPassword:       "rootPassword",
+		resource.SchemaKeyAutomationPassword: "automationPassword",
+		resource.SchemaKeyAutomationCIDR:     "203.0.113.1/32",
+		resource.SchemaKeyVersion:            "8.0.33",
+		resource.SchemaKeyPort:               closedPort(t),
+		schemaKeyEncryptClusterTraffic:       true,
+	}
+	for k, v := range raw {
+		cfg[k] = v
+	}
+	data := schema.TestResourceDataRaw(t, new(PerconaXtraDBCluster).Schema(), cfg)
+	if err := resource.GenerateCA(data, "test"); err != nil {
+		t.Fatal(err)
+	}
+	c.OnCommand("apt-cache show", "8.0.33-25-1.focal\n8.0.32-24-1.focal")
+	return newManager(c, "test", data)
+}
+
+// closedPort returns a local port which refuses connections, so that database connections fail immediately.
+func closedPort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func publicAddresses(instances []cloud.Instance) []string {
+	hosts := make([]string, 0, len(instances))
+	for _, instance := range instances {
+		hosts = append(hosts, instance.PublicIpAddress)
+	}
+	return hosts
+}
+
+// newTestServer returns the database server of the nodes with the public addresses,
+// it accepts the connections over TLS with the CA of the manager.
+func newTestServer(t *testing.T, m *manager, hosts ...string) *fakedb.Server {
+	t.Helper()
+	db, err := fakedb.New(hosts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(db.Close)
+	db.TLS(m.caCert, m.caKey)
+	m.mysqlPort = db.Port()
+	return db
+}
+
+// onStatus sets the value of the status variable on all nodes, the first value set for the variable wins.
+func onStatus(db *fakedb.Server, name, value string) {
+	db.OnQuery("SHOW GLOBAL STATUS LIKE '"+name+"'", []string{"Variable_name", "Value"}, []string{name, value})
+}
+
+// onSynced makes all nodes report the status of synced members of the primary component of the cluster.
+func onSynced(db *fakedb.Server, clusterSize int) {
+	onStatus(db, schemaKeyInstancesClusterSize, strconv.Itoa(clusterSize))
+	onStatus(db, schemaKeyInstancesClusterStatus, clusterStatusPrimary)
+	onStatus(db, schemaKeyInstancesLocalState, localStateSynced)
+}
+
+func TestCreate(t *testing.T) {
+	c := fake.New()
+	m := newTestManager(t, c, map[string]interface{}{
+		resource.SchemaKeyClusterSize: 3,
+	})
+	instances, err := m.Create(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(instances) != 3 {
+		t.Fatalf("Create() returned %d instances, want 3", len(instances))
+	}
+
+	// The version is resolved once, every node installs the same packages
+	var versionLists int
+	for _, call := range c.Calls() {
+		if strings.Contains(call.Arg, "apt-cache show") {
+			versionLists++
+		}
+	}
+	if versionLists != 1 {
+		t.Errorf("versions are listed %d times, want once", versionLists)
+	}
+	for _, instance := range instances {
+		if !strings.Contains(strings.Join(c.Commands(instance), "\n"), cmd.InstallPerconaXtraDBCluster("8.0.33-25-1.focal")) {
+			t.Errorf("node %s doesn't install version 8.0.33-25-1.focal, commands: %q", instance.PrivateIpAddress, c.Commands(instance))
+		}
+	}
+
+	for _, instance := range instances {
+		cfg, ok := c.File(instance, defaultMysqlConfigPath)
+		if !ok {
+			t.Fatalf("config of node %s is not written", instance.PrivateIpAddress)
+		}
+		for _, want := range []string{
+			"wsrep_cluster_address = gcomm://" + strings.Join(clusterHosts(instances, m.galeraPort), ","),
+			"wsrep_node_address    = " + instance.PrivateIpAddress + ":4567",
+			"pxc-encrypt-cluster-traffic = ON",
+			"socket.ssl=yes",
+			"require_secure_transport = ON",
+		} {
+			if !strings.Contains(strings.Join(strings.Fields(cfg), " "), strings.Join(strings.Fields(want), " ")) {
+				t.Errorf("config of node %s doesn't contain %q:\n%s", instance.PrivateIpAddress, want, cfg)
+			}
+		}
+		for _, path := range []string{"ca.pem", "server-cert.pem", "server-key.pem"} {
+			if _, ok := c.File(instance, "percona-certs/"+path); !ok {
+				t.Errorf("%s is not sent to node %s", path, instance.PrivateIpAddress)
+			}
+		}
+	}
+
+	// Certificates should be in place on every node before the cluster is bootstrapped
+	var started []string
+	for _, call := range c.Calls() {
+		switch {
+		case call.Method == fake.MethodSendFile && len(started) > 0:
+			t.Errorf("file %s is sent to node %s after the cluster is started", call.Arg, call.Instance.PrivateIpAddress)
+		case call.Arg == cmd.Start(true):
+			started = append(started, "bootstrap "+call.Instance.PrivateIpAddress)
+		case call.Arg == cmd.Start(false) && len(started) > 0:
+			started = append(started, "start "+call.Instance.PrivateIpAddress)
+		}
+	}
+	want := []string{
+		"bootstrap " + instances[0].PrivateIpAddress,
+		"start " + instances[1].PrivateIpAddress,
+		"start " + instances[2].PrivateIpAddress,
+		"start " + instances[0].PrivateIpAddress,
+	}
+	if strings.Join(started, ", ") != strings.Join(want, ", ") {
+		t.Errorf("nodes started in order %v, want %v", started, want)
+	}
+}
+
+func TestCreateWithoutEncryption(t *testing.T) {
+	c := fake.New()
+	m := newTestManager(t, c, map[string]interface{}{
+		resource.SchemaKeyClusterSize:  1,
+		schemaKeyEncryptClusterTraffic: false,
+	})
+	instances, err := m.Create(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, _ := c.File(instances[0], defaultMysqlConfigPath)
+	if !strings.Contains(cfg, "pxc-encrypt-cluster-traffic = OFF") || strings.Contains(cfg, "socket.ssl") {
+		t.Errorf("galera traffic should not be encrypted:\n%s", cfg)
+	}
+}
+
+// TestCreateFailures fails every call of a successful creation in turn, the failure should be returned as is.
+func TestCreateFailures(t *testing.T) {
+	c := fake.New()
+	if _, err := newTestManager(t, c, nil).Create(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	calls := len(c.Calls())
+	for n := 0; n < calls; n++ {
+		c := fake.New()
+		c.FailAfter(n, errInjected)
+		_, err := newTestManager(t, c, nil).Create(context.Background())
+		if !errors.Is(err, errInjected) {
+			t.Fatalf("Create() with failure of call %d error = %v, want %v", n, err, errInjected)
+		}
+		if got := len(c.Calls()); got > calls {
+			t.Errorf("Create() made %d calls after failure of call %d, successful creation makes %d", got, n, calls)
+		}
+	}
+}
+
+func TestCreateBootstrapFailure(t *testing.T) {
+	c := fake.New()
+	c.FailOn(cmd.Start(true), errInjected)
+	_, err := newTestManager(t, c, nil).Create(context.Background())
+	if !errors.Is(err, errInjected) {
+		t.Fatalf("Create() error = %v, want %v", err, errInjected)
+	}
+	bootstrapped := false
+	for _, call := range c.Calls() {
+		switch {
+		case call.Arg == cmd.Start(true):
+			bootstrapped = true
+		case call.Arg == cmd.Start(false) && bootstrapped:
+			t.Errorf("node %s is started after the bootstrap failure", call.Instance.PrivateIpAddress)
+		}
+	}
+}
+
+// TestInspect restores the attributes of an imported cluster from its nodes, nothing should be changed on them.
+func TestInspect(t *testing.T) {
+	c := fake.New()
+	m := newTestManager(t, c, nil)
+	if _, err := c.CreateInstances(context.Background(), "test", 3, map[string]string{
+		resource.LabelKeyInstanceType: resource.LabelValueInstanceTypeMySQL,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	c.OnCommand(cmd.InstalledVersion(), "8.0.32-24-1.focal\n")
+	c.OnCommand(cmd.ReadFile(defaultMysqlConfigPath), "[mysqld]\nwsrep_node_address = 10.0.0.1:5567\npxc-encrypt-cluster-traffic = OFF\n")
+
+	data := schema.TestResourceDataRaw(t, new(PerconaXtraDBCluster).Schema(), map[string]interface{}{})
+	if err := m.inspect(context.Background(), data); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]interface{}{
+		resource.SchemaKeyVersion:      "8.0.32",
+		schemaKeyGaleraPort:            5567,
+		schemaKeyEncryptClusterTraffic: false,
+	} {
+		if got := data.Get(key); got != want {
+			t.Errorf("%s = %v, want %v", key, got, want)
+		}
+	}
+	for _, call := range c.Calls() {
+		if call.Method != fake.MethodRunCommand {
+			t.Errorf("inspect changed the node: %s %s", call.Method, call.Arg)
+		}
+	}
+}
+
+// TestSetTrafficEncryption reads the encryption of the existing cluster, clusters created by previous versions have it turned off in the config.
+func TestSetTrafficEncryption(t *testing.T) {
+	c := fake.New()
+	m := newTestManager(t, c, nil)
+	instances, err := c.CreateInstances(context.Background(), "test", 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.OnCommand(cmd.ReadFile(defaultMysqlConfigPath), "[mysqld]\nwsrep_provider_options = base_port=4567\npxc-encrypt-cluster-traffic = OFF\n")
+	data := schema.TestResourceDataRaw(t, new(PerconaXtraDBCluster).Schema(), map[string]interface{}{
+		schemaKeyEncryptClusterTraffic: true,
+	})
+	if err := m.setTrafficEncryption(context.Background(), data, []instanceState{{Instance: instances[0]}}); err != nil {
+		t.Fatal(err)
+	}
+	if data.Get(schemaKeyEncryptClusterTraffic).(bool) {
+		t.Errorf("%s = true, want false", schemaKeyEncryptClusterTraffic)
+	}
+}
+
+// TestSetConfigFileHash reads the hash of the config file on the nodes, so that imported clusters aren't restarted by the first apply.
+func TestSetConfigFileHash(t *testing.T) {
+	tests := map[string]struct {
+		out  string
+		want string
+	}{
+		"config file": {
+			out:  "0123abcd  " + customMysqlConfigPath + "\n",
+			want: "0123abcd",
+		},
+		"no config file": {},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := fake.New()
+			m := newTestManager(t, c, nil)
+			instances, err := c.CreateInstances(context.Background(), "test", 1, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.OnCommand(cmd.FileHash(customMysqlConfigPath), tt.out)
+			data := schema.TestResourceDataRaw(t, new(PerconaXtraDBCluster).Schema(), map[string]interface{}{})
+			if err := data.Set(resource.SchemaKeyConfigFileHash, "stale"); err != nil {
+				t.Fatal(err)
+			}
+			if err := m.setConfigFileHash(context.Background(), data, []instanceState{{Instance: instances[0]}}); err != nil {
+				t.Fatal(err)
+			}
+			if got := data.Get(resource.SchemaKeyConfigFileHash).(string); got != tt.want {
+				t.Errorf("%s = %q, want %q", resource.SchemaKeyConfigFileHash, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestUpgradeRefusedByChecker enables the repository of the installed version again if the upgrade checker finds blocking issues.
+func TestUpgradeRefusedByChecker(t *testing.T) {
+	c := fake.New()
+	c.OnCommand(cmd.RetrieveVersions(resource.ReleaseSeries84), "8.4.0-1-1.focal")
+	c.OnCommand(cmd.InstalledVersion(), "8.0.33-25-1.focal")
+	c.OnCommand("check-for-server-upgrade", `{"errorCount": 1, "checksPerformed": [{"id": "removedSysVars", "title": "Removed system variables",`+
+		`"detectedProblems": [{"level": "Error", "dbObject": "wsrep_causal_reads", "description": "removed in 8.4"}]}]}`)
+	m := newTestManager(t, c, nil)
+	instances, err := c.CreateInstances(context.Background(), "test", 3, map[string]string{
+		resource.LabelKeyInstanceType: resource.LabelValueInstanceTypeMySQL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	onSynced(newTestServer(t, m, publicAddresses(instances)...), 3)
+	m.version = "8.4.0"
+
+	if err := m.upgrade(context.Background()); err == nil || !strings.Contains(err.Error(), "upgrade check failed") {
+		t.Fatalf("upgrade() error = %v, want upgrade check error", err)
+	}
+	for _, instance := range instances {
+		enabled := ""
+		for _, command := range c.Commands(instance) {
+			switch command {
+			case cmd.UpdateRepository("pxc-84-lts"):
+				enabled = "pxc-84-lts"
+			case cmd.UpdateRepository("pxc-80"):
+				enabled = "pxc-80"
+			}
+			if strings.Contains(command, "percona-xtradb-cluster-server=") {
+				t.Errorf("percona xtradb cluster is upgraded on node %s: %s", instance.PrivateIpAddress, command)
+			}
+		}
+		if enabled == "pxc-84-lts" {
+			t.Errorf("repository pxc-84-lts is left enabled on node %s", instance.PrivateIpAddress)
+		}
+	}
+	if got := c.Commands(instances[0]); !strings.Contains(strings.Join(got, "\n"), cmd.UpdateRepository("pxc-80")) {
+		t.Errorf("repository pxc-80 isn't enabled again on node %s", instances[0].PrivateIpAddress)
+	}
+}
+
+// TestSetupAutomationUser enables partial revokes on every node before the automation user is created,
+// as galera replicates the revokes of its privileges on the system schemas.
+func TestSetupAutomationUser(t *testing.T) {
+	tests := []struct {
+		name       string
+		failOn     string
+		wantErr    string
+		wantCreate bool
+	}{
+		{name: "create", wantCreate: true},
+		{name: "partial revokes failure", failOn: cmd.EnablePartialRevokes("rootPassword"), wantErr: "failed to enable partial revokes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.New()
+			c.OnCommand(cmd.InstalledVersion(), "8.0.33-25-1.focal")
+			m := newTestManager(t, c, nil)
+			instances, err := c.CreateInstances(context.Background(), "test", 3, map[string]string{
+				resource.LabelKeyInstanceType: resource.LabelValueInstanceTypeMySQL,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.failOn != "" {
+				c.FailOn(tt.failOn, errInjected)
+			}
+
+			err = m.setupAutomationUser(context.Background())
+			if tt.wantErr == "" && err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("setupAutomationUser() error = %v, want error containing %q", err, tt.wantErr)
+			}
+
+			createUser := cmd.CreateAutomationUser("rootPassword", "203.0.113.1", "automationPassword", "8.0.33-25-1.focal")
+			enabled := make(map[cloud.Instance]bool)
+			var created []string
+			for _, call := range c.Calls() {
+				switch call.Arg {
+				case cmd.EnablePartialRevokes("rootPassword"):
+					enabled[call.Instance] = true
+				case createUser:
+					if len(enabled) != len(instances) {
+						t.Errorf("automation user is created on node %s before partial revokes are enabled on every node", call.Instance.PrivateIpAddress)
+					}
+					created = append(created, call.Instance.PrivateIpAddress)
+				}
+			}
+			// Galera replicates the user, so it's created on the first node
+			var want []string
+			if tt.wantCreate {
+				want = []string{instances[0].PrivateIpAddress}
+			}
+			if strings.Join(created, ",") != strings.Join(want, ",") {
+				t.Errorf("automation user is created on nodes %v, want %v", created, want)
+			}
+		})
+	}
+}
+
+func TestReceivedSST(t *testing.T) {
+	tests := []struct {
+		name         string
+		committed    string
+		cachedDownto string
+		want         bool
+	}{
+		{name: "nothing written", committed: "100", cachedDownto: "0"},
+		{name: "IST", committed: "120", cachedDownto: "101"},
+		{name: "recovered gcache", committed: "120", cachedDownto: "40"},
+		{name: "SST", committed: "120", cachedDownto: "111", want: true},
+		{name: "SST without writes since", committed: "110", cachedDownto: "0", want: true},
+		{name: "SST with empty gcache of galera 3", committed: "110", cachedDownto: "18446744073709551615", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := receivedSST(100, tt.committed, tt.cachedDownto)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("receivedSST(100, %s, %s) = %v, want %v", tt.committed, tt.cachedDownto, got, tt.want)
+			}
+		})
+	}
+}
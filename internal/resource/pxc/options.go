@@ -0,0 +1,116 @@
+package pxc
+
+import (
+	"context"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/pkg/errors"
+
+	"terraform-percona/internal/cloud"
+	"terraform-percona/internal/resource"
+	"terraform-percona/internal/resource/pxc/cmd"
+	"terraform-percona/internal/utils"
+)
+
+// applyOptions applies the changes of tuning_profile and mysqld_options to the config file of every node, dynamic variables are set at runtime.
+// Nodes are restarted one by one only if some variables can't be changed at runtime.
+func (m *manager) applyOptions(ctx context.Context, oldProfile string, oldOptions map[string]string) error {
+	states, err := m.instanceStates(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get instance states")
+	}
+	for _, state := range states {
+		if !state.isHealthy() {
+			return errors.Errorf("node %s is not a synced member of the primary component: wsrep_cluster_status=%s, wsrep_local_state_comment=%s, all nodes should be synced before config change",
+				state.PrivateIpAddress, state.clusterStatus, state.localState)
+		}
+	}
+	// The only node of the cluster is running with the bootstrap service
+	bootstrap := len(states) == 1
+	for _, state := range states {
+		version, err := m.installedVersion(ctx, state)
+		if err != nil {
+			return err
+		}
+		oldCfg, err := m.mysqldConfig(ctx, state.Instance, oldProfile, oldOptions, version)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get mysqld options of node %s", state.PrivateIpAddress)
+		}
+		newCfg, err := m.mysqldConfig(ctx, state.Instance, m.tuningProfile, m.options, version)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get mysqld options of node %s", state.PrivateIpAddress)
+		}
+		changed, removed := resource.ConfigChanges(oldCfg, newCfg)
+		if len(changed) == 0 && len(removed) == 0 {
+			continue
+		}
+		restart, err := m.applyNodeOptions(ctx, state.Instance, changed, removed)
+		if err != nil {
+			return errors.Wrapf(err, "failed to apply mysqld options to node %s", state.PrivateIpAddress)
+		}
+		if !restart {
+			continue
+		}
+		if _, err := m.runCommand(ctx, state.Instance, cmd.Stop(bootstrap)); err != nil {
+			return errors.Wrapf(err, "failed to stop node %s", state.PrivateIpAddress)
+		}
+		if _, err := m.runCommand(ctx, state.Instance, cmd.Start(bootstrap)); err != nil {
+			return errors.Wrapf(err, "failed to start node %s", state.PrivateIpAddress)
+		}
+		if err := m.waitForState(ctx, state.Instance, instanceState.isHealthy); err != nil {
+			return errors.Wrapf(err, "node %s failed to rejoin the cluster after restart, check /var/log/mysql/error.log on the node", state.PrivateIpAddress)
+		}
+	}
+	return nil
+}
+
+// mysqldConfig returns the options set by the tuning profile and mysqld_options, mysqld_options take precedence.
+func (m *manager) mysqldConfig(ctx context.Context, instance cloud.Instance, profile string, options map[string]string, version string) (map[string]string, error) {
+	cfg := make(map[string]string)
+	if profile == resource.TuningProfileAuto {
+		out, err := m.runCommand(ctx, instance, cmd.SystemResources())
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get system resources")
+		}
+		res, err := resource.ParseSystemResources(out)
+		if err != nil {
+			return nil, err
+		}
+		cfg = resource.TuningConfig(res, m.volumeIOPS, version)
+	}
+	return utils.MapMerge(cfg, options), nil
+}
+
+// applyNodeOptions sets the variables at runtime and then updates the config file of the node.
+// It reports whether the node should be restarted to apply the rest of them.
+func (m *manager) applyNodeOptions(ctx context.Context, instance cloud.Instance, changed map[string]string, removed []string) (bool, error) {
+	db, err := m.newAdminClient(instance)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to create new mysql client")
+	}
+	defer db.Close()
+	// Unknown variables and invalid values fail before the config file is changed, so that the instance can still start
+	static, err := db.ApplyVariables(ctx, changed, removed)
+	if err != nil {
+		return false, err
+	}
+	if len(changed) > 0 {
+		if err := m.editDefaultCfg(ctx, instance, "mysqld", changed); err != nil {
+			return false, errors.Wrap(err, "edit default cfg")
+		}
+	}
+	if len(removed) > 0 {
+		if err := m.cloud.EditFile(ctx, m.resourceID, instance, defaultMysqlConfigPath, utils.DeleteIniFields("mysqld", removed)); err != nil {
+			return false, errors.Wrap(err, "edit default cfg")
+		}
+	}
+	if len(static) == 0 {
+		return false, nil
+	}
+	tflog.Info(ctx, "Restart is required to apply mysqld options", map[string]interface{}{
+		resource.LogArgInstanceIP: instance.PublicIpAddress,
+		"options":                 strings.Join(static, ","),
+	})
+	return true, nil
+}
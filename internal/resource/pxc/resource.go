@@ -2,6 +2,8 @@ package pxc
 
 import (
 	"context"
+	"fmt"
+	"strings"
 
 	"github.com/hashicorp/terraform-plugin-log/tflog"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
@@ -15,9 +17,16 @@ import (
 )
 
 const (
-	schemaKeyGaleraPort = "galera_port"
+	schemaKeyGaleraPort            = "galera_port"
+	schemaKeyEncryptClusterTraffic = "encrypt_cluster_traffic"
 )
 
+// passwordKeys are the attributes which can be kept in the secret store.
+var passwordKeys = []string{resource.SchemaKeyRootPassword, resource.SchemaKeyPMMPassword, resource.SchemaKeyAutomationPassword}
+
+// secretKeys are kept in the secret store along with the passwords, they are needed only to change the cluster.
+var secretKeys = append(append([]string{}, passwordKeys...), resource.SchemaKeyCAPrivateKey)
+
 type PerconaXtraDBCluster struct {
 }
 
@@ -32,6 +41,12 @@ func (r *PerconaXtraDBCluster) Schema() map[string]*schema.Schema {
 			Optional: true,
 			Default:  4567,
 		},
+		// Traffic of new clusters is encrypted by default, the value of existing clusters is read from the config of their nodes
+		schemaKeyEncryptClusterTraffic: {
+			Type:     schema.TypeBool,
+			Optional: true,
+			Computed: true,
+		},
 		resource.SchemaKeyInstances: {
 			Type:     schema.TypeSet,
 			Computed: true,
@@ -45,6 +60,18 @@ func (r *PerconaXtraDBCluster) Schema() map[string]*schema.Schema {
 						Type:     schema.TypeString,
 						Computed: true,
 					},
+					schemaKeyInstancesClusterSize: {
+						Type:     schema.TypeInt,
+						Computed: true,
+					},
+					schemaKeyInstancesClusterStatus: {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					schemaKeyInstancesLocalState: {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
 				},
 			},
 		},
@@ -58,6 +85,32 @@ func (r *PerconaXtraDBCluster) Create(ctx context.Context, data *schema.Resource
 		return diag.FromErr(errors.Wrap(err, "can't configure cloud"))
 	}
 
+	defer resource.ClearSecrets(data, secretKeys...)
+	if err := resource.PullSecrets(ctx, data, c, secretKeys...); err != nil {
+		return diag.FromErr(errors.Wrap(err, "can't pull passwords"))
+	}
+	err = resource.GeneratePasswords(data, passwordKeys...)
+	if err != nil {
+		return diag.FromErr(errors.Wrap(err, "can't generate passwords"))
+	}
+	if err := resource.GenerateCA(data, resourceID); err != nil {
+		return diag.FromErr(errors.Wrap(err, "can't generate ca"))
+	}
+	if err := resource.PushSecrets(ctx, data, c, secretKeys...); err != nil {
+		return diag.FromErr(errors.Wrap(err, "can't push passwords"))
+	}
+	if raw := data.GetRawConfig(); raw.IsNull() || raw.GetAttr(schemaKeyEncryptClusterTraffic).IsNull() {
+		if err := data.Set(schemaKeyEncryptClusterTraffic, true); err != nil {
+			return diag.FromErr(errors.Wrap(err, "can't set cluster traffic encryption"))
+		}
+	}
+
+	// Scripts are read before the cluster is created, so that a missing file fails fast
+	initScripts, err := resource.InitScripts(data)
+	if err != nil {
+		return diag.FromErr(errors.Wrap(err, "can't read init sql"))
+	}
+
 	data.SetId(resourceID)
 	err = c.CreateInfrastructure(ctx, resourceID)
 	if err != nil {
@@ -65,21 +118,21 @@ func (r *PerconaXtraDBCluster) Create(ctx context.Context, data *schema.Resource
 	}
 
 	manager := newManager(c, resourceID, data)
+	manager.initScripts = initScripts
 	instances, err := manager.Create(ctx)
+	if err := data.Set(resource.SchemaKeyAutomationCIDR, manager.automationCIDR); err != nil {
+		return diag.FromErr(errors.Wrap(err, "can't set automation cidr"))
+	}
 	if err != nil {
 		return diag.FromErr(errors.Wrap(err, "can't create pxc cluster"))
 	}
 
-	set := data.Get(resource.SchemaKeyInstances).(*schema.Set)
-	for _, instance := range instances {
-		set.Add(map[string]interface{}{
-			resource.SchemaKeyInstancesPublicIP:  instance.PublicIpAddress,
-			resource.SchemaKeyInstancesPrivateIP: instance.PrivateIpAddress,
-		})
-	}
-	err = data.Set(resource.SchemaKeyInstances, set)
+	states, err := manager.instanceStates(ctx)
 	if err != nil {
-		return diag.FromErr(errors.Wrap(err, "can't set instances"))
+		return diag.FromErr(errors.Wrap(err, "failed to get instance states"))
+	}
+	if err := setInstances(data, states); err != nil {
+		return diag.FromErr(err)
 	}
 
 	args := make(map[string]interface{})
@@ -91,14 +144,211 @@ func (r *PerconaXtraDBCluster) Create(ctx context.Context, data *schema.Resource
 	return nil
 }
 
-func (r *PerconaXtraDBCluster) Read(_ context.Context, _ *schema.ResourceData, _ cloud.Cloud) diag.Diagnostics {
-	//TODO
+func setInstances(data *schema.ResourceData, states []instanceState) error {
+	instances := make([]interface{}, 0, len(states))
+	for _, state := range states {
+		instances = append(instances, map[string]interface{}{
+			resource.SchemaKeyInstancesPublicIP:  state.PublicIpAddress,
+			resource.SchemaKeyInstancesPrivateIP: state.PrivateIpAddress,
+			schemaKeyInstancesClusterSize:        state.clusterSize,
+			schemaKeyInstancesClusterStatus:      state.clusterStatus,
+			schemaKeyInstancesLocalState:         state.localState,
+		})
+	}
+	if err := data.Set(resource.SchemaKeyInstances, instances); err != nil {
+		return errors.Wrap(err, "can't set instances")
+	}
 	return nil
 }
 
-func (r *PerconaXtraDBCluster) Update(_ context.Context, _ *schema.ResourceData, _ cloud.Cloud) diag.Diagnostics {
-	//TODO
-	return nil
+func (r *PerconaXtraDBCluster) Read(ctx context.Context, data *schema.ResourceData, c cloud.Cloud) diag.Diagnostics {
+	resourceID := data.Id()
+	if resourceID == "" {
+		return diag.FromErr(errors.New("empty resource id"))
+	}
+
+	err := c.Configure(ctx, resourceID, data)
+	if err != nil {
+		return diag.FromErr(errors.Wrap(err, "can't configure cloud"))
+	}
+	defer resource.ClearSecrets(data, passwordKeys...)
+	if err := resource.PullSecrets(ctx, data, c, passwordKeys...); err != nil {
+		return diag.FromErr(errors.Wrap(err, "can't pull passwords"))
+	}
+
+	manager := newManager(c, resourceID, data)
+	states, err := manager.instanceStates(ctx)
+	if err != nil {
+		return diag.FromErr(errors.Wrap(err, "failed to get instance states"))
+	}
+	if len(states) == 0 {
+		tflog.Warn(ctx, "Percona XtraDB Cluster instances are not found, removing resource from state", map[string]interface{}{
+			"resource_id": resourceID,
+		})
+		data.SetId("")
+		return nil
+	}
+
+	// Terminated nodes show up as a cluster_size change in the plan.
+	if err := data.Set(resource.SchemaKeyClusterSize, len(states)); err != nil {
+		return diag.FromErr(errors.Wrap(err, "can't set cluster size"))
+	}
+	if err := resource.SetProviderCIDR(ctx, data, c, resourceID); err != nil {
+		return diag.FromErr(errors.Wrap(err, "can't set provider cidr"))
+	}
+	if err := manager.setTrafficEncryption(ctx, data, states); err != nil {
+		return diag.FromErr(errors.Wrap(err, "can't set cluster traffic encryption"))
+	}
+	if err := manager.setConfigFileHash(ctx, data, states); err != nil {
+		return diag.FromErr(errors.Wrap(err, "can't set config file hash"))
+	}
+	if err := setInstances(data, states); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return stateDiagnostics(states)
+}
+
+func stateDiagnostics(states []instanceState) diag.Diagnostics {
+	var diags diag.Diagnostics
+	for _, state := range states {
+		if state.isHealthy() {
+			continue
+		}
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "Percona XtraDB Cluster node is not a synced member of the primary component",
+			Detail: fmt.Sprintf("Node %s (%s): wsrep_cluster_status=%s, wsrep_local_state_comment=%s",
+				state.PublicIpAddress, state.PrivateIpAddress, state.clusterStatus, state.localState),
+		})
+	}
+	return diags
+}
+
+func (r *PerconaXtraDBCluster) Update(ctx context.Context, data *schema.ResourceData, c cloud.Cloud) diag.Diagnostics {
+	resourceID := data.Id()
+	if resourceID == "" {
+		return diag.FromErr(errors.New("empty resource id"))
+	}
+
+	err := c.Configure(ctx, resourceID, data)
+	if err != nil {
+		return diag.FromErr(errors.Wrap(err, "can't configure cloud"))
+	}
+
+	defer resource.ClearSecrets(data, secretKeys...)
+	if err := resource.PullSecrets(ctx, data, c, secretKeys...); err != nil {
+		return diag.FromErr(errors.Wrap(err, "can't pull passwords"))
+	}
+	required := []string{resource.SchemaKeyRootPassword}
+	if data.Get(resource.SchemaKeyPMMAddress).(string) != "" {
+		required = append(required, resource.SchemaKeyPMMPassword)
+	}
+	if err := resource.RequirePasswords(data, required...); err != nil {
+		return diag.FromErr(err)
+	}
+	previous, err := resource.PreviousPasswords(ctx, data, c, passwordKeys...)
+	if err != nil {
+		return diag.FromErr(errors.Wrap(err, "can't get previous passwords"))
+	}
+
+	manager := newManager(c, resourceID, data)
+	if oldCIDR, _ := data.GetChange(resource.SchemaKeyAutomationCIDR); oldCIDR.(string) == "" {
+		// The cluster was created before the automation user was introduced
+		if err := setupAutomationUser(ctx, manager, data); err != nil {
+			return resource.PartialError(data, err, "can't set up pxc cluster automation user")
+		}
+		previous[resource.SchemaKeyAutomationPassword] = manager.automationPassword
+	}
+	// The provider may be unable to connect from its new address before the automation user is renamed
+	if oldCIDR, _ := data.GetChange(resource.SchemaKeyAutomationCIDR); oldCIDR.(string) != "" && data.HasChange(resource.SchemaKeyAutomationCIDR) {
+		if err := manager.updateAutomationCIDR(ctx, oldCIDR.(string), previous[resource.SchemaKeyRootPassword]); err != nil {
+			return resource.PartialError(data, err, "can't change pxc cluster automation cidr")
+		}
+	}
+	if resource.PasswordsChanged(data, previous, passwordKeys...) {
+		if err := manager.rotatePasswords(ctx, previousCredentials(previous)); err != nil {
+			return resource.PartialError(data, err, "can't change pxc cluster passwords")
+		}
+	}
+	if err := resource.PushSecrets(ctx, data, c, secretKeys...); err != nil {
+		return diag.FromErr(errors.Wrap(err, "can't push passwords"))
+	}
+	if data.HasChange(resource.SchemaKeyUsers) {
+		if err := manager.applyUsers(ctx, resource.PreviousUsers(data)); err != nil {
+			return resource.PartialError(data, err, "can't apply pxc cluster users")
+		}
+	}
+	if data.HasChange(resource.SchemaKeyVersion) {
+		if err := manager.upgrade(ctx); err != nil {
+			return resource.PartialError(data, err, "can't upgrade pxc cluster")
+		}
+	}
+	// The file is pushed only if its content differs from the file on the instances
+	if data.HasChange(resource.SchemaKeyConfigFileHash) {
+		if err := manager.pushConfig(ctx); err != nil {
+			return resource.PartialError(data, err, "can't update pxc cluster config")
+		}
+	}
+	if data.HasChanges(resource.SchemaKeyMySQLDOptions, resource.SchemaKeyTuningProfile) {
+		oldProfile, oldOptions := resource.PreviousMySQLDOptions(data)
+		if err := manager.applyOptions(ctx, oldProfile, oldOptions); err != nil {
+			return resource.PartialError(data, err, "can't apply pxc mysqld options")
+		}
+	}
+	if data.HasChange(schemaKeyEncryptClusterTraffic) {
+		if err := manager.applyTrafficEncryption(ctx, data); err != nil {
+			return resource.PartialError(data, err, "can't change pxc cluster traffic encryption")
+		}
+		// The CA is generated along with the encryption for clusters created without it
+		if err := resource.PushSecrets(ctx, data, c, secretKeys...); err != nil {
+			return diag.FromErr(errors.Wrap(err, "can't push ca private key"))
+		}
+	}
+	if data.HasChange(resource.SchemaKeyClusterSize) {
+		if err := manager.resize(ctx); err != nil {
+			return resource.PartialError(data, err, "can't resize pxc cluster")
+		}
+	}
+
+	states, err := manager.instanceStates(ctx)
+	if err != nil {
+		return diag.FromErr(errors.Wrap(err, "failed to get instance states"))
+	}
+	if err := setInstances(data, states); err != nil {
+		return diag.FromErr(err)
+	}
+	members := make([]string, 0, len(states))
+	for _, state := range states {
+		members = append(members, state.PrivateIpAddress)
+	}
+	tflog.Info(ctx, "Percona XtraDB Cluster resource updated", map[string]interface{}{
+		"members": strings.Join(members, ","),
+	})
+	return stateDiagnostics(states)
+}
+
+func (r *PerconaXtraDBCluster) Import(ctx context.Context, data *schema.ResourceData, c cloud.Cloud) error {
+	resourceID := data.Id()
+	err := c.Configure(ctx, resourceID, data)
+	if err != nil {
+		return errors.Wrap(err, "can't configure cloud")
+	}
+	// The automation user is set up by the first apply, which is planned as automation_cidr is empty
+	return newManager(c, resourceID, data).inspect(ctx, data)
+}
+
+// setupAutomationUser creates the automation user in the cluster, which was created before it was introduced or imported.
+// Its password is generated if it's not set.
+func setupAutomationUser(ctx context.Context, manager *manager, data *schema.ResourceData) error {
+	if err := resource.GeneratePasswords(data, resource.SchemaKeyAutomationPassword); err != nil {
+		return errors.Wrap(err, "can't generate password")
+	}
+	manager.automationPassword = data.Get(resource.SchemaKeyAutomationPassword).(string)
+	if err := manager.setupAutomationUser(ctx); err != nil {
+		return err
+	}
+	return data.Set(resource.SchemaKeyAutomationCIDR, manager.automationCIDR)
 }
 
 func (r *PerconaXtraDBCluster) Delete(ctx context.Context, data *schema.ResourceData, c cloud.Cloud) diag.Diagnostics {
@@ -0,0 +1,269 @@
+package pxc
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+
+	"terraform-percona/internal/cloud"
+	"terraform-percona/internal/cloud/fake"
+	fakedb "terraform-percona/internal/db/mysql/fake"
+	"terraform-percona/internal/resource"
+	"terraform-percona/internal/resource/pxc/cmd"
+)
+
+// testCluster is a cluster of 3 synced nodes, which are served by the fake cloud and database.
+type testCluster struct {
+	cloud     *fake.Cloud
+	db        *fakedb.Server
+	instances []cloud.Instance
+	state     *terraform.InstanceState
+}
+
+func testConfig(port int) map[string]interface{} {
+	return map[string]interface{}{
+		resource.SchemaKeyInstanceType:       "t3.micro",
+		resource.SchemaKeyKeyPairName:        "key",
+		resource.SchemaKeyRootPassword:       "rootPassword",
+		resource.SchemaKeyAutomationPassword: "automationPassword",
+		resource.SchemaKeyAutomationCIDR:     "203.0.113.1/32",
+		resource.SchemaKeyVersion:            "8.0.33",
+		resource.SchemaKeyClusterSize:        3,
+		resource.SchemaKeyPort:               port,
+		resource.SchemaKeyMySQLDOptions:      map[string]interface{}{"max_connections": "200"},
+		schemaKeyEncryptClusterTraffic:       true,
+	}
+}
+
+func newTestCluster(t *testing.T, c *fake.Cloud) *testCluster {
+	t.Helper()
+	instances, err := c.CreateInstances(context.Background(), "test", 3, map[string]string{
+		resource.LabelKeyInstanceType: resource.LabelValueInstanceTypeMySQL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := fakedb.New(publicAddresses(instances)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(db.Close)
+	onSynced(db, 3)
+
+	data := schema.TestResourceDataRaw(t, new(PerconaXtraDBCluster).Schema(), testConfig(db.Port()))
+	if err := resource.GenerateCA(data, "test"); err != nil {
+		t.Fatal(err)
+	}
+	db.TLS(data.Get(resource.SchemaKeyCACertificate).(string), data.Get(resource.SchemaKeyCAPrivateKey).(string))
+	data.SetId("test")
+	return &testCluster{cloud: c, db: db, instances: instances, state: data.State()}
+}
+
+// update applies the changes of the config through the terraform resource and returns the new state.
+func (tc *testCluster) update(t *testing.T, changes map[string]interface{}) (*terraform.InstanceState, diag.Diagnostics) {
+	t.Helper()
+	r := resource.ResourcesMap(new(PerconaXtraDBCluster))["percona_pxc"]
+	config := testConfig(tc.db.Port())
+	for k, v := range changes {
+		config[k] = v
+	}
+	diff, err := r.Diff(context.Background(), tc.state, terraform.NewResourceConfigRaw(config), tc.cloud)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r.Apply(context.Background(), tc.state, diff, tc.cloud)
+}
+
+// TestUpdate applies each change of the config, failed changes are kept in the state with the previous value,
+// so that they are retried on the next apply.
+func TestUpdate(t *testing.T) {
+	tests := []struct {
+		name    string
+		changes map[string]interface{}
+		// key is the attribute which is checked in the new state
+		key       string
+		value     string
+		failQuery string
+		failCmd   string
+		// status is the wsrep status of the nodes in addition to the synced state
+		status  map[string]string
+		wantErr string
+		// check returns the unexpected effect of the update on the cluster
+		check func(tc *testCluster) string
+	}{
+		{
+			name:    "passwords",
+			changes: map[string]interface{}{resource.SchemaKeyRootPassword: "newRootPassword"},
+			key:     resource.SchemaKeyRootPassword,
+			value:   "newRootPassword",
+			check: func(tc *testCluster) string {
+				for _, query := range tc.db.Queries() {
+					if strings.Contains(query.SQL, "ALTER USER IF EXISTS 'root'@'localhost'") {
+						return ""
+					}
+				}
+				return "root password isn't changed"
+			},
+		},
+		{
+			name:      "passwords failure",
+			changes:   map[string]interface{}{resource.SchemaKeyRootPassword: "newRootPassword"},
+			key:       resource.SchemaKeyRootPassword,
+			value:     "rootPassword",
+			failQuery: "ALTER USER IF EXISTS 'root'",
+			wantErr:   "can't change pxc cluster passwords",
+		},
+		{
+			name:    "upgrade",
+			changes: map[string]interface{}{resource.SchemaKeyVersion: "8.0.33"},
+			key:     resource.SchemaKeyVersion,
+			value:   "8.0.33",
+			// Nodes rejoin with IST, their gcache starts right after the seqno of the shutdown
+			status: map[string]string{"wsrep_last_committed": "120", "wsrep_local_cached_downto": "101"},
+			check: func(tc *testCluster) string {
+				var upgraded []string
+				for _, call := range tc.cloud.Calls() {
+					if call.Arg == cmd.UpgradePerconaXtraDBCluster("8.0.33-25-1.focal") {
+						upgraded = append(upgraded, call.Instance.PrivateIpAddress)
+					}
+				}
+				if len(upgraded) != len(tc.instances) {
+					return "upgraded nodes " + strings.Join(upgraded, ",") + ", want all nodes"
+				}
+				return ""
+			},
+		},
+		{
+			name:    "upgrade with SST",
+			changes: map[string]interface{}{resource.SchemaKeyVersion: "8.0.33"},
+			key:     resource.SchemaKeyVersion,
+			value:   "8.0.32",
+			// The gcache of the node is reset by SST, it starts after the seqno of the snapshot
+			status:  map[string]string{"wsrep_last_committed": "120", "wsrep_local_cached_downto": "111"},
+			wantErr: "can't upgrade pxc cluster",
+			check: func(tc *testCluster) string {
+				var upgraded int
+				for _, call := range tc.cloud.Calls() {
+					if call.Arg == cmd.UpgradePerconaXtraDBCluster("8.0.33-25-1.focal") {
+						upgraded++
+					}
+				}
+				// The upgraded node is kept, the next apply continues with the next node
+				if upgraded != 1 {
+					return "upgrade doesn't stop after the first node which received SST, " + strconv.Itoa(upgraded) + " nodes are upgraded"
+				}
+				return ""
+			},
+		},
+		{
+			name:    "upgrade failure",
+			changes: map[string]interface{}{resource.SchemaKeyVersion: "8.0.33"},
+			key:     resource.SchemaKeyVersion,
+			value:   "8.0.32",
+			failCmd: "percona-xtradb-cluster-server=",
+			wantErr: "can't upgrade pxc cluster",
+			check: func(tc *testCluster) string {
+				// Nodes are upgraded one by one, the rest of them aren't stopped after the failure
+				for _, instance := range tc.instances[1:] {
+					for _, command := range tc.cloud.Commands(instance) {
+						if command == cmd.Stop(false) {
+							return "node " + instance.PrivateIpAddress + " is stopped after the failure of the first node"
+						}
+					}
+				}
+				return ""
+			},
+		},
+		{
+			name:    "options",
+			changes: map[string]interface{}{resource.SchemaKeyMySQLDOptions: map[string]interface{}{"max_connections": "300"}},
+			key:     resource.SchemaKeyMySQLDOptions + ".max_connections",
+			value:   "300",
+			check: func(tc *testCluster) string {
+				for _, instance := range tc.instances {
+					if !strings.Contains(strings.Join(tc.db.HostQueries(instance.PublicIpAddress), "\n"), "max_connections") {
+						return "max_connections isn't set on node " + instance.PrivateIpAddress
+					}
+				}
+				return ""
+			},
+		},
+		{
+			name:      "options failure",
+			changes:   map[string]interface{}{resource.SchemaKeyMySQLDOptions: map[string]interface{}{"max_connections": "300"}},
+			key:       resource.SchemaKeyMySQLDOptions + ".max_connections",
+			value:     "200",
+			failQuery: "max_connections",
+			wantErr:   "can't apply pxc mysqld options",
+		},
+		{
+			name:    "resize",
+			changes: map[string]interface{}{resource.SchemaKeyClusterSize: 2},
+			key:     resource.SchemaKeyClusterSize,
+			value:   "2",
+			check: func(tc *testCluster) string {
+				if instances, _ := tc.cloud.ListInstances(context.Background(), "test", nil); len(instances) != 2 {
+					return "the last node should be removed"
+				}
+				return ""
+			},
+		},
+		{
+			name:    "resize failure",
+			changes: map[string]interface{}{resource.SchemaKeyClusterSize: 2},
+			key:     resource.SchemaKeyClusterSize,
+			value:   "3",
+			failCmd: cmd.Stop(false),
+			wantErr: "can't resize pxc cluster",
+			check: func(tc *testCluster) string {
+				if instances, _ := tc.cloud.ListInstances(context.Background(), "test", nil); len(instances) != 3 {
+					return "node is deleted although it isn't shut down"
+				}
+				return ""
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.New()
+			c.OnCommand(cmd.InstalledVersion(), "8.0.32-24-1.focal")
+			c.OnCommand(cmd.GrastateSeqno(), "100")
+			if tt.failCmd != "" {
+				c.FailOn(tt.failCmd, errInjected)
+			}
+			c.OnCommand("apt-cache show", "8.0.33-25-1.focal\n8.0.32-24-1.focal")
+			tc := newTestCluster(t, c)
+			for name, value := range tt.status {
+				onStatus(tc.db, name, value)
+			}
+			if tt.failQuery != "" {
+				tc.db.FailOn(tt.failQuery, 1045, "Access denied")
+			}
+			if tt.changes[resource.SchemaKeyVersion] != nil {
+				// The version of the state is the installed one
+				tc.state.Attributes[resource.SchemaKeyVersion] = "8.0.32"
+			}
+
+			state, diags := tc.update(t, tt.changes)
+			if tt.wantErr == "" && diags.HasError() {
+				t.Fatalf("update diagnostics = %v", diags)
+			}
+			if tt.wantErr != "" && (!diags.HasError() || !strings.Contains(diags[0].Summary, tt.wantErr)) {
+				t.Fatalf("update diagnostics = %v, want error containing %q", diags, tt.wantErr)
+			}
+			if got := state.Attributes[tt.key]; got != tt.value {
+				t.Errorf("%s = %q in the new state, want %q", tt.key, got, tt.value)
+			}
+			if tt.check != nil {
+				if msg := tt.check(tc); msg != "" {
+					t.Error(msg)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,263 @@
+package pxc
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/pkg/errors"
+
+	"terraform-percona/internal/cloud"
+	"terraform-percona/internal/resource"
+	"terraform-percona/internal/resource/pxc/cmd"
+)
+
+const (
+	stateWaitTimeout  = time.Minute * 30
+	stateWaitInterval = time.Second * 10
+)
+
+// resize adds or removes nodes until the cluster has m.size members.
+func (m *manager) resize(ctx context.Context) error {
+	if m.size < 1 {
+		return errors.Errorf("%s should be 1 or more", resource.SchemaKeyClusterSize)
+	}
+	states, err := m.instanceStates(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get instance states")
+	}
+	switch {
+	case m.size > len(states):
+		return m.scaleOut(ctx, states, m.size-len(states))
+	case m.size < len(states):
+		return m.scaleIn(ctx, states, len(states)-m.size)
+	}
+	return nil
+}
+
+func (m *manager) scaleOut(ctx context.Context, states []instanceState, count int) error {
+	var donor *instanceState
+	for i := range states {
+		if states[i].isHealthy() {
+			donor = &states[i]
+			break
+		}
+	}
+	if donor == nil {
+		return errors.New("cluster has no synced node in the primary component, new nodes can't join it")
+	}
+	version, err := m.runCommand(ctx, donor.Instance, cmd.InstalledVersion())
+	if err != nil {
+		return errors.Wrap(err, "failed to get installed version")
+	}
+	// New nodes should run exactly the same version as the rest of the cluster
+	m.version = strings.TrimSpace(version)
+
+	if err := m.cloud.CreateInfrastructure(ctx, m.resourceID); err != nil {
+		return errors.Wrap(err, "can't create cloud infrastructure")
+	}
+	tflog.Info(ctx, "Creating instances", map[string]interface{}{
+		"count": count,
+	})
+	instances, err := m.cloud.CreateInstances(ctx, m.resourceID, int64(count), map[string]string{
+		resource.LabelKeyInstanceType: resource.LabelValueInstanceTypeMySQL,
+	})
+	if err != nil {
+		return errors.Wrap(err, "create instances")
+	}
+
+	members := make([]cloud.Instance, 0, len(states)+len(instances))
+	for _, state := range states {
+		members = append(members, state.Instance)
+	}
+	members = append(members, instances...)
+	hosts := clusterHosts(members, m.galeraPort)
+
+	tflog.Info(ctx, "Configuring instances")
+	if err := m.installInstances(ctx, instances, hosts); err != nil {
+		return errors.Wrap(err, "configure instances")
+	}
+
+	// Nodes join one by one, so that each state transfer has a synced donor
+	tflog.Info(ctx, "Starting instances")
+	for _, instance := range instances {
+		if _, err := m.runCommand(ctx, instance, cmd.Start(false)); err != nil {
+			return errors.Wrap(err, "run command pxc start")
+		}
+		if err := m.waitForState(ctx, instance, instanceState.isHealthy); err != nil {
+			return errors.Wrapf(err, "node %s failed to join the cluster", instance.PrivateIpAddress)
+		}
+		if err := m.setupPMM(ctx, instance); err != nil {
+			return err
+		}
+	}
+
+	if err := m.setClusterAddress(ctx, states, hosts); err != nil {
+		return err
+	}
+
+	// The first node of a single node cluster is still running with the bootstrap service,
+	// it should be restarted as a regular member now that there are other nodes to join
+	if len(states) == 1 {
+		if _, err := m.runCommand(ctx, states[0].Instance, cmd.Stop(true)); err != nil {
+			return errors.Wrap(err, "run command bootstrap stop")
+		}
+		if _, err := m.runCommand(ctx, states[0].Instance, cmd.Start(false)); err != nil {
+			return errors.Wrap(err, "run command first node pxc start")
+		}
+		if err := m.waitForState(ctx, states[0].Instance, instanceState.isHealthy); err != nil {
+			return errors.Wrapf(err, "node %s failed to rejoin the cluster", states[0].PrivateIpAddress)
+		}
+	}
+	return nil
+}
+
+func (m *manager) scaleIn(ctx context.Context, states []instanceState, count int) error {
+	removed, remaining := nodesToRemove(states, count)
+	if err := checkQuorum(states, remaining); err != nil {
+		return errors.Wrap(err, "refusing to shrink the cluster")
+	}
+
+	// Nodes are shut down gracefully one by one, so that the primary component
+	// recalculates its quorum after each of them leaves
+	for _, state := range removed {
+		if err := m.shutdownNode(ctx, state); err != nil {
+			return errors.Wrapf(err, "failed to shut down node %s", state.PrivateIpAddress)
+		}
+		if err := m.waitForState(ctx, remaining[0].Instance, func(s instanceState) bool {
+			return s.clusterStatus == clusterStatusPrimary
+		}); err != nil {
+			return errors.Wrapf(err, "cluster lost primary component after node %s left", state.PrivateIpAddress)
+		}
+	}
+
+	instances := make([]cloud.Instance, 0, len(removed))
+	for _, state := range removed {
+		instances = append(instances, state.Instance)
+	}
+	tflog.Info(ctx, "Deleting instances", map[string]interface{}{
+		"count": len(instances),
+	})
+	if err := m.cloud.DeleteInstances(ctx, m.resourceID, instances); err != nil {
+		return errors.Wrap(err, "delete instances")
+	}
+
+	members := make([]cloud.Instance, 0, len(remaining))
+	for _, state := range remaining {
+		members = append(members, state.Instance)
+	}
+	return m.setClusterAddress(ctx, remaining, clusterHosts(members, m.galeraPort))
+}
+
+// nodesToRemove selects unhealthy nodes first, then the most recently listed ones.
+func nodesToRemove(states []instanceState, count int) (removed []instanceState, remaining []instanceState) {
+	selected := make([]bool, len(states))
+	n := 0
+	for i, state := range states {
+		if n == count {
+			break
+		}
+		if !state.isHealthy() {
+			selected[i] = true
+			n++
+		}
+	}
+	for i := len(states) - 1; i >= 0 && n < count; i-- {
+		if !selected[i] {
+			selected[i] = true
+			n++
+		}
+	}
+	for i, state := range states {
+		if selected[i] {
+			removed = append(removed, state)
+		} else {
+			remaining = append(remaining, state)
+		}
+	}
+	return removed, remaining
+}
+
+// checkQuorum verifies that the remaining nodes are a majority of the current primary component.
+// The shutdown of the removed nodes may be not graceful, so that they are counted as members until they leave.
+func checkQuorum(states, remaining []instanceState) error {
+	if len(remaining) == 0 {
+		return errors.New("at least one node should remain in the cluster")
+	}
+	for _, state := range remaining {
+		if !state.isHealthy() {
+			return errors.Errorf("remaining node %s is not a synced member of the primary component: wsrep_cluster_status=%s, wsrep_local_state_comment=%s",
+				state.PrivateIpAddress, state.clusterStatus, state.localState)
+		}
+	}
+	componentSize := 0
+	for _, state := range states {
+		if state.isHealthy() && state.clusterSize > componentSize {
+			componentSize = state.clusterSize
+		}
+	}
+	if len(remaining)*2 <= componentSize {
+		return errors.Errorf("%d remaining nodes are not a majority of the primary component of %d nodes", len(remaining), componentSize)
+	}
+	return nil
+}
+
+func (m *manager) shutdownNode(ctx context.Context, state instanceState) error {
+	if state.clusterStatus == stateUnknown {
+		tflog.Warn(ctx, "node is unreachable, it will be deleted without shutdown", map[string]interface{}{
+			resource.LogArgInstanceIP: state.PublicIpAddress,
+		})
+		return nil
+	}
+	if m.pmmAddress != "" {
+		if _, err := m.runCommand(ctx, state.Instance, cmd.RemoveFromPMM()); err != nil {
+			return errors.Wrap(err, "remove from pmm")
+		}
+	}
+	// The node can still be running with the bootstrap service
+	if _, err := m.runCommand(ctx, state.Instance, cmd.Stop(true)); err != nil {
+		return errors.Wrap(err, "run command bootstrap stop")
+	}
+	if _, err := m.runCommand(ctx, state.Instance, cmd.Stop(false)); err != nil {
+		return errors.Wrap(err, "run command pxc stop")
+	}
+	return nil
+}
+
+// setClusterAddress updates wsrep_cluster_address in the config of the running nodes,
+// running nodes learn about membership changes from the group communication itself.
+func (m *manager) setClusterAddress(ctx context.Context, states []instanceState, hosts []string) error {
+	for _, state := range states {
+		if state.clusterStatus == stateUnknown {
+			tflog.Warn(ctx, "node is unreachable, skipping wsrep_cluster_address update", map[string]interface{}{
+				resource.LogArgInstanceIP: state.PublicIpAddress,
+			})
+			continue
+		}
+		if err := m.editDefaultCfg(ctx, state.Instance, "mysqld", map[string]string{
+			"wsrep_cluster_address": "gcomm://" + strings.Join(hosts, ","),
+		}); err != nil {
+			return errors.Wrapf(err, "failed to update wsrep_cluster_address on node %s", state.PrivateIpAddress)
+		}
+	}
+	return nil
+}
+
+func (m *manager) waitForState(ctx context.Context, instance cloud.Instance, ok func(instanceState) bool) error {
+	ctx, cancel := context.WithTimeout(ctx, stateWaitTimeout)
+	defer cancel()
+	ticker := time.NewTicker(stateWaitInterval)
+	defer ticker.Stop()
+	for {
+		state := m.instanceState(ctx, instance)
+		if ok(state) {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return errors.Errorf("timeout waiting for node state: wsrep_cluster_status=%s, wsrep_local_state_comment=%s", state.clusterStatus, state.localState)
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package pxc
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"terraform-percona/internal/cloud"
+	"terraform-percona/internal/cloud/fake"
+	"terraform-percona/internal/resource"
+	"terraform-percona/internal/resource/pxc/cmd"
+)
+
+func privateAddresses(states []instanceState) string {
+	addresses := make([]string, 0, len(states))
+	for _, state := range states {
+		addresses = append(addresses, state.PrivateIpAddress)
+	}
+	return strings.Join(addresses, ",")
+}
+
+func TestCheckQuorum(t *testing.T) {
+	node := func(n string, clusterSize int, clusterStatus, localState string) instanceState {
+		return instanceState{
+			Instance:      cloud.Instance{PrivateIpAddress: "10.0.0." + n},
+			clusterSize:   clusterSize,
+			clusterStatus: clusterStatus,
+			localState:    localState,
+		}
+	}
+	synced := func(n string, clusterSize int) instanceState {
+		return node(n, clusterSize, clusterStatusPrimary, localStateSynced)
+	}
+	tests := []struct {
+		name        string
+		states      []instanceState
+		count       int
+		wantRemoved string
+		wantErr     string
+	}{
+		{
+			name:        "3 to 1",
+			states:      []instanceState{synced("1", 3), synced("2", 3), synced("3", 3)},
+			count:       2,
+			wantRemoved: "10.0.0.2,10.0.0.3",
+			wantErr:     "1 remaining nodes are not a majority of the primary component of 3 nodes",
+		},
+		{
+			name:        "2 to 1",
+			states:      []instanceState{synced("1", 2), synced("2", 2)},
+			count:       1,
+			wantRemoved: "10.0.0.2",
+			wantErr:     "1 remaining nodes are not a majority of the primary component of 2 nodes",
+		},
+		{
+			name:        "5 to 3",
+			states:      []instanceState{synced("1", 5), synced("2", 5), synced("3", 5), synced("4", 5), synced("5", 5)},
+			count:       2,
+			wantRemoved: "10.0.0.4,10.0.0.5",
+		},
+		{
+			name:        "3 to 2",
+			states:      []instanceState{synced("1", 3), synced("2", 3), synced("3", 3)},
+			count:       1,
+			wantRemoved: "10.0.0.3",
+		},
+		{
+			name:        "unhealthy nodes first",
+			states:      []instanceState{synced("1", 3), node("2", 0, stateUnknown, stateUnknown), synced("3", 3), synced("4", 3)},
+			count:       1,
+			wantRemoved: "10.0.0.2",
+		},
+		{
+			name:        "non-Primary component",
+			states:      []instanceState{node("1", 1, "non-Primary", "Initialized"), node("2", 1, "non-Primary", "Initialized"), node("3", 1, "non-Primary", "Initialized")},
+			count:       1,
+			wantRemoved: "10.0.0.1",
+			wantErr:     "remaining node 10.0.0.2 is not a synced member of the primary component",
+		},
+		{
+			name:        "all nodes",
+			states:      []instanceState{synced("1", 1)},
+			count:       1,
+			wantRemoved: "10.0.0.1",
+			wantErr:     "at least one node should remain in the cluster",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			removed, remaining := nodesToRemove(tt.states, tt.count)
+			if got := privateAddresses(removed); got != tt.wantRemoved {
+				t.Errorf("nodesToRemove() = %s, want %s", got, tt.wantRemoved)
+			}
+			if len(removed)+len(remaining) != len(tt.states) {
+				t.Errorf("nodesToRemove() returned %d removed and %d remaining nodes of %d", len(removed), len(remaining), len(tt.states))
+			}
+			err := checkQuorum(tt.states, remaining)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("checkQuorum() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("checkQuorum() error = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestScaleOut starts the new nodes one by one, the first node leaves the bootstrap service once the others have joined.
+// The scale-out stops at the first node which fails to join, the existing node keeps its config then.
+func TestScaleOut(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   string
+		unsynced bool
+		failOn   string
+		wantErr  string
+		// wantStarted is the order of the joins, the nodes are numbered in the order of creation
+		wantStarted []string
+		wantAddress bool
+	}{
+		{
+			name:        "join",
+			status:      clusterStatusPrimary,
+			wantStarted: []string{"start 1", "start 2", "stop bootstrap 0", "start 0"},
+			wantAddress: true,
+		},
+		{
+			name:        "join failure",
+			status:      clusterStatusPrimary,
+			unsynced:    true,
+			wantErr:     "failed to join the cluster",
+			wantStarted: []string{"start 1", "start 2"},
+		},
+		{
+			name:        "bootstrap stop failure",
+			status:      clusterStatusPrimary,
+			failOn:      cmd.Stop(true),
+			wantErr:     "run command bootstrap stop",
+			wantStarted: []string{"start 1", "start 2", "stop bootstrap 0"},
+			wantAddress: true,
+		},
+		{
+			name:    "non-Primary component",
+			status:  "non-Primary",
+			wantErr: "cluster has no synced node",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.New()
+			if tt.failOn != "" {
+				c.FailOn(tt.failOn, errInjected)
+			}
+			m := newTestManager(t, c, map[string]interface{}{
+				resource.SchemaKeyClusterSize: 3,
+			})
+			existing, err := c.CreateInstances(context.Background(), "test", 1, map[string]string{
+				resource.LabelKeyInstanceType: resource.LabelValueInstanceTypeMySQL,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// New nodes report their state as soon as they are started
+			next := c.NextAddresses(2)
+			db := newTestServer(t, m, append(publicAddresses(existing), next...)...)
+			if tt.unsynced {
+				db.OnHostQuery(next[1], "'"+schemaKeyInstancesLocalState+"'", []string{"Variable_name", "Value"},
+					[]string{schemaKeyInstancesLocalState, "Joining: receiving State Transfer"})
+			}
+			onStatus(db, schemaKeyInstancesClusterSize, "1")
+			onStatus(db, schemaKeyInstancesClusterStatus, tt.status)
+			onStatus(db, schemaKeyInstancesLocalState, localStateSynced)
+
+			// The unsynced node is waited for until the deadline
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+			err = m.resize(ctx)
+			if tt.wantErr == "" && err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("resize() error = %v, want error containing %q", err, tt.wantErr)
+			}
+
+			instances, _ := c.ListInstances(context.Background(), "test", nil)
+			if tt.wantStarted == nil && len(instances) != 1 {
+				t.Errorf("%d nodes exist after the refused scale-out, want 1", len(instances))
+			}
+			number := make(map[string]string, len(instances))
+			for i, instance := range instances {
+				number[instance.PrivateIpAddress] = strconv.Itoa(i)
+			}
+			// Nodes are started by the installation too, the joins follow the config of the last new node
+			var started []string
+			for _, call := range c.Calls() {
+				switch {
+				case call.Method == fake.MethodEditFile && call.Instance != existing[0]:
+					started = nil
+				case call.Arg == cmd.Stop(true):
+					started = append(started, "stop bootstrap "+number[call.Instance.PrivateIpAddress])
+				case call.Arg == cmd.Start(false):
+					started = append(started, "start "+number[call.Instance.PrivateIpAddress])
+				}
+			}
+			if strings.Join(started, ", ") != strings.Join(tt.wantStarted, ", ") {
+				t.Errorf("nodes started in order %v, want %v", started, tt.wantStarted)
+			}
+
+			cfg, _ := c.File(existing[0], defaultMysqlConfigPath)
+			address := "wsrep_cluster_address = gcomm://" + strings.Join(clusterHosts(instances, m.galeraPort), ",")
+			if updated := len(instances) > 1 && strings.Contains(cfg, address); updated != tt.wantAddress {
+				t.Errorf("wsrep_cluster_address of node %s is updated: %v, want %v, config:\n%s", existing[0].PrivateIpAddress, updated, tt.wantAddress, cfg)
+			}
+		})
+	}
+}
+
+// TestScaleIn shuts down the removed nodes, the remaining ones keep only each other in wsrep_cluster_address.
+func TestScaleIn(t *testing.T) {
+	c := fake.New()
+	m := newTestManager(t, c, map[string]interface{}{
+		resource.SchemaKeyClusterSize: 3,
+	})
+	instances, err := c.CreateInstances(context.Background(), "test", 5, map[string]string{
+		resource.LabelKeyInstanceType: resource.LabelValueInstanceTypeMySQL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	onSynced(newTestServer(t, m, publicAddresses(instances)...), 5)
+
+	if err := m.resize(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	remaining, _ := c.ListInstances(context.Background(), "test", nil)
+	if len(remaining) != 3 || remaining[2] != instances[2] {
+		t.Fatalf("remaining nodes = %v, want the first 3 nodes", remaining)
+	}
+	for _, instance := range instances[3:] {
+		if commands := strings.Join(c.Commands(instance), "\n"); !strings.Contains(commands, cmd.Stop(false)) {
+			t.Errorf("node %s is deleted without shutdown, commands: %q", instance.PrivateIpAddress, commands)
+		}
+	}
+	address := "wsrep_cluster_address = gcomm://" + strings.Join(clusterHosts(remaining, m.galeraPort), ",")
+	for _, instance := range remaining {
+		if cfg, _ := c.File(instance, defaultMysqlConfigPath); !strings.Contains(cfg, address) {
+			t.Errorf("config of node %s doesn't contain %q:\n%s", instance.PrivateIpAddress, address, cfg)
+		}
+	}
+}
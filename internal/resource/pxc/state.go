@@ -0,0 +1,117 @@
+package pxc
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/pkg/errors"
+
+	"terraform-percona/internal/cloud"
+	"terraform-percona/internal/resource"
+)
+
+const (
+	schemaKeyInstancesClusterSize   = "wsrep_cluster_size"
+	schemaKeyInstancesClusterStatus = "wsrep_cluster_status"
+	schemaKeyInstancesLocalState    = "wsrep_local_state_comment"
+)
+
+const (
+	clusterStatusPrimary = "Primary"
+	localStateSynced     = "Synced"
+	stateUnknown         = "Unknown"
+)
+
+const instanceStateTimeout = time.Second * 30
+
+type instanceState struct {
+	cloud.Instance
+
+	clusterSize   int
+	clusterStatus string
+	localState    string
+}
+
+func (s instanceState) isHealthy() bool {
+	return s.clusterStatus == clusterStatusPrimary && s.localState == localStateSynced
+}
+
+func syncedNode(states []instanceState) (instanceState, bool) {
+	for _, state := range states {
+		if state.isHealthy() {
+			return state, true
+		}
+	}
+	return instanceState{}, false
+}
+
+func (m *manager) instanceStates(ctx context.Context) ([]instanceState, error) {
+	instances, err := m.cloud.ListInstances(ctx, m.resourceID, map[string]string{
+		resource.LabelKeyInstanceType: resource.LabelValueInstanceTypeMySQL,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list instances")
+	}
+	states := make([]instanceState, len(instances))
+	wg := new(sync.WaitGroup)
+	for i, instance := range instances {
+		i, instance := i, instance
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			states[i] = m.instanceState(ctx, instance)
+		}()
+	}
+	wg.Wait()
+	return states, nil
+}
+
+func (m *manager) instanceState(ctx context.Context, instance cloud.Instance) instanceState {
+	state := instanceState{
+		Instance:      instance,
+		clusterStatus: stateUnknown,
+		localState:    stateUnknown,
+	}
+	if instance.PublicIpAddress == "" {
+		return state
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, instanceStateTimeout)
+	defer cancel()
+
+	db, err := m.connect(ctx, instance)
+	if err != nil {
+		tflog.Warn(ctx, "failed to establish sql connection", map[string]interface{}{
+			resource.LogArgInstanceIP: instance.PublicIpAddress,
+			"error":                   err,
+		})
+		return state
+	}
+	defer db.Close()
+
+	status := make(map[string]string, 3)
+	for _, name := range []string{schemaKeyInstancesClusterSize, schemaKeyInstancesClusterStatus, schemaKeyInstancesLocalState} {
+		value, err := db.Status(ctx, name)
+		if err != nil {
+			tflog.Warn(ctx, "failed to get wsrep status", map[string]interface{}{
+				resource.LogArgInstanceIP: instance.PublicIpAddress,
+				"error":                   err,
+			})
+			return state
+		}
+		status[name] = value
+	}
+	state.clusterSize, err = strconv.Atoi(status[schemaKeyInstancesClusterSize])
+	if err != nil {
+		tflog.Warn(ctx, "failed to parse wsrep_cluster_size", map[string]interface{}{
+			resource.LogArgInstanceIP: instance.PublicIpAddress,
+			"error":                   err,
+		})
+	}
+	state.clusterStatus = status[schemaKeyInstancesClusterStatus]
+	state.localState = status[schemaKeyInstancesLocalState]
+	return state
+}
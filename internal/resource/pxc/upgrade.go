@@ -0,0 +1,263 @@
+package pxc
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/pkg/errors"
+
+	"terraform-percona/internal/resource"
+	"terraform-percona/internal/resource/pxc/cmd"
+	"terraform-percona/internal/utils"
+)
+
+// upgrade performs a rolling upgrade of the cluster to m.version.
+// Nodes are upgraded one by one, each of them should rejoin the cluster before the next one is stopped.
+// The repository of the installed version is enabled again if the upgrade is refused before any node is upgraded.
+func (m *manager) upgrade(ctx context.Context) error {
+	// Unset version means the version installed on creation
+	if m.version == "" {
+		return nil
+	}
+	if err := resource.CheckReleaseSeries(m.series, m.version); err != nil {
+		return err
+	}
+	states, err := m.instanceStates(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get instance states")
+	}
+	if len(states) == 0 {
+		return errors.New("no instances found")
+	}
+	for _, state := range states {
+		if !state.isHealthy() {
+			return errors.Errorf("node %s is not a synced member of the primary component: wsrep_cluster_status=%s, wsrep_local_state_comment=%s, all nodes should be synced before upgrade",
+				state.PrivateIpAddress, state.clusterStatus, state.localState)
+		}
+	}
+
+	installed := make([]string, len(states))
+	for i, state := range states {
+		if installed[i], err = m.installedVersion(ctx, state); err != nil {
+			return err
+		}
+	}
+	repository := m.repository()
+	version, err := m.checkUpgrade(ctx, states, installed, repository)
+	if err != nil {
+		// Nothing is upgraded yet, so that the nodes keep the packages of their installed series
+		m.restoreRepositories(ctx, states, installed, repository)
+		return err
+	}
+
+	// The only node of the cluster is running with the bootstrap service
+	bootstrap := len(states) == 1
+	for _, state := range states {
+		if err := m.upgradeNode(ctx, state, version, repository, bootstrap); err != nil {
+			return errors.Wrapf(err, "failed to upgrade node %s", state.PrivateIpAddress)
+		}
+	}
+	return nil
+}
+
+// checkUpgrade returns the full target version and checks that every node can be upgraded to it.
+// The repository of the target series is enabled on the first node to list the versions and on the nodes which are
+// checked for a major upgrade, as the upgrade checker of the target version is installed from it.
+func (m *manager) checkUpgrade(ctx context.Context, states []instanceState, installed []string, repository string) (string, error) {
+	if _, err := m.runCommand(ctx, states[0].Instance, cmd.UpdateRepository(repository)); err != nil {
+		return "", errors.Wrap(err, "update repository")
+	}
+	availableVersions, err := m.versionList(ctx, states[0].Instance)
+	if err != nil {
+		return "", errors.Wrap(err, "retrieve versions")
+	}
+	version := utils.SelectVersion(availableVersions, m.version)
+	if version == "" {
+		return "", errors.Errorf("version not found, available versions: %v", availableVersions)
+	}
+	for i, state := range states {
+		if err := resource.CheckUpgradePath(installed[i], version); err != nil {
+			return "", errors.Wrapf(err, "node %s", state.PrivateIpAddress)
+		}
+		if !resource.IsMajorUpgrade(installed[i], version) {
+			continue
+		}
+		if err := m.checkForServerUpgrade(ctx, state, version, repository); err != nil {
+			return "", errors.Wrapf(err, "upgrade check failed on node %s", state.PrivateIpAddress)
+		}
+	}
+	return version, nil
+}
+
+// restoreRepositories enables the repository of the installed version on the nodes again after the upgrade is refused.
+// Failures are only logged, as the error of the upgrade is returned.
+func (m *manager) restoreRepositories(ctx context.Context, states []instanceState, installed []string, repository string) {
+	for i, state := range states {
+		original := resource.ReleaseRepository("pxc", resource.ReleaseSeries(installed[i]), installed[i])
+		if original == repository {
+			continue
+		}
+		if _, err := m.runCommand(ctx, state.Instance, cmd.UpdateRepository(original)); err != nil {
+			tflog.Warn(ctx, "failed to restore the repository", map[string]interface{}{
+				resource.LogArgInstanceIP: state.PublicIpAddress,
+				"repository":              original,
+				"error":                   err,
+			})
+		}
+	}
+}
+
+func (m *manager) installedVersion(ctx context.Context, state instanceState) (string, error) {
+	out, err := m.runCommand(ctx, state.Instance, cmd.InstalledVersion())
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get installed version")
+	}
+	version := strings.TrimSpace(out)
+	if version == "" {
+		return "", errors.Errorf("percona xtradb cluster is not installed on node %s", state.PrivateIpAddress)
+	}
+	return version, nil
+}
+
+func (m *manager) checkForServerUpgrade(ctx context.Context, state instanceState, version, repository string) error {
+	tflog.Info(ctx, "Checking for server upgrade", map[string]interface{}{
+		resource.LogArgVersion:    version,
+		resource.LogArgInstanceIP: state.PublicIpAddress,
+	})
+	if _, err := m.runCommand(ctx, state.Instance, cmd.UpdateRepository(repository)); err != nil {
+		return errors.Wrap(err, "update repository")
+	}
+	if _, err := m.runCommand(ctx, state.Instance, cmd.InstallMySQLShell()); err != nil {
+		return errors.Wrap(err, "install mysql shell")
+	}
+	out, err := m.runCommand(ctx, state.Instance, cmd.CheckForServerUpgrade(m.password, strings.Split(version, "-")[0]))
+	if err != nil {
+		return errors.Wrap(err, "run upgrade checker")
+	}
+	problems, err := resource.UpgradeCheckErrors(out)
+	if err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return errors.Errorf("blocking issues found:\n%s", strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+func (m *manager) upgradeNode(ctx context.Context, state instanceState, version, repository string, bootstrap bool) error {
+	installed, err := m.installedVersion(ctx, state)
+	if err != nil {
+		return err
+	}
+	if installed == version {
+		return nil
+	}
+
+	if err := m.waitForState(ctx, state.Instance, instanceState.isHealthy); err != nil {
+		return errors.Wrap(err, "node is not synced")
+	}
+
+	tflog.Info(ctx, "Upgrading Percona XtraDB Cluster", map[string]interface{}{
+		resource.LogArgVersion:    version,
+		resource.LogArgInstanceIP: state.PublicIpAddress,
+	})
+	if _, err := m.runCommand(ctx, state.Instance, cmd.Stop(bootstrap)); err != nil {
+		return errors.Wrap(err, "pxc stop")
+	}
+	stopped, err := m.stoppedSeqno(ctx, state)
+	if err != nil {
+		return err
+	}
+	if resource.IsMajorUpgrade(installed, version) {
+		if err := m.editDefaultCfg(ctx, state.Instance, "mysqld", m.versionConfig(version)); err != nil {
+			return errors.Wrap(err, "edit default cfg for upgrade")
+		}
+	}
+	if _, err := m.runCommand(ctx, state.Instance, cmd.UpdateRepository(repository)); err != nil {
+		return errors.Wrap(err, "update repository")
+	}
+	if _, err := m.runCommand(ctx, state.Instance, cmd.UpgradePerconaXtraDBCluster(version)); err != nil {
+		return errors.Wrap(err, "upgrade pxc")
+	}
+	if _, err := m.runCommand(ctx, state.Instance, cmd.Start(bootstrap)); err != nil {
+		return errors.Wrap(err, "pxc start")
+	}
+
+	if err := m.waitForState(ctx, state.Instance, instanceState.isHealthy); err != nil {
+		return errors.Wrap(err, "node failed to rejoin the cluster after upgrade, check /var/log/mysql/error.log on the node")
+	}
+	db, err := m.newAdminClient(state.Instance)
+	if err != nil {
+		return errors.Wrap(err, "failed to create new mysql client")
+	}
+	defer db.Close()
+	running, err := db.Version(ctx)
+	if err != nil {
+		return errors.Wrap(err, "get running version")
+	}
+	if utils.CompareVersions(running, version) != 0 {
+		return errors.Errorf("node is running %s after upgrade to %s", running, version)
+	}
+
+	if bootstrap || stopped < 0 {
+		return nil
+	}
+	committed, err := db.Status(ctx, "wsrep_last_committed")
+	if err != nil {
+		return errors.Wrap(err, "get last committed seqno")
+	}
+	cachedDownto, err := db.Status(ctx, "wsrep_local_cached_downto")
+	if err != nil {
+		return errors.Wrap(err, "get gcache seqno")
+	}
+	sst, err := receivedSST(stopped, committed, cachedDownto)
+	if err != nil {
+		return err
+	}
+	// SST copies the whole data set from a donor, the next nodes would likely need it too, as gcache is too small for the upgrade time.
+	// Upgraded nodes are skipped, so the next apply continues with the next node.
+	if sst {
+		return errors.New("node rejoined the cluster with full state transfer (SST) instead of IST, upgrade is stopped before the next node, " +
+			"increase gcache.size in wsrep_provider_options or apply again to continue the upgrade with SST")
+	}
+	return nil
+}
+
+// stoppedSeqno returns the seqno of the last writeset committed by the node, which galera saves in grastate.dat on shutdown.
+// It's -1 if the node wasn't shut down gracefully, then the state transfer after the restart isn't checked.
+func (m *manager) stoppedSeqno(ctx context.Context, state instanceState) (int64, error) {
+	out, err := m.runCommand(ctx, state.Instance, cmd.GrastateSeqno())
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to read grastate.dat")
+	}
+	seqno, err := strconv.ParseInt(strings.TrimSpace(out), 10, 64)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to parse seqno of grastate.dat")
+	}
+	return seqno, nil
+}
+
+// receivedSST reports whether the node, which was stopped at the seqno, rejoined the cluster with full state transfer
+// according to wsrep_last_committed and wsrep_local_cached_downto of the node. IST writesets are kept in the gcache of the joiner,
+// while SST resets it, so the gcache of a node which received IST starts at the next seqno after the stop at the latest.
+// The gcache is empty if nothing was written since the state transfer, galera reports 0 or the maximum uint64 then.
+func receivedSST(stopped int64, committed, cachedDownto string) (bool, error) {
+	last, err := strconv.ParseInt(committed, 10, 64)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to parse wsrep_last_committed")
+	}
+	if last == stopped {
+		return false, nil
+	}
+	downto, err := strconv.ParseUint(cachedDownto, 10, 64)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to parse wsrep_local_cached_downto")
+	}
+	if downto == 0 || downto == math.MaxUint64 {
+		return true, nil
+	}
+	return downto > uint64(stopped)+1, nil
+}
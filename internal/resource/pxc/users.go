@@ -0,0 +1,166 @@
+package pxc
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/pkg/errors"
+
+	"terraform-percona/internal/cloud"
+	internaldb "terraform-percona/internal/db"
+	"terraform-percona/internal/db/mysql"
+	"terraform-percona/internal/resource"
+	"terraform-percona/internal/resource/pxc/cmd"
+)
+
+// newAdminClient returns a client of the automation user.
+// Clusters created before the automation user was introduced are accessed as root until they are updated.
+func (m *manager) newAdminClient(instance cloud.Instance) (*mysql.DB, error) {
+	if m.automationPassword == "" {
+		return m.newClient(instance, internaldb.UserRoot, m.password)
+	}
+	return m.newClient(instance, internaldb.UserAutomation, m.automationPassword)
+}
+
+// detectAutomationCIDR restricts the automation user to the address of the provider, if the CIDR is not set in the configuration.
+func (m *manager) detectAutomationCIDR(ctx context.Context, instance cloud.Instance) error {
+	if m.automationCIDR != "" {
+		return nil
+	}
+	cidr, err := resource.DetectAutomationCIDR(ctx, m.cloud, m.resourceID, instance)
+	if err != nil {
+		return errors.Wrap(err, "failed to detect automation cidr")
+	}
+	m.automationCIDR = cidr
+	return nil
+}
+
+// createAutomationUser creates the automation user with the privileges of the installed version.
+func (m *manager) createAutomationUser(ctx context.Context, instance cloud.Instance, version string) error {
+	host, err := resource.MySQLHost(m.automationCIDR)
+	if err != nil {
+		return errors.Wrap(err, "automation user host")
+	}
+	if _, err := m.runCommand(ctx, instance, cmd.CreateAutomationUser(m.password, host, m.automationPassword, version)); err != nil {
+		return errors.Wrap(err, "create automation user")
+	}
+	return nil
+}
+
+// setupAutomationUser creates the automation user in the cluster, which was created with remote root access or imported,
+// and makes root accessible only locally. Galera replicates the statements, so they are executed on the first node which accepts them.
+func (m *manager) setupAutomationUser(ctx context.Context) error {
+	instances, err := m.cloud.ListInstances(ctx, m.resourceID, map[string]string{
+		resource.LabelKeyInstanceType: resource.LabelValueInstanceTypeMySQL,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to list instances")
+	}
+	if len(instances) == 0 {
+		return errors.New("no instances found")
+	}
+	if err := m.detectAutomationCIDR(ctx, instances[0]); err != nil {
+		return err
+	}
+	// The privileges of the automation user are partially revoked on the system schemas, every node must accept the revokes
+	var version string
+	for _, instance := range instances {
+		version, err = m.installedVersion(ctx, instanceState{Instance: instance})
+		if err != nil {
+			return err
+		}
+		if !mysql.PartialRevokes(version) {
+			continue
+		}
+		if _, err := m.runCommand(ctx, instance, cmd.EnablePartialRevokes(m.password)); err != nil {
+			return errors.Wrapf(err, "failed to enable partial revokes on node %s", instance.PrivateIpAddress)
+		}
+	}
+	tflog.Info(ctx, "Creating automation user")
+	return m.onAnyNode(ctx, instances, func(instance cloud.Instance) error {
+		if err := m.createAutomationUser(ctx, instance, version); err != nil {
+			return err
+		}
+		if _, err := m.runCommand(ctx, instance, cmd.RestrictRootUser(m.password)); err != nil {
+			return errors.Wrap(err, "failed to restrict root user")
+		}
+		return nil
+	})
+}
+
+// updateAutomationCIDR changes the host of the automation user.
+// Root is used locally with the password before the change, as the provider may be unable to connect from its new address.
+func (m *manager) updateAutomationCIDR(ctx context.Context, oldCIDR, rootPassword string) error {
+	oldHost, err := resource.MySQLHost(oldCIDR)
+	if err != nil {
+		return errors.Wrap(err, "previous automation user host")
+	}
+	newHost, err := resource.MySQLHost(m.automationCIDR)
+	if err != nil {
+		return errors.Wrap(err, "automation user host")
+	}
+	instances, err := m.cloud.ListInstances(ctx, m.resourceID, map[string]string{
+		resource.LabelKeyInstanceType: resource.LabelValueInstanceTypeMySQL,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to list instances")
+	}
+	return m.onAnyNode(ctx, instances, func(instance cloud.Instance) error {
+		if _, err := m.runCommand(ctx, instance, cmd.RenameAutomationUser(rootPassword, oldHost, newHost)); err != nil {
+			return errors.Wrap(err, "failed to rename automation user")
+		}
+		return nil
+	})
+}
+
+// onAnyNode runs f on the nodes one by one until it succeeds on one of them.
+func (m *manager) onAnyNode(ctx context.Context, instances []cloud.Instance, f func(cloud.Instance) error) error {
+	var lastErr error
+	for _, instance := range instances {
+		err := f(instance)
+		if err == nil {
+			return nil
+		}
+		tflog.Warn(ctx, "failed to run on node", map[string]interface{}{
+			resource.LogArgInstanceIP: instance.PublicIpAddress,
+			"error":                   err,
+		})
+		lastErr = errors.Wrapf(err, "node %s", instance.PrivateIpAddress)
+	}
+	if lastErr == nil {
+		return errors.New("no instances found")
+	}
+	return lastErr
+}
+
+// applyUsers creates, changes and drops the application users on one of the synced nodes, galera replicates the statements to the other nodes.
+func (m *manager) applyUsers(ctx context.Context, previous []resource.User) error {
+	changed, removed := resource.UserChanges(previous, m.users)
+	if len(changed) == 0 && len(removed) == 0 {
+		return nil
+	}
+	states, err := m.instanceStates(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get instance states")
+	}
+	node, ok := syncedNode(states)
+	if !ok {
+		return errors.New("failed to find synced node")
+	}
+	db, err := m.connect(ctx, node.Instance)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	for _, u := range removed {
+		if err := db.DropUser(ctx, u.Name, u.Host); err != nil {
+			return errors.Wrapf(err, "failed to drop user %s@%s", u.Name, u.Host)
+		}
+	}
+	for _, u := range changed {
+		if err := db.CreateApplicationUser(ctx, u.Name, u.Host, u.Password, u.Privileges, u.Database); err != nil {
+			return errors.Wrapf(err, "failed to create user %s@%s", u.Name, u.Host)
+		}
+	}
+	return nil
+}
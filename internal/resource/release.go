@@ -0,0 +1,150 @@
+package resource
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/pkg/errors"
+	"golang.org/x/mod/semver"
+
+	"terraform-percona/internal/utils"
+)
+
+const (
+	ReleaseSeries57         = "5.7"
+	ReleaseSeries80         = "8.0"
+	ReleaseSeries84         = "8.4"
+	ReleaseSeriesInnovation = "innovation"
+)
+
+var releaseSeries = []string{ReleaseSeries57, ReleaseSeries80, ReleaseSeries84, ReleaseSeriesInnovation}
+
+// majorUpgradePath lists release series in the order they can be upgraded.
+var majorUpgradePath = []string{ReleaseSeries57, ReleaseSeries80, ReleaseSeries84}
+
+// ReleaseSeries maps the version to its release series, 8.0 is used if the version is not specified.
+func ReleaseSeries(version string) string {
+	if version == "" {
+		return ReleaseSeries80
+	}
+	switch majorMinor := utils.MajorMinor(version); majorMinor {
+	case ReleaseSeries57, ReleaseSeries80, ReleaseSeries84:
+		return majorMinor
+	}
+	return ReleaseSeriesInnovation
+}
+
+// CheckReleaseSeries returns an error if the version doesn't belong to the release series.
+func CheckReleaseSeries(series, version string) error {
+	if series == "" || version == "" {
+		return nil
+	}
+	if ReleaseSeries(version) != series {
+		return errors.Errorf("version %s doesn't belong to %s release series", version, series)
+	}
+	return nil
+}
+
+// customizeReleaseSeries refuses to change release_series of existing clusters without an upgrade to a version of the new series,
+// as the installed packages are changed only by the upgrade and the state would claim the series which isn't installed.
+func customizeReleaseSeries(ctx context.Context, diff *schema.ResourceDiff, meta interface{}) error {
+	if diff.Id() == "" || !diff.HasChange(SchemaKeyReleaseSeries) || diff.HasChange(SchemaKeyVersion) {
+		return nil
+	}
+	series := diff.Get(SchemaKeyReleaseSeries).(string)
+	version := diff.Get(SchemaKeyVersion).(string)
+	if series == "" || version != "" && ReleaseSeries(version) == series {
+		return nil
+	}
+	return errors.Errorf("%s can't be changed without %s, set %s of the %s release series to upgrade the cluster",
+		SchemaKeyReleaseSeries, SchemaKeyVersion, SchemaKeyVersion, series)
+}
+
+// ReleaseRepository returns the percona-release repository of the product (ps or pxc) for the release series.
+func ReleaseRepository(product, series, version string) string {
+	switch series {
+	case ReleaseSeries57:
+		return product + "-57"
+	case ReleaseSeries80:
+		return product + "-80"
+	case ReleaseSeries84:
+		return product + "-84-lts"
+	}
+	major := strings.TrimPrefix(semver.Major("v"+version), "v")
+	if major == "" {
+		major = "8"
+	}
+	return fmt.Sprintf("%s-%sx-innovation", product, major)
+}
+
+// IsMajorUpgrade reports whether the upgrade between versions changes the release series.
+func IsMajorUpgrade(from, to string) bool {
+	return utils.MajorMinor(from) != utils.MajorMinor(to)
+}
+
+// CheckUpgradePath returns an error if the version can't be upgraded in place to the target one.
+// Major upgrades are supported only between adjacent release series.
+func CheckUpgradePath(from, to string) error {
+	if utils.CompareVersions(to, from) < 0 {
+		return errors.Errorf("downgrade from %s to %s is not supported", from, to)
+	}
+	if !IsMajorUpgrade(from, to) {
+		return nil
+	}
+	fromIdx, toIdx := -1, -1
+	for i, series := range majorUpgradePath {
+		if series == utils.MajorMinor(from) {
+			fromIdx = i
+		}
+		if series == utils.MajorMinor(to) {
+			toIdx = i
+		}
+	}
+	if fromIdx == -1 || toIdx != fromIdx+1 {
+		return errors.Errorf("upgrade from %s to %s is not supported, major upgrades are supported only in order: %s",
+			from, to, strings.Join(majorUpgradePath, " -> "))
+	}
+	return nil
+}
+
+type upgradeCheckReport struct {
+	ErrorCount      int `json:"errorCount"`
+	ChecksPerformed []struct {
+		ID               string `json:"id"`
+		Title            string `json:"title"`
+		DetectedProblems []struct {
+			Level       string `json:"level"`
+			DBObject    string `json:"dbObject"`
+			Description string `json:"description"`
+		} `json:"detectedProblems"`
+	} `json:"checksPerformed"`
+}
+
+// UpgradeCheckErrors parses the JSON output of MySQL Shell upgrade checker and returns the blocking issues.
+func UpgradeCheckErrors(output string) ([]string, error) {
+	// MySQL Shell can print warnings before the report
+	start := strings.Index(output, "{")
+	if start == -1 {
+		return nil, errors.Errorf("unexpected upgrade checker output: %s", output)
+	}
+	report := new(upgradeCheckReport)
+	if err := json.Unmarshal([]byte(output[start:]), report); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse upgrade checker output: %s", output)
+	}
+	var problems []string
+	for _, check := range report.ChecksPerformed {
+		for _, problem := range check.DetectedProblems {
+			if problem.Level != "Error" {
+				continue
+			}
+			problems = append(problems, fmt.Sprintf("%s: %s %s", check.Title, problem.DBObject, problem.Description))
+		}
+	}
+	if report.ErrorCount > 0 && len(problems) == 0 {
+		problems = append(problems, fmt.Sprintf("%d errors are reported", report.ErrorCount))
+	}
+	return problems, nil
+}
@@ -0,0 +1,116 @@
+package resource_test
+
+import (
+	"reflect"
+	"terraform-percona/internal/resource"
+	"testing"
+)
+
+func TestCheckUpgradePath(t *testing.T) {
+	tests := []struct {
+		from    string
+		to      string
+		wantErr bool
+	}{
+		{"8.0.32-24-1.focal", "8.0.33-25-1.focal", false},
+		{"8.0.33-25-1.focal", "8.0.33-25-1.focal", false},
+		{"5.7.42-46-1.focal", "8.0.33-25-1.focal", false},
+		{"8.0.33-25-1.focal", "8.4.0-1-1.focal", false},
+		{"8.0.33-25-1.focal", "8.0.32-24-1.focal", true},
+		{"5.7.42-46-1.focal", "8.4.0-1-1.focal", true},
+		{"8.0.33-25-1.focal", "8.3.0-1-1.focal", true},
+	}
+	for _, tt := range tests {
+		err := resource.CheckUpgradePath(tt.from, tt.to)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("CheckUpgradePath(%s, %s) error = %v, wantErr %v", tt.from, tt.to, err, tt.wantErr)
+		}
+	}
+}
+
+func TestReleaseRepository(t *testing.T) {
+	tests := []struct {
+		version string
+		want    string
+	}{
+		{"", "ps-80"},
+		{"5.7.42", "ps-57"},
+		{"8.0.33", "ps-80"},
+		{"8.4.0", "ps-84-lts"},
+		{"8.3.0", "ps-8x-innovation"},
+	}
+	for _, tt := range tests {
+		got := resource.ReleaseRepository("ps", resource.ReleaseSeries(tt.version), tt.version)
+		if got != tt.want {
+			t.Errorf("ReleaseRepository(%s) = %s, want %s", tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestCheckReleaseSeries(t *testing.T) {
+	tests := []struct {
+		series  string
+		version string
+		wantErr bool
+	}{
+		{"", "8.0.33", false},
+		{"8.4", "", false},
+		{"5.7", "5.7.42-46-1.focal", false},
+		{"innovation", "8.3", false},
+		{"8.0", "8.4.0", true},
+		{"innovation", "8.0.33", true},
+	}
+	for _, tt := range tests {
+		err := resource.CheckReleaseSeries(tt.series, tt.version)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("CheckReleaseSeries(%s, %s) error = %v, wantErr %v", tt.series, tt.version, err, tt.wantErr)
+		}
+	}
+}
+
+func TestUpgradeCheckErrors(t *testing.T) {
+	output := `WARNING: Using a password on the command line interface can be insecure.
+{
+    "serverAddress": "127.0.0.1:3306",
+    "serverVersion": "5.7.42-46-log - Percona Server (GPL), Release 46, Revision e1995a8bb71",
+    "targetVersion": "8.0.33",
+    "errorCount": 1,
+    "warningCount": 1,
+    "noticeCount": 0,
+    "summary": "1 errors were found. Please correct these issues before upgrading to avoid compatibility issues.",
+    "checksPerformed": [
+        {
+            "id": "reservedKeywordsCheck",
+            "title": "Usage of db objects with names conflicting with new reserved keywords",
+            "status": "OK",
+            "detectedProblems": [
+                {
+                    "level": "Warning",
+                    "dbObject": "test.rank",
+                    "description": "Table name"
+                }
+            ]
+        },
+        {
+            "id": "mysqlSchemaCheck",
+            "title": "Table names in the mysql schema conflicting with new tables in 8.0",
+            "status": "OK",
+            "detectedProblems": [
+                {
+                    "level": "Error",
+                    "dbObject": "mysql.role_edges",
+                    "description": "Table name used in mysql schema in 8.0"
+                }
+            ]
+        }
+    ]
+}`
+	got, err := resource.UpgradeCheckErrors(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"Table names in the mysql schema conflicting with new tables in 8.0: mysql.role_edges Table name used in mysql schema in 8.0"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("UpgradeCheckErrors() = %v, want %v", got, want)
+	}
+}
@@ -7,7 +7,9 @@ import (
 
 	"github.com/hashicorp/terraform-plugin-log/tflog"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/customdiff"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/pkg/errors"
 )
 
 type Resource interface {
@@ -18,9 +20,26 @@ type Resource interface {
 	Read(ctx context.Context, data *schema.ResourceData, cloud cloud.Cloud) diag.Diagnostics
 	Update(ctx context.Context, data *schema.ResourceData, cloud cloud.Cloud) diag.Diagnostics
 	Delete(ctx context.Context, data *schema.ResourceData, cloud cloud.Cloud) diag.Diagnostics
+
+	// Import fills the attributes which can't be restored by Read from the existing infrastructure.
+	Import(ctx context.Context, data *schema.ResourceData, cloud cloud.Cloud) error
 }
 
 func toTerraformResource(resource Resource) *schema.Resource {
+	var customizeDiffs []schema.CustomizeDiffFunc
+	if _, ok := resource.Schema()[SchemaKeyConfigFileHash]; ok {
+		customizeDiffs = append(customizeDiffs, customizeConfigFileHash)
+	}
+	if _, ok := resource.Schema()[SchemaKeyAutomationCIDR]; ok {
+		customizeDiffs = append(customizeDiffs, customizeAutomationCIDR)
+	}
+	if _, ok := resource.Schema()[SchemaKeyReleaseSeries]; ok {
+		customizeDiffs = append(customizeDiffs, customizeReleaseSeries)
+	}
+	var customizeDiff schema.CustomizeDiffFunc
+	if len(customizeDiffs) > 0 {
+		customizeDiff = customdiff.All(customizeDiffs...)
+	}
 	return &schema.Resource{
 		CreateContext: func(ctx context.Context, data *schema.ResourceData, meta interface{}) diag.Diagnostics {
 			c, ok := meta.(cloud.Cloud)
@@ -59,11 +78,60 @@ func toTerraformResource(resource Resource) *schema.Resource {
 			}
 			return resource.Delete(ctx, data, c)
 		},
+		Importer: &schema.ResourceImporter{
+			StateContext: func(ctx context.Context, data *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+				c, ok := meta.(cloud.Cloud)
+				if !ok {
+					return nil, errors.New("failed to get cloud controller")
+				}
+				if err := SetDefaults(data, resource.Schema()); err != nil {
+					return nil, errors.Wrap(err, "failed to set default values")
+				}
+				if err := resource.Import(ctx, data, c); err != nil {
+					return nil, errors.Wrap(err, "failed to import resource")
+				}
+				for _, d := range resource.Read(ctx, data, c) {
+					if d.Severity == diag.Error {
+						return nil, errors.Errorf("failed to read resource: %s", d.Summary)
+					}
+				}
+				if data.Id() == "" {
+					return nil, errors.New("resource is not found")
+				}
+				return []*schema.ResourceData{data}, nil
+			},
+		},
+
+		CustomizeDiff: customizeDiff,
 
 		Schema: resource.Schema(),
 	}
 }
 
+// SetDefaults sets default values of the top level attributes which are not set yet.
+// Imported resources have only an id, so that their state would match the configuration which relies on defaults.
+func SetDefaults(data *schema.ResourceData, s map[string]*schema.Schema) error {
+	for key, v := range s {
+		if v.Default == nil {
+			continue
+		}
+		if _, ok := data.GetOk(key); ok {
+			continue
+		}
+		if err := data.Set(key, v.Default); err != nil {
+			return errors.Wrapf(err, "failed to set %s", key)
+		}
+	}
+	return nil
+}
+
+// PartialError returns the error of the failed update step.
+// Previous values are kept in the state, so that the change is retried on the next apply.
+func PartialError(data *schema.ResourceData, err error, msg string) diag.Diagnostics {
+	data.Partial(true)
+	return diag.FromErr(errors.Wrap(err, msg))
+}
+
 func ResourcesMap(resources ...Resource) map[string]*schema.Resource {
 	m := make(map[string]*schema.Resource, len(resources))
 	for _, r := range resources {
@@ -0,0 +1,169 @@
+package resource
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/pkg/errors"
+
+	"terraform-percona/internal/cloud"
+	"terraform-percona/internal/secrets"
+)
+
+// PullSecrets sets the passwords from secret_version of the secret, the latest version is used if it's not set yet.
+// Passwords are kept in the state if secret_store is not configured, they are moved there from the secret after its removal.
+func PullSecrets(ctx context.Context, data *schema.ResourceData, c cloud.Cloud, keys ...string) error {
+	storeName := data.Get(SchemaKeySecretStore).(string)
+	path := data.Get(SchemaKeySecretPath).(string)
+	version := secretVersion(data)
+	if storeName == "" {
+		oldStore, _ := data.GetChange(SchemaKeySecretStore)
+		oldPath, _ := data.GetChange(SchemaKeySecretPath)
+		oldVersion, _ := data.GetChange(SchemaKeySecretVersion)
+		storeName, path, version = oldStore.(string), oldPath.(string), oldVersion.(string)
+		if storeName == "" {
+			return nil
+		}
+	}
+	store, err := secrets.New(ctx, storeName, c)
+	if err != nil {
+		return errors.Wrap(err, "failed to create secret store")
+	}
+	values, readVersion, err := store.Read(ctx, path, version)
+	if err != nil {
+		if errors.Is(err, secrets.ErrNotFound) && version == "" {
+			// The secret is created by PushSecrets
+			return nil
+		}
+		return errors.Wrap(err, "failed to read secret")
+	}
+	for _, key := range keys {
+		if value, ok := values[key]; ok {
+			if err := data.Set(key, value); err != nil {
+				return errors.Wrapf(err, "failed to set %s", key)
+			}
+		}
+	}
+	if data.Get(SchemaKeySecretStore).(string) == "" {
+		readVersion = ""
+	}
+	return data.Set(SchemaKeySecretVersion, readVersion)
+}
+
+// PushSecrets writes the passwords, which are missing in the secret or differ from it, as a new version of the secret.
+// Other values of the secret are kept as is.
+// The CA private key is generated by the provider, so it's written only if secret_version isn't set in the configuration.
+func PushSecrets(ctx context.Context, data *schema.ResourceData, c cloud.Cloud, keys ...string) error {
+	storeName := data.Get(SchemaKeySecretStore).(string)
+	if storeName == "" {
+		return nil
+	}
+	store, err := secrets.New(ctx, storeName, c)
+	if err != nil {
+		return errors.Wrap(err, "failed to create secret store")
+	}
+	path := data.Get(SchemaKeySecretPath).(string)
+	values, version, err := store.Read(ctx, path, secretVersion(data))
+	if err != nil && !errors.Is(err, secrets.ErrNotFound) {
+		return errors.Wrap(err, "failed to read secret")
+	}
+	if values == nil {
+		values = make(map[string]string, len(keys))
+	}
+	pinned := secretVersionPinned(data)
+	changed := false
+	for _, key := range keys {
+		if pinned && key == SchemaKeyCAPrivateKey {
+			continue
+		}
+		value := data.Get(key).(string)
+		if values[key] != value {
+			values[key] = value
+			changed = true
+		}
+	}
+	if !changed {
+		return data.Set(SchemaKeySecretVersion, version)
+	}
+	if pinned {
+		return errors.Errorf("%s %s of the secret doesn't contain all passwords", SchemaKeySecretVersion, version)
+	}
+	version, err = store.Write(ctx, path, values)
+	if err != nil {
+		return errors.Wrap(err, "failed to write secret")
+	}
+	return data.Set(SchemaKeySecretVersion, version)
+}
+
+// secretVersionPinned reports whether secret_version is set in the configuration, the version is written by the user then.
+func secretVersionPinned(data *schema.ResourceData) bool {
+	config := data.GetRawConfig()
+	return !config.IsNull() && !config.GetAttr(SchemaKeySecretVersion).IsNull()
+}
+
+// secretVersion returns secret_version, the version of the previous secret isn't used after secret_store or secret_path change.
+func secretVersion(data *schema.ResourceData) string {
+	if data.HasChanges(SchemaKeySecretStore, SchemaKeySecretPath) && !data.HasChange(SchemaKeySecretVersion) {
+		return ""
+	}
+	return data.Get(SchemaKeySecretVersion).(string)
+}
+
+// PreviousPasswords returns the passwords before the change,
+// they are read from the previous version of the secret if secret_store was configured.
+// Passwords of imported resources are unknown, the current ones are assumed for them.
+func PreviousPasswords(ctx context.Context, data *schema.ResourceData, c cloud.Cloud, keys ...string) (map[string]string, error) {
+	passwords := make(map[string]string, len(keys))
+	for _, key := range keys {
+		v, _ := data.GetChange(key)
+		passwords[key] = v.(string)
+	}
+	if storeName, _ := data.GetChange(SchemaKeySecretStore); storeName.(string) != "" {
+		store, err := secrets.New(ctx, storeName.(string), c)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create secret store")
+		}
+		path, _ := data.GetChange(SchemaKeySecretPath)
+		version, _ := data.GetChange(SchemaKeySecretVersion)
+		values, _, err := store.Read(ctx, path.(string), version.(string))
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read previous version of secret")
+		}
+		for _, key := range keys {
+			passwords[key] = values[key]
+		}
+	}
+	for _, key := range keys {
+		if passwords[key] == "" {
+			passwords[key] = data.Get(key).(string)
+		}
+	}
+	return passwords, nil
+}
+
+// PasswordsChanged reports whether the passwords differ from the previous ones.
+func PasswordsChanged(data *schema.ResourceData, previous map[string]string, keys ...string) bool {
+	for _, key := range keys {
+		if data.Get(key).(string) != previous[key] {
+			return true
+		}
+	}
+	return false
+}
+
+// ClearSecrets removes the passwords from the state if secret_store is configured,
+// so that they are kept only in the secret store.
+// The CA private key is kept in the state if it isn't written by PushSecrets.
+func ClearSecrets(data *schema.ResourceData, keys ...string) {
+	if data.Get(SchemaKeySecretStore).(string) == "" {
+		return
+	}
+	pinned := secretVersionPinned(data)
+	for _, key := range keys {
+		if pinned && key == SchemaKeyCAPrivateKey {
+			continue
+		}
+		// Setting a string attribute of the schema doesn't fail
+		_ = data.Set(key, "")
+	}
+}
@@ -0,0 +1,123 @@
+package resource_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+
+	"terraform-percona/internal/cloud"
+	"terraform-percona/internal/cloud/fake"
+	"terraform-percona/internal/resource"
+)
+
+// kvServer emulates the KV version 2 secrets engine mounted at secret/, only the latest version of a secret is read.
+type kvServer struct {
+	mu       sync.Mutex
+	versions []map[string]interface{}
+}
+
+func (s *kvServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch r.Method {
+	case http.MethodGet:
+		if len(s.versions) == 0 {
+			http.Error(w, `{"errors":[]}`, http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{
+				"data":     s.versions[len(s.versions)-1],
+				"metadata": map[string]interface{}{"version": len(s.versions)},
+			},
+		})
+	case http.MethodPost:
+		req := struct {
+			Data map[string]interface{} `json:"data"`
+		}{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.versions = append(s.versions, req.Data)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{"version": len(s.versions)},
+		})
+	}
+}
+
+func TestPushCAPrivateKey(t *testing.T) {
+	r := &schema.Resource{Schema: resource.DefaultMySQLSchema()}
+	tests := map[string]struct {
+		secretVersion cty.Value
+		wantPushed    bool
+	}{
+		"secret version is computed": {
+			secretVersion: cty.NullVal(cty.String),
+			wantPushed:    true,
+		},
+		"secret version is configured": {
+			secretVersion: cty.StringVal("1"),
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := &kvServer{versions: []map[string]interface{}{{resource.SchemaKeyRootPassword: "password"}}}
+			httpServer := httptest.NewServer(server)
+			defer httpServer.Close()
+			c := fake.New()
+			c.Meta = cloud.Metadata{VaultAddress: httpServer.URL, VaultToken: "token"}
+
+			config := make(map[string]cty.Value)
+			for key, attr := range r.CoreConfigSchema().Attributes {
+				config[key] = cty.NullVal(attr.Type)
+			}
+			config[resource.SchemaKeySecretStore] = cty.StringVal("vault")
+			config[resource.SchemaKeySecretPath] = cty.StringVal("secret/ps")
+			config[resource.SchemaKeySecretVersion] = tt.secretVersion
+			data := r.Data(&terraform.InstanceState{
+				ID: "test",
+				Attributes: map[string]string{
+					resource.SchemaKeySecretStore:   "vault",
+					resource.SchemaKeySecretPath:    "secret/ps",
+					resource.SchemaKeySecretVersion: "1",
+					resource.SchemaKeyRootPassword:  "password",
+					resource.SchemaKeyCAPrivateKey:  "key",
+				},
+				RawConfig: cty.ObjectVal(config),
+			})
+
+			keys := []string{resource.SchemaKeyRootPassword, resource.SchemaKeyCAPrivateKey}
+			if err := resource.PushSecrets(context.Background(), data, c, keys...); err != nil {
+				t.Fatal(err)
+			}
+			resource.ClearSecrets(data, keys...)
+
+			latest := server.versions[len(server.versions)-1]
+			pushed := latest[resource.SchemaKeyCAPrivateKey] == "key"
+			if pushed != tt.wantPushed {
+				t.Errorf("ca private key pushed = %t, want %t", pushed, tt.wantPushed)
+			}
+			if key := data.Get(resource.SchemaKeyCAPrivateKey).(string); (key == "") != tt.wantPushed {
+				t.Errorf("ca private key in state = %q, pushed = %t", key, pushed)
+			}
+			if password := data.Get(resource.SchemaKeyRootPassword).(string); password != "" {
+				t.Errorf("password in state = %q, want empty", password)
+			}
+			wantVersion := "1"
+			if tt.wantPushed {
+				wantVersion = "2"
+			}
+			if version := data.Get(resource.SchemaKeySecretVersion).(string); version != wantVersion {
+				t.Errorf("secret version = %s, want %s", version, wantVersion)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package resource
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestCustomizeReleaseSeries(t *testing.T) {
+	r := &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			SchemaKeyVersion:       DefaultMySQLSchema()[SchemaKeyVersion],
+			SchemaKeyReleaseSeries: DefaultMySQLSchema()[SchemaKeyReleaseSeries],
+		},
+		CustomizeDiff: customizeReleaseSeries,
+	}
+	tests := map[string]struct {
+		oldVersion, oldSeries string
+		version, series       string
+		wantErr               bool
+	}{
+		"series without version":          {oldSeries: "8.0", series: "8.4", wantErr: true},
+		"series of the unchanged version": {oldVersion: "8.0.33", oldSeries: "8.0", version: "8.0.33", series: "8.4", wantErr: true},
+		"series along with version":       {oldVersion: "8.0.33", oldSeries: "8.0", version: "8.4.0", series: "8.4"},
+		"series of the version is set":    {oldVersion: "8.0.33", version: "8.0.33", series: "8.0"},
+		"series is removed":               {oldSeries: "8.0"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			value := func(v string) cty.Value {
+				if v == "" {
+					return cty.NullVal(cty.String)
+				}
+				return cty.StringVal(v)
+			}
+			raw := cty.ObjectVal(map[string]cty.Value{
+				SchemaKeyVersion:       value(tt.version),
+				SchemaKeyReleaseSeries: value(tt.series),
+			})
+			state := &terraform.InstanceState{
+				ID: "test",
+				Attributes: map[string]string{
+					SchemaKeyVersion:       tt.oldVersion,
+					SchemaKeyReleaseSeries: tt.oldSeries,
+				},
+				RawConfig: raw,
+			}
+			config := terraform.NewResourceConfigShimmed(raw, r.CoreConfigSchema())
+			_, err := r.SimpleDiff(context.Background(), state, config, nil)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("diff error = %v, want error %t", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package resource
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/pkg/errors"
+
+	"terraform-percona/internal/cloud"
+	"terraform-percona/internal/utils"
+)
+
+const (
+	CertsDir       = "/etc/mysql/certs"
+	CACertPath     = CertsDir + "/ca.pem"
+	ServerCertPath = CertsDir + "/server-cert.pem"
+	ServerKeyPath  = CertsDir + "/server-key.pem"
+
+	// certsUploadDir is created in the home directory of the ssh user, so the private key isn't readable by others during the upload
+	certsUploadDir = "percona-certs"
+)
+
+// GenerateCA sets a new CA of the cluster if it's not set yet.
+func GenerateCA(data *schema.ResourceData, resourceID string) error {
+	if data.Get(SchemaKeyCACertificate).(string) != "" {
+		return nil
+	}
+	cert, key, err := utils.GenerateCA(resourceID)
+	if err != nil {
+		return errors.Wrap(err, "failed to generate ca")
+	}
+	if err := data.Set(SchemaKeyCACertificate, cert); err != nil {
+		return errors.Wrapf(err, "failed to set %s", SchemaKeyCACertificate)
+	}
+	if err := data.Set(SchemaKeyCAPrivateKey, key); err != nil {
+		return errors.Wrapf(err, "failed to set %s", SchemaKeyCAPrivateKey)
+	}
+	return nil
+}
+
+// SendCertificates issues the certificate of the instance, signed by the cluster CA, and places it with the CA certificate to CertsDir.
+// The certificate is valid for the public and private addresses and the hostname of the instance, and for local connections.
+// MySQL server should be installed on the instance, as the files are owned by the mysql user.
+func SendCertificates(ctx context.Context, c cloud.Cloud, resourceID string, instance cloud.Instance, caCert, caKey string) error {
+	if caKey == "" {
+		return errors.Errorf("%s is required to issue the certificate of the instance", SchemaKeyCAPrivateKey)
+	}
+	hostname, err := c.RunCommand(ctx, resourceID, instance, "hostname")
+	if err != nil {
+		return errors.Wrap(err, "failed to get hostname")
+	}
+	cert, key, err := utils.GenerateCertificate(caCert, caKey, instance.PrivateIpAddress,
+		[]string{instance.PublicIpAddress, instance.PrivateIpAddress, "127.0.0.1"},
+		[]string{strings.TrimSpace(hostname), "localhost"})
+	if err != nil {
+		return errors.Wrap(err, "failed to generate certificate")
+	}
+	if _, err := c.RunCommand(ctx, resourceID, instance, fmt.Sprintf("mkdir -p -m 700 %s", certsUploadDir)); err != nil {
+		return errors.Wrap(err, "failed to create upload directory")
+	}
+	files := map[string]string{
+		CACertPath:     caCert,
+		ServerCertPath: cert,
+		ServerKeyPath:  key,
+	}
+	for remotePath, content := range files {
+		if err := c.SendFile(ctx, resourceID, instance, strings.NewReader(content), path.Join(certsUploadDir, path.Base(remotePath))); err != nil {
+			return errors.Wrapf(err, "failed to send %s", path.Base(remotePath))
+		}
+	}
+	_, err = c.RunCommand(ctx, resourceID, instance, fmt.Sprintf(`
+		set -o errexit
+		sudo mkdir -p %[1]s
+		sudo mv %[2]s/* %[1]s/
+		rmdir %[2]s
+		sudo chown -R mysql:mysql %[1]s
+		sudo chmod 644 %[1]s/*.pem
+		sudo chmod 600 %[3]s
+	`, CertsDir, certsUploadDir, ServerKeyPath))
+	if err != nil {
+		return errors.Wrap(err, "failed to install certificates")
+	}
+	return nil
+}
+
+// TLSConfig returns the mysqld config which enables the certificates placed by SendCertificates and rejects unencrypted connections over TCP.
+func TLSConfig() map[string]string {
+	return map[string]string{
+		"ssl_ca":                   CACertPath,
+		"ssl_cert":                 ServerCertPath,
+		"ssl_key":                  ServerKeyPath,
+		"require_secure_transport": "ON",
+	}
+}
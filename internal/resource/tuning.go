@@ -0,0 +1,106 @@
+package resource
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"terraform-percona/internal/utils"
+)
+
+const (
+	TuningProfileNone = "none"
+	TuningProfileAuto = "auto"
+)
+
+const (
+	mib = int64(1) << 20
+	gib = int64(1) << 30
+
+	// innodb_buffer_pool_size is a multiple of innodb_buffer_pool_chunk_size
+	bufferPoolChunkSize = 128 * mib
+)
+
+// SystemResources are the memory and CPUs of the instance.
+type SystemResources struct {
+	MemoryBytes int64
+	CPUs        int
+}
+
+// ParseSystemResources parses MemTotal from /proc/meminfo in kB and the output of nproc, printed on separate lines.
+func ParseSystemResources(output string) (SystemResources, error) {
+	lines := strings.Fields(output)
+	if len(lines) != 2 {
+		return SystemResources{}, errors.Errorf("unexpected system resources output: %s", output)
+	}
+	memKB, err := strconv.ParseInt(lines[0], 10, 64)
+	if err != nil {
+		return SystemResources{}, errors.Wrap(err, "failed to parse total memory")
+	}
+	cpus, err := strconv.Atoi(lines[1])
+	if err != nil {
+		return SystemResources{}, errors.Wrap(err, "failed to parse number of cpus")
+	}
+	return SystemResources{
+		MemoryBytes: memKB * 1024,
+		CPUs:        cpus,
+	}, nil
+}
+
+// TuningConfig returns InnoDB and connection settings for the instance resources, volume IOPS and the server version.
+// IO capacity is set only if the volume IOPS are known.
+func TuningConfig(res SystemResources, iops int, version string) map[string]string {
+	// Smaller instances need more memory for the OS and connections
+	bufferPool := res.MemoryBytes / 2
+	if res.MemoryBytes >= 4*gib {
+		bufferPool = res.MemoryBytes * 3 / 4
+	}
+	bufferPool = clamp(bufferPool/bufferPoolChunkSize*bufferPoolChunkSize, bufferPoolChunkSize, res.MemoryBytes)
+
+	bufferPoolInstances := int64(1)
+	if bufferPool >= gib {
+		bufferPoolInstances = clamp(int64(res.CPUs), 1, clamp(bufferPool/gib, 1, 8))
+	}
+
+	cfg := map[string]string{
+		"innodb_buffer_pool_size":      strconv.FormatInt(bufferPool, 10),
+		"innodb_buffer_pool_instances": strconv.FormatInt(bufferPoolInstances, 10),
+		"max_connections":              strconv.FormatInt(clamp(res.MemoryBytes/(16*mib), 151, 10000), 10),
+	}
+
+	// Redo log should hold about an hour of writes, a quarter of the buffer pool is a common estimation
+	redoLog := clamp(bufferPool/4/mib*mib, 128*mib, 16*gib)
+	if version == "" || utils.CompareVersions(version, "8.0.30") >= 0 {
+		cfg["innodb_redo_log_capacity"] = strconv.FormatInt(redoLog, 10)
+	} else {
+		// There are two redo log files by default
+		cfg["innodb_log_file_size"] = strconv.FormatInt(redoLog/2, 10)
+	}
+
+	if iops > 0 {
+		// Background flushing uses half of the IOPS, the rest is left for the foreground queries
+		ioCapacity := iops / 2
+		if ioCapacity < 100 {
+			ioCapacity = 100
+		}
+		ioCapacityMax := iops
+		if ioCapacityMax < ioCapacity*2 {
+			ioCapacityMax = ioCapacity * 2
+		}
+		cfg["innodb_io_capacity"] = strconv.Itoa(ioCapacity)
+		cfg["innodb_io_capacity_max"] = strconv.Itoa(ioCapacityMax)
+	}
+	return cfg
+}
+
+// clamp returns the value limited to [lo, hi], lo wins if hi is lower than lo.
+func clamp(v, lo, hi int64) int64 {
+	if v > hi {
+		v = hi
+	}
+	if v < lo {
+		v = lo
+	}
+	return v
+}
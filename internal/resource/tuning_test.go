@@ -0,0 +1,79 @@
+package resource_test
+
+import (
+	"reflect"
+	"testing"
+
+	"terraform-percona/internal/resource"
+)
+
+func TestParseSystemResources(t *testing.T) {
+	res, err := resource.ParseSystemResources("16273872\n4\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := resource.SystemResources{MemoryBytes: 16273872 * 1024, CPUs: 4}
+	if res != want {
+		t.Errorf("ParseSystemResources() = %+v, want %+v", res, want)
+	}
+	if _, err := resource.ParseSystemResources("16273872\n"); err == nil {
+		t.Error("ParseSystemResources() expected error for incomplete output")
+	}
+}
+
+func TestTuningConfig(t *testing.T) {
+	const gib = int64(1) << 30
+	tests := []struct {
+		name    string
+		res     resource.SystemResources
+		iops    int
+		version string
+		want    map[string]string
+	}{
+		{
+			name:    "small instance",
+			res:     resource.SystemResources{MemoryBytes: gib, CPUs: 2},
+			version: "8.0.33",
+			want: map[string]string{
+				"innodb_buffer_pool_size":      "536870912",
+				"innodb_buffer_pool_instances": "1",
+				"max_connections":              "151",
+				"innodb_redo_log_capacity":     "134217728",
+			},
+		},
+		{
+			name:    "large instance",
+			res:     resource.SystemResources{MemoryBytes: 16 * gib, CPUs: 4},
+			iops:    4000,
+			version: "8.0.33",
+			want: map[string]string{
+				"innodb_buffer_pool_size":      "12884901888",
+				"innodb_buffer_pool_instances": "4",
+				"max_connections":              "1024",
+				"innodb_redo_log_capacity":     "3221225472",
+				"innodb_io_capacity":           "2000",
+				"innodb_io_capacity_max":       "4000",
+			},
+		},
+		{
+			name:    "5.7",
+			res:     resource.SystemResources{MemoryBytes: 8 * gib, CPUs: 16},
+			iops:    150,
+			version: "5.7.42",
+			want: map[string]string{
+				"innodb_buffer_pool_size":      "6442450944",
+				"innodb_buffer_pool_instances": "6",
+				"max_connections":              "512",
+				"innodb_log_file_size":         "805306368",
+				"innodb_io_capacity":           "100",
+				"innodb_io_capacity_max":       "200",
+			},
+		},
+	}
+	for _, tt := range tests {
+		got := resource.TuningConfig(tt.res, tt.iops, tt.version)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: TuningConfig() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
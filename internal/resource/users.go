@@ -0,0 +1,187 @@
+package resource
+
+import (
+	"context"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/pkg/errors"
+
+	"terraform-percona/internal/cloud"
+)
+
+const (
+	SchemaKeyUsersName       = "name"
+	SchemaKeyUsersHost       = "host"
+	SchemaKeyUsersPassword   = "password"
+	SchemaKeyUsersPrivileges = "privileges"
+	SchemaKeyUsersDatabase   = "database"
+)
+
+var (
+	// privilegesRegexp matches comma separated privilege names, which are used as is in GRANT statements.
+	privilegesRegexp = regexp.MustCompile(`^[a-zA-Z_]+( [a-zA-Z_]+)*(, *[a-zA-Z_]+( [a-zA-Z_]+)*)*$`)
+	userNameRegexp   = regexp.MustCompile(`^[a-zA-Z0-9_.-]{1,32}$`)
+)
+
+// User is an application user declared in the resource configuration.
+type User struct {
+	Name       string
+	Host       string
+	Password   string
+	Privileges string
+	Database   string
+}
+
+func (u User) key() string {
+	return u.Name + "@" + u.Host
+}
+
+// Users returns the application users of the resource.
+func Users(data *schema.ResourceData) []User {
+	return usersOf(data.Get(SchemaKeyUsers))
+}
+
+// PreviousUsers returns the application users before the change.
+func PreviousUsers(data *schema.ResourceData) []User {
+	v, _ := data.GetChange(SchemaKeyUsers)
+	return usersOf(v)
+}
+
+func usersOf(v interface{}) []User {
+	list, _ := v.([]interface{})
+	users := make([]User, 0, len(list))
+	for _, item := range list {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		users = append(users, User{
+			Name:       m[SchemaKeyUsersName].(string),
+			Host:       m[SchemaKeyUsersHost].(string),
+			Password:   m[SchemaKeyUsersPassword].(string),
+			Privileges: m[SchemaKeyUsersPrivileges].(string),
+			Database:   m[SchemaKeyUsersDatabase].(string),
+		})
+	}
+	return users
+}
+
+// UserChanges returns the users which are added or changed, and the ones which are removed.
+// Users are identified by their name and host.
+func UserChanges(oldUsers, newUsers []User) (changed []User, removed []User) {
+	old := make(map[string]User, len(oldUsers))
+	for _, u := range oldUsers {
+		old[u.key()] = u
+	}
+	current := make(map[string]struct{}, len(newUsers))
+	for _, u := range newUsers {
+		current[u.key()] = struct{}{}
+		if o, ok := old[u.key()]; !ok || o != u {
+			changed = append(changed, u)
+		}
+	}
+	for _, u := range oldUsers {
+		if _, ok := current[u.key()]; !ok {
+			removed = append(removed, u)
+		}
+	}
+	return changed, removed
+}
+
+// MySQLHost returns the host part of an account which matches the addresses of the CIDR block.
+// MySQL accepts CIDR notation only since 8.0.23, so that the netmask notation is used.
+func MySQLHost(cidr string) (string, error) {
+	ip, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return "", errors.Wrap(err, "parse cidr")
+	}
+	ones, bits := ipNet.Mask.Size()
+	if ones == bits {
+		return ip.String(), nil
+	}
+	if ip.To4() == nil {
+		return "", errors.Errorf("only single addresses are supported for ipv6: %s", cidr)
+	}
+	return ipNet.IP.String() + "/" + net.IP(ipNet.Mask).String(), nil
+}
+
+// customizeAutomationCIDR plans an update of the clusters, which were created before the automation user was introduced,
+// so that the user is created and root access is restricted on the next apply.
+// The detected CIDR follows the address of the provider, e.g. of a CI runner, so that the provider isn't locked out when its address changes.
+func customizeAutomationCIDR(ctx context.Context, diff *schema.ResourceDiff, meta interface{}) error {
+	if diff.Id() == "" {
+		return nil
+	}
+	cidr := diff.Get(SchemaKeyAutomationCIDR).(string)
+	if cidr == "" {
+		return diff.SetNewComputed(SchemaKeyAutomationCIDR)
+	}
+	if raw := diff.GetRawConfig(); !raw.IsKnown() || raw.IsNull() || !raw.GetAttr(SchemaKeyAutomationCIDR).IsNull() {
+		return nil
+	}
+	providerCIDR := diff.Get(SchemaKeyProviderCIDR).(string)
+	if providerCIDR == "" || cidrContains(cidr, providerCIDR) {
+		return nil
+	}
+	return diff.SetNew(SchemaKeyAutomationCIDR, providerCIDR)
+}
+
+// cidrContains reports whether the address of addressCIDR belongs to cidr.
+func cidrContains(cidr, addressCIDR string) bool {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return false
+	}
+	ip, _, err := net.ParseCIDR(addressCIDR)
+	if err != nil {
+		return false
+	}
+	return ipNet.Contains(ip)
+}
+
+// SetProviderCIDR sets provider_cidr to the address of the provider as it's seen by the first reachable mysql instance.
+// Instances are reachable over ssh even if the provider is outside of automation_cidr, the failure to detect the address is only logged.
+func SetProviderCIDR(ctx context.Context, data *schema.ResourceData, c cloud.Cloud, resourceID string) error {
+	instances, err := c.ListInstances(ctx, resourceID, map[string]string{
+		LabelKeyInstanceType: LabelValueInstanceTypeMySQL,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to list instances")
+	}
+	for _, instance := range instances {
+		if instance.PublicIpAddress == "" {
+			continue
+		}
+		cidr, err := DetectAutomationCIDR(ctx, c, resourceID, instance)
+		if err != nil {
+			tflog.Warn(ctx, "failed to detect provider address", map[string]interface{}{
+				LogArgInstanceIP: instance.PublicIpAddress,
+				"error":          err,
+			})
+			continue
+		}
+		return data.Set(SchemaKeyProviderCIDR, cidr)
+	}
+	return nil
+}
+
+// DetectAutomationCIDR returns the address of the provider as it's seen by the instance,
+// connections of the provider to the database come from the same address.
+func DetectAutomationCIDR(ctx context.Context, c cloud.Cloud, resourceID string, instance cloud.Instance) (string, error) {
+	out, err := c.RunCommand(ctx, resourceID, instance, `echo "${SSH_CLIENT%% *}"`)
+	if err != nil {
+		return "", errors.Wrap(err, "get ssh client address")
+	}
+	ip := net.ParseIP(strings.TrimSpace(out))
+	if ip == nil {
+		return "", errors.Errorf("failed to parse ssh client address: %q", out)
+	}
+	if ip.To4() != nil {
+		return ip.String() + "/32", nil
+	}
+	return ip.String() + "/128", nil
+}
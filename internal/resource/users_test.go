@@ -0,0 +1,90 @@
+package resource
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestCustomizeAutomationCIDR(t *testing.T) {
+	r := &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			SchemaKeyAutomationCIDR: DefaultMySQLSchema()[SchemaKeyAutomationCIDR],
+			SchemaKeyProviderCIDR:   DefaultMySQLSchema()[SchemaKeyProviderCIDR],
+		},
+		CustomizeDiff: customizeAutomationCIDR,
+	}
+	tests := map[string]struct {
+		automationCIDR string
+		providerCIDR   string
+		config         cty.Value
+		want           string
+		wantComputed   bool
+	}{
+		"provider within detected cidr": {
+			automationCIDR: "203.0.113.1/32",
+			providerCIDR:   "203.0.113.1/32",
+			config:         cty.NullVal(cty.String),
+		},
+		"provider moved from detected cidr": {
+			automationCIDR: "203.0.113.1/32",
+			providerCIDR:   "198.51.100.7/32",
+			config:         cty.NullVal(cty.String),
+			want:           "198.51.100.7/32",
+		},
+		"configured cidr is kept": {
+			automationCIDR: "203.0.113.0/24",
+			providerCIDR:   "198.51.100.7/32",
+			config:         cty.StringVal("203.0.113.0/24"),
+		},
+		"provider address is unknown": {
+			automationCIDR: "203.0.113.1/32",
+			config:         cty.NullVal(cty.String),
+		},
+		"cluster without automation user": {
+			providerCIDR: "198.51.100.7/32",
+			config:       cty.NullVal(cty.String),
+			wantComputed: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			raw := cty.ObjectVal(map[string]cty.Value{
+				SchemaKeyAutomationCIDR: tt.config,
+				SchemaKeyProviderCIDR:   cty.NullVal(cty.String),
+			})
+			// Terraform sends the configuration along with the prior state
+			state := &terraform.InstanceState{
+				ID: "test",
+				Attributes: map[string]string{
+					SchemaKeyAutomationCIDR: tt.automationCIDR,
+					SchemaKeyProviderCIDR:   tt.providerCIDR,
+				},
+				RawConfig: raw,
+			}
+			config := terraform.NewResourceConfigShimmed(raw, r.CoreConfigSchema())
+			diff, err := r.SimpleDiff(context.Background(), state, config, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			attr := diff.Attributes[SchemaKeyAutomationCIDR]
+			switch {
+			case tt.wantComputed:
+				if attr == nil || !attr.NewComputed {
+					t.Errorf("%s should be computed, diff %+v", SchemaKeyAutomationCIDR, attr)
+				}
+			case tt.want != "":
+				if attr == nil || attr.New != tt.want {
+					t.Errorf("%s should change to %s, diff %+v", SchemaKeyAutomationCIDR, tt.want, attr)
+				}
+			default:
+				if attr != nil && attr.Old != attr.New {
+					t.Errorf("%s should not change, diff %+v", SchemaKeyAutomationCIDR, attr)
+				}
+			}
+		})
+	}
+}
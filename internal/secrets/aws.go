@@ -0,0 +1,77 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/pkg/errors"
+
+	"terraform-percona/internal/cloud"
+)
+
+// AWS keeps secrets in AWS Secrets Manager as JSON objects, secret paths are names or ARNs of the secrets.
+type AWS struct {
+	client *secretsmanager.SecretsManager
+}
+
+func NewAWS(creds cloud.Credentials) (*AWS, error) {
+	sess, err := session.NewSession(&aws.Config{
+		Region:      aws.String(creds.Region),
+		Credentials: credentials.NewStaticCredentials(creds.AccessKey, creds.SecretKey, creds.SessionToken),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed create aws session")
+	}
+	return &AWS{client: secretsmanager.New(sess)}, nil
+}
+
+func (s *AWS) Read(ctx context.Context, path string, version string) (map[string]string, string, error) {
+	in := &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(path),
+	}
+	if version != "" {
+		in.VersionId = aws.String(version)
+	}
+	out, err := s.client.GetSecretValueWithContext(ctx, in)
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == secretsmanager.ErrCodeResourceNotFoundException {
+			return nil, "", ErrNotFound
+		}
+		return nil, "", errors.Wrapf(err, "failed to get secret value of %s", path)
+	}
+	values, err := unmarshalValues([]byte(aws.StringValue(out.SecretString)))
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "failed to parse secret %s", path)
+	}
+	return values, aws.StringValue(out.VersionId), nil
+}
+
+func (s *AWS) Write(ctx context.Context, path string, values map[string]string) (string, error) {
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to marshal secret")
+	}
+	out, err := s.client.PutSecretValueWithContext(ctx, &secretsmanager.PutSecretValueInput{
+		SecretId:     aws.String(path),
+		SecretString: aws.String(string(data)),
+	})
+	if err == nil {
+		return aws.StringValue(out.VersionId), nil
+	}
+	if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != secretsmanager.ErrCodeResourceNotFoundException {
+		return "", errors.Wrapf(err, "failed to put secret value of %s", path)
+	}
+	created, err := s.client.CreateSecretWithContext(ctx, &secretsmanager.CreateSecretInput{
+		Name:         aws.String(path),
+		SecretString: aws.String(string(data)),
+	})
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to create secret %s", path)
+	}
+	return aws.StringValue(created.VersionId), nil
+}
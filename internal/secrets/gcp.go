@@ -0,0 +1,101 @@
+package secrets
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+	"google.golang.org/api/secretmanager/v1"
+
+	"terraform-percona/internal/cloud"
+)
+
+// GCP keeps secrets in GCP Secret Manager as JSON objects.
+// Secret paths are secret ids in the project of the provider or full names, e.g. projects/my-project/secrets/my-secret.
+type GCP struct {
+	service *secretmanager.Service
+	project string
+}
+
+func NewGCP(ctx context.Context, creds cloud.Credentials) (*GCP, error) {
+	var opts []option.ClientOption
+	if creds.TokenSource != nil {
+		opts = append(opts, option.WithTokenSource(creds.TokenSource))
+	}
+	service, err := secretmanager.NewService(ctx, opts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create secret manager client")
+	}
+	return &GCP{service: service, project: creds.Project}, nil
+}
+
+func (s *GCP) Read(ctx context.Context, secretPath string, version string) (map[string]string, string, error) {
+	if version == "" {
+		version = "latest"
+	}
+	resp, err := s.service.Projects.Secrets.Versions.Access(s.secretName(secretPath) + "/versions/" + version).Context(ctx).Do()
+	if err != nil {
+		if isNotFound(err) {
+			return nil, "", ErrNotFound
+		}
+		return nil, "", errors.Wrapf(err, "failed to access secret %s", secretPath)
+	}
+	data, err := base64.StdEncoding.DecodeString(resp.Payload.Data)
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "failed to decode secret %s", secretPath)
+	}
+	values, err := unmarshalValues(data)
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "failed to parse secret %s", secretPath)
+	}
+	// Version names are returned in the form of projects/*/secrets/*/versions/*
+	return values, path.Base(resp.Name), nil
+}
+
+func (s *GCP) Write(ctx context.Context, secretPath string, values map[string]string) (string, error) {
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to marshal secret")
+	}
+	name := s.secretName(secretPath)
+	req := &secretmanager.AddSecretVersionRequest{
+		Payload: &secretmanager.SecretPayload{Data: base64.StdEncoding.EncodeToString(data)},
+	}
+	resp, err := s.service.Projects.Secrets.AddVersion(name, req).Context(ctx).Do()
+	if err == nil {
+		return path.Base(resp.Name), nil
+	}
+	if !isNotFound(err) {
+		return "", errors.Wrapf(err, "failed to add version of secret %s", secretPath)
+	}
+	// Secret names are in the form of projects/*/secrets/*
+	_, err = s.service.Projects.Secrets.Create(path.Dir(path.Dir(name)), &secretmanager.Secret{
+		Replication: &secretmanager.Replication{Automatic: &secretmanager.Automatic{}},
+	}).SecretId(path.Base(name)).Context(ctx).Do()
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to create secret %s", secretPath)
+	}
+	resp, err = s.service.Projects.Secrets.AddVersion(name, req).Context(ctx).Do()
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to add version of secret %s", secretPath)
+	}
+	return path.Base(resp.Name), nil
+}
+
+func (s *GCP) secretName(secretPath string) string {
+	if strings.HasPrefix(secretPath, "projects/") {
+		return secretPath
+	}
+	return "projects/" + s.project + "/secrets/" + secretPath
+}
+
+func isNotFound(err error) bool {
+	var gerr *googleapi.Error
+	return errors.As(err, &gerr) && gerr.Code == http.StatusNotFound
+}
@@ -0,0 +1,70 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"terraform-percona/internal/cloud"
+)
+
+const (
+	StoreVault = "vault"
+	StoreAWS   = "aws"
+	StoreGCP   = "gcp"
+)
+
+var ErrNotFound = errors.New("secret is not found")
+
+// Store keeps the values of a secret as a set of named strings, every write creates a new version of the secret.
+type Store interface {
+	// Read returns the values of the secret version and the version itself, the latest version is read if version is empty.
+	Read(ctx context.Context, path string, version string) (map[string]string, string, error)
+	// Write stores the values as a new version of the secret and returns the version.
+	Write(ctx context.Context, path string, values map[string]string) (string, error)
+}
+
+// New returns the store by its name, AWS and GCP stores use the credentials of the configured cloud.
+func New(ctx context.Context, name string, c cloud.Cloud) (Store, error) {
+	switch name {
+	case StoreVault:
+		meta := c.Metadata()
+		return NewVault(meta.VaultAddress, meta.VaultToken)
+	case StoreAWS:
+		creds, err := c.Credentials()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get aws credentials")
+		}
+		return NewAWS(creds)
+	case StoreGCP:
+		creds, err := c.Credentials()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get gcp credentials")
+		}
+		return NewGCP(ctx, creds)
+	}
+	return nil, errors.Errorf("unknown secret store: %s", name)
+}
+
+// unmarshalValues parses the secret stored as a JSON object, non-string values are formatted as is.
+func unmarshalValues(data []byte) (map[string]string, error) {
+	raw := make(map[string]interface{})
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, errors.Wrap(err, "secret is not a JSON object")
+	}
+	return stringValues(raw), nil
+}
+
+func stringValues(raw map[string]interface{}) map[string]string {
+	values := make(map[string]string, len(raw))
+	for k, v := range raw {
+		if s, ok := v.(string); ok {
+			values[k] = s
+			continue
+		}
+		values[k] = fmt.Sprint(v)
+	}
+	return values
+}
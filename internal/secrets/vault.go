@@ -0,0 +1,137 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Vault is a client of the HashiCorp Vault KV version 2 secrets engine.
+// Secret paths start with the mount of the engine, e.g. secret/percona/cluster.
+type Vault struct {
+	c        *http.Client
+	endpoint *url.URL
+	token    string
+}
+
+func NewVault(address, token string) (*Vault, error) {
+	if address == "" {
+		return nil, errors.New("vault address is not set")
+	}
+	if token == "" {
+		return nil, errors.New("vault token is not set")
+	}
+	endpoint, err := url.Parse(address)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse vault address")
+	}
+	return &Vault{
+		c:        http.DefaultClient,
+		endpoint: endpoint,
+		token:    token,
+	}, nil
+}
+
+type vaultReadResponse struct {
+	Data struct {
+		Data     map[string]interface{} `json:"data"`
+		Metadata vaultMetadata          `json:"metadata"`
+	} `json:"data"`
+}
+
+type vaultWriteRequest struct {
+	Data map[string]string `json:"data"`
+}
+
+type vaultWriteResponse struct {
+	Data vaultMetadata `json:"data"`
+}
+
+type vaultMetadata struct {
+	Version int `json:"version"`
+}
+
+func (v *Vault) Read(ctx context.Context, path string, version string) (map[string]string, string, error) {
+	u, err := v.dataURL(path)
+	if err != nil {
+		return nil, "", err
+	}
+	if version != "" {
+		u.RawQuery = url.Values{"version": []string{version}}.Encode()
+	}
+	resp := vaultReadResponse{}
+	if err := v.do(ctx, http.MethodGet, u, nil, &resp); err != nil {
+		return nil, "", errors.Wrapf(err, "failed to read %s", path)
+	}
+	// Deleted and destroyed versions are returned without data
+	if resp.Data.Data == nil {
+		return nil, "", ErrNotFound
+	}
+	return stringValues(resp.Data.Data), strconv.Itoa(resp.Data.Metadata.Version), nil
+}
+
+func (v *Vault) Write(ctx context.Context, path string, values map[string]string) (string, error) {
+	u, err := v.dataURL(path)
+	if err != nil {
+		return "", err
+	}
+	resp := vaultWriteResponse{}
+	if err := v.do(ctx, http.MethodPost, u, &vaultWriteRequest{Data: values}, &resp); err != nil {
+		return "", errors.Wrapf(err, "failed to write %s", path)
+	}
+	return strconv.Itoa(resp.Data.Version), nil
+}
+
+// dataURL returns the URL of the secret data, which is located under the data/ prefix of the engine mount.
+func (v *Vault) dataURL(path string) (*url.URL, error) {
+	mount, name, ok := strings.Cut(strings.Trim(path, "/"), "/")
+	if !ok || mount == "" || name == "" {
+		return nil, errors.Errorf("vault secret path %q should start with the secrets engine mount", path)
+	}
+	return v.endpoint.JoinPath("v1", mount, "data", name), nil
+}
+
+func (v *Vault) do(ctx context.Context, method string, u *url.URL, request interface{}, response interface{}) error {
+	var body io.Reader
+	if request != nil {
+		data, err := json.Marshal(request)
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal request")
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return errors.Wrap(err, "failed to create request")
+	}
+	req.Header.Set("X-Vault-Token", v.token)
+	if request != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := v.c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respData, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Wrap(err, "failed to read response")
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("non-ok status code %d: %s", resp.StatusCode, string(respData))
+	}
+	if err := json.Unmarshal(respData, response); err != nil {
+		return errors.Wrap(err, "failed to unmarshal response")
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package secrets_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"terraform-percona/internal/secrets"
+	"terraform-percona/internal/utils"
+)
+
+const testVaultToken = "test-token"
+
+// kvServer emulates the KV version 2 secrets engine mounted at secret/.
+type kvServer struct {
+	mu       sync.Mutex
+	versions map[string][]map[string]interface{}
+}
+
+func (s *kvServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-Vault-Token") != testVaultToken {
+		http.Error(w, `{"errors":["permission denied"]}`, http.StatusForbidden)
+		return
+	}
+	if !strings.HasPrefix(r.URL.Path, "/v1/secret/data/") {
+		http.NotFound(w, r)
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, "/v1/secret/data/")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch r.Method {
+	case http.MethodGet:
+		versions := s.versions[name]
+		version := len(versions)
+		if v := r.URL.Query().Get("version"); v != "" {
+			version, _ = strconv.Atoi(v)
+		}
+		if version < 1 || version > len(versions) {
+			http.Error(w, `{"errors":[]}`, http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{
+				"data":     versions[version-1],
+				"metadata": map[string]interface{}{"version": version},
+			},
+		})
+	case http.MethodPost:
+		req := struct {
+			Data map[string]interface{} `json:"data"`
+		}{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.versions[name] = append(s.versions[name], req.Data)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{"version": len(s.versions[name])},
+		})
+	default:
+		http.Error(w, "", http.StatusMethodNotAllowed)
+	}
+}
+
+// testVault returns a client of the vault dev server from VAULT_ADDR and VAULT_TOKEN,
+// the server is emulated if they are not set.
+func testVault(t *testing.T) *secrets.Vault {
+	address, token := os.Getenv("VAULT_ADDR"), os.Getenv("VAULT_TOKEN")
+	if address == "" || token == "" {
+		server := httptest.NewServer(&kvServer{versions: make(map[string][]map[string]interface{})})
+		t.Cleanup(server.Close)
+		address, token = server.URL, testVaultToken
+	}
+	v, err := secrets.NewVault(address, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestVault(t *testing.T) {
+	ctx := context.Background()
+	v := testVault(t)
+
+	suffix, err := utils.GeneratePassword(8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := "secret/terraform-percona-test/" + suffix
+
+	if _, _, err := v.Read(ctx, path, ""); !errors.Is(err, secrets.ErrNotFound) {
+		t.Fatalf("Read() of missing secret error = %v, want %v", err, secrets.ErrNotFound)
+	}
+
+	first := map[string]string{"password": "first"}
+	firstVersion, err := v.Write(ctx, path, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := map[string]string{"password": "second", "replication_password": "replica"}
+	secondVersion, err := v.Write(ctx, path, second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if firstVersion == secondVersion {
+		t.Fatalf("Write() returned the same version %s twice", firstVersion)
+	}
+
+	tests := []struct {
+		version     string
+		want        map[string]string
+		wantVersion string
+	}{
+		{"", second, secondVersion},
+		{firstVersion, first, firstVersion},
+		{secondVersion, second, secondVersion},
+	}
+	for _, tt := range tests {
+		got, gotVersion, err := v.Read(ctx, path, tt.version)
+		if err != nil {
+			t.Fatalf("Read(%q) error = %v", tt.version, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) || gotVersion != tt.wantVersion {
+			t.Errorf("Read(%q) = %v, %s, want %v, %s", tt.version, got, gotVersion, tt.want, tt.wantVersion)
+		}
+	}
+
+	if _, err := v.Write(ctx, "secret", first); err == nil {
+		t.Error("Write() to path without mount should fail")
+	}
+}
@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	certificateKeySize  = 2048
+	caValidity          = 10 * 365 * 24 * time.Hour
+	certificateValidity = 5 * 365 * 24 * time.Hour
+)
+
+// GenerateCA returns a self-signed CA certificate and its private key in PEM format.
+func GenerateCA(commonName string) (certPEM string, keyPEM string, err error) {
+	key, err := rsa.GenerateKey(rand.Reader, certificateKeySize)
+	if err != nil {
+		return "", "", errors.Wrap(err, "failed to generate private key")
+	}
+	template, err := certificateTemplate(commonName, caValidity)
+	if err != nil {
+		return "", "", err
+	}
+	template.IsCA = true
+	template.BasicConstraintsValid = true
+	template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return "", "", errors.Wrap(err, "failed to create certificate")
+	}
+	return encodeCertificate(der), encodeKey(key), nil
+}
+
+// GenerateCertificate returns a certificate for the addresses and host names signed by the CA, and its private key in PEM format.
+// The certificate can be used by both servers and clients.
+func GenerateCertificate(caCertPEM, caKeyPEM, commonName string, ips []string, dnsNames []string) (certPEM string, keyPEM string, err error) {
+	caCert, caKey, err := parseCA(caCertPEM, caKeyPEM)
+	if err != nil {
+		return "", "", err
+	}
+	key, err := rsa.GenerateKey(rand.Reader, certificateKeySize)
+	if err != nil {
+		return "", "", errors.Wrap(err, "failed to generate private key")
+	}
+	template, err := certificateTemplate(commonName, certificateValidity)
+	if err != nil {
+		return "", "", err
+	}
+	template.KeyUsage = x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}
+	template.DNSNames = dnsNames
+	for _, ip := range ips {
+		if parsed := net.ParseIP(ip); parsed != nil {
+			template.IPAddresses = append(template.IPAddresses, parsed)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+	if err != nil {
+		return "", "", errors.Wrap(err, "failed to create certificate")
+	}
+	return encodeCertificate(der), encodeKey(key), nil
+}
+
+func certificateTemplate(commonName string, validity time.Duration) (*x509.Certificate, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate serial number")
+	}
+	now := time.Now()
+	return &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		// Clocks of the instances may be slightly behind
+		NotBefore: now.Add(-time.Hour),
+		NotAfter:  now.Add(validity),
+	}, nil
+}
+
+func parseCA(caCertPEM, caKeyPEM string) (*x509.Certificate, *rsa.PrivateKey, error) {
+	certBlock, _ := pem.Decode([]byte(caCertPEM))
+	if certBlock == nil {
+		return nil, nil, errors.New("failed to decode ca certificate")
+	}
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to parse ca certificate")
+	}
+	keyBlock, _ := pem.Decode([]byte(caKeyPEM))
+	if keyBlock == nil {
+		return nil, nil, errors.New("failed to decode ca private key")
+	}
+	key, err := x509.ParsePKCS1PrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to parse ca private key")
+	}
+	return cert, key, nil
+}
+
+func encodeCertificate(der []byte) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+// encodeKey encodes the key in PKCS #1 format, which is supported by both OpenSSL and yaSSL builds of MySQL.
+func encodeKey(key *rsa.PrivateKey) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+}
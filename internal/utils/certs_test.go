@@ -0,0 +1,46 @@
+package utils_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"terraform-percona/internal/utils"
+)
+
+func TestGenerateCertificate(t *testing.T) {
+	caCert, caKey, err := utils.GenerateCA("test-ca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM, keyPEM, err := utils.GenerateCertificate(caCert, caKey, "node", []string{"10.0.0.1", "203.0.113.10", "invalid"}, []string{"localhost"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tls.X509KeyPair([]byte(certPEM), []byte(keyPEM)); err != nil {
+		t.Fatalf("certificate doesn't match its key: %v", err)
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM([]byte(caCert)) {
+		t.Fatal("failed to add ca certificate")
+	}
+	block, _ := pem.Decode([]byte(certPEM))
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"10.0.0.1", "203.0.113.10", "localhost"} {
+		if _, err := cert.Verify(x509.VerifyOptions{Roots: roots, DNSName: name}); err != nil {
+			t.Errorf("Verify(%s) error = %v", name, err)
+		}
+	}
+	if _, err := cert.Verify(x509.VerifyOptions{Roots: roots, DNSName: "10.0.0.2"}); err == nil {
+		t.Error("Verify(10.0.0.2) should fail for an address which is not in the certificate")
+	}
+
+	if _, _, err := utils.GenerateCertificate(caCert, "invalid", "node", nil, nil); err == nil {
+		t.Error("GenerateCertificate() should fail for an invalid ca key")
+	}
+}
@@ -8,6 +8,33 @@ import (
 )
 
 func SetIniFields(section string, keysAndValues map[string]string) func(r io.ReadWriteSeeker) error {
+	return editIniSection(section, func(s *ini.Section) {
+		for k, v := range keysAndValues {
+			s.Key(k).SetValue(v)
+		}
+	})
+}
+
+func DeleteIniFields(section string, keys []string) func(r io.ReadWriteSeeker) error {
+	return editIniSection(section, func(s *ini.Section) {
+		for _, k := range keys {
+			s.DeleteKey(k)
+		}
+	})
+}
+
+// IniSection returns the keys and values of the section, keys without values are returned with empty values.
+func IniSection(data []byte, section string) (map[string]string, error) {
+	iniFile, err := ini.LoadSources(ini.LoadOptions{
+		AllowBooleanKeys: true,
+	}, data)
+	if err != nil {
+		return nil, errors.Wrap(err, "load ini file")
+	}
+	return iniFile.Section(section).KeysHash(), nil
+}
+
+func editIniSection(section string, editFunc func(s *ini.Section)) func(r io.ReadWriteSeeker) error {
 	return func(rws io.ReadWriteSeeker) error {
 		data, err := io.ReadAll(rws)
 		if err != nil {
@@ -19,16 +46,20 @@ func SetIniFields(section string, keysAndValues map[string]string) func(r io.Rea
 		if err != nil {
 			return errors.Wrap(err, "load ini file")
 		}
-		for k, v := range keysAndValues {
-			iniFile.Section(section).Key(k).SetValue(v)
-		}
+		editFunc(iniFile.Section(section))
 		if _, err = rws.Seek(0, io.SeekStart); err != nil {
 			return errors.Wrap(err, "seek")
 		}
-		_, err = iniFile.WriteTo(rws)
+		n, err := iniFile.WriteTo(rws)
 		if err != nil {
 			return errors.Wrap(err, "write to ini file")
 		}
+		// The file can become shorter, e.g. if keys are deleted
+		if t, ok := rws.(interface{ Truncate(int64) error }); ok {
+			if err := t.Truncate(n); err != nil {
+				return errors.Wrap(err, "truncate file")
+			}
+		}
 		return nil
 	}
 }
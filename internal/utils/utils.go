@@ -1,6 +1,8 @@
 package utils
 
 import (
+	crand "crypto/rand"
+	"math/big"
 	"math/rand"
 	"net/url"
 	"strings"
@@ -25,6 +27,23 @@ func getRandomString(n int) string {
 	return string(b)
 }
 
+var passwordRunes = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789")
+
+// GeneratePassword returns a cryptographically random alphanumeric password.
+// Passwords are passed to shell commands, so they shouldn't contain special characters.
+func GeneratePassword(n int) (string, error) {
+	b := make([]rune, n)
+	max := big.NewInt(int64(len(passwordRunes)))
+	for i := range b {
+		idx, err := crand.Int(crand.Reader, max)
+		if err != nil {
+			return "", errors.Wrap(err, "failed to generate random number")
+		}
+		b[i] = passwordRunes[idx.Int64()]
+	}
+	return string(b), nil
+}
+
 func GenerateResourceID() string {
 	return strings.ToLower(getRandomString(20))
 }
@@ -80,6 +99,21 @@ func removeDebianRevision(version string) string {
 	return strings.Split(version, "-")[0]
 }
 
+// CompareVersions compares package versions ignoring their revisions.
+func CompareVersions(a, b string) int {
+	return semver.Compare("v"+removeDebianRevision(a), "v"+removeDebianRevision(b))
+}
+
+// MajorMinor returns MAJOR.MINOR part of the package version.
+func MajorMinor(version string) string {
+	return strings.TrimPrefix(semver.MajorMinor("v"+removeDebianRevision(version)), "v")
+}
+
+// ShellQuote returns the value as a single word of a shell command.
+func ShellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
 func Ref[T any](x T) *T {
 	return &x
 }
@@ -0,0 +1,19 @@
+package utils
+
+import (
+	"os/exec"
+	"testing"
+)
+
+// TestShellQuote passes the quoted values through the shell, they should be received as is.
+func TestShellQuote(t *testing.T) {
+	for _, value := range []string{"password", "", "it's", `a"b`, "$(id) `id` $HOME", `back\slash`, "new\nline", "'"} {
+		out, err := exec.Command("sh", "-c", "printf %s "+ShellQuote(value)).Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(out) != value {
+			t.Errorf("value %q is received as %q", value, out)
+		}
+	}
+}